@@ -0,0 +1,204 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+)
+
+// The most scancodes sent in one call, as in the shared PC XT driver
+const keyboardScanCodeChunkSize = 32
+
+// The specials that modify the keys typed while they are held
+var keyboardModifiers = map[string]bool{
+	"leftalt": true, "leftctrl": true, "leftshift": true, "leftsuper": true,
+	"rightalt": true, "rightctrl": true, "rightshift": true, "rightsuper": true,
+}
+
+// keyboardInput is either a run of text for TypeText or scancodes.
+type keyboardInput struct {
+	Text      string
+	ScanCodes []string
+}
+
+// keyboardDriver is the boot command driver typing through one Keyboard
+// for the whole boot command. When the guest uses the US layout, runs of
+// printable ASCII are typed with a single TypeText call. Everything else
+// is translated to scancodes for the layout of the guest, including the
+// keys typed while a modifier is held, as TypeText handles shift itself.
+type keyboardDriver struct {
+	// Cancels the delays between inputs
+	ctx      context.Context
+	open     func() (Keyboard, error)
+	keyboard Keyboard
+
+	layout   keyboardLayout
+	typeText bool
+
+	// If set, every key is sent on its own with this delay in between.
+	// Otherwise inputs are batched with the group interval in between.
+	keyInterval   time.Duration
+	groupInterval time.Duration
+
+	queue []keyboardInput
+	// The modifiers currently held with <...On>
+	held map[string]bool
+}
+
+func newKeyboardDriver(ctx context.Context, open func() (Keyboard, error), layoutName string,
+	groupInterval time.Duration, keyInterval time.Duration) (*keyboardDriver, error) {
+	layout, err := newKeyboardLayout(layoutName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the same delay between groups as the shared driver
+	if groupInterval <= 0 {
+		groupInterval = bootcommand.PackerKeyDefault
+		if delay, err := time.ParseDuration(os.Getenv(bootcommand.PackerKeyEnv)); err == nil {
+			groupInterval = delay
+		}
+	}
+
+	return &keyboardDriver{
+		ctx:           ctx,
+		open:          open,
+		layout:        layout,
+		typeText:      layoutName == DefaultKeyboardLayout && keyInterval <= 0,
+		keyInterval:   keyInterval,
+		groupInterval: groupInterval,
+		held:          make(map[string]bool),
+	}, nil
+}
+
+func (d *keyboardDriver) SendKey(key rune, action bootcommand.KeyAction) error {
+	if d.typeText && len(d.held) == 0 && action == bootcommand.KeyPress && key >= ' ' && key <= '~' {
+		if n := len(d.queue); n > 0 && d.queue[n-1].ScanCodes == nil {
+			d.queue[n-1].Text += string(key)
+		} else {
+			d.queue = append(d.queue, keyboardInput{Text: string(key)})
+		}
+		return nil
+	}
+
+	codes, err := d.layout.scancodes(key, action)
+	if err != nil {
+		return err
+	}
+	d.queue = append(d.queue, keyboardInput{ScanCodes: codes})
+	return nil
+}
+
+func (d *keyboardDriver) SendSpecial(special string, action bootcommand.KeyAction) error {
+	codes, ok := specialScancodes[special]
+	if !ok {
+		return fmt.Errorf("special %s not found.", special)
+	}
+
+	if keyboardModifiers[special] {
+		switch action {
+		case bootcommand.KeyOn:
+			d.held[special] = true
+		case bootcommand.KeyOff:
+			delete(d.held, special)
+		}
+	}
+
+	var sc []string
+	if action&(bootcommand.KeyOn|bootcommand.KeyPress) != 0 {
+		sc = append(sc, codes[0]...)
+	}
+	if action&(bootcommand.KeyOff|bootcommand.KeyPress) != 0 {
+		sc = append(sc, codes[1]...)
+	}
+	d.queue = append(d.queue, keyboardInput{ScanCodes: sc})
+	return nil
+}
+
+// Flush types the queued input. It is called before every <wait> and at
+// the end of the boot command, which waits are timed from, so the delay is
+// only slept between inputs.
+func (d *keyboardDriver) Flush() error {
+	inputs := d.batch()
+	d.queue = nil
+
+	if len(inputs) == 0 {
+		return nil
+	}
+
+	if d.keyboard == nil {
+		keyboard, err := d.open()
+		if err != nil {
+			return fmt.Errorf("error opening keyboard: %s", err)
+		}
+		d.keyboard = keyboard
+	}
+
+	interval := d.groupInterval
+	if d.keyInterval > 0 {
+		interval = d.keyInterval
+	}
+
+	for i, input := range inputs {
+		if i > 0 {
+			select {
+			case <-d.ctx.Done():
+				return d.ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+
+		var err error
+		if input.ScanCodes != nil {
+			log.Printf("Sending scancodes '%s'", strings.Join(input.ScanCodes, " "))
+			err = d.keyboard.TypeScanCodes(input.ScanCodes)
+		} else {
+			log.Printf("Sending text '%s'", input.Text)
+			err = d.keyboard.TypeText(input.Text)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// batch merges the queued scancodes of consecutive keys into chunks, never
+// splitting a key, unless every key is sent on its own.
+func (d *keyboardDriver) batch() []keyboardInput {
+	if d.keyInterval > 0 {
+		return d.queue
+	}
+
+	var inputs []keyboardInput
+	for _, input := range d.queue {
+		n := len(inputs)
+		if input.ScanCodes != nil && n > 0 && inputs[n-1].ScanCodes != nil &&
+			len(inputs[n-1].ScanCodes)+len(input.ScanCodes) <= keyboardScanCodeChunkSize {
+			inputs[n-1].ScanCodes = append(inputs[n-1].ScanCodes, input.ScanCodes...)
+			continue
+		}
+		inputs = append(inputs, keyboardInput{
+			Text:      input.Text,
+			ScanCodes: append([]string(nil), input.ScanCodes...),
+		})
+	}
+	return inputs
+}
+
+// Close closes the keyboard if anything was typed.
+func (d *keyboardDriver) Close() error {
+	if d.keyboard == nil {
+		return nil
+	}
+	return d.keyboard.Close()
+}
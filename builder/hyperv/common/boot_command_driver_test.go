@@ -0,0 +1,181 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+)
+
+// typeBootCommand runs a boot command through a keyboard driver and
+// returns what the keyboard received.
+func typeBootCommand(t *testing.T, command string, layout string, keyInterval time.Duration) *KeyboardMock {
+	keyboard := new(KeyboardMock)
+	d, err := newKeyboardDriver(context.Background(), func() (Keyboard, error) {
+		return keyboard, nil
+	}, layout, time.Nanosecond, keyInterval)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	seq, err := bootcommand.GenerateExpressionSequence(command)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := seq.Do(context.Background(), d); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return keyboard
+}
+
+func TestKeyboardDriver_typeText(t *testing.T) {
+	keyboard := typeBootCommand(t, "root<enter><wait10ms>Pa$$ word<enter>", "us", 0)
+
+	expected := []string{
+		"text:root",
+		"scancodes:1c 9c",
+		"text:Pa$$ word",
+		"scancodes:1c 9c",
+	}
+	if !reflect.DeepEqual(keyboard.Typed, expected) {
+		t.Fatalf("Expected %v, got %v", expected, keyboard.Typed)
+	}
+	if !keyboard.Close_Called {
+		t.Fatal("Should close the keyboard")
+	}
+}
+
+func TestKeyboardDriver_layout(t *testing.T) {
+	keyboard := typeBootCommand(t, "z@<enter>", "de", 0)
+
+	// TypeText only types for the US layout
+	expected := []string{"scancodes:15 95 e0 38 10 90 e0 b8 1c 9c"}
+	if !reflect.DeepEqual(keyboard.Typed, expected) {
+		t.Fatalf("Expected %v, got %v", expected, keyboard.Typed)
+	}
+}
+
+func TestKeyboardDriver_keyOnOff(t *testing.T) {
+	keyboard := typeBootCommand(t, "<leftCtrlOn>c<leftCtrlOff>ab", "us", 0)
+
+	// Keys typed while a modifier is held are sent as scancodes
+	expected := []string{"scancodes:1d 2e ae 9d", "text:ab"}
+	if !reflect.DeepEqual(keyboard.Typed, expected) {
+		t.Fatalf("Expected %v, got %v", expected, keyboard.Typed)
+	}
+}
+
+func TestKeyboardDriver_keyInterval(t *testing.T) {
+	start := time.Now()
+	keyboard := typeBootCommand(t, "ab<enter>", "us", 20*time.Millisecond)
+
+	expected := []string{"scancodes:1e 9e", "scancodes:30 b0", "scancodes:1c 9c"}
+	if !reflect.DeepEqual(keyboard.Typed, expected) {
+		t.Fatalf("Expected %v, got %v", expected, keyboard.Typed)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("Should wait between every key, took %s", elapsed)
+	}
+}
+
+func TestKeyboardDriver_chunks(t *testing.T) {
+	keyboard := typeBootCommand(t, strings.Repeat("<enter>", 20), "us", 0)
+
+	if len(keyboard.Typed) != 2 {
+		t.Fatalf("Expected 2 chunks, got %v", keyboard.Typed)
+	}
+	if codes := strings.Fields(strings.TrimPrefix(keyboard.Typed[0], "scancodes:")); len(codes) != 32 {
+		t.Fatalf("Expected a first chunk of 32 scancodes, got %d", len(codes))
+	}
+}
+
+func TestKeyboardDriver_subSecondWait(t *testing.T) {
+	start := time.Now()
+	typeBootCommand(t, "a<wait250ms>b", "us", 0)
+
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond || elapsed > 900*time.Millisecond {
+		t.Fatalf("Should wait 250ms, took %s", elapsed)
+	}
+}
+
+// The interval separates inputs, it isn't added to the waits
+func TestKeyboardDriver_intervalBetweenInputs(t *testing.T) {
+	start := time.Now()
+	typeBootCommand(t, "a", "us", time.Hour)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Should NOT wait after the last input, took %s", elapsed)
+	}
+}
+
+func TestKeyboardDriver_cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	keyboard := new(KeyboardMock)
+	d, err := newKeyboardDriver(ctx, func() (Keyboard, error) {
+		return keyboard, nil
+	}, "us", time.Nanosecond, time.Hour)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, c := range "ab" {
+		if err := d.SendKey(c, bootcommand.KeyPress); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := d.Flush(); err != context.Canceled {
+		t.Fatalf("Should be canceled, got %v", err)
+	}
+	if len(keyboard.Typed) != 1 {
+		t.Fatalf("Should stop after the first key, typed %v", keyboard.Typed)
+	}
+}
+
+func TestKeyboardDriver_nothingTyped(t *testing.T) {
+	opened := false
+	d, err := newKeyboardDriver(context.Background(), func() (Keyboard, error) {
+		opened = true
+		return new(KeyboardMock), nil
+	}, "us", time.Nanosecond, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := d.Flush(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if opened {
+		t.Fatal("Should NOT open the keyboard when there is nothing to type")
+	}
+}
+
+func TestKeyboardDriver_openError(t *testing.T) {
+	d, err := newKeyboardDriver(context.Background(), func() (Keyboard, error) {
+		return nil, errors.New("keyboard class is not found")
+	}, "us", time.Nanosecond, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := d.SendKey('a', bootcommand.KeyPress); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := d.Flush(); err == nil {
+		t.Fatal("should have error")
+	}
+}
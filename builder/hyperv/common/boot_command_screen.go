@@ -0,0 +1,309 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// How long <waitScreen> and <waitIdle> wait when no timeout is given
+	DefaultScreenWaitTimeout = 5 * time.Minute
+
+	// How often the console is captured while waiting for it
+	screenPollInterval = time.Second
+
+	// Console thumbnails are lossy RGB565 and guests draw with slightly
+	// different colors depending on the firmware, so pixels within this
+	// distance per channel are considered equal.
+	screenPixelTolerance = 24
+
+	// The fraction of compared pixels that may differ for two screens to
+	// still be considered the same, which absorbs blinking cursors and
+	// spinners.
+	screenDifferenceTolerance = 0.02
+)
+
+// screenWaitPattern matches the <waitScreen "image.png" timeout> and
+// <waitIdle duration timeout> boot command directives. They aren't part of
+// the shared boot command grammar, so they are cut out of the command
+// before the rest is handed to it.
+var screenWaitPattern = regexp.MustCompile(`<(waitScreen|waitIdle)(\s[^>]*)?>`)
+
+// screenWait is a boot command directive that waits for the console.
+type screenWait struct {
+	// The reference image to wait for, for <waitScreen>
+	Image string
+	// How long the screen must not change, for <waitIdle>
+	Idle time.Duration
+	// How long to wait before failing the build
+	Timeout time.Duration
+}
+
+func (w screenWait) String() string {
+	if w.Image != "" {
+		return fmt.Sprintf("<waitScreen %q %s>", w.Image, w.Timeout)
+	}
+	return fmt.Sprintf("<waitIdle %s %s>", w.Idle, w.Timeout)
+}
+
+// bootCommandSegment is a run of keys to type followed by an optional wait
+// for the console.
+type bootCommandSegment struct {
+	Keys string
+	Wait *screenWait
+}
+
+// splitBootCommand splits a boot command at its screen wait directives.
+func splitBootCommand(command string) ([]bootCommandSegment, error) {
+	var segments []bootCommandSegment
+
+	for {
+		loc := screenWaitPattern.FindStringSubmatchIndex(command)
+		if loc == nil {
+			break
+		}
+
+		directive := command[loc[0]:loc[1]]
+		name := command[loc[2]:loc[3]]
+		var args string
+		if loc[4] >= 0 {
+			args = command[loc[4]:loc[5]]
+		}
+
+		wait, err := parseScreenWait(name, args)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", directive, err)
+		}
+
+		segments = append(segments, bootCommandSegment{Keys: command[:loc[0]], Wait: &wait})
+		command = command[loc[1]:]
+	}
+
+	if command != "" {
+		segments = append(segments, bootCommandSegment{Keys: command})
+	}
+
+	return segments, nil
+}
+
+func parseScreenWait(name string, args string) (screenWait, error) {
+	wait := screenWait{Timeout: DefaultScreenWaitTimeout}
+	args = strings.TrimSpace(args)
+
+	var durations []string
+	switch name {
+	case "waitScreen":
+		if !strings.HasPrefix(args, `"`) {
+			return wait, fmt.Errorf("expected a quoted image path")
+		}
+		end := strings.Index(args[1:], `"`)
+		if end < 0 {
+			return wait, fmt.Errorf("unterminated image path")
+		}
+		wait.Image = args[1 : end+1]
+		if wait.Image == "" {
+			return wait, fmt.Errorf("image path must not be empty")
+		}
+		durations = strings.Fields(args[end+2:])
+		if len(durations) > 1 {
+			return wait, fmt.Errorf("expected at most a timeout after the image path")
+		}
+	case "waitIdle":
+		durations = strings.Fields(args)
+		if len(durations) < 1 || len(durations) > 2 {
+			return wait, fmt.Errorf("expected an idle duration and an optional timeout")
+		}
+		idle, err := time.ParseDuration(durations[0])
+		if err != nil {
+			return wait, err
+		}
+		if idle <= 0 {
+			return wait, fmt.Errorf("idle duration must be positive")
+		}
+		wait.Idle = idle
+		durations = durations[1:]
+	}
+
+	if len(durations) == 1 {
+		timeout, err := time.ParseDuration(durations[0])
+		if err != nil {
+			return wait, err
+		}
+		if timeout <= 0 {
+			return wait, fmt.Errorf("timeout must be positive")
+		}
+		wait.Timeout = timeout
+	}
+
+	if wait.Idle > 0 && wait.Idle > wait.Timeout {
+		return wait, fmt.Errorf("idle duration %s is longer than the timeout %s", wait.Idle, wait.Timeout)
+	}
+
+	return wait, nil
+}
+
+// CheckBootCommandScreenWaits validates the screen wait directives of a boot
+// command and that their reference images can be loaded. Images whose path
+// is a template are only checked when the boot command is typed.
+func CheckBootCommandScreenWaits(command string) []error {
+	var errs []error
+
+	for _, match := range screenWaitPattern.FindAllStringSubmatch(command, -1) {
+		wait, err := parseScreenWait(match[1], match[2])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("boot_command: invalid %s: %s", match[0], err))
+			continue
+		}
+		if wait.Image != "" && !strings.Contains(wait.Image, "{{") {
+			if _, err := loadReferenceImage(wait.Image); err != nil {
+				errs = append(errs, fmt.Errorf("boot_command: %s", err))
+			}
+		}
+	}
+
+	return errs
+}
+
+func loadReferenceImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening reference image: %s", err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding reference image %s: %s", path, err)
+	}
+	return img, nil
+}
+
+// screenDifference returns the fraction of pixels that differ between two
+// screens of the same size. Only the pixels that are opaque in the mask are
+// compared, if one is given.
+func screenDifference(a image.Image, b image.Image, mask image.Image) (float64, error) {
+	bounds := a.Bounds()
+	if b.Bounds().Size() != bounds.Size() {
+		return 1, fmt.Errorf("screen size %v doesn't match %v", b.Bounds().Size(), bounds.Size())
+	}
+
+	offset := b.Bounds().Min.Sub(bounds.Min)
+	compared, differing := 0, 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if mask != nil {
+				if _, _, _, alpha := mask.At(x, y).RGBA(); alpha < 0x8000 {
+					continue
+				}
+			}
+			compared++
+			if !similarPixels(a, b, x, y, offset) {
+				differing++
+			}
+		}
+	}
+
+	if compared == 0 {
+		return 1, fmt.Errorf("reference image has no opaque pixels")
+	}
+	return float64(differing) / float64(compared), nil
+}
+
+func similarPixels(a image.Image, b image.Image, x int, y int, offset image.Point) bool {
+	ar, ag, ab, _ := a.At(x, y).RGBA()
+	br, bg, bb, _ := b.At(x+offset.X, y+offset.Y).RGBA()
+
+	return channelDistance(ar, br) <= screenPixelTolerance &&
+		channelDistance(ag, bg) <= screenPixelTolerance &&
+		channelDistance(ab, bb) <= screenPixelTolerance
+}
+
+// channelDistance returns the distance between two 16 bit color channels
+// on an 8 bit scale.
+func channelDistance(a uint32, b uint32) uint32 {
+	a, b = a>>8, b>>8
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// captureScreen returns the current console of the VM as an image.
+func captureScreen(driver Driver, vmName string) (image.Image, error) {
+	data, err := driver.GetVirtualMachineScreenshot(vmName)
+	if err != nil {
+		return nil, err
+	}
+	return png.Decode(bytes.NewReader(data))
+}
+
+// waitForScreen polls the console until it matches the reference image of
+// the wait, or until it hasn't changed for the idle duration. The console
+// can't always be captured, for instance while the VM resets its display,
+// so capture errors are only logged.
+func waitForScreen(ctx context.Context, capture func() (image.Image, error), wait screenWait,
+	interval time.Duration) error {
+	var reference image.Image
+	if wait.Image != "" {
+		var err error
+		reference, err = loadReferenceImage(wait.Image)
+		if err != nil {
+			return err
+		}
+	}
+
+	deadline := time.Now().Add(wait.Timeout)
+	var last image.Image
+	var unchangedSince time.Time
+
+	for {
+		screen, err := capture()
+		if err != nil {
+			log.Printf("Error capturing console: %s", err)
+		} else if reference != nil {
+			diff, err := screenDifference(reference, screen, reference)
+			if err != nil {
+				log.Printf("Error comparing console to %s: %s", wait.Image, err)
+			} else if diff <= screenDifferenceTolerance {
+				return nil
+			}
+		} else {
+			now := time.Now()
+			changed := last == nil
+			if !changed {
+				diff, err := screenDifference(last, screen, nil)
+				changed = err != nil || diff > screenDifferenceTolerance
+			}
+			if changed {
+				last = screen
+				unchangedSince = now
+			} else if now.Sub(unchangedSince) >= wait.Idle {
+				return nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			if reference != nil {
+				return fmt.Errorf("screen didn't match %s within %s", wait.Image, wait.Timeout)
+			}
+			return fmt.Errorf("screen didn't stop changing for %s within %s", wait.Idle, wait.Timeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
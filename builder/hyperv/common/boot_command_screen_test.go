@@ -0,0 +1,207 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSplitBootCommand(t *testing.T) {
+	segments, err := splitBootCommand(`<esc><waitScreen "grub.png" 30s>linux<enter><waitIdle 5s>`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(segments) != 2 {
+		t.Fatalf("Expected 2 segments, got %d", len(segments))
+	}
+	if segments[0].Keys != "<esc>" || *segments[0].Wait != (screenWait{Image: "grub.png", Timeout: 30 * time.Second}) {
+		t.Fatalf("Bad first segment: %q %v", segments[0].Keys, segments[0].Wait)
+	}
+	if segments[1].Keys != "linux<enter>" ||
+		*segments[1].Wait != (screenWait{Idle: 5 * time.Second, Timeout: DefaultScreenWaitTimeout}) {
+		t.Fatalf("Bad second segment: %q %v", segments[1].Keys, segments[1].Wait)
+	}
+}
+
+func TestSplitBootCommand_noWaits(t *testing.T) {
+	segments, err := splitBootCommand("<wait5>text<enter>")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(segments) != 1 || segments[0].Keys != "<wait5>text<enter>" || segments[0].Wait != nil {
+		t.Fatalf("Bad segments: %v", segments)
+	}
+}
+
+func TestParseScreenWait_invalid(t *testing.T) {
+	cases := map[string]string{
+		"missing image":  `<waitScreen>`,
+		"unquoted image": `<waitScreen grub.png>`,
+		"unterminated":   `<waitScreen "grub.png 30s>`,
+		"empty image":    `<waitScreen "">`,
+		"extra args":     `<waitScreen "grub.png" 30s 1m>`,
+		"bad timeout":    `<waitScreen "grub.png" soon>`,
+		"missing idle":   `<waitIdle>`,
+		"bad idle":       `<waitIdle 5>`,
+		"zero idle":      `<waitIdle 0s>`,
+		"idle > timeout": `<waitIdle 5m 1m>`,
+	}
+
+	for name, command := range cases {
+		if _, err := splitBootCommand(command); err == nil {
+			t.Fatalf("%s: %s should have error", name, command)
+		}
+	}
+}
+
+// testScreen returns a 4x4 screen filled with c.
+func testScreen(c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func writeTestPNG(t *testing.T, img image.Image) string {
+	path := filepath.Join(t.TempDir(), "reference.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return path
+}
+
+func TestScreenDifference(t *testing.T) {
+	black := testScreen(color.Black)
+	screen := testScreen(color.Black)
+	screen.Set(0, 0, color.White)
+
+	diff, err := screenDifference(black, screen, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if diff != 1.0/16 {
+		t.Fatalf("Expected 1/16 of the pixels to differ, got %f", diff)
+	}
+
+	// Transparent pixels of the mask are ignored
+	reference := testScreen(color.Transparent)
+	reference.Set(3, 3, color.Black)
+	diff, err = screenDifference(reference, screen, reference)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if diff != 0 {
+		t.Fatalf("Expected no difference in the reference region, got %f", diff)
+	}
+
+	// Small color differences from the RGB565 capture are tolerated
+	diff, _ = screenDifference(black, testScreen(color.RGBA{R: 8, G: 4, B: 8, A: 0xff}), nil)
+	if diff != 0 {
+		t.Fatalf("Expected nearly equal colors to match, got %f", diff)
+	}
+
+	if _, err := screenDifference(black, image.NewRGBA(image.Rect(0, 0, 2, 2)), nil); err == nil {
+		t.Fatal("Screens of different sizes should have error")
+	}
+	if _, err := screenDifference(black, screen, testScreen(color.Transparent)); err == nil {
+		t.Fatal("A fully transparent reference should have error")
+	}
+}
+
+func TestCheckBootCommandScreenWaits(t *testing.T) {
+	reference := writeTestPNG(t, testScreen(color.Black))
+
+	if errs := CheckBootCommandScreenWaits(`<waitScreen "` + reference + `" 30s><waitIdle 5s>`); len(errs) > 0 {
+		t.Fatalf("should not have error: %v", errs)
+	}
+	if errs := CheckBootCommandScreenWaits(`<waitScreen "{{ .Name }}.png">`); len(errs) > 0 {
+		t.Fatalf("templated images should not be checked: %v", errs)
+	}
+	if errs := CheckBootCommandScreenWaits(`<waitScreen "missing.png"><waitIdle>`); len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", errs)
+	}
+}
+
+// testCapture returns a capture func that returns the given screens in
+// order, repeating the last one.
+func testCapture(screens ...image.Image) func() (image.Image, error) {
+	i := 0
+	return func() (image.Image, error) {
+		screen := screens[i]
+		if i < len(screens)-1 {
+			i++
+		}
+		if screen == nil {
+			return nil, errors.New("no display")
+		}
+		return screen, nil
+	}
+}
+
+func TestWaitForScreen_image(t *testing.T) {
+	reference := writeTestPNG(t, testScreen(color.White))
+	wait := screenWait{Image: reference, Timeout: 5 * time.Second}
+
+	capture := testCapture(nil, testScreen(color.Black), testScreen(color.White))
+	if err := waitForScreen(context.Background(), capture, wait, time.Millisecond); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	wait.Timeout = 20 * time.Millisecond
+	capture = testCapture(testScreen(color.Black))
+	if err := waitForScreen(context.Background(), capture, wait, time.Millisecond); err == nil {
+		t.Fatal("should have timed out")
+	}
+}
+
+func TestWaitForScreen_idle(t *testing.T) {
+	wait := screenWait{Idle: 20 * time.Millisecond, Timeout: 5 * time.Second}
+
+	capture := testCapture(testScreen(color.Black), testScreen(color.White), testScreen(color.Black))
+	if err := waitForScreen(context.Background(), capture, wait, time.Millisecond); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A screen that keeps changing never becomes idle
+	flip := false
+	changing := func() (image.Image, error) {
+		flip = !flip
+		if flip {
+			return testScreen(color.White), nil
+		}
+		return testScreen(color.Black), nil
+	}
+	wait.Timeout = 50 * time.Millisecond
+	if err := waitForScreen(context.Background(), changing, wait, time.Millisecond); err == nil {
+		t.Fatal("should have timed out")
+	}
+}
+
+func TestWaitForScreen_cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	wait := screenWait{Idle: time.Minute, Timeout: time.Hour}
+	err := waitForScreen(ctx, testCapture(testScreen(color.Black)), wait, time.Millisecond)
+	if err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+}
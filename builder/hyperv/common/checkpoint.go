@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Checkpoint
+
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The phases of a build at which checkpoints can be taken
+const (
+	// Once the install has completed, before the guest files are copied and
+	// the communicator connects
+	CheckpointPhaseOsInstalled = "os_installed"
+	// Right before the provisioners run
+	CheckpointPhaseBeforeProvisioning = "before_provisioning"
+	// Once the VM has been shut down after provisioning
+	CheckpointPhaseAfterProvisioning = "after_provisioning"
+)
+
+// CheckpointPhases lists the phases in the order they happen during a build.
+var CheckpointPhases = []string{
+	CheckpointPhaseOsInstalled,
+	CheckpointPhaseBeforeProvisioning,
+	CheckpointPhaseAfterProvisioning,
+}
+
+// CheckpointTypes lists the values accepted by `Set-VM -CheckpointType`.
+var CheckpointTypes = []string{"Standard", "Production", "ProductionOnly"}
+
+// Checkpoint describes a named checkpoint of the VM taken at a phase of the
+// build. Checkpoints that aren't kept are removed before the VM is
+// exported; kept checkpoints are exported along with the VM.
+type Checkpoint struct {
+	// The phase of the build to take the checkpoint at. One of
+	// `os_installed`, `before_provisioning` and `after_provisioning`.
+	Phase string `mapstructure:"phase" required:"true"`
+	// The name of the checkpoint. Defaults to `packer-<phase>`.
+	Name string `mapstructure:"name" required:"false"`
+	// Keep the checkpoint in the exported VM, so it can be restored from
+	// the artifact. This defaults to false. Checkpoints taken before
+	// `after_provisioning` hold the build switch, media and hardware, so
+	// they can't be kept with `export_cleanup` or `export_hardware`.
+	Keep bool `mapstructure:"keep" required:"false"`
+}
+
+func (c *Checkpoint) Prepare() []error {
+	var errs []error
+
+	known := false
+	for _, phase := range CheckpointPhases {
+		if c.Phase == phase {
+			known = true
+		}
+	}
+	if !known {
+		errs = append(errs, fmt.Errorf("phase must be one of %s, but defined: %q",
+			strings.Join(CheckpointPhases, ", "), c.Phase))
+	}
+
+	if c.Name == "" {
+		c.Name = "packer-" + c.Phase
+	}
+
+	return errs
+}
+
+// normalizeCheckpointType returns the Hyper-V spelling of checkpointType,
+// matched case insensitively.
+func normalizeCheckpointType(checkpointType string) (string, error) {
+	for _, t := range CheckpointTypes {
+		if strings.EqualFold(checkpointType, t) {
+			return t, nil
+		}
+	}
+
+	return "", fmt.Errorf("checkpoint_type must be one of %s, but defined: %q",
+		strings.Join(CheckpointTypes, ", "), checkpointType)
+}
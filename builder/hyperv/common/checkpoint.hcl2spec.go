@@ -0,0 +1,35 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatCheckpoint is an auto-generated flat version of Checkpoint.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatCheckpoint struct {
+	Phase *string `mapstructure:"phase" required:"true" cty:"phase" hcl:"phase"`
+	Name  *string `mapstructure:"name" required:"false" cty:"name" hcl:"name"`
+	Keep  *bool   `mapstructure:"keep" required:"false" cty:"keep" hcl:"keep"`
+}
+
+// FlatMapstructure returns a new FlatCheckpoint.
+// FlatCheckpoint is an auto-generated flat version of Checkpoint.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Checkpoint) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatCheckpoint)
+}
+
+// HCL2Spec returns the hcl spec of a Checkpoint.
+// This spec is used by HCL to read the fields of Checkpoint.
+// The decoded values from this spec will then be applied to a FlatCheckpoint.
+func (*FlatCheckpoint) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"phase": &hcldec.AttrSpec{Name: "phase", Type: cty.String, Required: false},
+		"name":  &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"keep":  &hcldec.AttrSpec{Name: "keep", Type: cty.Bool, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,98 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type CloudInitConfig
+
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// The volume label cloud-init looks for to find a NoCloud seed
+const CloudInitLabel = "cidata"
+
+// The meta-data written when `meta_data` isn't set. cloud-init requires the
+// file, and only runs again for a new instance-id.
+const DefaultCloudInitMetaData = "instance-id: {{ .Name }}\nlocal-hostname: {{ .Name }}\n"
+
+// CloudInitConfig generates a NoCloud seed for cloud-init, an ISO 9660
+// image labelled `cidata` that is mounted as a DVD drive while the VM is
+// built. This configures cloud images of Linux distributions, such as the
+// Ubuntu or Debian VHDX images, that don't have an installer to answer.
+//
+// Each file is a template, rendered when the VM is started with:
+//
+//   - `HTTPIP` and `HTTPPort` - The IP and port of the HTTP server.
+//   - `Name` - The name of the VM.
+//   - `SSHPublicKey` - The public key of `ssh_private_key_file`, or of the
+//     temporary key pair Packer creates for the `ssh` communicator when no
+//     key file is set.
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	cloud_init {
+//	  user_data = <<-EOF
+//	    #cloud-config
+//	    users:
+//	      - name: packer
+//	        sudo: ALL=(ALL) NOPASSWD:ALL
+//	        ssh_authorized_keys:
+//	          - {{ .SSHPublicKey }}
+//	    EOF
+//	}
+//
+// ```
+type CloudInitConfig struct {
+	// The user-data file, usually a `#cloud-config` document or a script.
+	// Setting any of the files enables the seed.
+	UserData string `mapstructure:"user_data" required:"false"`
+	// The meta-data file. Defaults to an `instance-id` and
+	// `local-hostname` set to the name of the VM.
+	MetaData string `mapstructure:"meta_data" required:"false"`
+	// The network-config file, in the version 1 or version 2 network
+	// configuration format. cloud-init configures DHCP on the first
+	// interface when it isn't set.
+	NetworkConfig string `mapstructure:"network_config" required:"false"`
+}
+
+// IsSet reports whether a NoCloud seed should be generated.
+func (c *CloudInitConfig) IsSet() bool {
+	return c.UserData != "" || c.MetaData != "" || c.NetworkConfig != ""
+}
+
+func (c *CloudInitConfig) Prepare() []error {
+	if !c.IsSet() {
+		return nil
+	}
+
+	var errs []error
+
+	if c.MetaData == "" {
+		c.MetaData = DefaultCloudInitMetaData
+	}
+
+	for name, v := range map[string]string{
+		"user_data":      c.UserData,
+		"meta_data":      c.MetaData,
+		"network_config": c.NetworkConfig,
+	} {
+		if err := interpolate.Validate(v, &interpolate.Context{}); err != nil {
+			errs = append(errs, fmt.Errorf("cloud_init: %s is not a valid template: %s", name, err))
+		}
+	}
+
+	return errs
+}
+
+// UsesSSHPublicKey reports whether any of the files refer to the public
+// key of the communicator.
+func (c *CloudInitConfig) UsesSSHPublicKey() bool {
+	return strings.Contains(c.UserData+c.MetaData+c.NetworkConfig, "SSHPublicKey")
+}
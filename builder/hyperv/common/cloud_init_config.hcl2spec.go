@@ -0,0 +1,35 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatCloudInitConfig is an auto-generated flat version of CloudInitConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatCloudInitConfig struct {
+	UserData      *string `mapstructure:"user_data" required:"false" cty:"user_data" hcl:"user_data"`
+	MetaData      *string `mapstructure:"meta_data" required:"false" cty:"meta_data" hcl:"meta_data"`
+	NetworkConfig *string `mapstructure:"network_config" required:"false" cty:"network_config" hcl:"network_config"`
+}
+
+// FlatMapstructure returns a new FlatCloudInitConfig.
+// FlatCloudInitConfig is an auto-generated flat version of CloudInitConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*CloudInitConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatCloudInitConfig)
+}
+
+// HCL2Spec returns the hcl spec of a CloudInitConfig.
+// This spec is used by HCL to read the fields of CloudInitConfig.
+// The decoded values from this spec will then be applied to a FlatCloudInitConfig.
+func (*FlatCloudInitConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"user_data":      &hcldec.AttrSpec{Name: "user_data", Type: cty.String, Required: false},
+		"meta_data":      &hcldec.AttrSpec{Name: "meta_data", Type: cty.String, Required: false},
+		"network_config": &hcldec.AttrSpec{Name: "network_config", Type: cty.String, Required: false},
+	}
+	return s
+}
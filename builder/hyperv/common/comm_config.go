@@ -7,6 +7,7 @@
 package common
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
@@ -14,11 +15,26 @@ import (
 	psrp "github.com/smnsjas/packer-psrp-communicator/communicator/psrp"
 )
 
+// The transports the SSH communicator can connect over
+const (
+	SSHTransportTCP    = "tcp"
+	SSHTransportHvsock = "hvsock"
+)
+
 // CommConfig contains configuration for all communicator types (SSH, WinRM, PSRP)
 type CommConfig struct {
 	// Standard communicator configuration (SSH/WinRM)
 	Comm communicator.Config `mapstructure:",squash"`
 
+	// --- SSH Transport Settings ---
+	// Transport for the SSH communicator: "tcp" or "hvsock". With "hvsock",
+	// SSH connects to `ssh_port` of the guest over Hyper-V sockets instead
+	// of the network, so the VM needs neither a network adapter nor an IP
+	// address. The guest must accept SSH connections on that AF_VSOCK port,
+	// which needs the `hv_sock` kernel module. Only supported on Windows
+	// hosts. Defaults to "tcp".
+	SSHTransport string `mapstructure:"ssh_transport" required:"false"`
+
 	// PSRP communicator configuration (internal use)
 	PSRP psrp.Config `mapstructure:"-"`
 
@@ -85,7 +101,34 @@ func (c *CommConfig) Prepare(ctx *interpolate.Context) []error {
 	}
 
 	// For SSH/WinRM, use SDK's validation
-	return c.Comm.Prepare(ctx)
+	errs := c.Comm.Prepare(ctx)
+
+	switch c.SSHTransport {
+	case "":
+		c.SSHTransport = SSHTransportTCP
+	case SSHTransportTCP:
+	case SSHTransportHvsock:
+		if c.Comm.Type == "ssh" && (c.Comm.SSHBastionHost != "" || c.Comm.SSHProxyHost != "") {
+			errs = append(errs, fmt.Errorf("ssh_transport hvsock can't be used with a bastion or proxy host"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("ssh_transport must be %q or %q, but defined: %q",
+			SSHTransportTCP, SSHTransportHvsock, c.SSHTransport))
+	}
+
+	return errs
+}
+
+// UsesHvsock reports whether the communicator connects to the guest over
+// Hyper-V sockets rather than the network.
+func (c *CommConfig) UsesHvsock() bool {
+	switch c.Comm.Type {
+	case "psrp":
+		return c.PSRPTransport == "hvsock"
+	case "ssh":
+		return c.SSHTransport == SSHTransportHvsock
+	}
+	return false
 }
 
 // populatePSRPConfig copies field values from the HCL-exposed fields to the internal psrp.Config
@@ -59,6 +59,7 @@ type FlatCommConfig struct {
 	WinRMUseSSL               *bool    `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
 	WinRMInsecure             *bool    `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
 	WinRMUseNTLM              *bool    `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	SSHTransport              *string  `mapstructure:"ssh_transport" required:"false" cty:"ssh_transport" hcl:"ssh_transport"`
 	PSRPHost                  *string  `mapstructure:"psrp_host" required:"false" cty:"psrp_host" hcl:"psrp_host"`
 	PSRPPort                  *int     `mapstructure:"psrp_port" required:"false" cty:"psrp_port" hcl:"psrp_port"`
 	PSRPUsername              *string  `mapstructure:"psrp_username" required:"false" cty:"psrp_username" hcl:"psrp_username"`
@@ -135,6 +136,7 @@ func (*FlatCommConfig) HCL2Spec() map[string]hcldec.Spec {
 		"winrm_use_ssl":                &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
 		"winrm_insecure":               &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
 		"winrm_use_ntlm":               &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
+		"ssh_transport":                &hcldec.AttrSpec{Name: "ssh_transport", Type: cty.String, Required: false},
 		"psrp_host":                    &hcldec.AttrSpec{Name: "psrp_host", Type: cty.String, Required: false},
 		"psrp_port":                    &hcldec.AttrSpec{Name: "psrp_port", Type: cty.Number, Required: false},
 		"psrp_username":                &hcldec.AttrSpec{Name: "psrp_username", Type: cty.String, Required: false},
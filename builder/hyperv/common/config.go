@@ -9,8 +9,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/powershell/hyperv"
 	"github.com/hashicorp/packer-plugin-sdk/common"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
@@ -33,10 +37,21 @@ const (
 
 	LowRam = 256 // 256MB
 
+	MinMemoryBuffer = 5    // 5%
+	MaxMemoryBuffer = 2000 // 2000%
+	MaxMemoryWeight = 100
+
 	DefaultUsername = ""
 	DefaultPassword = ""
 )
 
+const guestServiceInterface = "Guest Service Interface"
+
+// The values accepted by Set-VMHardDiskDrive -OverrideCacheAttributes
+var diskCacheAttributes = []string{"Default", "WriteCacheEnabled", "WriteCacheAndFUAEnabled", "WriteCacheDisabled"}
+
+var guidRegex = regexp.MustCompile(`^\{?[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\}?$`)
+
 type CommonConfig struct {
 	commonsteps.FloppyConfig `mapstructure:",squash"`
 	commonsteps.CDConfig     `mapstructure:",squash"`
@@ -60,6 +75,25 @@ type CommonConfig struct {
 	// file representing the disk will not use the full size unless it is
 	// full.
 	AdditionalDiskSize []uint `mapstructure:"disk_additional_size" required:"false"`
+	// The minimum normalized IOPS, in 8 KB increments, reserved with
+	// Storage QoS for each hard disk the builder creates: the primary disk
+	// of the iso builder and the `disk_additional_size` disks. This keeps
+	// parallel builds on one host from starving each other for I/O. By
+	// default no minimum is reserved.
+	MinimumIOPS uint64 `mapstructure:"minimum_iops" required:"false"`
+	// The maximum normalized IOPS, in 8 KB increments, allowed for each hard
+	// disk the builder creates. By default I/O is not capped.
+	MaximumIOPS uint64 `mapstructure:"maximum_iops" required:"false"`
+	// The ID of a Storage QoS policy, as returned by `Get-StorageQosPolicy`,
+	// to apply to each hard disk the builder creates. This can't be
+	// combined with `minimum_iops` or `maximum_iops`.
+	QoSPolicyID string `mapstructure:"qos_policy_id" required:"false"`
+	// Overrides how the host caches writes to the hard disks the builder
+	// creates. One of `Default`, `WriteCacheEnabled`,
+	// `WriteCacheAndFUAEnabled` (write through) or `WriteCacheDisabled`.
+	// Requires Windows 10 or Windows Server 2016 onwards. By default the
+	// host setting is left as is.
+	DiskCacheAttributes string `mapstructure:"disk_cache_attributes" required:"false"`
 	// If set to attach then attach and
 	// mount the ISO image specified in guest_additions_path. If set to
 	// none then guest additions are not attached and mounted; This is the
@@ -104,6 +138,22 @@ type CommonConfig struct {
 	// If true enable dynamic memory for
 	// the virtual machine. This defaults to false.
 	EnableDynamicMemory bool `mapstructure:"enable_dynamic_memory" required:"false"`
+	// The minimum amount of memory, in megabytes, dynamic memory may shrink
+	// the virtual machine to. Must not be greater than `memory`. Requires
+	// `enable_dynamic_memory`. By default Hyper-V uses 512 MB.
+	MemoryMinimum uint `mapstructure:"memory_minimum" required:"false"`
+	// The maximum amount of memory, in megabytes, dynamic memory may grow
+	// the virtual machine to. Must not be less than `memory`. Requires
+	// `enable_dynamic_memory`. By default Hyper-V uses 1 TB.
+	MemoryMaximum uint `mapstructure:"memory_maximum" required:"false"`
+	// The percentage of memory Hyper-V reserves for the virtual machine on
+	// top of what it currently uses, between 5 and 2000. Requires
+	// `enable_dynamic_memory`. By default Hyper-V uses 20.
+	MemoryBuffer uint `mapstructure:"memory_buffer" required:"false"`
+	// The priority of the virtual machine when memory is scarce on the host,
+	// between 0 and 100. Requires `enable_dynamic_memory`. By default
+	// Hyper-V uses 50.
+	MemoryWeight *uint `mapstructure:"memory_weight" required:"false"`
 	// If true enable secure boot for the
 	// virtual machine. This defaults to false. See secure_boot_template
 	// below for additional settings.
@@ -122,6 +172,24 @@ type CommonConfig struct {
 	// If true enable a virtual TPM for the
 	// virtual machine. This defaults to false.
 	EnableTPM bool `mapstructure:"enable_tpm" required:"false"`
+	// Integration services to enable or disable before the VM boots for the
+	// first time, as a map of the service name to whether it is enabled.
+	// The services are `Time Synchronization`, `Heartbeat`,
+	// `Key-Value Pair Exchange`, `Shutdown`, `VSS` and
+	// `Guest Service Interface`. Services that aren't listed keep the Hyper-V
+	// defaults, except `Guest Service Interface` which is enabled unless set
+	// to false here.
+	//
+	// ```hcl
+	// integration_services = {
+	//   "Time Synchronization" = false
+	// }
+	// ```
+	IntegrationServices map[string]bool `mapstructure:"integration_services" required:"false"`
+	// Integration services to enable or disable once the VM has been shut
+	// down, so the exported VM can differ from the state used for the build.
+	// Accepts the same services as `integration_services`.
+	ExportIntegrationServices map[string]bool `mapstructure:"export_integration_services" required:"false"`
 	// The location under which Packer will create a directory to house all the
 	// VM files and folders during the build. By default `%TEMP%` is used
 	// which, for most systems, will evaluate to
@@ -132,6 +200,19 @@ type CommonConfig struct {
 	// automatically generated by Packer to ensure the directory name is
 	// unique.
 	TempPath string `mapstructure:"temp_path" required:"false"`
+	// The location under which Packer will create a directory to house the
+	// virtual hard disks of the VM during the build, instead of the build
+	// directory under `temp_path`. Useful to keep large disks off a small
+	// system volume. The directory is removed when the build completes.
+	VhdPath string `mapstructure:"vhd_path" required:"false"`
+	// The location under which Packer will create a directory to house the
+	// checkpoints of the VM during the build. Defaults to the build
+	// directory under `temp_path`.
+	CheckpointPath string `mapstructure:"checkpoint_path" required:"false"`
+	// The location under which Packer will create a directory to house the
+	// smart paging file of the VM during the build. Defaults to the build
+	// directory under `temp_path`.
+	SmartPagingPath string `mapstructure:"smart_paging_path" required:"false"`
 	// This allows you to set the vm version when calling New-VM to generate
 	// the vm.
 	Version string `mapstructure:"configuration_version" required:"false"`
@@ -183,6 +264,118 @@ type CommonConfig struct {
 	//
 	// **NB** This only works for Generation 2 machines.
 	BootOrder []string `mapstructure:"boot_order" required:"false"`
+	// Files and drivers to inject into the boot disk from the host before
+	// the VM boots for the first time. See the
+	// [Offline Customization](#offline-customization) section for details.
+	OfflineCustomization OfflineCustomizationConfig `mapstructure:"offline_customization" required:"false"`
+	// Files and directories to copy from the host into the guest with
+	// `Copy-VMFile` once the VM has booted, before the communicator
+	// connects. No network access to the guest is needed. Requires the
+	// Guest Service Interface, so it can't be combined with disabling it in
+	// `integration_services`.
+	//
+	// ```hcl
+	// guest_files {
+	//   source      = "payload/"
+	//   destination = "C:\\payload"
+	// }
+	// ```
+	GuestFiles []GuestFile `mapstructure:"guest_files" required:"false"`
+	// How long to keep retrying `guest_files` while the Guest Service
+	// Interface isn't running in the guest yet, for instance during an OS
+	// installation. Defaults to 30m.
+	GuestFilesTimeout time.Duration `mapstructure:"guest_files_timeout" required:"false"`
+	// Capture a serial port of the VM to a file in `output_directory`. See
+	// the [Serial Log](#serial-log) section for details.
+	SerialLog SerialLogConfig `mapstructure:"serial_log" required:"false"`
+	// Also capture a screenshot of the VM console at this interval while
+	// the VM is running, for instance `30s`. Screenshots are always taken
+	// after each boot command group and when the build fails. They are
+	// written to a `debug` directory next to `output_directory`. Defaults
+	// to 0, which disables periodic screenshots.
+	ScreenshotInterval time.Duration `mapstructure:"screenshot_interval" required:"false"`
+	// The keyboard layout the guest expects during the boot command, so
+	// that characters are sent as the scancodes of the keys that type them
+	// on that layout. One of `us`, `uk`, `de`, `fr` and `es`. Defaults to
+	// `us`.
+	BootKeyboardLayout string `mapstructure:"boot_keyboard_layout" required:"false"`
+	// Time to wait between the keys of the boot command, for instance `50ms`,
+	// for guests that drop keys typed too quickly. By default runs of text
+	// are typed in one go and only groups of keys are separated by
+	// `boot_keygroup_interval`.
+	BootKeyInterval time.Duration `mapstructure:"boot_key_interval" required:"false"`
+	// Processor reservation, limits, weight, SMT and NUMA settings. See the
+	// [Processor](#processor) section for details.
+	Processor ProcessorConfig `mapstructure:"processor" required:"false"`
+	// The type of checkpoints Hyper-V takes of the VM, one of `Standard`,
+	// `Production` and `ProductionOnly`. By default the Hyper-V default for
+	// new VMs is kept, which is `Production`. Production checkpoints of a
+	// running VM need the Backup integration service in the guest; with
+	// `ProductionOnly` taking them fails otherwise instead of falling back
+	// to a standard checkpoint. Automatic checkpoints are always disabled.
+	CheckpointType string `mapstructure:"checkpoint_type" required:"false"`
+	// Named checkpoints to take at phases of the build. See the
+	// [Checkpoints](#checkpoints) section for details.
+	//
+	// ```hcl
+	// checkpoints {
+	//   phase = "os_installed"
+	//   name  = "clean-os"
+	//   keep  = true
+	// }
+	// ```
+	Checkpoints []Checkpoint `mapstructure:"checkpoints" required:"false"`
+	// Generate a NoCloud seed for cloud-init and mount it while the VM is
+	// built. See the [cloud-init](#cloud-init) section for details.
+	CloudInit CloudInitConfig `mapstructure:"cloud_init" required:"false"`
+	// Wait for the guest OS installation to complete before connecting the
+	// communicator. See the [Install Complete](#install-complete) section
+	// for details.
+	InstallComplete InstallCompleteConfig `mapstructure:"install_complete" required:"false"`
+	// Items to send to the guest through the Data Exchange integration
+	// service before the VM starts. The values are templates, see the
+	// [KVP Exchange](#kvp-exchange) section for details.
+	//
+	// ```hcl
+	// kvp_items = {
+	//   PackerBuildName = "{{ build_name }}"
+	//   PackerHTTPURL   = "http://{{ .HTTPIP }}:{{ .HTTPPort }}"
+	// }
+	// ```
+	KVPItems map[string]string `mapstructure:"kvp_items" required:"false"`
+	// How to power off the VM after provisioning, as a chain of stages
+	// tried in turn until the VM is off. See the
+	// [Shutdown](#shutdown) section for details.
+	//
+	// ```hcl
+	// shutdown_mode {
+	//   method  = "command"
+	//   timeout = "10m"
+	// }
+	// shutdown_mode {
+	//   method  = "turn_off"
+	// }
+	// ```
+	ShutdownMode []ShutdownStage `mapstructure:"shutdown_mode" required:"false"`
+	// Generalize the Windows guest with sysprep instead of running
+	// `shutdown_command`. See the [Windows Generalize](#windows-generalize)
+	// section for details.
+	WindowsGeneralize WindowsGeneralizeConfig `mapstructure:"windows_generalize" required:"false"`
+	// Clean up the identity of a Linux guest, such as its machine ID and
+	// SSH host keys, before it is shut down. See the
+	// [Linux Generalize](#linux-generalize) section for details.
+	LinuxGeneralize LinuxGeneralizeConfig `mapstructure:"linux_generalize" required:"false"`
+	// Hardware of the exported VM that differs from the hardware it was
+	// built with. See the [Export Hardware](#export-hardware) section for
+	// details.
+	ExportHardware ExportHardwareConfig `mapstructure:"export_hardware" required:"false"`
+	// Remove the devices and bindings only the build needed before the VM
+	// is exported: empty DVD drives, the floppy media, the switch
+	// connection, the static MAC address and the VLAN of the network
+	// adapters. This lets the VM be imported on hosts without the build
+	// switch. `export_hardware` is applied afterwards, so it can still
+	// connect the VM to a switch. This defaults to false.
+	ExportCleanup bool `mapstructure:"export_cleanup" required:"false"`
 }
 
 func (c *CommonConfig) Prepare(ctx *interpolate.Context, pc *common.PackerConfig) ([]error, []string) {
@@ -290,6 +483,10 @@ func (c *CommonConfig) Prepare(ctx *interpolate.Context, pc *common.PackerConfig
 	}
 
 	// Note: virtualization extensions check moved to StepValidateHost (requires PowerShell).
+	if c.Processor.ExposeVirtualizationExtensions {
+		c.EnableVirtualizationExtensions = true
+	}
+	errs = append(errs, c.Processor.Prepare()...)
 
 	if c.FirstBootDevice != "" {
 		_, _, _, err := ParseBootDeviceIdentifier(c.FirstBootDevice, c.Generation)
@@ -331,10 +528,45 @@ func (c *CommonConfig) Prepare(ctx *interpolate.Context, pc *common.PackerConfig
 	if err != nil {
 		errs = append(errs, err)
 	}
+	errs = append(errs, c.checkDiskPerformance()...)
+	errs = append(errs, c.checkIntegrationServices()...)
+	errs = append(errs, c.checkGuestFiles()...)
+	errs = append(errs, c.OfflineCustomization.Prepare()...)
+	errs = append(errs, c.SerialLog.Prepare()...)
+	errs = append(errs, c.checkCheckpoints()...)
+	errs = append(errs, c.CloudInit.Prepare()...)
+	errs = append(errs, c.InstallComplete.Prepare()...)
+	errs = append(errs, c.checkKVPItems()...)
+	errs = append(errs, c.checkShutdownMode()...)
+	errs = append(errs, c.WindowsGeneralize.Prepare()...)
+	if c.WindowsGeneralize.IsSet() && len(c.ShutdownMode) > 0 {
+		errs = append(errs, fmt.Errorf("windows_generalize and shutdown_mode can't be used together"))
+	}
+	errs = append(errs, c.LinuxGeneralize.Prepare()...)
+	errs = append(errs, c.ExportHardware.Prepare()...)
+	if c.LinuxGeneralize.IsSet() && c.WindowsGeneralize.IsSet() {
+		errs = append(errs, fmt.Errorf("linux_generalize and windows_generalize can't be used together"))
+	}
+
+	if c.ScreenshotInterval < 0 {
+		errs = append(errs, fmt.Errorf("screenshot_interval must not be negative"))
+	}
+
+	if c.BootKeyboardLayout == "" {
+		c.BootKeyboardLayout = DefaultKeyboardLayout
+	}
+	if _, err := newKeyboardLayout(c.BootKeyboardLayout); err != nil {
+		errs = append(errs, fmt.Errorf("boot_keyboard_layout: %s", err))
+	}
+	if c.BootKeyInterval < 0 {
+		errs = append(errs, fmt.Errorf("boot_key_interval must not be negative"))
+	}
+
 	err = c.checkRamSize()
 	if err != nil {
 		errs = append(errs, err)
 	}
+	errs = append(errs, c.checkDynamicMemory()...)
 
 	// Note: host memory check moved to StepValidateHost (requires PowerShell).
 
@@ -364,6 +596,253 @@ func (c *CommonConfig) checkDiskBlockSize() error {
 }
 
 
+func (c *CommonConfig) checkDiskPerformance() []error {
+	var errs []error
+
+	if c.MaximumIOPS > 0 && c.MinimumIOPS > c.MaximumIOPS {
+		errs = append(errs, fmt.Errorf("minimum_iops: %d must not be greater than maximum_iops: %d",
+			c.MinimumIOPS, c.MaximumIOPS))
+	}
+
+	if c.QoSPolicyID != "" {
+		if c.MinimumIOPS > 0 || c.MaximumIOPS > 0 {
+			errs = append(errs, fmt.Errorf("qos_policy_id can't be combined with minimum_iops or maximum_iops"))
+		}
+		if !guidRegex.MatchString(c.QoSPolicyID) {
+			errs = append(errs, fmt.Errorf("qos_policy_id: %q is not a valid policy ID", c.QoSPolicyID))
+		}
+	}
+
+	if c.DiskCacheAttributes != "" {
+		valid := false
+		for _, attributes := range diskCacheAttributes {
+			if strings.EqualFold(c.DiskCacheAttributes, attributes) {
+				c.DiskCacheAttributes = attributes
+				valid = true
+			}
+		}
+		if !valid {
+			errs = append(errs, fmt.Errorf("disk_cache_attributes: must be one of %s",
+				strings.Join(diskCacheAttributes, ", ")))
+		}
+	}
+
+	return errs
+}
+
+func (c *CommonConfig) checkIntegrationServices() []error {
+	var errs []error
+
+	var err error
+	c.IntegrationServices, err = normalizeIntegrationServices(c.IntegrationServices)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("integration_services: %s", err))
+	}
+	c.ExportIntegrationServices, err = normalizeIntegrationServices(c.ExportIntegrationServices)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("export_integration_services: %s", err))
+	}
+
+	// The Guest Service Interface has always been enabled for builds
+	if _, ok := c.IntegrationServices[guestServiceInterface]; !ok {
+		if c.IntegrationServices == nil {
+			c.IntegrationServices = make(map[string]bool)
+		}
+		c.IntegrationServices[guestServiceInterface] = true
+	}
+
+	return errs
+}
+
+func (c *CommonConfig) checkGuestFiles() []error {
+	var errs []error
+
+	for i, f := range c.GuestFiles {
+		for _, err := range f.Prepare() {
+			errs = append(errs, fmt.Errorf("guest_files[%d]: %s", i, err))
+		}
+	}
+
+	if len(c.GuestFiles) > 0 && !c.IntegrationServices[guestServiceInterface] {
+		errs = append(errs, fmt.Errorf("guest_files requires the %s integration service", guestServiceInterface))
+	}
+
+	if c.GuestFilesTimeout == 0 {
+		c.GuestFilesTimeout = DefaultGuestFilesTimeout
+	}
+
+	return errs
+}
+
+func (c *CommonConfig) checkCheckpoints() []error {
+	var errs []error
+
+	if c.CheckpointType != "" {
+		checkpointType, err := normalizeCheckpointType(c.CheckpointType)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		c.CheckpointType = checkpointType
+	}
+
+	names := make(map[string]bool)
+	for i := range c.Checkpoints {
+		checkpoint := &c.Checkpoints[i]
+		for _, err := range checkpoint.Prepare() {
+			errs = append(errs, fmt.Errorf("checkpoints[%d]: %s", i, err))
+		}
+
+		if names[checkpoint.Name] {
+			errs = append(errs, fmt.Errorf("checkpoints[%d]: name %q is already used", i, checkpoint.Name))
+		}
+		names[checkpoint.Name] = true
+
+		if checkpoint.Keep && c.SkipExport {
+			errs = append(errs, fmt.Errorf("checkpoints[%d]: keep requires the VM to be exported, "+
+				"but skip_export is set", i))
+		}
+		// Checkpoints taken before the shutdown hold the build switch,
+		// media and hardware, which the export cleanups don't reach
+		if checkpoint.Keep && checkpoint.Phase != CheckpointPhaseAfterProvisioning &&
+			(c.ExportCleanup || c.ExportHardware.IsSet()) {
+			errs = append(errs, fmt.Errorf("checkpoints[%d]: keep can only be used with the %s phase "+
+				"when export_cleanup or export_hardware is set", i, CheckpointPhaseAfterProvisioning))
+		}
+	}
+
+	return errs
+}
+
+func (c *CommonConfig) checkKVPItems() []error {
+	var errs []error
+
+	names := make([]string, 0, len(c.KVPItems))
+	for name := range c.KVPItems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "" {
+			errs = append(errs, fmt.Errorf("kvp_items: names must not be empty"))
+			continue
+		}
+		if len(name) > maxKvpNameSize {
+			errs = append(errs, fmt.Errorf("kvp_items: name %q is longer than %d bytes", name, maxKvpNameSize))
+		}
+		if err := interpolate.Validate(c.KVPItems[name], &interpolate.Context{}); err != nil {
+			errs = append(errs, fmt.Errorf("kvp_items: error parsing %s template: %s", name, err))
+		}
+	}
+
+	return errs
+}
+
+func (c *CommonConfig) checkShutdownMode() []error {
+	var errs []error
+
+	last := -1
+	for i := range c.ShutdownMode {
+		stage := &c.ShutdownMode[i]
+		for _, err := range stage.Prepare() {
+			errs = append(errs, fmt.Errorf("shutdown_mode[%d]: %s", i, err))
+		}
+
+		rank := shutdownMethodRank(stage.Method)
+		if rank < 0 {
+			continue
+		}
+		if rank <= last {
+			errs = append(errs, fmt.Errorf("shutdown_mode[%d]: methods must be used once, in the order %s",
+				i, strings.Join(ShutdownMethods, ", ")))
+			continue
+		}
+		last = rank
+	}
+
+	return errs
+}
+
+// normalizeIntegrationServices returns services with the names matched case
+// insensitively against the known integration services.
+func normalizeIntegrationServices(services map[string]bool) (map[string]bool, error) {
+	if len(services) == 0 {
+		return services, nil
+	}
+
+	var known []string
+	for name := range hyperv.IntegrationServiceIds {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+
+	normalized := make(map[string]bool, len(services))
+	for name, enabled := range services {
+		match := ""
+		for _, k := range known {
+			if strings.EqualFold(name, k) {
+				match = k
+			}
+		}
+		if match == "" {
+			return nil, fmt.Errorf("unknown integration service %q, must be one of %s", name,
+				strings.Join(known, ", "))
+		}
+		normalized[match] = enabled
+	}
+
+	return normalized, nil
+}
+
+func (c *CommonConfig) checkDynamicMemory() []error {
+	var errs []error
+
+	if c.MemoryMinimum == 0 && c.MemoryMaximum == 0 && c.MemoryBuffer == 0 && c.MemoryWeight == nil {
+		return nil
+	}
+
+	if !c.EnableDynamicMemory {
+		errs = append(errs, fmt.Errorf("memory_minimum, memory_maximum, memory_buffer and memory_weight "+
+			"require enable_dynamic_memory"))
+	}
+	if c.EnableVirtualizationExtensions {
+		errs = append(errs, fmt.Errorf("memory_minimum, memory_maximum, memory_buffer and memory_weight "+
+			"can't be used with enable_virtualization_extensions, as nested virtualization doesn't support "+
+			"dynamic memory"))
+	}
+
+	if c.MemoryMinimum > 0 {
+		if c.MemoryMinimum < MinRamSize {
+			errs = append(errs, fmt.Errorf("memory_minimum: Virtual machine requires memory size >= %v MB, "+
+				"but defined: %v", MinRamSize, c.MemoryMinimum))
+		}
+		if c.MemoryMinimum > c.RamSize {
+			errs = append(errs, fmt.Errorf("memory_minimum: %v MB must not be greater than memory: %v MB",
+				c.MemoryMinimum, c.RamSize))
+		}
+	}
+	if c.MemoryMaximum > 0 {
+		if c.MemoryMaximum > MaxRamSize {
+			errs = append(errs, fmt.Errorf("memory_maximum: Virtual machine requires memory size <= %v MB, "+
+				"but defined: %v", MaxRamSize, c.MemoryMaximum))
+		}
+		if c.MemoryMaximum < c.RamSize {
+			errs = append(errs, fmt.Errorf("memory_maximum: %v MB must not be less than memory: %v MB",
+				c.MemoryMaximum, c.RamSize))
+		}
+	}
+	if c.MemoryBuffer > 0 && (c.MemoryBuffer < MinMemoryBuffer || c.MemoryBuffer > MaxMemoryBuffer) {
+		errs = append(errs, fmt.Errorf("memory_buffer: must be between %v and %v, but defined: %v",
+			MinMemoryBuffer, MaxMemoryBuffer, c.MemoryBuffer))
+	}
+	if c.MemoryWeight != nil && *c.MemoryWeight > MaxMemoryWeight {
+		errs = append(errs, fmt.Errorf("memory_weight: must be between 0 and %v, but defined: %v",
+			MaxMemoryWeight, *c.MemoryWeight))
+	}
+
+	return errs
+}
+
 func (c *CommonConfig) checkRamSize() error {
 	if c.RamSize == 0 {
 		c.RamSize = DefaultRamSize
@@ -5,8 +5,21 @@ package common
 
 import (
 	"context"
+
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/powershell/hyperv"
 )
 
+// A Keyboard types into the console of a VM until it is closed.
+type Keyboard interface {
+	// Types printable ASCII text
+	TypeText(string) error
+
+	// Sends PC XT scancodes given as hex bytes
+	TypeScanCodes([]string) error
+
+	Close() error
+}
+
 // A driver is able to talk to HyperV and perform certain
 // operations with it. Some of the operations on here may seem overly
 // specific, but they were built specifically in mind to handle features
@@ -30,6 +43,10 @@ type Driver interface {
 	// Stop stops a VM specified by the name given.
 	Stop(string) error
 
+	// TurnOff powers off a VM specified by the name given, without
+	// shutting down the guest OS.
+	TurnOff(string) error
+
 	// Verify checks to make sure that this driver should function
 	// properly. If there is any indication the driver can't function,
 	// this will return an error.
@@ -47,11 +64,28 @@ type Driver interface {
 	// Gets the VM GUID for the specified VM name (required for HvSocket/PowerShell Direct)
 	GetVMId(string) (string, error)
 
+	// Registers a Hyper-V socket service GUID on the host, so that the host
+	// can connect to the service of a guest
+	RegisterHvsockService(string, string) error
+
+	// Gets the KVP items the integration services of the guest publish
+	GetGuestIntrinsicKvpItems(vmName string) (map[string]string, error)
+
+	// Gets the KVP items the guest set in its pool
+	GetGuestKvpItems(vmName string) (map[string]string, error)
+
+	// Adds or changes a KVP item the host sends to the guest
+	SetHostKvpItem(vmName string, name string, data string) error
+
+	// Removes a KVP item the host sends to the guest
+	RemoveHostKvpItem(vmName string, name string) error
+
 	// Finds the IP address of a host adapter connected to switch
 	GetHostAdapterIpAddressForSwitch(string) (string, error)
 
-	// Type scan codes to virtual keyboard of vm
-	TypeScanCodes(string, string) error
+	// Opens the virtual keyboard of vm for typing. Typing is abandoned once
+	// the context is done.
+	OpenKeyboard(context.Context, string) (Keyboard, error)
 
 	//Get the ip address for network adaptor
 	GetVirtualMachineNetworkAdapterAddress(string) (string, error)
@@ -64,6 +98,15 @@ type Driver interface {
 
 	SetVmNetworkAdapterMacAddress(string, string) error
 
+	// Lets Hyper-V assign the MAC address of the network adapters again
+	SetVmNetworkAdapterDynamicMacAddress(string) error
+
+	// Disconnects the network adapters of the VM from their switch
+	DisconnectVirtualMachineNetworkAdapter(string) error
+
+	// Removes the VLAN of the network adapters of the VM
+	UntagVirtualMachineVlan(string) error
+
 	//Replace the network adapter with a (non-)legacy adapter
 	ReplaceVirtualMachineNetworkAdapter(string, bool) error
 
@@ -81,23 +124,77 @@ type Driver interface {
 
 	CheckVMName(string) error
 
-	CreateVirtualMachine(string, string, string, int64, int64, int64, string, uint, bool, bool, string) error
+	CreateVirtualMachine(string, string, string, int64, int64, int64, string, uint, bool, bool, string,
+		hyperv.DiskPerformance, hyperv.StoragePaths) error
 
-	AddVirtualMachineHardDrive(string, string, string, int64, int64, string) error
+	AddVirtualMachineHardDrive(string, string, string, int64, int64, string, hyperv.DiskPerformance) error
 
-	CloneVirtualMachine(string, string, string, bool, string, string, string, int64, string, bool) error
+	CloneVirtualMachine(string, string, string, bool, string, string, string, int64, string,
+		hyperv.StoragePaths) error
 
 	ResizeVirtualMachineVhd(string, uint64) error
 
+	// Returns the size in bytes of the first vhd on the virtual machine
+	GetVirtualMachineVhdSize(string) (uint64, error)
+
+	// Grows the last partition of the first vhd to fill the disk, returning
+	// the partition's file system and whether it was expanded
+	ExpandVirtualMachineVhdPartition(string) (string, bool, error)
+
+	// Mounts the first vhd of the virtual machine on the host, returning the
+	// vhd path and the root of the given partition. The vhd path may be
+	// returned along with an error, in which case the vhd is mounted and
+	// must still be dismounted.
+	MountVirtualMachineVhd(string, uint) (string, string, error)
+
+	CopyFileToMountedVhd(string, string) error
+
+	// Adds drivers to the offline Windows image rooted at the given path
+	AddDriverToMountedVhd(string, string, bool) error
+
+	DismountVhd(string) error
+
+	// Copies a file or directory from the host into the running guest
+	// through the Guest Service Interface
+	CopyFileToVirtualMachine(string, string, string) error
+
 	DeleteVirtualMachine(string) error
 
+	// Returns a PNG image of the VM console
+	GetVirtualMachineScreenshot(string) ([]byte, error)
+
 	GetVirtualMachineGeneration(string) (uint, error)
 
 	SetVirtualMachineCpuCount(string, uint) error
 
+	// Sets the startup memory in bytes, and enables or disables dynamic
+	// memory with its limits, in a single change. A zero startup memory or
+	// a nil dynamic memory switch leaves that setting unchanged.
+	SetVirtualMachineMemory(string, int64, *bool, hyperv.DynamicMemory) error
+
+	SetVirtualMachineNotes(string, string) error
+
+	// Applies reservation, limits, weight and topology settings of the
+	// virtual processors
+	SetVirtualMachineProcessor(string, hyperv.ProcessorSettings) error
+
 	SetVirtualMachineMacSpoofing(string, bool) error
 
-	SetVirtualMachineDynamicMemory(string, bool) error
+	// Connects a COM port of the VM to a named pipe, or disconnects it if
+	// the pipe path is empty
+	SetVirtualMachineComPort(string, uint, string) error
+
+	// Sets the type of checkpoints taken of the VM: Standard, Production
+	// or ProductionOnly
+	SetVirtualMachineCheckpointType(string, string) error
+
+	// Takes a checkpoint of the VM with the given name
+	CreateVirtualMachineCheckpoint(string, string) error
+
+	// Removes the named checkpoint of the VM, merging its disks
+	RemoveVirtualMachineCheckpoint(string, string) error
+
+	SetVirtualMachineDynamicMemory(string, bool, hyperv.DynamicMemory) error
 
 	SetVirtualMachineSecureBoot(string, bool, string) error
 
@@ -107,6 +204,8 @@ type Driver interface {
 
 	EnableVirtualMachineIntegrationService(string, string) error
 
+	DisableVirtualMachineIntegrationService(string, string) error
+
 	ExportVirtualMachine(string, string) error
 
 	PreserveLegacyExportBehaviour(string, string) error
@@ -131,6 +230,10 @@ type Driver interface {
 
 	DeleteDvdDrive(string, uint, uint) error
 
+	// Removes the DVD drives of the VM without media, returning how many
+	// were removed
+	DeleteEmptyDvdDrives(string) (uint, error)
+
 	MountFloppyDrive(string, string) error
 
 	UnmountFloppyDrive(string) error
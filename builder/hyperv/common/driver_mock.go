@@ -5,6 +5,9 @@ package common
 
 import (
 	"context"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/powershell/hyperv"
 )
 
 type DriverMock struct {
@@ -31,6 +34,10 @@ type DriverMock struct {
 	Stop_VmName string
 	Stop_Err    error
 
+	TurnOff_Called bool
+	TurnOff_VmName string
+	TurnOff_Err    error
+
 	Verify_Called bool
 	Verify_Err    error
 
@@ -59,15 +66,40 @@ type DriverMock struct {
 	GetVMId_Return string
 	GetVMId_Err    error
 
+	RegisterHvsockService_Called    bool
+	RegisterHvsockService_ServiceId string
+	RegisterHvsockService_Name      string
+	RegisterHvsockService_Err       error
+
+	GetGuestIntrinsicKvpItems_Called bool
+	GetGuestIntrinsicKvpItems_VmName string
+	GetGuestIntrinsicKvpItems_Return map[string]string
+	GetGuestIntrinsicKvpItems_Err    error
+
+	GetGuestKvpItems_Called bool
+	GetGuestKvpItems_VmName string
+	GetGuestKvpItems_Return map[string]string
+	GetGuestKvpItems_Err    error
+
+	SetHostKvpItem_Called bool
+	SetHostKvpItem_VmName string
+	SetHostKvpItem_Items  map[string]string
+	SetHostKvpItem_Err    error
+
+	RemoveHostKvpItem_Called bool
+	RemoveHostKvpItem_VmName string
+	RemoveHostKvpItem_Names  []string
+	RemoveHostKvpItem_Err    error
+
 	GetHostAdapterIpAddressForSwitch_Called     bool
 	GetHostAdapterIpAddressForSwitch_SwitchName string
 	GetHostAdapterIpAddressForSwitch_Return     string
 	GetHostAdapterIpAddressForSwitch_Err        error
 
-	TypeScanCodes_Called    bool
-	TypeScanCodes_VmName    string
-	TypeScanCodes_ScanCodes string
-	TypeScanCodes_Err       error
+	OpenKeyboard_Called bool
+	OpenKeyboard_VmName string
+	OpenKeyboard_Return *KeyboardMock
+	OpenKeyboard_Err    error
 
 	GetVirtualMachineNetworkAdapterAddress_Called bool
 	GetVirtualMachineNetworkAdapterAddress_VmName string
@@ -89,6 +121,18 @@ type DriverMock struct {
 	SetVmNetworkAdapterMacAddress_Mac    string
 	SetVmNetworkAdapterMacAddress_Err    error
 
+	SetVmNetworkAdapterDynamicMacAddress_Called bool
+	SetVmNetworkAdapterDynamicMacAddress_VmName string
+	SetVmNetworkAdapterDynamicMacAddress_Err    error
+
+	DisconnectVirtualMachineNetworkAdapter_Called bool
+	DisconnectVirtualMachineNetworkAdapter_VmName string
+	DisconnectVirtualMachineNetworkAdapter_Err    error
+
+	UntagVirtualMachineVlan_Called bool
+	UntagVirtualMachineVlan_VmName string
+	UntagVirtualMachineVlan_Err    error
+
 	SetVirtualMachineVlanId_Called bool
 	SetVirtualMachineVlanId_VmName string
 	SetVirtualMachineVlanId_VlanId string
@@ -127,14 +171,15 @@ type DriverMock struct {
 	CreateVirtualSwitch_Return     bool
 	CreateVirtualSwitch_Err        error
 
-	AddVirtualMachineHardDrive_Called         bool
-	AddVirtualMachineHardDrive_VmName         string
-	AddVirtualMachineHardDrive_VhdFile        string
-	AddVirtualMachineHardDrive_VhdName        string
-	AddVirtualMachineHardDrive_VhdSizeBytes   int64
-	AddVirtualMachineHardDrive_VhdBlockSize   int64
-	AddVirtualMachineHardDrive_ControllerType string
-	AddVirtualMachineHardDrive_Err            error
+	AddVirtualMachineHardDrive_Called          bool
+	AddVirtualMachineHardDrive_VmName          string
+	AddVirtualMachineHardDrive_VhdFile         string
+	AddVirtualMachineHardDrive_VhdName         string
+	AddVirtualMachineHardDrive_VhdSizeBytes    int64
+	AddVirtualMachineHardDrive_VhdBlockSize    int64
+	AddVirtualMachineHardDrive_ControllerType  string
+	AddVirtualMachineHardDrive_DiskPerformance hyperv.DiskPerformance
+	AddVirtualMachineHardDrive_Err             error
 
 	CreateVirtualMachine_Called           bool
 	CreateVirtualMachine_VmName           string
@@ -148,6 +193,8 @@ type DriverMock struct {
 	CreateVirtualMachine_DifferentialDisk bool
 	CreateVirtualMachine_FixedVHD         bool
 	CreateVirtualMachine_Version          string
+	CreateVirtualMachine_DiskPerformance  hyperv.DiskPerformance
+	CreateVirtualMachine_StoragePaths     hyperv.StoragePaths
 	CreateVirtualMachine_Err              error
 
 	CloneVirtualMachine_Called                bool
@@ -160,7 +207,7 @@ type DriverMock struct {
 	CloneVirtualMachine_HarddrivePath         string
 	CloneVirtualMachine_Ram                   int64
 	CloneVirtualMachine_SwitchName            string
-	CloneVirtualMachine_Copy                  bool
+	CloneVirtualMachine_StoragePaths          hyperv.StoragePaths
 	CloneVirtualMachine_Err                   error
 
 	ResizeVirtualMachineVhd_Called         bool
@@ -168,6 +215,50 @@ type DriverMock struct {
 	ResizeVirtualMachineVhd_newSizeInBytes uint64
 	ResizeVirtualMachineVhd_Err            error
 
+	GetVirtualMachineVhdSize_Called bool
+	GetVirtualMachineVhdSize_VmName string
+	GetVirtualMachineVhdSize_Return uint64
+	GetVirtualMachineVhdSize_Err    error
+
+	ExpandVirtualMachineVhdPartition_Called     bool
+	ExpandVirtualMachineVhdPartition_VmName     string
+	ExpandVirtualMachineVhdPartition_FileSystem string
+	ExpandVirtualMachineVhdPartition_Expanded   bool
+	ExpandVirtualMachineVhdPartition_Err        error
+
+	MountVirtualMachineVhd_Called          bool
+	MountVirtualMachineVhd_VmName          string
+	MountVirtualMachineVhd_PartitionNumber uint
+	MountVirtualMachineVhd_VhdPath         string
+	MountVirtualMachineVhd_Root            string
+	MountVirtualMachineVhd_Err             error
+
+	CopyFileToMountedVhd_Called       bool
+	CopyFileToMountedVhd_Sources      []string
+	CopyFileToMountedVhd_Destinations []string
+	CopyFileToMountedVhd_Err          error
+
+	AddDriverToMountedVhd_Called        bool
+	AddDriverToMountedVhd_Root          string
+	AddDriverToMountedVhd_Drivers       []string
+	AddDriverToMountedVhd_ForceUnsigned bool
+	AddDriverToMountedVhd_Err           error
+
+	DismountVhd_Called  bool
+	DismountVhd_VhdPath string
+	DismountVhd_Err     error
+
+	CopyFileToVirtualMachine_Called      bool
+	CopyFileToVirtualMachine_VmName      string
+	CopyFileToVirtualMachine_Source      string
+	CopyFileToVirtualMachine_Destination string
+	CopyFileToVirtualMachine_Err         error
+
+	GetVirtualMachineScreenshot_Called bool
+	GetVirtualMachineScreenshot_VmName string
+	GetVirtualMachineScreenshot_Return []byte
+	GetVirtualMachineScreenshot_Err    error
+
 	DeleteVirtualMachine_Called bool
 	DeleteVirtualMachine_VmName string
 	DeleteVirtualMachine_Err    error
@@ -177,15 +268,54 @@ type DriverMock struct {
 	SetVirtualMachineCpuCount_Cpu    uint
 	SetVirtualMachineCpuCount_Err    error
 
+	SetVirtualMachineMemory_Called              bool
+	SetVirtualMachineMemory_VmName              string
+	SetVirtualMachineMemory_RamBytes            int64
+	SetVirtualMachineMemory_EnableDynamicMemory *bool
+	SetVirtualMachineMemory_DynamicMemory       hyperv.DynamicMemory
+	SetVirtualMachineMemory_Err                 error
+
+	SetVirtualMachineNotes_Called bool
+	SetVirtualMachineNotes_VmName string
+	SetVirtualMachineNotes_Notes  string
+	SetVirtualMachineNotes_Err    error
+
+	SetVirtualMachineProcessor_Called   bool
+	SetVirtualMachineProcessor_VmName   string
+	SetVirtualMachineProcessor_Settings hyperv.ProcessorSettings
+	SetVirtualMachineProcessor_Err      error
+
 	SetVirtualMachineMacSpoofing_Called bool
 	SetVirtualMachineMacSpoofing_VmName string
 	SetVirtualMachineMacSpoofing_Enable bool
 	SetVirtualMachineMacSpoofing_Err    error
 
-	SetVirtualMachineDynamicMemory_Called bool
-	SetVirtualMachineDynamicMemory_VmName string
-	SetVirtualMachineDynamicMemory_Enable bool
-	SetVirtualMachineDynamicMemory_Err    error
+	SetVirtualMachineComPort_Called bool
+	SetVirtualMachineComPort_VmName string
+	SetVirtualMachineComPort_Number uint
+	SetVirtualMachineComPort_Path   string
+	SetVirtualMachineComPort_Err    error
+
+	SetVirtualMachineCheckpointType_Called bool
+	SetVirtualMachineCheckpointType_VmName string
+	SetVirtualMachineCheckpointType_Type   string
+	SetVirtualMachineCheckpointType_Err    error
+
+	CreateVirtualMachineCheckpoint_Called bool
+	CreateVirtualMachineCheckpoint_VmName string
+	CreateVirtualMachineCheckpoint_Names  []string
+	CreateVirtualMachineCheckpoint_Err    error
+
+	RemoveVirtualMachineCheckpoint_Called bool
+	RemoveVirtualMachineCheckpoint_VmName string
+	RemoveVirtualMachineCheckpoint_Names  []string
+	RemoveVirtualMachineCheckpoint_Err    error
+
+	SetVirtualMachineDynamicMemory_Called        bool
+	SetVirtualMachineDynamicMemory_VmName        string
+	SetVirtualMachineDynamicMemory_Enable        bool
+	SetVirtualMachineDynamicMemory_DynamicMemory hyperv.DynamicMemory
+	SetVirtualMachineDynamicMemory_Err           error
 
 	SetVirtualMachineSecureBoot_Called       bool
 	SetVirtualMachineSecureBoot_VmName       string
@@ -208,6 +338,11 @@ type DriverMock struct {
 	EnableVirtualMachineIntegrationService_IntegrationServiceName string
 	EnableVirtualMachineIntegrationService_Err                    error
 
+	DisableVirtualMachineIntegrationService_Called                 bool
+	DisableVirtualMachineIntegrationService_VmName                 string
+	DisableVirtualMachineIntegrationService_IntegrationServiceName string
+	DisableVirtualMachineIntegrationService_Err                    error
+
 	ExportVirtualMachine_Called bool
 	ExportVirtualMachine_VmName string
 	ExportVirtualMachine_Path   string
@@ -279,6 +414,11 @@ type DriverMock struct {
 	DeleteDvdDrive_ControllerLocation uint
 	DeleteDvdDrive_Err                error
 
+	DeleteEmptyDvdDrives_Called bool
+	DeleteEmptyDvdDrives_VmName string
+	DeleteEmptyDvdDrives_Return uint
+	DeleteEmptyDvdDrives_Err    error
+
 	MountFloppyDrive_Called bool
 	MountFloppyDrive_VmName string
 	MountFloppyDrive_Path   string
@@ -327,6 +467,12 @@ func (d *DriverMock) Stop(vmName string) error {
 	return d.Stop_Err
 }
 
+func (d *DriverMock) TurnOff(vmName string) error {
+	d.TurnOff_Called = true
+	d.TurnOff_VmName = vmName
+	return d.TurnOff_Err
+}
+
 func (d *DriverMock) Verify() error {
 	d.Verify_Called = true
 	return d.Verify_Err
@@ -362,17 +508,58 @@ func (d *DriverMock) GetVMId(vmName string) (string, error) {
 	return d.GetVMId_Return, d.GetVMId_Err
 }
 
+func (d *DriverMock) RegisterHvsockService(serviceId string, name string) error {
+	d.RegisterHvsockService_Called = true
+	d.RegisterHvsockService_ServiceId = serviceId
+	d.RegisterHvsockService_Name = name
+	return d.RegisterHvsockService_Err
+}
+
+func (d *DriverMock) GetGuestIntrinsicKvpItems(vmName string) (map[string]string, error) {
+	d.GetGuestIntrinsicKvpItems_Called = true
+	d.GetGuestIntrinsicKvpItems_VmName = vmName
+	return d.GetGuestIntrinsicKvpItems_Return, d.GetGuestIntrinsicKvpItems_Err
+}
+
+func (d *DriverMock) GetGuestKvpItems(vmName string) (map[string]string, error) {
+	d.GetGuestKvpItems_Called = true
+	d.GetGuestKvpItems_VmName = vmName
+	return d.GetGuestKvpItems_Return, d.GetGuestKvpItems_Err
+}
+
+func (d *DriverMock) SetHostKvpItem(vmName string, name string, data string) error {
+	d.SetHostKvpItem_Called = true
+	d.SetHostKvpItem_VmName = vmName
+	if d.SetHostKvpItem_Items == nil {
+		d.SetHostKvpItem_Items = map[string]string{}
+	}
+	d.SetHostKvpItem_Items[name] = data
+	return d.SetHostKvpItem_Err
+}
+
+func (d *DriverMock) RemoveHostKvpItem(vmName string, name string) error {
+	d.RemoveHostKvpItem_Called = true
+	d.RemoveHostKvpItem_VmName = vmName
+	d.RemoveHostKvpItem_Names = append(d.RemoveHostKvpItem_Names, name)
+	return d.RemoveHostKvpItem_Err
+}
+
 func (d *DriverMock) GetHostAdapterIpAddressForSwitch(switchName string) (string, error) {
 	d.GetHostAdapterIpAddressForSwitch_Called = true
 	d.GetHostAdapterIpAddressForSwitch_SwitchName = switchName
 	return d.GetHostAdapterIpAddressForSwitch_Return, d.GetHostAdapterIpAddressForSwitch_Err
 }
 
-func (d *DriverMock) TypeScanCodes(vmName string, scanCodes string) error {
-	d.TypeScanCodes_Called = true
-	d.TypeScanCodes_VmName = vmName
-	d.TypeScanCodes_ScanCodes = scanCodes
-	return d.TypeScanCodes_Err
+func (d *DriverMock) OpenKeyboard(ctx context.Context, vmName string) (Keyboard, error) {
+	d.OpenKeyboard_Called = true
+	d.OpenKeyboard_VmName = vmName
+	if d.OpenKeyboard_Err != nil {
+		return nil, d.OpenKeyboard_Err
+	}
+	if d.OpenKeyboard_Return == nil {
+		d.OpenKeyboard_Return = new(KeyboardMock)
+	}
+	return d.OpenKeyboard_Return, nil
 }
 
 func (d *DriverMock) GetVirtualMachineNetworkAdapterAddress(vmName string) (string, error) {
@@ -402,6 +589,24 @@ func (d *DriverMock) SetVmNetworkAdapterMacAddress(vmName string, mac string) er
 	return d.SetVmNetworkAdapterMacAddress_Err
 }
 
+func (d *DriverMock) SetVmNetworkAdapterDynamicMacAddress(vmName string) error {
+	d.SetVmNetworkAdapterDynamicMacAddress_Called = true
+	d.SetVmNetworkAdapterDynamicMacAddress_VmName = vmName
+	return d.SetVmNetworkAdapterDynamicMacAddress_Err
+}
+
+func (d *DriverMock) DisconnectVirtualMachineNetworkAdapter(vmName string) error {
+	d.DisconnectVirtualMachineNetworkAdapter_Called = true
+	d.DisconnectVirtualMachineNetworkAdapter_VmName = vmName
+	return d.DisconnectVirtualMachineNetworkAdapter_Err
+}
+
+func (d *DriverMock) UntagVirtualMachineVlan(vmName string) error {
+	d.UntagVirtualMachineVlan_Called = true
+	d.UntagVirtualMachineVlan_VmName = vmName
+	return d.UntagVirtualMachineVlan_Err
+}
+
 func (d *DriverMock) SetVirtualMachineVlanId(vmName string, vlanId string) error {
 	d.SetVirtualMachineVlanId_Called = true
 	d.SetVirtualMachineVlanId_VmName = vmName
@@ -450,7 +655,7 @@ func (d *DriverMock) CreateVirtualSwitch(switchName string, switchType string) (
 }
 
 func (d *DriverMock) AddVirtualMachineHardDrive(vmName string, vhdFile string, vhdName string,
-	vhdSizeBytes int64, vhdDiskBlockSize int64, controllerType string) error {
+	vhdSizeBytes int64, vhdDiskBlockSize int64, controllerType string, diskPerformance hyperv.DiskPerformance) error {
 	d.AddVirtualMachineHardDrive_Called = true
 	d.AddVirtualMachineHardDrive_VmName = vmName
 	d.AddVirtualMachineHardDrive_VhdFile = vhdFile
@@ -458,6 +663,7 @@ func (d *DriverMock) AddVirtualMachineHardDrive(vmName string, vhdFile string, v
 	d.AddVirtualMachineHardDrive_VhdSizeBytes = vhdSizeBytes
 	d.AddVirtualMachineHardDrive_VhdSizeBytes = vhdDiskBlockSize
 	d.AddVirtualMachineHardDrive_ControllerType = controllerType
+	d.AddVirtualMachineHardDrive_DiskPerformance = diskPerformance
 	return d.AddVirtualMachineHardDrive_Err
 }
 
@@ -468,7 +674,8 @@ func (d *DriverMock) CheckVMName(vmName string) error {
 
 func (d *DriverMock) CreateVirtualMachine(vmName string, path string, harddrivePath string,
 	ram int64, diskSize int64, diskBlockSize int64, switchName string, generation uint,
-	diffDisks bool, fixedVHD bool, version string) error {
+	diffDisks bool, fixedVHD bool, version string, diskPerformance hyperv.DiskPerformance,
+	storagePaths hyperv.StoragePaths) error {
 	d.CreateVirtualMachine_Called = true
 	d.CreateVirtualMachine_VmName = vmName
 	d.CreateVirtualMachine_Path = path
@@ -480,12 +687,14 @@ func (d *DriverMock) CreateVirtualMachine(vmName string, path string, harddriveP
 	d.CreateVirtualMachine_Generation = generation
 	d.CreateVirtualMachine_DifferentialDisk = diffDisks
 	d.CreateVirtualMachine_Version = version
+	d.CreateVirtualMachine_DiskPerformance = diskPerformance
+	d.CreateVirtualMachine_StoragePaths = storagePaths
 	return d.CreateVirtualMachine_Err
 }
 
 func (d *DriverMock) CloneVirtualMachine(cloneFromVmcxPath string, cloneFromVmName string,
 	cloneFromSnapshotName string, cloneAllSnapshots bool, vmName string, path string,
-	harddrivePath string, ram int64, switchName string, copyTF bool) error {
+	harddrivePath string, ram int64, switchName string, storagePaths hyperv.StoragePaths) error {
 	d.CloneVirtualMachine_Called = true
 	d.CloneVirtualMachine_CloneFromVmcxPath = cloneFromVmcxPath
 	d.CloneVirtualMachine_CloneFromVmName = cloneFromVmName
@@ -496,7 +705,7 @@ func (d *DriverMock) CloneVirtualMachine(cloneFromVmcxPath string, cloneFromVmNa
 	d.CloneVirtualMachine_HarddrivePath = harddrivePath
 	d.CloneVirtualMachine_Ram = ram
 	d.CloneVirtualMachine_SwitchName = switchName
-	d.CloneVirtualMachine_Copy = copyTF
+	d.CloneVirtualMachine_StoragePaths = storagePaths
 
 	return d.CloneVirtualMachine_Err
 }
@@ -509,6 +718,61 @@ func (d *DriverMock) ResizeVirtualMachineVhd(vmName string, newSizeInBytes uint6
 	return d.ResizeVirtualMachineVhd_Err
 }
 
+func (d *DriverMock) GetVirtualMachineVhdSize(vmName string) (uint64, error) {
+	d.GetVirtualMachineVhdSize_Called = true
+	d.GetVirtualMachineVhdSize_VmName = vmName
+	return d.GetVirtualMachineVhdSize_Return, d.GetVirtualMachineVhdSize_Err
+}
+
+func (d *DriverMock) ExpandVirtualMachineVhdPartition(vmName string) (string, bool, error) {
+	d.ExpandVirtualMachineVhdPartition_Called = true
+	d.ExpandVirtualMachineVhdPartition_VmName = vmName
+	return d.ExpandVirtualMachineVhdPartition_FileSystem, d.ExpandVirtualMachineVhdPartition_Expanded,
+		d.ExpandVirtualMachineVhdPartition_Err
+}
+
+func (d *DriverMock) MountVirtualMachineVhd(vmName string, partitionNumber uint) (string, string, error) {
+	d.MountVirtualMachineVhd_Called = true
+	d.MountVirtualMachineVhd_VmName = vmName
+	d.MountVirtualMachineVhd_PartitionNumber = partitionNumber
+	return d.MountVirtualMachineVhd_VhdPath, d.MountVirtualMachineVhd_Root, d.MountVirtualMachineVhd_Err
+}
+
+func (d *DriverMock) CopyFileToMountedVhd(source string, destination string) error {
+	d.CopyFileToMountedVhd_Called = true
+	d.CopyFileToMountedVhd_Sources = append(d.CopyFileToMountedVhd_Sources, source)
+	d.CopyFileToMountedVhd_Destinations = append(d.CopyFileToMountedVhd_Destinations, destination)
+	return d.CopyFileToMountedVhd_Err
+}
+
+func (d *DriverMock) AddDriverToMountedVhd(root string, driver string, forceUnsigned bool) error {
+	d.AddDriverToMountedVhd_Called = true
+	d.AddDriverToMountedVhd_Root = root
+	d.AddDriverToMountedVhd_Drivers = append(d.AddDriverToMountedVhd_Drivers, driver)
+	d.AddDriverToMountedVhd_ForceUnsigned = forceUnsigned
+	return d.AddDriverToMountedVhd_Err
+}
+
+func (d *DriverMock) DismountVhd(vhdPath string) error {
+	d.DismountVhd_Called = true
+	d.DismountVhd_VhdPath = vhdPath
+	return d.DismountVhd_Err
+}
+
+func (d *DriverMock) CopyFileToVirtualMachine(vmName string, source string, destination string) error {
+	d.CopyFileToVirtualMachine_Called = true
+	d.CopyFileToVirtualMachine_VmName = vmName
+	d.CopyFileToVirtualMachine_Source = source
+	d.CopyFileToVirtualMachine_Destination = destination
+	return d.CopyFileToVirtualMachine_Err
+}
+
+func (d *DriverMock) GetVirtualMachineScreenshot(vmName string) ([]byte, error) {
+	d.GetVirtualMachineScreenshot_Called = true
+	d.GetVirtualMachineScreenshot_VmName = vmName
+	return d.GetVirtualMachineScreenshot_Return, d.GetVirtualMachineScreenshot_Err
+}
+
 func (d *DriverMock) DeleteVirtualMachine(vmName string) error {
 	d.DeleteVirtualMachine_Called = true
 	d.DeleteVirtualMachine_VmName = vmName
@@ -522,6 +786,30 @@ func (d *DriverMock) SetVirtualMachineCpuCount(vmName string, cpu uint) error {
 	return d.SetVirtualMachineCpuCount_Err
 }
 
+func (d *DriverMock) SetVirtualMachineMemory(vmName string, ramBytes int64, enableDynamicMemory *bool,
+	dynamicMemory hyperv.DynamicMemory) error {
+	d.SetVirtualMachineMemory_Called = true
+	d.SetVirtualMachineMemory_VmName = vmName
+	d.SetVirtualMachineMemory_RamBytes = ramBytes
+	d.SetVirtualMachineMemory_EnableDynamicMemory = enableDynamicMemory
+	d.SetVirtualMachineMemory_DynamicMemory = dynamicMemory
+	return d.SetVirtualMachineMemory_Err
+}
+
+func (d *DriverMock) SetVirtualMachineNotes(vmName string, notes string) error {
+	d.SetVirtualMachineNotes_Called = true
+	d.SetVirtualMachineNotes_VmName = vmName
+	d.SetVirtualMachineNotes_Notes = notes
+	return d.SetVirtualMachineNotes_Err
+}
+
+func (d *DriverMock) SetVirtualMachineProcessor(vmName string, settings hyperv.ProcessorSettings) error {
+	d.SetVirtualMachineProcessor_Called = true
+	d.SetVirtualMachineProcessor_VmName = vmName
+	d.SetVirtualMachineProcessor_Settings = settings
+	return d.SetVirtualMachineProcessor_Err
+}
+
 func (d *DriverMock) SetVirtualMachineMacSpoofing(vmName string, enable bool) error {
 	d.SetVirtualMachineMacSpoofing_Called = true
 	d.SetVirtualMachineMacSpoofing_VmName = vmName
@@ -529,10 +817,41 @@ func (d *DriverMock) SetVirtualMachineMacSpoofing(vmName string, enable bool) er
 	return d.SetVirtualMachineMacSpoofing_Err
 }
 
-func (d *DriverMock) SetVirtualMachineDynamicMemory(vmName string, enable bool) error {
+func (d *DriverMock) SetVirtualMachineComPort(vmName string, number uint, path string) error {
+	d.SetVirtualMachineComPort_Called = true
+	d.SetVirtualMachineComPort_VmName = vmName
+	d.SetVirtualMachineComPort_Number = number
+	d.SetVirtualMachineComPort_Path = path
+	return d.SetVirtualMachineComPort_Err
+}
+
+func (d *DriverMock) SetVirtualMachineCheckpointType(vmName string, checkpointType string) error {
+	d.SetVirtualMachineCheckpointType_Called = true
+	d.SetVirtualMachineCheckpointType_VmName = vmName
+	d.SetVirtualMachineCheckpointType_Type = checkpointType
+	return d.SetVirtualMachineCheckpointType_Err
+}
+
+func (d *DriverMock) CreateVirtualMachineCheckpoint(vmName string, name string) error {
+	d.CreateVirtualMachineCheckpoint_Called = true
+	d.CreateVirtualMachineCheckpoint_VmName = vmName
+	d.CreateVirtualMachineCheckpoint_Names = append(d.CreateVirtualMachineCheckpoint_Names, name)
+	return d.CreateVirtualMachineCheckpoint_Err
+}
+
+func (d *DriverMock) RemoveVirtualMachineCheckpoint(vmName string, name string) error {
+	d.RemoveVirtualMachineCheckpoint_Called = true
+	d.RemoveVirtualMachineCheckpoint_VmName = vmName
+	d.RemoveVirtualMachineCheckpoint_Names = append(d.RemoveVirtualMachineCheckpoint_Names, name)
+	return d.RemoveVirtualMachineCheckpoint_Err
+}
+
+func (d *DriverMock) SetVirtualMachineDynamicMemory(vmName string, enable bool,
+	dynamicMemory hyperv.DynamicMemory) error {
 	d.SetVirtualMachineDynamicMemory_Called = true
 	d.SetVirtualMachineDynamicMemory_VmName = vmName
 	d.SetVirtualMachineDynamicMemory_Enable = enable
+	d.SetVirtualMachineDynamicMemory_DynamicMemory = dynamicMemory
 	return d.SetVirtualMachineDynamicMemory_Err
 }
 
@@ -565,6 +884,13 @@ func (d *DriverMock) EnableVirtualMachineIntegrationService(vmName string, integ
 	return d.EnableVirtualMachineIntegrationService_Err
 }
 
+func (d *DriverMock) DisableVirtualMachineIntegrationService(vmName string, integrationServiceName string) error {
+	d.DisableVirtualMachineIntegrationService_Called = true
+	d.DisableVirtualMachineIntegrationService_VmName = vmName
+	d.DisableVirtualMachineIntegrationService_IntegrationServiceName = integrationServiceName
+	return d.DisableVirtualMachineIntegrationService_Err
+}
+
 func (d *DriverMock) ExportVirtualMachine(vmName string, path string) error {
 	d.ExportVirtualMachine_Called = true
 	d.ExportVirtualMachine_VmName = vmName
@@ -661,6 +987,12 @@ func (d *DriverMock) DeleteDvdDrive(vmName string, controllerNumber uint, contro
 	return d.DeleteDvdDrive_Err
 }
 
+func (d *DriverMock) DeleteEmptyDvdDrives(vmName string) (uint, error) {
+	d.DeleteEmptyDvdDrives_Called = true
+	d.DeleteEmptyDvdDrives_VmName = vmName
+	return d.DeleteEmptyDvdDrives_Return, d.DeleteEmptyDvdDrives_Err
+}
+
 func (d *DriverMock) MountFloppyDrive(vmName string, path string) error {
 	d.MountFloppyDrive_Called = true
 	d.MountFloppyDrive_VmName = vmName
@@ -684,3 +1016,28 @@ func (d *DriverMock) Disconnect(cancel context.CancelFunc) {
 	d.Disconnect_Called = true
 	d.Disconnect_Cancel = cancel
 }
+
+type KeyboardMock struct {
+	// Everything typed in order, as "text:<text>" or "scancodes:<codes>"
+	Typed []string
+
+	TypeText_Err      error
+	TypeScanCodes_Err error
+
+	Close_Called bool
+}
+
+func (k *KeyboardMock) TypeText(text string) error {
+	k.Typed = append(k.Typed, "text:"+text)
+	return k.TypeText_Err
+}
+
+func (k *KeyboardMock) TypeScanCodes(scanCodes []string) error {
+	k.Typed = append(k.Typed, "scancodes:"+strings.Join(scanCodes, " "))
+	return k.TypeScanCodes_Err
+}
+
+func (k *KeyboardMock) Close() error {
+	k.Close_Called = true
+	return nil
+}
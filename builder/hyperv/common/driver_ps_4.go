@@ -57,6 +57,12 @@ func (d *HypervPS4Driver) Stop(vmName string) error {
 	return hyperv.StopVirtualMachine(vmName)
 }
 
+// TurnOff powers off a VM specified by the name given, without shutting down
+// the guest OS.
+func (d *HypervPS4Driver) TurnOff(vmName string) error {
+	return hyperv.TurnOff(vmName)
+}
+
 func (d *HypervPS4Driver) Verify() error {
 
 	if err := d.verifyPSVersion(); err != nil {
@@ -119,6 +125,26 @@ func (d *HypervPS4Driver) GetVMId(vmName string) (string, error) {
 	return hyperv.GetVMId(vmName)
 }
 
+func (d *HypervPS4Driver) RegisterHvsockService(serviceId string, name string) error {
+	return hyperv.RegisterHvsockService(serviceId, name)
+}
+
+func (d *HypervPS4Driver) GetGuestIntrinsicKvpItems(vmName string) (map[string]string, error) {
+	return hyperv.GetGuestIntrinsicKvpItems(vmName)
+}
+
+func (d *HypervPS4Driver) GetGuestKvpItems(vmName string) (map[string]string, error) {
+	return hyperv.GetGuestKvpItems(vmName)
+}
+
+func (d *HypervPS4Driver) SetHostKvpItem(vmName string, name string, data string) error {
+	return hyperv.SetHostKvpItem(vmName, name, data)
+}
+
+func (d *HypervPS4Driver) RemoveHostKvpItem(vmName string, name string) error {
+	return hyperv.RemoveHostKvpItem(vmName, name)
+}
+
 // Finds the IP address of a host adapter connected to switch
 func (d *HypervPS4Driver) GetHostAdapterIpAddressForSwitch(switchName string) (string, error) {
 	res, err := hyperv.GetHostAdapterIpAddressForSwitch(switchName)
@@ -134,9 +160,13 @@ func (d *HypervPS4Driver) GetHostAdapterIpAddressForSwitch(switchName string) (s
 	return res, err
 }
 
-// Type scan codes to virtual keyboard of vm
-func (d *HypervPS4Driver) TypeScanCodes(vmName string, scanCodes string) error {
-	return hyperv.TypeScanCodes(vmName, scanCodes)
+// Open the virtual keyboard of vm for typing
+func (d *HypervPS4Driver) OpenKeyboard(ctx context.Context, vmName string) (Keyboard, error) {
+	keyboard, err := hyperv.OpenKeyboardSession(ctx, vmName)
+	if err != nil {
+		return nil, err
+	}
+	return keyboard, nil
 }
 
 // Get network adapter address
@@ -158,6 +188,18 @@ func (d *HypervPS4Driver) SetVmNetworkAdapterMacAddress(vmName string, mac strin
 	return hyperv.SetVmNetworkAdapterMacAddress(vmName, mac)
 }
 
+func (d *HypervPS4Driver) SetVmNetworkAdapterDynamicMacAddress(vmName string) error {
+	return hyperv.SetVmNetworkAdapterDynamicMacAddress(vmName)
+}
+
+func (d *HypervPS4Driver) DisconnectVirtualMachineNetworkAdapter(vmName string) error {
+	return hyperv.DisconnectVirtualMachineNetworkAdapter(vmName)
+}
+
+func (d *HypervPS4Driver) UntagVirtualMachineVlan(vmName string) error {
+	return hyperv.UntagVirtualMachineVlan(vmName)
+}
+
 // Replace the network adapter with a (non-)legacy adapter
 func (d *HypervPS4Driver) ReplaceVirtualMachineNetworkAdapter(vmName string, virtual bool) error {
 	return hyperv.ReplaceVirtualMachineNetworkAdapter(vmName, virtual)
@@ -188,9 +230,9 @@ func (d *HypervPS4Driver) CreateVirtualSwitch(switchName string, switchType stri
 }
 
 func (d *HypervPS4Driver) AddVirtualMachineHardDrive(vmName string, vhdFile string, vhdName string,
-	vhdSizeBytes int64, diskBlockSize int64, controllerType string) error {
+	vhdSizeBytes int64, diskBlockSize int64, controllerType string, diskPerformance hyperv.DiskPerformance) error {
 	return hyperv.AddVirtualMachineHardDiskDrive(vmName, vhdFile, vhdName, vhdSizeBytes,
-		diskBlockSize, controllerType)
+		diskBlockSize, controllerType, diskPerformance)
 }
 
 func (d *HypervPS4Driver) CheckVMName(vmName string) error {
@@ -199,22 +241,59 @@ func (d *HypervPS4Driver) CheckVMName(vmName string) error {
 
 func (d *HypervPS4Driver) CreateVirtualMachine(vmName string, path string, harddrivePath string, ram int64,
 	diskSize int64, diskBlockSize int64, switchName string, generation uint, diffDisks bool,
-	fixedVHD bool, version string) error {
+	fixedVHD bool, version string, diskPerformance hyperv.DiskPerformance, storagePaths hyperv.StoragePaths) error {
 	return hyperv.CreateVirtualMachine(vmName, path, harddrivePath, ram, diskSize, diskBlockSize, switchName,
-		generation, diffDisks, fixedVHD, version)
+		generation, diffDisks, fixedVHD, version, diskPerformance, storagePaths)
 }
 
 func (d *HypervPS4Driver) CloneVirtualMachine(cloneFromVmcxPath string, cloneFromVmName string,
 	cloneFromSnapshotName string, cloneAllSnapshots bool, vmName string, path string, harddrivePath string,
-	ram int64, switchName string, copyTF bool) error {
+	ram int64, switchName string, storagePaths hyperv.StoragePaths) error {
 	return hyperv.CloneVirtualMachine(cloneFromVmcxPath, cloneFromVmName, cloneFromSnapshotName,
-		cloneAllSnapshots, vmName, path, harddrivePath, ram, switchName, copyTF)
+		cloneAllSnapshots, vmName, path, harddrivePath, ram, switchName, storagePaths)
 }
 
 func (d *HypervPS4Driver) ResizeVirtualMachineVhd(vmName string, newSizeInBytes uint64) error {
 	return hyperv.ResizeVirtualMachineVhd(vmName, newSizeInBytes)
 }
 
+func (d *HypervPS4Driver) GetVirtualMachineVhdSize(vmName string) (uint64, error) {
+	return hyperv.GetVirtualMachineVhdSize(vmName)
+}
+
+func (d *HypervPS4Driver) ExpandVirtualMachineVhdPartition(vmName string) (string, bool, error) {
+	return hyperv.ExpandVirtualMachineVhdPartition(vmName)
+}
+
+func (d *HypervPS4Driver) MountVirtualMachineVhd(vmName string, partitionNumber uint) (string, string, error) {
+	return hyperv.MountVirtualMachineVhd(vmName, partitionNumber)
+}
+
+func (d *HypervPS4Driver) CopyFileToMountedVhd(source string, destination string) error {
+	return hyperv.CopyFileToMountedVhd(source, destination)
+}
+
+func (d *HypervPS4Driver) AddDriverToMountedVhd(root string, driver string, forceUnsigned bool) error {
+	return hyperv.AddDriverToMountedVhd(root, driver, forceUnsigned)
+}
+
+func (d *HypervPS4Driver) DismountVhd(vhdPath string) error {
+	return hyperv.DismountVhd(vhdPath)
+}
+
+func (d *HypervPS4Driver) CopyFileToVirtualMachine(vmName string, source string, destination string) error {
+	return hyperv.CopyFileToVirtualMachine(vmName, source, destination)
+}
+
+func (d *HypervPS4Driver) GetVirtualMachineScreenshot(vmName string) ([]byte, error) {
+	framebuffer, err := hyperv.GetVirtualMachineFramebuffer(vmName)
+	if err != nil {
+		return nil, err
+	}
+
+	return framebuffer.PNG()
+}
+
 func (d *HypervPS4Driver) DeleteVirtualMachine(vmName string) error {
 	return hyperv.DeleteVirtualMachine(vmName)
 }
@@ -223,12 +302,42 @@ func (d *HypervPS4Driver) SetVirtualMachineCpuCount(vmName string, cpu uint) err
 	return hyperv.SetVirtualMachineCpuCount(vmName, cpu)
 }
 
+func (d *HypervPS4Driver) SetVirtualMachineMemory(vmName string, ramBytes int64, enableDynamicMemory *bool,
+	dynamicMemory hyperv.DynamicMemory) error {
+	return hyperv.SetVirtualMachineMemory(vmName, ramBytes, enableDynamicMemory, dynamicMemory)
+}
+
+func (d *HypervPS4Driver) SetVirtualMachineNotes(vmName string, notes string) error {
+	return hyperv.SetVirtualMachineNotes(vmName, notes)
+}
+
+func (d *HypervPS4Driver) SetVirtualMachineProcessor(vmName string, settings hyperv.ProcessorSettings) error {
+	return hyperv.SetVirtualMachineProcessor(vmName, settings)
+}
+
 func (d *HypervPS4Driver) SetVirtualMachineMacSpoofing(vmName string, enable bool) error {
 	return hyperv.SetVirtualMachineMacSpoofing(vmName, enable)
 }
 
-func (d *HypervPS4Driver) SetVirtualMachineDynamicMemory(vmName string, enable bool) error {
-	return hyperv.SetVirtualMachineDynamicMemory(vmName, enable)
+func (d *HypervPS4Driver) SetVirtualMachineComPort(vmName string, number uint, path string) error {
+	return hyperv.SetVirtualMachineComPort(vmName, number, path)
+}
+
+func (d *HypervPS4Driver) SetVirtualMachineCheckpointType(vmName string, checkpointType string) error {
+	return hyperv.SetVirtualMachineCheckpointType(vmName, checkpointType)
+}
+
+func (d *HypervPS4Driver) CreateVirtualMachineCheckpoint(vmName string, name string) error {
+	return hyperv.CreateVirtualMachineCheckpoint(vmName, name)
+}
+
+func (d *HypervPS4Driver) RemoveVirtualMachineCheckpoint(vmName string, name string) error {
+	return hyperv.RemoveVirtualMachineCheckpoint(vmName, name)
+}
+
+func (d *HypervPS4Driver) SetVirtualMachineDynamicMemory(vmName string, enable bool,
+	dynamicMemory hyperv.DynamicMemory) error {
+	return hyperv.SetVirtualMachineDynamicMemory(vmName, enable, dynamicMemory)
 }
 
 func (d *HypervPS4Driver) SetVirtualMachineSecureBoot(vmName string, enable bool, templateName string) error {
@@ -248,6 +357,11 @@ func (d *HypervPS4Driver) EnableVirtualMachineIntegrationService(vmName string,
 	return hyperv.EnableVirtualMachineIntegrationService(vmName, integrationServiceName)
 }
 
+func (d *HypervPS4Driver) DisableVirtualMachineIntegrationService(vmName string,
+	integrationServiceName string) error {
+	return hyperv.DisableVirtualMachineIntegrationService(vmName, integrationServiceName)
+}
+
 func (d *HypervPS4Driver) ExportVirtualMachine(vmName string, path string) error {
 	return hyperv.ExportVirtualMachine(vmName, path)
 }
@@ -299,6 +413,10 @@ func (d *HypervPS4Driver) DeleteDvdDrive(vmName string, controllerNumber uint, c
 	return hyperv.DeleteDvdDrive(vmName, controllerNumber, controllerLocation)
 }
 
+func (d *HypervPS4Driver) DeleteEmptyDvdDrives(vmName string) (uint, error) {
+	return hyperv.DeleteEmptyDvdDrives(vmName)
+}
+
 func (d *HypervPS4Driver) MountFloppyDrive(vmName string, path string) error {
 	return hyperv.MountFloppyDrive(vmName, path)
 }
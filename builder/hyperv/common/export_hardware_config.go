@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type ExportHardwareConfig
+
+package common
+
+import (
+	"fmt"
+)
+
+// ExportHardwareConfig changes the hardware of the VM once it has been shut
+// down after provisioning, so the exported VM differs from the one that was
+// built. This lets a build run with more processors and memory than the
+// template ships with. Unset options leave the hardware of the build.
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	export_hardware {
+//	  cpus                = 2
+//	  memory              = 4096
+//	  switch_name         = "Default Switch"
+//	  dynamic_mac_address = true
+//	  notes               = "Built by Packer"
+//	}
+//
+// ```
+type ExportHardwareConfig struct {
+	// The number of virtual processors of the exported VM.
+	Cpu uint `mapstructure:"cpus" required:"false"`
+	// The startup memory of the exported VM, in megabytes.
+	RamSize uint `mapstructure:"memory" required:"false"`
+	// Enable or disable dynamic memory for the exported VM.
+	EnableDynamicMemory *bool `mapstructure:"enable_dynamic_memory" required:"false"`
+	// The minimum memory of the exported VM, in megabytes. Requires
+	// `enable_dynamic_memory = true`.
+	MemoryMinimum uint `mapstructure:"memory_minimum" required:"false"`
+	// The maximum memory of the exported VM, in megabytes. Requires
+	// `enable_dynamic_memory = true`.
+	MemoryMaximum uint `mapstructure:"memory_maximum" required:"false"`
+	// The switch to connect the network adapters of the exported VM to.
+	SwitchName string `mapstructure:"switch_name" required:"false"`
+	// Disconnect the network adapters of the exported VM from their switch.
+	// This defaults to false.
+	DisconnectNetwork bool `mapstructure:"disconnect_network" required:"false"`
+	// The VLAN ID of the network adapters of the exported VM.
+	VlanId string `mapstructure:"vlan_id" required:"false"`
+	// Remove the VLAN ID of the network adapters of the exported VM. This
+	// defaults to false.
+	DisableVlan bool `mapstructure:"disable_vlan" required:"false"`
+	// Let Hyper-V assign new MAC addresses to the network adapters of the
+	// VMs imported from the export, instead of the static `mac_address` or
+	// the one assigned during the build. This defaults to false.
+	DynamicMacAddress bool `mapstructure:"dynamic_mac_address" required:"false"`
+	// The notes of the exported VM, shown in Hyper-V Manager.
+	Notes string `mapstructure:"notes" required:"false"`
+}
+
+// IsSet reports whether any hardware of the exported VM should change.
+func (c *ExportHardwareConfig) IsSet() bool {
+	return c.Cpu > 0 || c.RamSize > 0 || c.EnableDynamicMemory != nil || c.MemoryMinimum > 0 ||
+		c.MemoryMaximum > 0 || c.SwitchName != "" || c.DisconnectNetwork || c.VlanId != "" ||
+		c.DisableVlan || c.DynamicMacAddress || c.Notes != ""
+}
+
+func (c *ExportHardwareConfig) Prepare() []error {
+	var errs []error
+
+	if c.RamSize > 0 && (c.RamSize < MinRamSize || c.RamSize > MaxRamSize) {
+		errs = append(errs, fmt.Errorf("export_hardware: memory must be between %v MB and %v MB, but defined: %v",
+			MinRamSize, MaxRamSize, c.RamSize))
+	}
+
+	if c.MemoryMinimum > 0 || c.MemoryMaximum > 0 {
+		if c.EnableDynamicMemory == nil || !*c.EnableDynamicMemory {
+			errs = append(errs, fmt.Errorf("export_hardware: memory_minimum and memory_maximum require "+
+				"enable_dynamic_memory = true"))
+		}
+		if c.RamSize > 0 && c.MemoryMinimum > c.RamSize {
+			errs = append(errs, fmt.Errorf("export_hardware: memory_minimum: %v MB must not be greater than "+
+				"memory: %v MB", c.MemoryMinimum, c.RamSize))
+		}
+		if c.MemoryMaximum > 0 && c.MemoryMaximum < c.RamSize {
+			errs = append(errs, fmt.Errorf("export_hardware: memory_maximum: %v MB must not be less than "+
+				"memory: %v MB", c.MemoryMaximum, c.RamSize))
+		}
+		if c.MemoryMaximum > 0 && c.MemoryMinimum > c.MemoryMaximum {
+			errs = append(errs, fmt.Errorf("export_hardware: memory_minimum: %v MB must not be greater than "+
+				"memory_maximum: %v MB", c.MemoryMinimum, c.MemoryMaximum))
+		}
+	}
+
+	if c.SwitchName != "" && c.DisconnectNetwork {
+		errs = append(errs, fmt.Errorf("export_hardware: only one of switch_name and disconnect_network can be set"))
+	}
+	if c.VlanId != "" && c.DisableVlan {
+		errs = append(errs, fmt.Errorf("export_hardware: only one of vlan_id and disable_vlan can be set"))
+	}
+
+	return errs
+}
@@ -0,0 +1,51 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatExportHardwareConfig is an auto-generated flat version of ExportHardwareConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatExportHardwareConfig struct {
+	Cpu                 *uint   `mapstructure:"cpus" required:"false" cty:"cpus" hcl:"cpus"`
+	RamSize             *uint   `mapstructure:"memory" required:"false" cty:"memory" hcl:"memory"`
+	EnableDynamicMemory *bool   `mapstructure:"enable_dynamic_memory" required:"false" cty:"enable_dynamic_memory" hcl:"enable_dynamic_memory"`
+	MemoryMinimum       *uint   `mapstructure:"memory_minimum" required:"false" cty:"memory_minimum" hcl:"memory_minimum"`
+	MemoryMaximum       *uint   `mapstructure:"memory_maximum" required:"false" cty:"memory_maximum" hcl:"memory_maximum"`
+	SwitchName          *string `mapstructure:"switch_name" required:"false" cty:"switch_name" hcl:"switch_name"`
+	DisconnectNetwork   *bool   `mapstructure:"disconnect_network" required:"false" cty:"disconnect_network" hcl:"disconnect_network"`
+	VlanId              *string `mapstructure:"vlan_id" required:"false" cty:"vlan_id" hcl:"vlan_id"`
+	DisableVlan         *bool   `mapstructure:"disable_vlan" required:"false" cty:"disable_vlan" hcl:"disable_vlan"`
+	DynamicMacAddress   *bool   `mapstructure:"dynamic_mac_address" required:"false" cty:"dynamic_mac_address" hcl:"dynamic_mac_address"`
+	Notes               *string `mapstructure:"notes" required:"false" cty:"notes" hcl:"notes"`
+}
+
+// FlatMapstructure returns a new FlatExportHardwareConfig.
+// FlatExportHardwareConfig is an auto-generated flat version of ExportHardwareConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*ExportHardwareConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatExportHardwareConfig)
+}
+
+// HCL2Spec returns the hcl spec of a ExportHardwareConfig.
+// This spec is used by HCL to read the fields of ExportHardwareConfig.
+// The decoded values from this spec will then be applied to a FlatExportHardwareConfig.
+func (*FlatExportHardwareConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"cpus":                  &hcldec.AttrSpec{Name: "cpus", Type: cty.Number, Required: false},
+		"memory":                &hcldec.AttrSpec{Name: "memory", Type: cty.Number, Required: false},
+		"enable_dynamic_memory": &hcldec.AttrSpec{Name: "enable_dynamic_memory", Type: cty.Bool, Required: false},
+		"memory_minimum":        &hcldec.AttrSpec{Name: "memory_minimum", Type: cty.Number, Required: false},
+		"memory_maximum":        &hcldec.AttrSpec{Name: "memory_maximum", Type: cty.Number, Required: false},
+		"switch_name":           &hcldec.AttrSpec{Name: "switch_name", Type: cty.String, Required: false},
+		"disconnect_network":    &hcldec.AttrSpec{Name: "disconnect_network", Type: cty.Bool, Required: false},
+		"vlan_id":               &hcldec.AttrSpec{Name: "vlan_id", Type: cty.String, Required: false},
+		"disable_vlan":          &hcldec.AttrSpec{Name: "disable_vlan", Type: cty.Bool, Required: false},
+		"dynamic_mac_address":   &hcldec.AttrSpec{Name: "dynamic_mac_address", Type: cty.Bool, Required: false},
+		"notes":                 &hcldec.AttrSpec{Name: "notes", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type GuestFile
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// DefaultGuestFilesTimeout is how long copying to the guest is retried
+// while the Guest Service Interface is not available yet.
+const DefaultGuestFilesTimeout = 30 * time.Minute
+
+const guestFileRetryDelay = 10 * time.Second
+
+// GuestFile describes a file or directory copied from the host into the
+// running guest with `Copy-VMFile`. No network connection to the guest is
+// needed, but the Guest Service Interface integration service must be
+// enabled and running in the guest.
+type GuestFile struct {
+	// The path of the file or directory on the host.
+	Source string `mapstructure:"source" required:"true"`
+	// The absolute path in the guest. For a file this is the path of the
+	// copy, for a directory the directory its contents are copied into.
+	// Missing parent directories are created.
+	Destination string `mapstructure:"destination" required:"true"`
+}
+
+func (f *GuestFile) Prepare() []error {
+	var errs []error
+
+	if f.Source == "" {
+		errs = append(errs, fmt.Errorf("a source is required"))
+	} else if _, err := os.Stat(f.Source); err != nil {
+		errs = append(errs, err)
+	}
+	if f.Destination == "" {
+		errs = append(errs, fmt.Errorf("a destination is required"))
+	}
+
+	return errs
+}
+
+// CopyFileToGuest copies file into the guest of vmName. Failures are retried
+// until timeout expires, as the Guest Service Interface only becomes
+// available once the guest OS has started its integration services.
+func CopyFileToGuest(ctx context.Context, driver Driver, vmName string, file GuestFile,
+	timeout time.Duration) error {
+	source, err := hostPath(file.Source)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := driver.CopyFileToVirtualMachine(vmName, source, file.Destination)
+		if err == nil {
+			return nil
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return err
+		}
+		if wait > guestFileRetryDelay {
+			wait = guestFileRetryDelay
+		}
+		log.Printf("Error copying %s to the guest, retrying in %s: %s", file.Source, wait, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatGuestFile is an auto-generated flat version of GuestFile.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatGuestFile struct {
+	Source      *string `mapstructure:"source" required:"true" cty:"source" hcl:"source"`
+	Destination *string `mapstructure:"destination" required:"true" cty:"destination" hcl:"destination"`
+}
+
+// FlatMapstructure returns a new FlatGuestFile.
+// FlatGuestFile is an auto-generated flat version of GuestFile.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*GuestFile) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatGuestFile)
+}
+
+// HCL2Spec returns the hcl spec of a GuestFile.
+// This spec is used by HCL to read the fields of GuestFile.
+// The decoded values from this spec will then be applied to a FlatGuestFile.
+func (*FlatGuestFile) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"source":      &hcldec.AttrSpec{Name: "source", Type: cty.String, Required: false},
+		"destination": &hcldec.AttrSpec{Name: "destination", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,39 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrp/hvsock"
+)
+
+// HvsockDialer connects to a Hyper-V socket service of a VM, both given as
+// GUIDs.
+type HvsockDialer func(ctx context.Context, vmId string, serviceId string) (net.Conn, error)
+
+// HvsockServiceId returns the service GUID of the AF_VSOCK port a Linux
+// guest listens on. The hv_sock driver maps port N to the GUID
+// N-facb-11e6-bd58-64006a7986d3, with N in hex.
+func HvsockServiceId(port int) string {
+	return fmt.Sprintf("%08x-facb-11e6-bd58-64006a7986d3", uint32(port))
+}
+
+// DialHvsock connects to a service of a VM with an AF_HYPERV socket. This is
+// only supported on Windows hosts.
+func DialHvsock(ctx context.Context, vmId string, serviceId string) (net.Conn, error) {
+	vm, err := uuid.Parse(vmId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VM GUID %q: %s", vmId, err)
+	}
+	service, err := uuid.Parse(serviceId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service GUID %q: %s", serviceId, err)
+	}
+
+	return hvsock.DialService(ctx, vm, service)
+}
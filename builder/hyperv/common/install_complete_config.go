@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type InstallCompleteConfig
+
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The ways an install_complete detector can tell that the installation of
+// the guest OS is complete.
+const (
+	InstallCompleteTCP      = "tcp"
+	InstallCompleteHTTP     = "http"
+	InstallCompleteKVP      = "kvp"
+	InstallCompletePowerOff = "power_off"
+	InstallCompleteReboots  = "reboots"
+)
+
+var InstallCompleteStrategies = []string{
+	InstallCompleteTCP,
+	InstallCompleteHTTP,
+	InstallCompleteKVP,
+	InstallCompletePowerOff,
+	InstallCompleteReboots,
+}
+
+const (
+	DefaultInstallCompleteTimeout = time.Hour
+	DefaultInstallCompletePath    = "/packer/install-complete"
+)
+
+// InstallCompleteConfig waits for the guest OS installation to complete
+// before Packer connects the communicator. This keeps the communicator from
+// connecting to the installer, or to the guest between the reboots of the
+// installation.
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	install_complete {
+//	  strategy = "http"
+//	  timeout  = "90m"
+//	}
+//
+// ```
+type InstallCompleteConfig struct {
+	// How to tell that the installation is complete, one of:
+	//
+	//   - `tcp` - The guest accepts connections on `port`. Not available
+	//     with the `hvsock` SSH or PSRP transports.
+	//   - `http` - The guest requests `path` from the HTTP server of the
+	//     build, e.g. with `curl http://{{ .HTTPIP }}:{{ .HTTPPort }}/packer/install-complete`
+	//     at the end of the installation. The HTTP server is started even
+	//     without `http_directory` or `http_content`.
+	//   - `kvp` - The guest sets the KVP key `kvp_key` in its pool of
+	//     guest exchange items.
+	//   - `power_off` - The guest powers off. Packer then starts the VM
+	//     again.
+	//   - `reboots` - The guest has rebooted `reboot_count` times.
+	Strategy string `mapstructure:"strategy" required:"true"`
+	// How long to wait for the installation to complete. Defaults to 1h.
+	Timeout time.Duration `mapstructure:"timeout" required:"false"`
+	// The TCP port of the guest to wait for with `tcp`. The IP address of
+	// the guest is found as for the communicator.
+	Port int `mapstructure:"port" required:"false"`
+	// The path of the callback with `http`. Defaults to
+	// `/packer/install-complete`.
+	Path string `mapstructure:"path" required:"false"`
+	// The KVP key the guest sets with `kvp`.
+	KVPKey string `mapstructure:"kvp_key" required:"false"`
+	// The value of `kvp_key` to wait for with `kvp`. By default any value
+	// other than an empty one completes the installation.
+	KVPValue string `mapstructure:"kvp_value" required:"false"`
+	// How many reboots to wait for with `reboots`.
+	RebootCount uint `mapstructure:"reboot_count" required:"false"`
+}
+
+// IsSet reports whether Packer should wait for the installation.
+func (c *InstallCompleteConfig) IsSet() bool {
+	return c.Strategy != ""
+}
+
+func (c *InstallCompleteConfig) Prepare() []error {
+	if !c.IsSet() {
+		return nil
+	}
+
+	var errs []error
+
+	c.Strategy = strings.ToLower(c.Strategy)
+	switch c.Strategy {
+	case InstallCompleteTCP:
+		if c.Port < 1 || c.Port > 65535 {
+			errs = append(errs, fmt.Errorf("install_complete: port must be between 1 and 65535 with the tcp strategy"))
+		}
+	case InstallCompleteHTTP:
+		if c.Path == "" {
+			c.Path = DefaultInstallCompletePath
+		}
+		if !strings.HasPrefix(c.Path, "/") {
+			errs = append(errs, fmt.Errorf("install_complete: path %q must start with /", c.Path))
+		}
+	case InstallCompleteKVP:
+		if c.KVPKey == "" {
+			errs = append(errs, fmt.Errorf("install_complete: kvp_key must be set with the kvp strategy"))
+		}
+	case InstallCompleteReboots:
+		if c.RebootCount == 0 {
+			errs = append(errs, fmt.Errorf("install_complete: reboot_count must be set with the reboots strategy"))
+		}
+	case InstallCompletePowerOff:
+	default:
+		errs = append(errs, fmt.Errorf("install_complete: strategy must be one of %s, but defined: %s",
+			strings.Join(InstallCompleteStrategies, ", "), c.Strategy))
+	}
+
+	if c.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("install_complete: timeout must not be negative"))
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultInstallCompleteTimeout
+	}
+
+	return errs
+}
+
+// CallbackPath returns the path the HTTP server answers the callback of the
+// http strategy on, or an empty string for the other strategies.
+func (c *InstallCompleteConfig) CallbackPath() string {
+	if c.Strategy != InstallCompleteHTTP {
+		return ""
+	}
+	return c.Path
+}
@@ -0,0 +1,43 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatInstallCompleteConfig is an auto-generated flat version of InstallCompleteConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatInstallCompleteConfig struct {
+	Strategy    *string `mapstructure:"strategy" required:"true" cty:"strategy" hcl:"strategy"`
+	Timeout     *string `mapstructure:"timeout" required:"false" cty:"timeout" hcl:"timeout"`
+	Port        *int    `mapstructure:"port" required:"false" cty:"port" hcl:"port"`
+	Path        *string `mapstructure:"path" required:"false" cty:"path" hcl:"path"`
+	KVPKey      *string `mapstructure:"kvp_key" required:"false" cty:"kvp_key" hcl:"kvp_key"`
+	KVPValue    *string `mapstructure:"kvp_value" required:"false" cty:"kvp_value" hcl:"kvp_value"`
+	RebootCount *uint   `mapstructure:"reboot_count" required:"false" cty:"reboot_count" hcl:"reboot_count"`
+}
+
+// FlatMapstructure returns a new FlatInstallCompleteConfig.
+// FlatInstallCompleteConfig is an auto-generated flat version of InstallCompleteConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*InstallCompleteConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatInstallCompleteConfig)
+}
+
+// HCL2Spec returns the hcl spec of a InstallCompleteConfig.
+// This spec is used by HCL to read the fields of InstallCompleteConfig.
+// The decoded values from this spec will then be applied to a FlatInstallCompleteConfig.
+func (*FlatInstallCompleteConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"strategy":     &hcldec.AttrSpec{Name: "strategy", Type: cty.String, Required: false},
+		"timeout":      &hcldec.AttrSpec{Name: "timeout", Type: cty.String, Required: false},
+		"port":         &hcldec.AttrSpec{Name: "port", Type: cty.Number, Required: false},
+		"path":         &hcldec.AttrSpec{Name: "path", Type: cty.String, Required: false},
+		"kvp_key":      &hcldec.AttrSpec{Name: "kvp_key", Type: cty.String, Required: false},
+		"kvp_value":    &hcldec.AttrSpec{Name: "kvp_value", Type: cty.String, Required: false},
+		"reboot_count": &hcldec.AttrSpec{Name: "reboot_count", Type: cty.Number, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,290 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package iso9660 writes small ISO 9660 images holding files in the root
+// directory, such as the NoCloud seed of cloud-init, without relying on
+// xorriso, mkisofs or oscdimg being installed. Names are recorded as
+// ISO 9660 level 1 names in the primary volume and unchanged in a Joliet
+// volume, which Linux, BSD and Windows read instead.
+package iso9660
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const sectorSize = 2048
+
+// The sectors of the image. Sectors 0 to 15 are the unused system area,
+// and the file data follows the root directories.
+const (
+	primaryDescriptorSector = 16 + iota
+	jolietDescriptorSector
+	terminatorSector
+	primaryLPathTableSector
+	primaryMPathTableSector
+	jolietLPathTableSector
+	jolietMPathTableSector
+	primaryRootSector
+	jolietRootSector
+	firstDataSector
+)
+
+// The size of a path table with only the root directory
+const pathTableSize = 10
+
+// MaxLabelLength is the longest volume label a Joliet volume can hold.
+const MaxLabelLength = 16
+
+// File is a file in the root directory of the image.
+type File struct {
+	Name string
+	Data []byte
+}
+
+type volume struct {
+	joliet bool
+	names  []string
+}
+
+// Write writes an image labelled label holding files to w. The files and
+// the volume are dated modTime.
+func Write(w io.Writer, label string, files []File, modTime time.Time) error {
+	if label == "" || len(label) > MaxLabelLength {
+		return fmt.Errorf("volume label %q must be 1 to %d characters", label, MaxLabelLength)
+	}
+	for _, r := range label {
+		if r > 0x7e || r < 0x20 {
+			return fmt.Errorf("volume label %q must be printable ASCII", label)
+		}
+	}
+
+	primary := volume{}
+	joliet := volume{joliet: true}
+	seen := map[string]string{}
+	for _, file := range files {
+		if file.Name == "" || strings.ContainsAny(file.Name, `/\`) || utf8Len(file.Name) > 64 {
+			return fmt.Errorf("invalid file name %q", file.Name)
+		}
+		name := levelOneName(file.Name)
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf("file names %q and %q are the same in ISO 9660 (%s)", other, file.Name, name)
+		}
+		seen[name] = file.Name
+		primary.names = append(primary.names, name)
+		joliet.names = append(joliet.names, file.Name)
+	}
+
+	// File data is laid out in the order given
+	extents := make([]uint32, len(files))
+	sector := uint32(firstDataSector)
+	for i, file := range files {
+		extents[i] = sector
+		sector += sectors(len(file.Data))
+	}
+	volumeSize := sector
+
+	modTime = modTime.UTC()
+	var image bytes.Buffer
+	image.Write(make([]byte, primaryDescriptorSector*sectorSize))
+	image.Write(descriptor(primary, label, volumeSize, modTime))
+	image.Write(descriptor(joliet, label, volumeSize, modTime))
+	image.Write(terminator())
+	image.Write(pathTable(primaryRootSector, binary.LittleEndian))
+	image.Write(pathTable(primaryRootSector, binary.BigEndian))
+	image.Write(pathTable(jolietRootSector, binary.LittleEndian))
+	image.Write(pathTable(jolietRootSector, binary.BigEndian))
+	for _, v := range []struct {
+		volume
+		root uint32
+	}{{primary, primaryRootSector}, {joliet, jolietRootSector}} {
+		dir, err := rootDirectory(v.volume, v.root, files, extents, modTime)
+		if err != nil {
+			return err
+		}
+		image.Write(dir)
+	}
+	for _, file := range files {
+		image.Write(pad(file.Data))
+	}
+
+	_, err := image.WriteTo(w)
+	return err
+}
+
+func utf8Len(s string) int {
+	return len([]rune(s))
+}
+
+func sectors(size int) uint32 {
+	return uint32((size + sectorSize - 1) / sectorSize)
+}
+
+func pad(b []byte) []byte {
+	return append(b[:len(b):len(b)], make([]byte, int(sectors(len(b)))*sectorSize-len(b))...)
+}
+
+// levelOneName returns the 8.3 upper case name of a file in the primary
+// volume, e.g. USER_DAT.;1 for user-data.
+func levelOneName(name string) string {
+	base, ext := name, ""
+	if i := strings.LastIndex(name, "."); i > 0 {
+		base, ext = name[:i], name[i+1:]
+	}
+	dChars := func(s string, max int) string {
+		var b strings.Builder
+		for _, r := range strings.ToUpper(s) {
+			if b.Len() == max {
+				break
+			}
+			if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+				b.WriteRune(r)
+			} else {
+				b.WriteByte('_')
+			}
+		}
+		return b.String()
+	}
+	return dChars(base, 8) + "." + dChars(ext, 3) + ";1"
+}
+
+func (v volume) encode(s string) []byte {
+	if !v.joliet {
+		return []byte(s)
+	}
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.BigEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+// text returns s padded with spaces to size bytes.
+func (v volume) text(s string, size int) []byte {
+	b := v.encode(s)
+	for len(b) < size {
+		if v.joliet {
+			b = append(b, 0)
+		}
+		b = append(b, ' ')
+	}
+	return b[:size]
+}
+
+func bothEndian32(b []byte, n uint32) {
+	binary.LittleEndian.PutUint32(b, n)
+	binary.BigEndian.PutUint32(b[4:], n)
+}
+
+func bothEndian16(b []byte, n uint16) {
+	binary.LittleEndian.PutUint16(b, n)
+	binary.BigEndian.PutUint16(b[2:], n)
+}
+
+func descriptor(v volume, label string, volumeSize uint32, modTime time.Time) []byte {
+	d := make([]byte, sectorSize)
+	root := uint32(primaryRootSector)
+	lPathTable, mPathTable := uint32(primaryLPathTableSector), uint32(primaryMPathTableSector)
+	d[0] = 1
+	if v.joliet {
+		d[0] = 2
+		root = jolietRootSector
+		lPathTable, mPathTable = jolietLPathTableSector, jolietMPathTableSector
+		// UCS-2 level 3
+		copy(d[88:], "%/E")
+	}
+	copy(d[1:], "CD001")
+	d[6] = 1
+	copy(d[8:40], v.text("", 32))
+	copy(d[40:72], v.text(label, 32))
+	bothEndian32(d[80:], volumeSize)
+	bothEndian16(d[120:], 1)
+	bothEndian16(d[124:], 1)
+	bothEndian16(d[128:], sectorSize)
+	bothEndian32(d[132:], pathTableSize)
+	binary.LittleEndian.PutUint32(d[140:], lPathTable)
+	binary.BigEndian.PutUint32(d[148:], mPathTable)
+	copy(d[156:190], directoryRecord([]byte{0}, root, sectorSize, true, modTime))
+	copy(d[190:813], v.text("", 623))
+	copy(d[813:], decimalTime(modTime))
+	copy(d[830:], decimalTime(modTime))
+	copy(d[847:], decimalTime(time.Time{}))
+	copy(d[864:], decimalTime(time.Time{}))
+	d[881] = 1
+	return d
+}
+
+func terminator() []byte {
+	d := make([]byte, sectorSize)
+	d[0] = 255
+	copy(d[1:], "CD001")
+	d[6] = 1
+	return d
+}
+
+func pathTable(root uint32, order binary.ByteOrder) []byte {
+	t := make([]byte, sectorSize)
+	t[0] = 1
+	order.PutUint32(t[2:], root)
+	order.PutUint16(t[6:], 1)
+	return t
+}
+
+func rootDirectory(v volume, root uint32, files []File, extents []uint32, modTime time.Time) ([]byte, error) {
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return bytes.Compare(v.encode(v.names[order[i]]), v.encode(v.names[order[j]])) < 0
+	})
+
+	dir := directoryRecord([]byte{0}, root, sectorSize, true, modTime)
+	dir = append(dir, directoryRecord([]byte{1}, root, sectorSize, true, modTime)...)
+	for _, i := range order {
+		dir = append(dir, directoryRecord(v.encode(v.names[i]), extents[i], uint32(len(files[i].Data)), false, modTime)...)
+	}
+	if len(dir) > sectorSize {
+		return nil, fmt.Errorf("too many files for the root directory")
+	}
+	return pad(dir), nil
+}
+
+func directoryRecord(name []byte, extent uint32, size uint32, isDir bool, modTime time.Time) []byte {
+	length := 33 + len(name)
+	if length%2 == 1 {
+		length++
+	}
+	r := make([]byte, length)
+	r[0] = byte(length)
+	bothEndian32(r[2:], extent)
+	bothEndian32(r[10:], size)
+	r[18] = byte(modTime.Year() - 1900)
+	r[19] = byte(modTime.Month())
+	r[20] = byte(modTime.Day())
+	r[21] = byte(modTime.Hour())
+	r[22] = byte(modTime.Minute())
+	r[23] = byte(modTime.Second())
+	if isDir {
+		r[25] = 2
+	}
+	bothEndian16(r[28:], 1)
+	r[32] = byte(len(name))
+	copy(r[33:], name)
+	return r
+}
+
+// decimalTime returns the 17 byte date of a volume descriptor. The zero
+// time is recorded as not specified.
+func decimalTime(t time.Time) []byte {
+	if t.IsZero() {
+		return append([]byte("0000000000000000"), 0)
+	}
+	return append([]byte(t.Format("20060102150405")+fmt.Sprintf("%02d", t.Nanosecond()/1e7)), 0)
+}
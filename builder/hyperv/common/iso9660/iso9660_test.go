@@ -0,0 +1,130 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iso9660
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+var testModTime = time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC)
+
+// readRoot returns the files in the root directory of the volume described
+// in sector, by name.
+func readRoot(t *testing.T, image []byte, sector int, joliet bool) map[string][]byte {
+	d := image[sector*sectorSize:]
+	if string(d[1:6]) != "CD001" {
+		t.Fatalf("Sector %d is not a volume descriptor", sector)
+	}
+
+	root := binary.LittleEndian.Uint32(d[156+2:])
+	dir := image[root*sectorSize : (root+1)*sectorSize]
+	files := map[string][]byte{}
+	for offset := 0; offset < len(dir) && dir[offset] != 0; offset += int(dir[offset]) {
+		r := dir[offset:]
+		name := r[33 : 33+r[32]]
+		if r[25]&2 != 0 {
+			continue
+		}
+		extent := binary.LittleEndian.Uint32(r[2:])
+		size := binary.LittleEndian.Uint32(r[10:])
+		if binary.BigEndian.Uint32(r[6:]) != extent || binary.BigEndian.Uint32(r[14:]) != size {
+			t.Fatalf("Both-endian fields of %q don't match", name)
+		}
+
+		key := string(name)
+		if joliet {
+			u := make([]uint16, len(name)/2)
+			for i := range u {
+				u[i] = binary.BigEndian.Uint16(name[i*2:])
+			}
+			key = string(utf16.Decode(u))
+		}
+		files[key] = image[extent*sectorSize : extent*sectorSize+size]
+	}
+	return files
+}
+
+func TestWrite(t *testing.T) {
+	files := []File{
+		{Name: "user-data", Data: []byte("#cloud-config\n")},
+		{Name: "meta-data", Data: bytes.Repeat([]byte("x"), sectorSize+1)},
+		{Name: "network-config", Data: []byte("version: 2\n")},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "cidata", files, testModTime); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	image := buf.Bytes()
+
+	// 16 system sectors, 3 descriptors, 4 path tables, 2 root directories
+	// and 4 sectors of data
+	if len(image) != 29*sectorSize {
+		t.Fatalf("Bad image size: %d", len(image))
+	}
+	if size := binary.LittleEndian.Uint32(image[16*sectorSize+80:]); size != 29 {
+		t.Fatalf("Bad volume space size: %d", size)
+	}
+
+	primary := image[16*sectorSize:]
+	if primary[0] != 1 || strings.TrimRight(string(primary[40:72]), " ") != "cidata" {
+		t.Fatalf("Bad primary volume descriptor: %q", primary[:72])
+	}
+	if string(primary[813:829]) != "2026101812300000" {
+		t.Fatalf("Bad creation date: %s", primary[813:829])
+	}
+	joliet := image[17*sectorSize:]
+	if joliet[0] != 2 || string(joliet[88:91]) != "%/E" {
+		t.Fatal("Should have a Joliet volume descriptor")
+	}
+	if image[18*sectorSize] != 255 {
+		t.Fatal("Should terminate the volume descriptors")
+	}
+
+	for name, expected := range map[string]string{
+		"USER_DAT.;1": "user-data",
+		"META_DAT.;1": "meta-data",
+		"NETWORK_.;1": "network-config",
+	} {
+		var data []byte
+		for _, f := range files {
+			if f.Name == expected {
+				data = f.Data
+			}
+		}
+		if got := readRoot(t, image, 16, false)[name]; !bytes.Equal(got, data) {
+			t.Fatalf("Bad primary file %s: %q", name, got)
+		}
+		if got := readRoot(t, image, 17, true)[expected]; !bytes.Equal(got, data) {
+			t.Fatalf("Bad Joliet file %s: %q", expected, got)
+		}
+	}
+}
+
+func TestWrite_errors(t *testing.T) {
+	for name, files := range map[string][]File{
+		"same level 1 name": {{Name: "user-data1"}, {Name: "user-data2"}},
+		"path":              {{Name: "dir/user-data"}},
+		"too many files":    make([]File, 60),
+	} {
+		if name == "too many files" {
+			for i := range files {
+				files[i].Name = fmt.Sprintf("file%04d-%s", i, strings.Repeat("a", 40))
+			}
+		}
+		if err := Write(&bytes.Buffer{}, "cidata", files, testModTime); err == nil {
+			t.Fatalf("Should have error for %s", name)
+		}
+	}
+
+	if err := Write(&bytes.Buffer{}, "a-label-too-long-for-joliet", nil, testModTime); err == nil {
+		t.Fatal("Should have error for a long label")
+	}
+}
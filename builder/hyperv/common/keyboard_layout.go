@@ -0,0 +1,227 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+)
+
+// DefaultKeyboardLayout is the layout Hyper-V types text for, so the boot
+// command can be typed with TypeText on it.
+const DefaultKeyboardLayout = "us"
+
+// Scancodes of the physical keys of each row of a 102 key keyboard, from
+// left to right. Row E is the number row, row B the bottom row including
+// the ISO key between the left shift and the key right of it.
+var (
+	keyboardRowE = []byte{0x29, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d}
+	keyboardRowD = []byte{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b}
+	keyboardRowC = []byte{0x1e, 0x1f, 0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x2b}
+	keyboardRowB = []byte{0x56, 0x2c, 0x2d, 0x2e, 0x2f, 0x30, 0x31, 0x32, 0x33, 0x34, 0x35}
+)
+
+// keyboardRow lists the characters a row of keys types without modifiers,
+// with shift and with AltGr. A space marks a key that types nothing with
+// that modifier.
+type keyboardRow struct {
+	Plain string
+	Shift string
+	AltGr string
+}
+
+// keyboardLayoutDef describes a layout by the characters of its rows.
+type keyboardLayoutDef struct {
+	E, D, C, B keyboardRow
+	// Keys and modifiers that are dead keys, which are followed by a space
+	// to type their character. A character that is also on a live key is
+	// typed with that key instead.
+	DeadKeys []layoutKey
+}
+
+var keyboardLayoutDefs = map[string]keyboardLayoutDef{
+	"us": {
+		E: keyboardRow{"`1234567890-=", "~!@#$%^&*()_+", ""},
+		D: keyboardRow{"qwertyuiop[]", "QWERTYUIOP{}", ""},
+		C: keyboardRow{`asdfghjkl;'\`, `ASDFGHJKL:"|`, ""},
+		B: keyboardRow{" zxcvbnm,./", " ZXCVBNM<>?", ""},
+	},
+	"uk": {
+		E: keyboardRow{"`1234567890-=", `¬!"£$%^&*()_+`, "¦   €        "},
+		D: keyboardRow{"qwertyuiop[]", "QWERTYUIOP{}", ""},
+		C: keyboardRow{"asdfghjkl;'#", "ASDFGHJKL:@~", ""},
+		B: keyboardRow{`\zxcvbnm,./`, "|ZXCVBNM<>?", ""},
+	},
+	"de": {
+		E:        keyboardRow{"^1234567890ß´", `°!"§$%&/()=?` + "`", `  ²³   {[]}\ `},
+		D:        keyboardRow{"qwertzuiopü+", "QWERTZUIOPÜ*", "@ €        ~"},
+		C:        keyboardRow{"asdfghjklöä#", "ASDFGHJKLÖÄ'", ""},
+		B:        keyboardRow{"<yxcvbnm,.-", ">YXCVBNM;:_", "|      µ   "},
+		DeadKeys: []layoutKey{{Code: 0x29}, {Code: 0x0d}, {Code: 0x0d, Shift: true}},
+	},
+	"fr": {
+		E:        keyboardRow{`²&é"'(-è_çà)=`, " 1234567890°+", "  ~#{[|`\\^@]}"},
+		D:        keyboardRow{"azertyuiop^$", "AZERTYUIOP¨£", "  €        ¤"},
+		C:        keyboardRow{"qsdfghjklmù*", "QSDFGHJKLM%µ", ""},
+		B:        keyboardRow{"<wxcvbn,;:!", ">WXCVBN?./§", ""},
+		DeadKeys: []layoutKey{{Code: 0x1a}, {Code: 0x1a, Shift: true}, {Code: 0x03, AltGr: true}, {Code: 0x08, AltGr: true}},
+	},
+	"es": {
+		E:        keyboardRow{"º1234567890'¡", `ª!"·$%&/()=?¿`, `\|@#~€¬      `},
+		D:        keyboardRow{"qwertyuiop`+", "QWERTYUIOP^*", "  €       []"},
+		C:        keyboardRow{"asdfghjklñ´ç", "ASDFGHJKLÑ¨Ç", "          {}"},
+		B:        keyboardRow{"<zxcvbnm,.-", ">ZXCVBNM;:_", ""},
+		DeadKeys: []layoutKey{{Code: 0x1a}, {Code: 0x1a, Shift: true}, {Code: 0x28}, {Code: 0x28, Shift: true}, {Code: 0x05, AltGr: true}},
+	},
+}
+
+// KeyboardLayouts returns the names of the supported keyboard layouts.
+func KeyboardLayouts() []string {
+	var names []string
+	for name := range keyboardLayoutDefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// layoutKey is how a character is typed on a layout.
+type layoutKey struct {
+	Code  byte
+	Shift bool
+	AltGr bool
+	Dead  bool
+}
+
+// keyboardLayout maps characters to the keys that type them.
+type keyboardLayout map[rune]layoutKey
+
+func newKeyboardLayout(name string) (keyboardLayout, error) {
+	def, ok := keyboardLayoutDefs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown keyboard layout %q, expected one of: %s",
+			name, strings.Join(KeyboardLayouts(), ", "))
+	}
+
+	layout := keyboardLayout{' ': {Code: 0x39}}
+	rows := []struct {
+		codes []byte
+		row   keyboardRow
+	}{
+		{keyboardRowE, def.E},
+		{keyboardRowD, def.D},
+		{keyboardRowC, def.C},
+		{keyboardRowB, def.B},
+	}
+	dead := make(map[layoutKey]bool)
+	for _, key := range def.DeadKeys {
+		dead[key] = true
+	}
+	for _, r := range rows {
+		layout.addRow(r.codes, r.row.Plain, layoutKey{}, dead)
+		layout.addRow(r.codes, r.row.Shift, layoutKey{Shift: true}, dead)
+		layout.addRow(r.codes, r.row.AltGr, layoutKey{AltGr: true}, dead)
+	}
+
+	return layout, nil
+}
+
+// addRow maps the characters of a row to their keys. The first key found
+// for a character wins, unless it is a dead key and this one isn't.
+func (l keyboardLayout) addRow(codes []byte, chars string, modifiers layoutKey, dead map[layoutKey]bool) {
+	i := 0
+	for _, c := range chars {
+		if c != ' ' {
+			key := modifiers
+			key.Code = codes[i]
+			key.Dead = dead[key]
+			if existing, ok := l[c]; !ok || (existing.Dead && !key.Dead) {
+				l[c] = key
+			}
+		}
+		i++
+	}
+}
+
+// Make and break codes of the modifiers and special keys, as used by the
+// shared boot command driver.
+var (
+	shiftScancodes = [2][]string{{"2a"}, {"aa"}}
+	altGrScancodes = [2][]string{{"e0", "38"}, {"e0", "b8"}}
+
+	specialScancodes = map[string][2][]string{
+		"bs":         {{"0e"}, {"8e"}},
+		"del":        {{"e0", "53"}, {"e0", "d3"}},
+		"down":       {{"e0", "50"}, {"e0", "d0"}},
+		"end":        {{"e0", "4f"}, {"e0", "cf"}},
+		"enter":      {{"1c"}, {"9c"}},
+		"esc":        {{"01"}, {"81"}},
+		"f1":         {{"3b"}, {"bb"}},
+		"f2":         {{"3c"}, {"bc"}},
+		"f3":         {{"3d"}, {"bd"}},
+		"f4":         {{"3e"}, {"be"}},
+		"f5":         {{"3f"}, {"bf"}},
+		"f6":         {{"40"}, {"c0"}},
+		"f7":         {{"41"}, {"c1"}},
+		"f8":         {{"42"}, {"c2"}},
+		"f9":         {{"43"}, {"c3"}},
+		"f10":        {{"44"}, {"c4"}},
+		"f11":        {{"57"}, {"d7"}},
+		"f12":        {{"58"}, {"d8"}},
+		"home":       {{"e0", "47"}, {"e0", "c7"}},
+		"insert":     {{"e0", "52"}, {"e0", "d2"}},
+		"left":       {{"e0", "4b"}, {"e0", "cb"}},
+		"leftalt":    {{"38"}, {"b8"}},
+		"leftctrl":   {{"1d"}, {"9d"}},
+		"leftshift":  {{"2a"}, {"aa"}},
+		"leftsuper":  {{"e0", "5b"}, {"e0", "db"}},
+		"menu":       {{"e0", "5d"}, {"e0", "dd"}},
+		"pagedown":   {{"e0", "51"}, {"e0", "d1"}},
+		"pageup":     {{"e0", "49"}, {"e0", "c9"}},
+		"return":     {{"1c"}, {"9c"}},
+		"right":      {{"e0", "4d"}, {"e0", "cd"}},
+		"rightalt":   {{"e0", "38"}, {"e0", "b8"}},
+		"rightctrl":  {{"e0", "1d"}, {"e0", "9d"}},
+		"rightshift": {{"36"}, {"b6"}},
+		"rightsuper": {{"e0", "5c"}, {"e0", "dc"}},
+		"spacebar":   {{"39"}, {"b9"}},
+		"tab":        {{"0f"}, {"8f"}},
+		"up":         {{"e0", "48"}, {"e0", "c8"}},
+	}
+)
+
+// scancodes returns the codes that type a character on the layout,
+// including the modifiers it needs and the space after a dead key.
+func (l keyboardLayout) scancodes(c rune, action bootcommand.KeyAction) ([]string, error) {
+	key, ok := l[c]
+	if !ok {
+		return nil, fmt.Errorf("character %q can't be typed on this keyboard layout", c)
+	}
+
+	var modifier [2][]string
+	switch {
+	case key.Shift:
+		modifier = shiftScancodes
+	case key.AltGr:
+		modifier = altGrScancodes
+	}
+
+	var codes []string
+	if action&(bootcommand.KeyOn|bootcommand.KeyPress) != 0 {
+		codes = append(codes, modifier[0]...)
+		codes = append(codes, fmt.Sprintf("%02x", key.Code))
+	}
+	if action&(bootcommand.KeyOff|bootcommand.KeyPress) != 0 {
+		codes = append(codes, fmt.Sprintf("%02x", key.Code+0x80))
+		codes = append(codes, modifier[1]...)
+		if key.Dead {
+			codes = append(codes, "39", "b9")
+		}
+	}
+
+	return codes, nil
+}
@@ -0,0 +1,181 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+)
+
+func TestKeyboardLayoutDefs(t *testing.T) {
+	for name, def := range keyboardLayoutDefs {
+		rows := map[string]struct {
+			codes []byte
+			row   keyboardRow
+		}{
+			"E": {keyboardRowE, def.E},
+			"D": {keyboardRowD, def.D},
+			"C": {keyboardRowC, def.C},
+			"B": {keyboardRowB, def.B},
+		}
+		keys := make(map[layoutKey]bool)
+		for rowName, r := range rows {
+			for _, m := range []struct {
+				chars     string
+				modifiers layoutKey
+			}{
+				{r.row.Plain, layoutKey{}},
+				{r.row.Shift, layoutKey{Shift: true}},
+				{r.row.AltGr, layoutKey{AltGr: true}},
+			} {
+				if m.chars != "" && utf8.RuneCountInString(m.chars) != len(r.codes) {
+					t.Fatalf("%s row %s: %q should have %d keys", name, rowName, m.chars, len(r.codes))
+				}
+				i := 0
+				for _, c := range m.chars {
+					if c != ' ' {
+						key := m.modifiers
+						key.Code = r.codes[i]
+						keys[key] = true
+					}
+					i++
+				}
+			}
+		}
+
+		if _, err := newKeyboardLayout(name); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		for _, key := range def.DeadKeys {
+			if !keys[key] {
+				t.Fatalf("%s: dead key %+v types no character", name, key)
+			}
+		}
+	}
+}
+
+func TestNewKeyboardLayout_unknown(t *testing.T) {
+	if _, err := newKeyboardLayout("dvorak"); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+// The us layout must type exactly what the shared PC XT driver types.
+func TestKeyboardLayout_usMatchesPCXTDriver(t *testing.T) {
+	layout, err := newKeyboardLayout("us")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for c := rune(' '); c <= '~'; c++ {
+		var sent []string
+		d := bootcommand.NewPCXTDriver(func(codes []string) error {
+			sent = append(sent, codes...)
+			return nil
+		}, 0, 1)
+		if err := d.SendKey(c, bootcommand.KeyPress); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := d.Flush(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		codes, err := layout.scancodes(c, bootcommand.KeyPress)
+		if err != nil {
+			t.Fatalf("%q: %s", c, err)
+		}
+		if !reflect.DeepEqual(codes, sent) {
+			t.Fatalf("%q: expected %v, got %v", c, sent, codes)
+		}
+	}
+}
+
+func TestKeyboardLayout_scancodes(t *testing.T) {
+	cases := map[string]map[rune]string{
+		"uk": {
+			'"':  "2a 03 83 aa",
+			'@':  "2a 28 a8 aa",
+			'#':  "2b ab",
+			'~':  "2a 2b ab aa",
+			'\\': "56 d6",
+			'|':  "2a 56 d6 aa",
+			'£':  "2a 04 84 aa",
+		},
+		"de": {
+			'z':  "15 95",
+			'y':  "2c ac",
+			'Z':  "2a 15 95 aa",
+			'@':  "e0 38 10 90 e0 b8",
+			'\\': "e0 38 0c 8c e0 b8",
+			'/':  "2a 08 88 aa",
+			'-':  "35 b5",
+			':':  "2a 34 b4 aa",
+			'<':  "56 d6",
+			'>':  "2a 56 d6 aa",
+			'|':  "e0 38 56 d6 e0 b8",
+			'~':  "e0 38 1b 9b e0 b8",
+			'ü':  "1a 9a",
+			'^':  "29 a9 39 b9",
+			'`':  "2a 0d 8d aa 39 b9",
+		},
+		"fr": {
+			'a':  "10 90",
+			'q':  "1e 9e",
+			'w':  "2c ac",
+			'm':  "27 a7",
+			'1':  "2a 02 82 aa",
+			'.':  "2a 33 b3 aa",
+			'/':  "2a 34 b4 aa",
+			':':  "34 b4",
+			'@':  "e0 38 0b 8b e0 b8",
+			'\\': "e0 38 09 89 e0 b8",
+			'é':  "03 83",
+			'~':  "e0 38 03 83 e0 b8 39 b9",
+			'^':  "e0 38 0a 8a e0 b8",
+			'¨':  "2a 1a 9a aa 39 b9",
+		},
+		"es": {
+			'ñ': "27 a7",
+			'-': "35 b5",
+			'/': "2a 08 88 aa",
+			':': "2a 34 b4 aa",
+			'@': "e0 38 03 83 e0 b8",
+			'[': "e0 38 1a 9a e0 b8",
+			'{': "e0 38 28 a8 e0 b8",
+			'|': "e0 38 02 82 e0 b8",
+			'^': "2a 1a 9a aa 39 b9",
+			'~': "e0 38 05 85 e0 b8 39 b9",
+		},
+	}
+
+	for name, chars := range cases {
+		layout, err := newKeyboardLayout(name)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		for c, expected := range chars {
+			codes, err := layout.scancodes(c, bootcommand.KeyPress)
+			if err != nil {
+				t.Fatalf("%s %q: %s", name, c, err)
+			}
+			if got := strings.Join(codes, " "); got != expected {
+				t.Fatalf("%s %q: expected %s, got %s", name, c, expected, got)
+			}
+		}
+	}
+}
+
+func TestKeyboardLayout_scancodesUnknownCharacter(t *testing.T) {
+	layout, err := newKeyboardLayout("de")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := layout.scancodes('¥', bootcommand.KeyPress); err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type LinuxGeneralizeConfig
+
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// The cleanup actions of the Linux generalization, in the order they run
+const (
+	LinuxGeneralizeCloudInit    = "cloud_init"
+	LinuxGeneralizeMachineId    = "machine_id"
+	LinuxGeneralizeSSHHostKeys  = "ssh_host_keys"
+	LinuxGeneralizePackerKey    = "packer_key"
+	LinuxGeneralizeDHCPLeases   = "dhcp_leases"
+	LinuxGeneralizeShellHistory = "shell_history"
+	LinuxGeneralizeLogs         = "logs"
+)
+
+var LinuxGeneralizeActions = []string{
+	LinuxGeneralizeCloudInit,
+	LinuxGeneralizeMachineId,
+	LinuxGeneralizeSSHHostKeys,
+	LinuxGeneralizePackerKey,
+	LinuxGeneralizeDHCPLeases,
+	LinuxGeneralizeShellHistory,
+	LinuxGeneralizeLogs,
+}
+
+// LinuxGeneralizeData are the names the Linux generalization adds to the
+// generated data of the build.
+var LinuxGeneralizeData = []string{
+	"LinuxGeneralizeDistro",
+	"LinuxGeneralizeActions",
+}
+
+const DefaultLinuxGeneralizeExecuteCommand = "sudo -n sh '{{ .Path }}'"
+
+// LinuxGeneralizeConfig cleans up a Linux guest through the communicator
+// after the provisioners have run, so VMs created from the exported image
+// don't share the identity of the build VM.
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	linux_generalize {
+//	  enabled      = true
+//	  skip_actions = ["logs"]
+//	}
+//
+// ```
+type LinuxGeneralizeConfig struct {
+	// Run the generalization. This defaults to false.
+	Enabled bool `mapstructure:"enabled" required:"false"`
+	// Actions not to take. The actions are:
+	//
+	//   - `cloud_init` - Run `cloud-init clean --logs`, so cloud-init runs
+	//     again on the next boot.
+	//   - `machine_id` - Empty `/etc/machine-id`, so a new one is generated
+	//     on the next boot.
+	//   - `ssh_host_keys` - Remove the SSH host keys. Debian and Ubuntu
+	//     without cloud-init don't create new ones on boot, so a oneshot
+	//     systemd unit running `ssh-keygen -A` is installed first and
+	//     `ssh_host_keys_first_boot` is reported too. Without systemd, the
+	//     keys are kept there and `ssh_host_keys` isn't reported.
+	//   - `packer_key` - Remove the temporary SSH key of Packer from the
+	//     `authorized_keys` of root and all users.
+	//   - `dhcp_leases` - Remove the DHCP leases of dhclient,
+	//     NetworkManager and wicked.
+	//   - `shell_history` - Remove the shell histories of root and all users.
+	//   - `logs` - Remove rotated logs, truncate the others and vacuum the
+	//     journal.
+	SkipActions []string `mapstructure:"skip_actions" required:"false"`
+	// The command running the generalization script as root. `{{ .Path }}`
+	// is the path of the script and `{{ .Password }}` the password of the
+	// communicator. Defaults to `sudo -n sh '{{ .Path }}'`.
+	ExecuteCommand string `mapstructure:"execute_command" required:"false"`
+}
+
+// IsSet reports whether the guest should be generalized.
+func (c *LinuxGeneralizeConfig) IsSet() bool {
+	return c.Enabled
+}
+
+func (c *LinuxGeneralizeConfig) Prepare() []error {
+	if !c.IsSet() {
+		return nil
+	}
+
+	var errs []error
+
+	for _, action := range c.SkipActions {
+		known := false
+		for _, a := range LinuxGeneralizeActions {
+			if action == a {
+				known = true
+			}
+		}
+		if !known {
+			errs = append(errs, fmt.Errorf("linux_generalize: skip_actions must be one of %s, but defined: %q",
+				strings.Join(LinuxGeneralizeActions, ", "), action))
+		}
+	}
+
+	if c.ExecuteCommand == "" {
+		c.ExecuteCommand = DefaultLinuxGeneralizeExecuteCommand
+	}
+	if err := interpolate.Validate(c.ExecuteCommand, &interpolate.Context{}); err != nil {
+		errs = append(errs, fmt.Errorf("linux_generalize: error parsing execute_command template: %s", err))
+	}
+
+	return errs
+}
+
+// Actions returns the actions to take, in the order they run.
+func (c *LinuxGeneralizeConfig) Actions() []string {
+	var actions []string
+	for _, action := range LinuxGeneralizeActions {
+		skip := false
+		for _, s := range c.SkipActions {
+			if action == s {
+				skip = true
+			}
+		}
+		if !skip {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
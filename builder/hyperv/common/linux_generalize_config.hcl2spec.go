@@ -0,0 +1,35 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatLinuxGeneralizeConfig is an auto-generated flat version of LinuxGeneralizeConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatLinuxGeneralizeConfig struct {
+	Enabled        *bool    `mapstructure:"enabled" required:"false" cty:"enabled" hcl:"enabled"`
+	SkipActions    []string `mapstructure:"skip_actions" required:"false" cty:"skip_actions" hcl:"skip_actions"`
+	ExecuteCommand *string  `mapstructure:"execute_command" required:"false" cty:"execute_command" hcl:"execute_command"`
+}
+
+// FlatMapstructure returns a new FlatLinuxGeneralizeConfig.
+// FlatLinuxGeneralizeConfig is an auto-generated flat version of LinuxGeneralizeConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*LinuxGeneralizeConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatLinuxGeneralizeConfig)
+}
+
+// HCL2Spec returns the hcl spec of a LinuxGeneralizeConfig.
+// This spec is used by HCL to read the fields of LinuxGeneralizeConfig.
+// The decoded values from this spec will then be applied to a FlatLinuxGeneralizeConfig.
+func (*FlatLinuxGeneralizeConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"enabled":         &hcldec.AttrSpec{Name: "enabled", Type: cty.Bool, Required: false},
+		"skip_actions":    &hcldec.AttrSpec{Name: "skip_actions", Type: cty.List(cty.String), Required: false},
+		"execute_command": &hcldec.AttrSpec{Name: "execute_command", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,238 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	ChecksumTypeNone   = "none"
+	ChecksumTypeMD5    = "md5"
+	ChecksumTypeSHA1   = "sha1"
+	ChecksumTypeSHA256 = "sha256"
+	ChecksumTypeSHA512 = "sha512"
+
+	DefaultChecksumType = ChecksumTypeSHA256
+)
+
+// Roles assigned to the files listed in an artifact manifest.
+const (
+	ManifestRoleVmcx   = "vmcx"
+	ManifestRoleVmgs   = "vmgs"
+	ManifestRoleVmrs   = "vmrs"
+	ManifestRoleVhdx   = "vhdx"
+	ManifestRoleBoxXml = "box.xml"
+	ManifestRoleOther  = "other"
+)
+
+// ManifestEntry describes a single file in the output directory.
+type ManifestEntry struct {
+	// Path of the file relative to the output directory, using forward
+	// slashes as separator.
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+	Role     string `json:"role"`
+}
+
+// Manifest lists the checksums of every file collated under the output
+// directory. It is exposed to post-processors through the artifact state
+// under the "manifest" key.
+type Manifest struct {
+	ChecksumType string          `json:"checksum_type"`
+	Files        []ManifestEntry `json:"files"`
+}
+
+// IsValidChecksumType reports whether t can be used to build a manifest.
+func IsValidChecksumType(t string) bool {
+	switch t {
+	case ChecksumTypeNone, ChecksumTypeMD5, ChecksumTypeSHA1, ChecksumTypeSHA256, ChecksumTypeSHA512:
+		return true
+	}
+	return false
+}
+
+func newChecksumHash(t string) (hash.Hash, error) {
+	switch t {
+	case ChecksumTypeMD5:
+		return md5.New(), nil
+	case ChecksumTypeSHA1:
+		return sha1.New(), nil
+	case ChecksumTypeSHA256:
+		return sha256.New(), nil
+	case ChecksumTypeSHA512:
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("Unsupported checksum type: %s", t)
+}
+
+// ManifestSumsFileName returns the name of the plain text checksum file
+// for the given checksum type, e.g. SHA256SUMS.
+func ManifestSumsFileName(checksumType string) string {
+	return strings.ToUpper(checksumType) + "SUMS"
+}
+
+// ManifestJsonFileName returns the name of the JSON manifest for the given
+// checksum type, e.g. SHA256SUMS.json.
+func ManifestJsonFileName(checksumType string) string {
+	return ManifestSumsFileName(checksumType) + ".json"
+}
+
+func manifestRole(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+	if name == "box.xml" {
+		return ManifestRoleBoxXml
+	}
+
+	switch filepath.Ext(name) {
+	case ".vmcx":
+		return ManifestRoleVmcx
+	case ".vmgs":
+		return ManifestRoleVmgs
+	case ".vmrs":
+		return ManifestRoleVmrs
+	case ".vhd", ".vhdx", ".avhd", ".avhdx":
+		return ManifestRoleVhdx
+	}
+	return ManifestRoleOther
+}
+
+// NewManifest hashes every file found under dir. Files are hashed
+// concurrently, as exported disks can be very large. Previously written
+// manifest files are ignored.
+func NewManifest(dir string, checksumType string) (*Manifest, error) {
+	if _, err := newChecksumHash(checksumType); err != nil {
+		return nil, err
+	}
+
+	skip := map[string]bool{
+		ManifestSumsFileName(checksumType): true,
+		ManifestJsonFileName(checksumType): true,
+	}
+
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if filepath.Dir(path) == filepath.Clean(dir) && skip[info.Name()] {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ManifestEntry, len(paths))
+	errs := make([]error, len(paths))
+
+	workers := runtime.NumCPU()
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+
+	var wg sync.WaitGroup
+	indexes := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				entries[i], errs[i] = hashManifestEntry(dir, paths[i], checksumType)
+			}
+		}()
+	}
+	for i := range paths {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+
+	return &Manifest{
+		ChecksumType: checksumType,
+		Files:        entries,
+	}, nil
+}
+
+func hashManifestEntry(dir string, path string, checksumType string) (ManifestEntry, error) {
+	h, err := newChecksumHash(checksumType)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	defer f.Close()
+
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return ManifestEntry{}, fmt.Errorf("Error hashing %s: %s", path, err)
+	}
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+
+	return ManifestEntry{
+		Path:     filepath.ToSlash(rel),
+		Size:     size,
+		Checksum: hex.EncodeToString(h.Sum(nil)),
+		Role:     manifestRole(path),
+	}, nil
+}
+
+// Write stores the manifest in dir, both in the format understood by
+// sha256sum and friends and as JSON.
+func (m *Manifest) Write(dir string) error {
+	var sums strings.Builder
+	for _, e := range m.Files {
+		fmt.Fprintf(&sums, "%s  %s\n", e.Checksum, e.Path)
+	}
+
+	sumsPath := filepath.Join(dir, ManifestSumsFileName(m.ChecksumType))
+	if err := os.WriteFile(sumsPath, []byte(sums.String()), 0644); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	jsonPath := filepath.Join(dir, ManifestJsonFileName(m.ChecksumType))
+	return os.WriteFile(jsonPath, append(data, '\n'), 0644)
+}
@@ -0,0 +1,135 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeManifestTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+}
+
+func TestNewManifest(t *testing.T) {
+	td := t.TempDir()
+	writeManifestTestFiles(t, td, map[string]string{
+		"Virtual Machines/ABC.vmcx":    "vmcx",
+		"Virtual Machines/ABC.vmgs":    "vmgs",
+		"Virtual Machines/ABC.VMRS":    "vmrs",
+		"Virtual Hard Disks/disk.vhdx": "vhdx",
+		"box.xml":                      "box",
+		"notes.txt":                    "hello",
+		// Left over from a previous run; must not be hashed
+		"SHA256SUMS":      "stale",
+		"SHA256SUMS.json": "stale",
+	})
+
+	m, err := NewManifest(td, ChecksumTypeSHA256)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if m.ChecksumType != ChecksumTypeSHA256 {
+		t.Fatalf("bad checksum type: %s", m.ChecksumType)
+	}
+
+	expected := []ManifestEntry{
+		{Path: "Virtual Hard Disks/disk.vhdx", Size: 4, Role: ManifestRoleVhdx},
+		{Path: "Virtual Machines/ABC.VMRS", Size: 4, Role: ManifestRoleVmrs},
+		{Path: "Virtual Machines/ABC.vmcx", Size: 4, Role: ManifestRoleVmcx},
+		{Path: "Virtual Machines/ABC.vmgs", Size: 4, Role: ManifestRoleVmgs},
+		{Path: "box.xml", Size: 3, Role: ManifestRoleBoxXml},
+		{Path: "notes.txt", Size: 5, Role: ManifestRoleOther,
+			Checksum: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+	}
+	if len(m.Files) != len(expected) {
+		t.Fatalf("bad number of files: %#v", m.Files)
+	}
+	for i, e := range expected {
+		got := m.Files[i]
+		if got.Path != e.Path || got.Size != e.Size || got.Role != e.Role {
+			t.Fatalf("bad entry %d. Got: %#v Wanted: %#v", i, got, e)
+		}
+		if e.Checksum != "" && got.Checksum != e.Checksum {
+			t.Fatalf("bad checksum for %s. Got: %s Wanted: %s", e.Path, got.Checksum, e.Checksum)
+		}
+	}
+}
+
+func TestNewManifest_checksumTypes(t *testing.T) {
+	td := t.TempDir()
+	writeManifestTestFiles(t, td, map[string]string{"notes.txt": "hello"})
+
+	expected := map[string]string{
+		ChecksumTypeMD5:  "5d41402abc4b2a76b9719d911017c592",
+		ChecksumTypeSHA1: "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+		ChecksumTypeSHA512: "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca7" +
+			"2323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043",
+	}
+	for checksumType, sum := range expected {
+		m, err := NewManifest(td, checksumType)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if m.Files[0].Checksum != sum {
+			t.Fatalf("bad %s checksum. Got: %s Wanted: %s", checksumType, m.Files[0].Checksum, sum)
+		}
+	}
+
+	if _, err := NewManifest(td, "crc32"); err == nil {
+		t.Fatal("should have error for unsupported checksum type")
+	}
+}
+
+func TestManifestWrite(t *testing.T) {
+	td := t.TempDir()
+	m := &Manifest{
+		ChecksumType: ChecksumTypeSHA256,
+		Files: []ManifestEntry{
+			{Path: "Virtual Hard Disks/disk.vhdx", Size: 4, Checksum: "abcd", Role: ManifestRoleVhdx},
+			{Path: "box.xml", Size: 3, Checksum: "ef01", Role: ManifestRoleBoxXml},
+		},
+	}
+
+	if err := m.Write(td); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sums, err := os.ReadFile(filepath.Join(td, "SHA256SUMS"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := strings.Join([]string{
+		"abcd  Virtual Hard Disks/disk.vhdx",
+		"ef01  box.xml",
+		"",
+	}, "\n")
+	if string(sums) != expected {
+		t.Fatalf("bad SHA256SUMS. Got:\n%s\nWanted:\n%s", sums, expected)
+	}
+
+	data, err := os.ReadFile(filepath.Join(td, "SHA256SUMS.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var decoded Manifest
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if decoded.ChecksumType != m.ChecksumType || len(decoded.Files) != 2 || decoded.Files[1] != m.Files[1] {
+		t.Fatalf("bad JSON manifest: %s", data)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type OfflineCustomizationConfig,OfflineFile
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// OfflineCustomizationConfig describes changes made to the boot disk of the
+// VM from the host, before the VM boots for the first time. The disk is
+// mounted with `Mount-VHD`, so this is mostly useful with a VHD/VHDX as
+// `iso_url` or when cloning a VM.
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	offline_customization {
+//	  files {
+//	    source      = "unattend.xml"
+//	    destination = "Windows/Panther/unattend.xml"
+//	  }
+//	  drivers = ["drivers/storage"]
+//	}
+//
+// ```
+type OfflineCustomizationConfig struct {
+	// The number of the partition to customize. By default the largest
+	// partition of the disk is used, which is the OS partition of most
+	// images.
+	PartitionNumber uint `mapstructure:"partition_number" required:"false"`
+	// Files or directories to copy from the host onto the partition.
+	Files []OfflineFile `mapstructure:"files" required:"false"`
+	// Paths to driver `.inf` files, or directories searched recursively for
+	// them, to add to the offline Windows image with DISM.
+	Drivers []string `mapstructure:"drivers" required:"false"`
+	// Add drivers that aren't signed. This defaults to false.
+	ForceUnsignedDrivers bool `mapstructure:"force_unsigned_drivers" required:"false"`
+}
+
+type OfflineFile struct {
+	// The path of the file or directory on the host.
+	Source string `mapstructure:"source" required:"true"`
+	// The path to copy to, relative to the root of the partition. Missing
+	// parent directories are created.
+	Destination string `mapstructure:"destination" required:"true"`
+}
+
+// IsSet reports whether any offline customization was requested.
+func (c *OfflineCustomizationConfig) IsSet() bool {
+	return len(c.Files) > 0 || len(c.Drivers) > 0
+}
+
+func (c *OfflineCustomizationConfig) Prepare() []error {
+	var errs []error
+
+	for i, f := range c.Files {
+		if f.Source == "" {
+			errs = append(errs, fmt.Errorf("offline_customization: files[%d] requires a source", i))
+		} else if _, err := os.Stat(f.Source); err != nil {
+			errs = append(errs, fmt.Errorf("offline_customization: files[%d]: %s", i, err))
+		}
+
+		destination := strings.ReplaceAll(f.Destination, `\`, "/")
+		switch {
+		case destination == "":
+			errs = append(errs, fmt.Errorf("offline_customization: files[%d] requires a destination", i))
+		case strings.HasPrefix(destination, "/") || strings.Contains(destination, ":"):
+			errs = append(errs, fmt.Errorf("offline_customization: files[%d]: destination %q must be "+
+				"relative to the root of the partition", i, f.Destination))
+		case containsDotDot(destination):
+			errs = append(errs, fmt.Errorf("offline_customization: files[%d]: destination %q must not "+
+				"leave the partition", i, f.Destination))
+		}
+	}
+
+	for _, driver := range c.Drivers {
+		if _, err := os.Stat(driver); err != nil {
+			errs = append(errs, fmt.Errorf("offline_customization: driver: %s", err))
+		}
+	}
+
+	return errs
+}
+
+func containsDotDot(path string) bool {
+	for _, element := range strings.Split(path, "/") {
+		if element == ".." {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatOfflineCustomizationConfig is an auto-generated flat version of OfflineCustomizationConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatOfflineCustomizationConfig struct {
+	PartitionNumber      *uint             `mapstructure:"partition_number" required:"false" cty:"partition_number" hcl:"partition_number"`
+	Files                []FlatOfflineFile `mapstructure:"files" required:"false" cty:"files" hcl:"files"`
+	Drivers              []string          `mapstructure:"drivers" required:"false" cty:"drivers" hcl:"drivers"`
+	ForceUnsignedDrivers *bool             `mapstructure:"force_unsigned_drivers" required:"false" cty:"force_unsigned_drivers" hcl:"force_unsigned_drivers"`
+}
+
+// FlatMapstructure returns a new FlatOfflineCustomizationConfig.
+// FlatOfflineCustomizationConfig is an auto-generated flat version of OfflineCustomizationConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*OfflineCustomizationConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatOfflineCustomizationConfig)
+}
+
+// HCL2Spec returns the hcl spec of a OfflineCustomizationConfig.
+// This spec is used by HCL to read the fields of OfflineCustomizationConfig.
+// The decoded values from this spec will then be applied to a FlatOfflineCustomizationConfig.
+func (*FlatOfflineCustomizationConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"partition_number":       &hcldec.AttrSpec{Name: "partition_number", Type: cty.Number, Required: false},
+		"files":                  &hcldec.BlockListSpec{TypeName: "files", Nested: hcldec.ObjectSpec((*FlatOfflineFile)(nil).HCL2Spec())},
+		"drivers":                &hcldec.AttrSpec{Name: "drivers", Type: cty.List(cty.String), Required: false},
+		"force_unsigned_drivers": &hcldec.AttrSpec{Name: "force_unsigned_drivers", Type: cty.Bool, Required: false},
+	}
+	return s
+}
+
+// FlatOfflineFile is an auto-generated flat version of OfflineFile.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatOfflineFile struct {
+	Source      *string `mapstructure:"source" required:"true" cty:"source" hcl:"source"`
+	Destination *string `mapstructure:"destination" required:"true" cty:"destination" hcl:"destination"`
+}
+
+// FlatMapstructure returns a new FlatOfflineFile.
+// FlatOfflineFile is an auto-generated flat version of OfflineFile.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*OfflineFile) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatOfflineFile)
+}
+
+// HCL2Spec returns the hcl spec of a OfflineFile.
+// This spec is used by HCL to read the fields of OfflineFile.
+// The decoded values from this spec will then be applied to a FlatOfflineFile.
+func (*FlatOfflineFile) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"source":      &hcldec.AttrSpec{Name: "source", Type: cty.String, Required: false},
+		"destination": &hcldec.AttrSpec{Name: "destination", Type: cty.String, Required: false},
+	}
+	return s
+}
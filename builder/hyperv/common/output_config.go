@@ -8,6 +8,7 @@ package common
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/common"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
@@ -22,6 +23,17 @@ type OutputConfig struct {
 	// created, must be empty prior to running the builder. By default this is
 	// "output-BUILDNAME" where "BUILDNAME" is the name of the build.
 	OutputDir string `mapstructure:"output_directory" required:"false"`
+	// The algorithm used to checksum the collated artifacts. Once the build
+	// completes, a `<ALGORITHM>SUMS` file and a JSON variant listing the
+	// size, checksum and role of every file are written to the output
+	// directory. Valid values are `md5`, `sha1`, `sha256`, `sha512` and
+	// `none`, which disables the manifest. Defaults to `sha256`.
+	//
+	// The files are read once more to be hashed, after they have been
+	// collated. Export-VM copies them inside Hyper-V, and collating only
+	// renames them on the same volume, so there is no copy the hashing
+	// could be done during. Use `none` to save that pass on large disks.
+	ManifestChecksumType string `mapstructure:"manifest_checksum_type" required:"false"`
 }
 
 func (c *OutputConfig) Prepare(ctx *interpolate.Context, pc *common.PackerConfig) []error {
@@ -29,5 +41,15 @@ func (c *OutputConfig) Prepare(ctx *interpolate.Context, pc *common.PackerConfig
 		c.OutputDir = fmt.Sprintf("output-%s", pc.PackerBuildName)
 	}
 
-	return nil
+	if c.ManifestChecksumType == "" {
+		c.ManifestChecksumType = DefaultChecksumType
+	}
+	c.ManifestChecksumType = strings.ToLower(c.ManifestChecksumType)
+
+	var errs []error
+	if !IsValidChecksumType(c.ManifestChecksumType) {
+		errs = append(errs, fmt.Errorf("manifest_checksum_type: %q is not a supported checksum type", c.ManifestChecksumType))
+	}
+
+	return errs
 }
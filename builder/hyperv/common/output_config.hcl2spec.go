@@ -10,7 +10,8 @@ import (
 // FlatOutputConfig is an auto-generated flat version of OutputConfig.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatOutputConfig struct {
-	OutputDir *string `mapstructure:"output_directory" required:"false" cty:"output_directory" hcl:"output_directory"`
+	OutputDir            *string `mapstructure:"output_directory" required:"false" cty:"output_directory" hcl:"output_directory"`
+	ManifestChecksumType *string `mapstructure:"manifest_checksum_type" required:"false" cty:"manifest_checksum_type" hcl:"manifest_checksum_type"`
 }
 
 // FlatMapstructure returns a new FlatOutputConfig.
@@ -25,7 +26,8 @@ func (*OutputConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.
 // The decoded values from this spec will then be applied to a FlatOutputConfig.
 func (*FlatOutputConfig) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"output_directory": &hcldec.AttrSpec{Name: "output_directory", Type: cty.String, Required: false},
+		"output_directory":       &hcldec.AttrSpec{Name: "output_directory", Type: cty.String, Required: false},
+		"manifest_checksum_type": &hcldec.AttrSpec{Name: "manifest_checksum_type", Type: cty.String, Required: false},
 	}
 	return s
 }
@@ -48,3 +48,28 @@ func TestOutputConfigPrepare_exists(t *testing.T) {
 		t.Fatal("should not have errors")
 	}
 }
+
+func TestOutputConfigPrepare_manifestChecksumType(t *testing.T) {
+	pc := &common.PackerConfig{PackerBuildName: "foo"}
+
+	c := new(OutputConfig)
+	if errs := c.Prepare(interpolate.NewContext(), pc); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if c.ManifestChecksumType != "sha256" {
+		t.Fatalf("should default to sha256. Got: %s", c.ManifestChecksumType)
+	}
+
+	c = &OutputConfig{ManifestChecksumType: "SHA512"}
+	if errs := c.Prepare(interpolate.NewContext(), pc); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if c.ManifestChecksumType != "sha512" {
+		t.Fatalf("should be lower cased. Got: %s", c.ManifestChecksumType)
+	}
+
+	c = &OutputConfig{ManifestChecksumType: "crc32"}
+	if errs := c.Prepare(interpolate.NewContext(), pc); len(errs) != 1 {
+		t.Fatalf("should have error. Got: %#v", errs)
+	}
+}
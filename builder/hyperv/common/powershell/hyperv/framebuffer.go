@@ -0,0 +1,100 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hyperv
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// Framebuffer is a capture of a virtual machine's console as returned by
+// Msvm_VirtualSystemManagementService.GetVirtualSystemThumbnailImage: a
+// row-major array of little-endian RGB565 pixels.
+type Framebuffer struct {
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// parseFramebuffer parses the "<width> <height> <base64 data>" line written
+// by the GetVirtualMachineFramebuffer script.
+func parseFramebuffer(output string) (Framebuffer, error) {
+	fields := strings.Fields(output)
+	if len(fields) != 3 {
+		return Framebuffer{}, fmt.Errorf("unexpected framebuffer output: %q", output)
+	}
+
+	width, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return Framebuffer{}, fmt.Errorf("invalid framebuffer width: %s", err)
+	}
+	height, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return Framebuffer{}, fmt.Errorf("invalid framebuffer height: %s", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(fields[2])
+	if err != nil {
+		return Framebuffer{}, fmt.Errorf("invalid framebuffer data: %s", err)
+	}
+
+	return Framebuffer{Width: width, Height: height, Data: data}, nil
+}
+
+// Image converts the framebuffer to an RGBA image.
+func (f Framebuffer) Image() (*image.RGBA, error) {
+	if f.Width <= 0 || f.Height <= 0 {
+		return nil, fmt.Errorf("invalid framebuffer size %dx%d", f.Width, f.Height)
+	}
+	if len(f.Data) != f.Width*f.Height*2 {
+		return nil, fmt.Errorf("framebuffer of %dx%d should be %d bytes, got %d",
+			f.Width, f.Height, f.Width*f.Height*2, len(f.Data))
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, f.Width, f.Height))
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			offset := (y*f.Width + x) * 2
+			img.SetRGBA(x, y, rgb565(binary.LittleEndian.Uint16(f.Data[offset:])))
+		}
+	}
+
+	return img, nil
+}
+
+// PNG encodes the framebuffer as a PNG image.
+func (f Framebuffer) PNG() ([]byte, error) {
+	img, err := f.Image()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// rgb565 expands a 16 bit pixel to 8 bits per channel, replicating the high
+// bits into the low bits so that full intensity maps to 0xff.
+func rgb565(pixel uint16) color.RGBA {
+	r := uint8(pixel>>11) & 0x1f
+	g := uint8(pixel>>5) & 0x3f
+	b := uint8(pixel) & 0x1f
+
+	return color.RGBA{
+		R: r<<3 | r>>2,
+		G: g<<2 | g>>4,
+		B: b<<3 | b>>2,
+		A: 0xff,
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hyperv
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// A 2x2 framebuffer: red, green / blue, white
+var testFramebufferData = []byte{
+	0x00, 0xf8, 0xe0, 0x07,
+	0x1f, 0x00, 0xff, 0xff,
+}
+
+func TestFramebuffer_Image(t *testing.T) {
+	fb := Framebuffer{Width: 2, Height: 2, Data: testFramebufferData}
+
+	img, err := fb.Image()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[[2]int]color.RGBA{
+		{0, 0}: {R: 0xff, A: 0xff},
+		{1, 0}: {G: 0xff, A: 0xff},
+		{0, 1}: {B: 0xff, A: 0xff},
+		{1, 1}: {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+	for pos, want := range expected {
+		if got := img.RGBAAt(pos[0], pos[1]); got != want {
+			t.Fatalf("pixel %v: expected %v, got %v", pos, want, got)
+		}
+	}
+}
+
+func TestFramebuffer_ImageBadSize(t *testing.T) {
+	cases := []Framebuffer{
+		{Width: 0, Height: 2, Data: testFramebufferData},
+		{Width: 2, Height: 2, Data: testFramebufferData[:6]},
+		{Width: 3, Height: 2, Data: testFramebufferData},
+	}
+
+	for _, fb := range cases {
+		if _, err := fb.Image(); err == nil {
+			t.Fatalf("%dx%d with %d bytes should have error", fb.Width, fb.Height, len(fb.Data))
+		}
+	}
+}
+
+func TestFramebuffer_PNG(t *testing.T) {
+	fb := Framebuffer{Width: 2, Height: 2, Data: testFramebufferData}
+
+	data, err := fb.PNG()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("should be a valid PNG: %s", err)
+	}
+	if size := img.Bounds().Size(); size.X != 2 || size.Y != 2 {
+		t.Fatalf("bad size: %v", size)
+	}
+	if r, g, b, _ := img.At(1, 0).RGBA(); r != 0 || g != 0xffff || b != 0 {
+		t.Fatalf("bad pixel: %d %d %d", r, g, b)
+	}
+}
+
+func TestParseFramebuffer(t *testing.T) {
+	output := "2 2 " + base64.StdEncoding.EncodeToString(testFramebufferData) + "\r\n"
+
+	fb, err := parseFramebuffer(output)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fb.Width != 2 || fb.Height != 2 {
+		t.Fatalf("bad size: %dx%d", fb.Width, fb.Height)
+	}
+	if !bytes.Equal(fb.Data, testFramebufferData) {
+		t.Fatalf("bad data: %v", fb.Data)
+	}
+
+	for _, output := range []string{"", "2 2", "a 2 AAAA", "2 b AAAA", "2 2 !!!"} {
+		if _, err := parseFramebuffer(output); err == nil {
+			t.Fatalf("%q should have error", output)
+		}
+	}
+}
@@ -6,6 +6,7 @@ package hyperv
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os/exec"
@@ -30,6 +31,132 @@ type scriptOptions struct {
 	Generation         uint
 	DiffDisks          bool
 	FixedVHD           bool
+	DiskPerformance    DiskPerformance
+	SetDiskArgs        string
+	StoragePaths       StoragePaths
+	VHDDir             string
+	SetVMArgs          string
+}
+
+// StoragePaths holds the directories the files of a virtual machine are
+// placed in when they shouldn't live alongside its configuration. Empty
+// values keep the files in the virtual machine directory.
+type StoragePaths struct {
+	VhdPath         string
+	CheckpointPath  string
+	SmartPagingPath string
+}
+
+// setVMArgs returns the Set-VM parameters for the checkpoint and smart
+// paging locations, or an empty string if there is nothing to change.
+func (p StoragePaths) setVMArgs() string {
+	var args []string
+	if p.CheckpointPath != "" {
+		args = append(args, fmt.Sprintf("-SnapshotFileLocation \"%s\"", p.CheckpointPath))
+	}
+	if p.SmartPagingPath != "" {
+		args = append(args, fmt.Sprintf("-SmartPagingFilePath \"%s\"", p.SmartPagingPath))
+	}
+	return strings.Join(args, " ")
+}
+
+// DiskPerformance holds the storage QoS and host caching settings applied
+// to a hard disk drive with Set-VMHardDiskDrive. Zero values leave the
+// Hyper-V defaults in place.
+type DiskPerformance struct {
+	// IOPS are normalized to 8 KB units, as expected by Hyper-V
+	MinimumIOPS     uint64
+	MaximumIOPS     uint64
+	QoSPolicyID     string
+	CacheAttributes string
+}
+
+// setArgs returns the Set-VMHardDiskDrive parameters for the settings, or
+// an empty string if there is nothing to change.
+func (p DiskPerformance) setArgs() string {
+	var args []string
+	if p.MinimumIOPS > 0 {
+		args = append(args, fmt.Sprintf("-MinimumIOPS %d", p.MinimumIOPS))
+	}
+	if p.MaximumIOPS > 0 {
+		args = append(args, fmt.Sprintf("-MaximumIOPS %d", p.MaximumIOPS))
+	}
+	if p.QoSPolicyID != "" {
+		args = append(args, fmt.Sprintf("-QoSPolicyID \"%s\"", p.QoSPolicyID))
+	}
+	if p.CacheAttributes != "" {
+		args = append(args, fmt.Sprintf("-OverrideCacheAttributes %s", p.CacheAttributes))
+	}
+	return strings.Join(args, " ")
+}
+
+// DynamicMemory holds the dynamic memory settings applied with
+// Set-VMMemory. Zero values leave the Hyper-V defaults in place.
+type DynamicMemory struct {
+	MinimumBytes int64
+	MaximumBytes int64
+	// The percentage of memory Hyper-V reserves on top of what the guest
+	// currently uses
+	Buffer uint
+	// The memory weight of the virtual machine, from 0 to 100. Nil leaves
+	// the default of 50.
+	Priority *uint
+}
+
+// setArgs returns the Set-VMMemory parameters for the settings, or an empty
+// string if there is nothing to change.
+func (m DynamicMemory) setArgs() string {
+	var args []string
+	if m.MinimumBytes > 0 {
+		args = append(args, fmt.Sprintf("-MinimumBytes %d", m.MinimumBytes))
+	}
+	if m.MaximumBytes > 0 {
+		args = append(args, fmt.Sprintf("-MaximumBytes %d", m.MaximumBytes))
+	}
+	if m.Buffer > 0 {
+		args = append(args, fmt.Sprintf("-Buffer %d", m.Buffer))
+	}
+	if m.Priority != nil {
+		args = append(args, fmt.Sprintf("-Priority %d", *m.Priority))
+	}
+	return strings.Join(args, " ")
+}
+
+// ProcessorSettings holds the Set-VMProcessor settings applied on top of
+// the processor count. Zero values leave the Hyper-V defaults in place.
+type ProcessorSettings struct {
+	Reserve        uint
+	Maximum        uint
+	RelativeWeight uint
+	// Nil leaves the default, 0 inherits the host SMT configuration
+	HwThreadCountPerCore             *uint
+	MaximumCountPerNumaNode          uint
+	CompatibilityForMigrationEnabled bool
+}
+
+// setArgs returns the Set-VMProcessor parameters for the settings, or an
+// empty string if there is nothing to change.
+func (p ProcessorSettings) setArgs() string {
+	var args []string
+	if p.Reserve > 0 {
+		args = append(args, fmt.Sprintf("-Reserve %d", p.Reserve))
+	}
+	if p.Maximum > 0 {
+		args = append(args, fmt.Sprintf("-Maximum %d", p.Maximum))
+	}
+	if p.RelativeWeight > 0 {
+		args = append(args, fmt.Sprintf("-RelativeWeight %d", p.RelativeWeight))
+	}
+	if p.HwThreadCountPerCore != nil {
+		args = append(args, fmt.Sprintf("-HwThreadCountPerCore %d", *p.HwThreadCountPerCore))
+	}
+	if p.MaximumCountPerNumaNode > 0 {
+		args = append(args, fmt.Sprintf("-MaximumCountPerNumaNode %d", p.MaximumCountPerNumaNode))
+	}
+	if p.CompatibilityForMigrationEnabled {
+		args = append(args, "-CompatibilityForMigrationEnabled $true")
+	}
+	return strings.Join(args, " ")
 }
 
 func GetHostAdapterIpAddressForSwitch(switchName string) (string, error) {
@@ -296,6 +423,29 @@ Hyper-V\Get-VMDvdDrive -VMName $vmName | Hyper-V\Remove-VMDvdDrive
 	return err
 }
 
+// DeleteEmptyDvdDrives removes the DVD drives of the VM without media and
+// returns how many were removed.
+func DeleteEmptyDvdDrives(vmName string) (uint, error) {
+	var script = `
+param([string]$vmName)
+$drives = @(Hyper-V\Get-VMDvdDrive -VMName $vmName | Where-Object { -not $_.Path })
+$drives | Hyper-V\Remove-VMDvdDrive
+$drives.Count
+`
+
+	var ps powershell.PowerShellCmd
+	cmdOut, err := ps.Output(script, vmName)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := strconv.ParseUint(strings.TrimSpace(cmdOut), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint(count), nil
+}
+
 func MountFloppyDrive(vmName string, path string) error {
 	var script = `
 param([string]$vmName, [string]$path)
@@ -337,9 +487,15 @@ func getCreateVMScript(opts *scriptOptions) (string, error) {
 	if opts.FixedVHD {
 		opts.VHDX = opts.VMName + ".vhd"
 	}
+	opts.SetDiskArgs = opts.DiskPerformance.setArgs()
+	opts.SetVMArgs = opts.StoragePaths.setVMArgs()
+	opts.VHDDir = opts.Path
+	if opts.StoragePaths.VhdPath != "" {
+		opts.VHDDir = opts.StoragePaths.VhdPath
+	}
 
 	var tpl = template.Must(template.New("createVM").Parse(`
-$vhdPath = Join-Path -Path "{{ .Path }}" -ChildPath "{{ .VHDX }}"
+$vhdPath = Join-Path -Path "{{ .VHDDir }}" -ChildPath "{{ .VHDX }}"
 
 {{ if ne .HardDrivePath "" -}}
     {{- if .DiffDisks -}}
@@ -358,6 +514,12 @@ $vhdPath = Join-Path -Path "{{ .Path }}" -ChildPath "{{ .VHDX }}"
 Hyper-V\New-VM -Name "{{ .VMName }}" -Path "{{ .Path }}" -MemoryStartupBytes {{ .MemoryStartupBytes }} -VHDPath $vhdPath -SwitchName "{{ .SwitchName }}"
 {{- if eq .Generation 2}} -Generation {{ .Generation }} {{- end -}}
 {{- if ne .Version ""}} -Version {{ .Version }} {{- end -}}
+{{- if ne .SetDiskArgs "" }}
+Hyper-V\Get-VMHardDiskDrive -VMName "{{ .VMName }}" | Hyper-V\Set-VMHardDiskDrive {{ .SetDiskArgs }}
+{{- end -}}
+{{- if ne .SetVMArgs "" }}
+Hyper-V\Set-VM -Name "{{ .VMName }}" {{ .SetVMArgs }}
+{{- end -}}
 `))
 
 	var b bytes.Buffer
@@ -397,7 +559,8 @@ func CheckVMName(vmName string) error {
 
 func CreateVirtualMachine(vmName string, path string, harddrivePath string, ram int64,
 	diskSize int64, diskBlockSize int64, switchName string, generation uint,
-	diffDisks bool, fixedVHD bool, version string) error {
+	diffDisks bool, fixedVHD bool, version string, diskPerformance DiskPerformance,
+	storagePaths StoragePaths) error {
 	opts := scriptOptions{
 		Version:            version,
 		VMName:             vmName,
@@ -410,6 +573,8 @@ func CreateVirtualMachine(vmName string, path string, harddrivePath string, ram
 		Generation:         generation,
 		DiffDisks:          diffDisks,
 		FixedVHD:           fixedVHD,
+		DiskPerformance:    diskPerformance,
+		StoragePaths:       storagePaths,
 	}
 
 	script, err := getCreateVMScript(&opts)
@@ -442,6 +607,42 @@ if ((Get-Command Hyper-V\Set-Vm).Parameters["AutomaticCheckpointsEnabled"]) {
 	return err
 }
 
+func SetVirtualMachineCheckpointType(vmName string, checkpointType string) error {
+	var script = `
+param([string]$vmName, [string]$checkpointType)
+Hyper-V\Set-VM -Name $vmName -CheckpointType $checkpointType
+`
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName, checkpointType)
+	return err
+}
+
+func CreateVirtualMachineCheckpoint(vmName string, name string) error {
+	var script = `
+param([string]$vmName, [string]$name)
+Hyper-V\Checkpoint-VM -Name $vmName -SnapshotName $name -ErrorAction Stop
+`
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName, name)
+	return err
+}
+
+// RemoveVirtualMachineCheckpoint removes a checkpoint and waits for Hyper-V
+// to merge its differencing disks, so that the disks can be compacted or
+// exported afterwards.
+func RemoveVirtualMachineCheckpoint(vmName string, name string) error {
+	var script = `
+param([string]$vmName, [string]$name)
+Hyper-V\Get-VMSnapshot -VMName $vmName -Name $name -ErrorAction Stop | Hyper-V\Remove-VMSnapshot -ErrorAction Stop
+while ((Hyper-V\Get-VM -Name $vmName).OperationalStatus -contains 'MergingDisks') {
+    Start-Sleep -Seconds 1
+}
+`
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName, name)
+	return err
+}
+
 func ExportVmcxVirtualMachine(exportPath string, vmName string, snapshotName string, allSnapshots bool) error {
 	var script = `
 param([string]$exportPath, [string]$vmName, [string]$snapshotName, [string]$allSnapshotsString)
@@ -521,23 +722,60 @@ Hyper-V\Set-VMNetworkAdapter $vmName -staticmacaddress $mac
 	return err
 }
 
+// SetVmNetworkAdapterDynamicMacAddress lets Hyper-V assign the MAC address
+// of the network adapters of the VM again.
+func SetVmNetworkAdapterDynamicMacAddress(vmName string) error {
+	var script = `
+param([string]$vmName)
+Hyper-V\Set-VMNetworkAdapter -VMName $vmName -DynamicMacAddress
+`
+
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName)
+	return err
+}
+
+func DisconnectVirtualMachineNetworkAdapter(vmName string) error {
+	var script = `
+param([string]$vmName)
+Hyper-V\Get-VMNetworkAdapter -VMName $vmName | Hyper-V\Disconnect-VMNetworkAdapter
+`
+
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName)
+	return err
+}
+
+// UntagVirtualMachineVlan removes the VLAN of the network adapters of the
+// VM, leaving the management OS adapter of the switch alone.
+func UntagVirtualMachineVlan(vmName string) error {
+	var script = `
+param([string]$vmName)
+Hyper-V\Set-VMNetworkAdapterVlan -VMName $vmName -Untagged
+`
+
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName)
+	return err
+}
+
 func ImportVmcxVirtualMachine(importPath string, vmName string, harddrivePath string,
-	ram int64, switchName string, copyTF bool) error {
+	ram int64, switchName string, storagePaths StoragePaths) error {
 
 	var script = `
-param([string]$importPath, [string]$vmName, [string]$harddrivePath, [long]$memoryStartupBytes, [string]$switchName, [string]$copy)
+param([string]$importPath, [string]$vmName, [string]$harddrivePath, [long]$memoryStartupBytes, [string]$switchName, [string]$vhdDestinationPath, [string]$snapshotFilePath, [string]$smartPagingFilePath)
 
 $VirtualHarddisksPath = Join-Path -Path $importPath -ChildPath 'Virtual Hard Disks'
+if (!$snapshotFilePath) {
+	$snapshotFilePath = $importPath
+}
+if (!$smartPagingFilePath) {
+	$smartPagingFilePath = $importPath
+}
 if (!(Test-Path $VirtualHarddisksPath)) {
 	New-Item -ItemType Directory -Force -Path $VirtualHarddisksPath
 }
 
-$vhdPath = ""
-if ($harddrivePath){
-	$vhdx = $vmName + '.vhdx'
-	$vhdPath = Join-Path -Path $VirtualHarddisksPath -ChildPath $vhdx
-}
-
 $VirtualMachinesPath = Join-Path $importPath 'Virtual Machines'
 if (!(Test-Path $VirtualMachinesPath)) {
 	New-Item -ItemType Directory -Force -Path $VirtualMachinesPath
@@ -551,13 +789,27 @@ if (!$VirtualMachinePath){
     $VirtualMachinePath = Get-ChildItem -Path $importPath -Filter *.xml -Recurse -ErrorAction SilentlyContinue | select -First 1 | %{$_.FullName}
 }
 
-$copyBool = $false
-switch($copy) {
-    "true" { $copyBool = $true }
-    default { $copyBool = $false }
+# The VM is registered in place unless the disks have to be copied to a
+# separate location. The new VM ID keeps the copied configuration files
+# from clashing with the imported ones.
+$copyDisks = $false
+if ($vhdDestinationPath) {
+	$copyDisks = $true
+} else {
+	$vhdDestinationPath = $VirtualHarddisksPath
 }
 
-$compatibilityReport = Hyper-V\Compare-VM -Path $VirtualMachinePath -VirtualMachinePath $importPath -SmartPagingFilePath $importPath -SnapshotFilePath $importPath -VhdDestinationPath $VirtualHarddisksPath -GenerateNewId -Copy:$false
+# The replacement disk goes next to the other disks of the VM
+$vhdPath = ""
+if ($harddrivePath){
+	if (!(Test-Path $vhdDestinationPath)) {
+		New-Item -ItemType Directory -Force -Path $vhdDestinationPath
+	}
+	$vhdx = $vmName + '.vhdx'
+	$vhdPath = Join-Path -Path $vhdDestinationPath -ChildPath $vhdx
+}
+
+$compatibilityReport = Hyper-V\Compare-VM -Path $VirtualMachinePath -VirtualMachinePath $importPath -SmartPagingFilePath $smartPagingFilePath -SnapshotFilePath $snapshotFilePath -VhdDestinationPath $vhdDestinationPath -GenerateNewId -Copy:$copyDisks
 if ($vhdPath){
 	Copy-Item -Path $harddrivePath -Destination $vhdPath
 	$existingFirstHarddrive = $compatibilityReport.VM.HardDrives | Select -First 1
@@ -578,14 +830,16 @@ if ($vm) {
 }
 	`
 	var ps powershell.PowerShellCmd
-	err := ps.Run(script, importPath, vmName, harddrivePath, strconv.FormatInt(ram, 10), switchName, strconv.FormatBool(copyTF))
+	err := ps.Run(script, importPath, vmName, harddrivePath, strconv.FormatInt(ram, 10), switchName,
+		storagePaths.VhdPath, storagePaths.CheckpointPath, storagePaths.SmartPagingPath)
 
 	return err
 }
 
 func CloneVirtualMachine(cloneFromVmcxPath string, cloneFromVmName string,
 	cloneFromSnapshotName string, cloneAllSnapshots bool, vmName string,
-	path string, harddrivePath string, ram int64, switchName string, copyTF bool) error {
+	path string, harddrivePath string, ram int64, switchName string,
+	storagePaths StoragePaths) error {
 
 	if cloneFromVmName != "" {
 		if err := ExportVmcxVirtualMachine(path, cloneFromVmName,
@@ -600,7 +854,7 @@ func CloneVirtualMachine(cloneFromVmcxPath string, cloneFromVmName string,
 		}
 	}
 
-	if err := ImportVmcxVirtualMachine(path, vmName, harddrivePath, ram, switchName, copyTF); err != nil {
+	if err := ImportVmcxVirtualMachine(path, vmName, harddrivePath, ram, switchName, storagePaths); err != nil {
 		return err
 	}
 
@@ -627,6 +881,222 @@ Hyper-V\Get-VHD -Path $firstVhdPath | Hyper-V\Resize-VHD -SizeBytes "$newSizeInB
 	return err
 }
 
+func GetVirtualMachineVhdSize(vmName string) (uint64, error) {
+
+	var script = `
+param([string]$vmName)
+
+$firstVhdPath = Hyper-V\Get-Vm -Name $vmName | Hyper-V\Get-VMHardDiskDrive | Sort-Object ControllerNumber,ControllerLocation | Select-Object -First 1 -ExpandProperty Path
+
+if (-not $firstVhdPath) {
+	throw 'Unable to get the size of the hard disk drive on virtual machine. No hard disk drive was found.'
+}
+
+Hyper-V\Get-VHD -Path $firstVhdPath | Select-Object -ExpandProperty Size
+`
+
+	var ps powershell.PowerShellCmd
+	cmdOut, err := ps.Output(script, vmName)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := strconv.ParseUint(strings.TrimSpace(cmdOut), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// ExpandVirtualMachineVhdPartition mounts the first vhd of a powered off
+// virtual machine on the host and grows its last partition to fill the disk.
+// Only file systems Resize-Partition can handle are expanded; the file system
+// found on the partition is returned either way.
+func ExpandVirtualMachineVhdPartition(vmName string) (string, bool, error) {
+
+	var script = `
+param([string]$vmName)
+
+$firstVhdPath = Hyper-V\Get-Vm -Name $vmName | Hyper-V\Get-VMHardDiskDrive | Sort-Object ControllerNumber,ControllerLocation | Select-Object -First 1 -ExpandProperty Path
+
+if (-not $firstVhdPath) {
+	throw 'Unable to expand partition on virtual machine. No hard disk drive was found.'
+}
+
+$disk = Hyper-V\Mount-VHD -Path $firstVhdPath -NoDriveLetter -Passthru | Get-Disk
+try {
+	$partition = Get-Partition -DiskNumber $disk.Number | Sort-Object Offset | Select-Object -Last 1
+	if (-not $partition) {
+		throw 'Unable to expand partition on virtual machine. No partition was found.'
+	}
+
+	$fileSystem = ($partition | Get-Volume -ErrorAction SilentlyContinue).FileSystem
+	if ($fileSystem -in 'NTFS','ReFS') {
+		$supportedSize = Get-PartitionSupportedSize -DiskNumber $disk.Number -PartitionNumber $partition.PartitionNumber
+		if ($supportedSize.SizeMax -gt $partition.Size) {
+			Resize-Partition -DiskNumber $disk.Number -PartitionNumber $partition.PartitionNumber -Size $supportedSize.SizeMax
+		}
+		"True;$fileSystem"
+	} else {
+		"False;$fileSystem"
+	}
+} finally {
+	Hyper-V\Dismount-VHD -Path $firstVhdPath
+}
+`
+
+	var ps powershell.PowerShellCmd
+	cmdOut, err := ps.Output(script, vmName)
+	if err != nil {
+		return "", false, err
+	}
+
+	expanded, fileSystem, found := strings.Cut(strings.TrimSpace(cmdOut), ";")
+	if !found {
+		return "", false, fmt.Errorf("Unexpected output expanding partition: %s", cmdOut)
+	}
+
+	return fileSystem, expanded == "True", nil
+}
+
+// MountVirtualMachineVhd mounts the first vhd of a powered off virtual
+// machine on the host and makes a partition of it reachable through a drive
+// letter. A partitionNumber of 0 picks the largest partition. The path of
+// the vhd and the root of the partition are returned.
+func MountVirtualMachineVhd(vmName string, partitionNumber uint) (string, string, error) {
+
+	var script = `
+param([string]$vmName, [int]$partitionNumber)
+
+$vhdPath = Hyper-V\Get-Vm -Name $vmName | Hyper-V\Get-VMHardDiskDrive | Sort-Object ControllerNumber,ControllerLocation | Select-Object -First 1 -ExpandProperty Path
+
+if (-not $vhdPath) {
+	throw 'Unable to mount hard disk drive of virtual machine. No hard disk drive was found.'
+}
+
+$disk = Hyper-V\Mount-VHD -Path $vhdPath -NoDriveLetter -Passthru | Get-Disk
+try {
+	if ($partitionNumber -gt 0) {
+		$partition = Get-Partition -DiskNumber $disk.Number -PartitionNumber $partitionNumber
+	} else {
+		$partition = Get-Partition -DiskNumber $disk.Number | Sort-Object Size -Descending | Select-Object -First 1
+	}
+	if (-not $partition) {
+		throw 'Unable to mount hard disk drive of virtual machine. No partition was found.'
+	}
+
+	if (-not $partition.DriveLetter -or $partition.DriveLetter -eq [char]0) {
+		$partition | Add-PartitionAccessPath -AssignDriveLetter
+		$partition = Get-Partition -DiskNumber $disk.Number -PartitionNumber $partition.PartitionNumber
+	}
+} catch {
+	Hyper-V\Dismount-VHD -Path $vhdPath
+	throw
+}
+
+$vhdPath
+"$($partition.DriveLetter):\"
+`
+
+	var ps powershell.PowerShellCmd
+	cmdOut, err := ps.Output(script, vmName, strconv.FormatUint(uint64(partitionNumber), 10))
+	if err != nil {
+		return "", "", err
+	}
+
+	// Cmdlets may write to the output before the two lines of the result
+	lines := strings.Split(strings.TrimSpace(cmdOut), "\n")
+	if len(lines) < 2 {
+		return "", "", fmt.Errorf("Unexpected output mounting vhd: %s", cmdOut)
+	}
+
+	vhdPath := strings.TrimSpace(lines[len(lines)-2])
+	root := strings.TrimSpace(lines[len(lines)-1])
+	if len(root) != 3 || !strings.HasSuffix(root, `:\`) {
+		// The vhd is mounted, so return its path for the caller to dismount
+		return vhdPath, "", fmt.Errorf("Unexpected output mounting vhd: %s", cmdOut)
+	}
+
+	return vhdPath, root, nil
+}
+
+func CopyFileToMountedVhd(source string, destination string) error {
+
+	var script = `
+param([string]$source, [string]$destination)
+$parent = Split-Path -Parent $destination
+if (-not (Test-Path -Path $parent)) {
+	New-Item -ItemType Directory -Path $parent -Force | Out-Null
+}
+Copy-Item -Path $source -Destination $destination -Recurse -Force
+`
+
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, source, destination)
+	return err
+}
+
+// CopyFileToVirtualMachine copies a file or directory from the host into
+// the running guest with Copy-VMFile, which goes through the Guest Service
+// Interface rather than the network. Directories are copied file by file,
+// as Copy-VMFile only accepts files.
+func CopyFileToVirtualMachine(vmName string, source string, destination string) error {
+
+	var script = `
+param([string]$vmName, [string]$source, [string]$destination)
+$ErrorActionPreference = 'Stop'
+$item = Get-Item -LiteralPath $source
+if ($item.PSIsContainer) {
+	# Keep the separator style of the guest path, so Linux guests work too
+	$separator = '\'
+	if ($destination.Contains('/')) {
+		$separator = '/'
+	}
+	Get-ChildItem -LiteralPath $item.FullName -Recurse -File | ForEach-Object {
+		$relative = $_.FullName.Substring($item.FullName.Length).TrimStart('\').Replace('\', $separator)
+		$target = $destination.TrimEnd('\', '/') + $separator + $relative
+		Hyper-V\Copy-VMFile -Name $vmName -SourcePath $_.FullName -DestinationPath $target -FileSource Host -CreateFullPath -Force
+	}
+} else {
+	Hyper-V\Copy-VMFile -Name $vmName -SourcePath $item.FullName -DestinationPath $destination -FileSource Host -CreateFullPath -Force
+}
+`
+
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName, source, destination)
+	return err
+}
+
+func AddDriverToMountedVhd(root string, driver string, forceUnsigned bool) error {
+
+	var script = `
+param([string]$root, [string]$driver, [string]$forceUnsignedString)
+$forceUnsigned = [System.Boolean]::Parse($forceUnsignedString)
+Add-WindowsDriver -Path $root -Driver $driver -Recurse -ForceUnsigned:$forceUnsigned | Out-Null
+`
+	forceUnsignedString := "False"
+	if forceUnsigned {
+		forceUnsignedString = "True"
+	}
+
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, root, driver, forceUnsignedString)
+	return err
+}
+
+func DismountVhd(vhdPath string) error {
+
+	var script = `
+param([string]$vhdPath)
+Hyper-V\Dismount-VHD -Path $vhdPath
+`
+
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vhdPath)
+	return err
+}
+
 func GetVirtualMachineGeneration(vmName string) (uint, error) {
 	var script = `
 param([string]$vmName)
@@ -675,6 +1145,127 @@ return $vm.Id.ToString()
 	return vmId, nil
 }
 
+// RegisterHvsockService adds a service GUID to the Hyper-V socket services
+// of the host, unless it is already there. This needs administrator rights.
+func RegisterHvsockService(serviceId string, name string) error {
+	var script = `
+param([string]$serviceId, [string]$name)
+$path = "HKLM:\SOFTWARE\Microsoft\Windows NT\CurrentVersion\Virtualization\GuestCommunicationServices\$serviceId"
+if (-not (Test-Path -Path $path)) {
+    New-Item -Path $path -Force -ErrorAction Stop | Out-Null
+    New-ItemProperty -Path $path -Name ElementName -Value $name -PropertyType String -Force -ErrorAction Stop | Out-Null
+}
+`
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, serviceId, name)
+	return err
+}
+
+// GetGuestIntrinsicKvpItems returns the KVP items the integration services
+// of the guest publish, such as OSName and FullyQualifiedDomainName.
+func GetGuestIntrinsicKvpItems(vmName string) (map[string]string, error) {
+	return getKvpItems(vmName, "GuestIntrinsicExchangeItems")
+}
+
+// GetGuestKvpItems returns the KVP items the guest set in its pool.
+func GetGuestKvpItems(vmName string) (map[string]string, error) {
+	return getKvpItems(vmName, "GuestExchangeItems")
+}
+
+func getKvpItems(vmName string, property string) (map[string]string, error) {
+	var script = `
+param([string]$vmName, [string]$property)
+$vm = Get-CimInstance -ClassName Msvm_ComputerSystem -Namespace root\virtualization\v2 -Filter "ElementName='$vmName'" -ErrorAction Stop
+if (!$vm) {
+    throw "Virtual machine $vmName not found"
+}
+$kvp = Get-CimAssociatedInstance -InputObject $vm -ResultClassName Msvm_KvpExchangeComponent -ErrorAction Stop
+$items = @{}
+foreach ($item in $kvp.$property) {
+    $xml = [xml]$item
+    $name = $xml.SelectSingleNode("/INSTANCE/PROPERTY[@NAME='Name']/VALUE")
+    $data = $xml.SelectSingleNode("/INSTANCE/PROPERTY[@NAME='Data']/VALUE")
+    if ($name -ne $null) {
+        $items[$name.InnerText] = if ($data -ne $null) { $data.InnerText } else { "" }
+    }
+}
+ConvertTo-Json -Compress -InputObject $items
+`
+	var ps powershell.PowerShellCmd
+	cmdOut, err := ps.Output(script, vmName, property)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseKvpItems(cmdOut)
+}
+
+func parseKvpItems(output string) (map[string]string, error) {
+	items := map[string]string{}
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return items, nil
+	}
+	if err := json.Unmarshal([]byte(output), &items); err != nil {
+		return nil, fmt.Errorf("failed to parse KVP items: %s", err)
+	}
+	return items, nil
+}
+
+// The KVP item script waits for the job a method of the management
+// service starts, and throws when the method or the job fails.
+const kvpItemScript = `
+param([string]$vmName, [string]$method, [string]$name, [string]$data)
+$service = Get-WmiObject -Namespace root\virtualization\v2 -Class Msvm_VirtualSystemManagementService
+$vm = Get-WmiObject -Namespace root\virtualization\v2 -Class Msvm_ComputerSystem -Filter "ElementName='$vmName'"
+if (!$vm) {
+    throw "Virtual machine $vmName not found"
+}
+$item = ([WMIClass]"root\virtualization\v2:Msvm_KvpExchangeDataItem").CreateInstance()
+$item.Name = $name
+$item.Data = $data
+$item.Source = 0
+
+function Invoke-KvpMethod([string]$method) {
+    $result = $service.$method($vm, $item.PSBase.GetText(1))
+    if ($result.ReturnValue -eq 4096) {
+        $job = [WMI]$result.Job
+        while ($job.JobState -eq 3 -or $job.JobState -eq 4) {
+            Start-Sleep -Milliseconds 100
+            $job = [WMI]$result.Job
+        }
+        if ($job.JobState -ne 7) {
+            return $job.ErrorDescription
+        }
+    } elseif ($result.ReturnValue -ne 0) {
+        return "return value $($result.ReturnValue)"
+    }
+}
+
+$err = Invoke-KvpMethod $method
+if ($err -and $method -eq 'AddKvpItems') {
+    # The item already exists
+    $err = Invoke-KvpMethod 'ModifyKvpItems'
+}
+if ($err) {
+    throw "$method failed for KVP item ${name}: $err"
+}
+`
+
+// SetHostKvpItem adds or changes a KVP item the host sends to the guest.
+func SetHostKvpItem(vmName string, name string, data string) error {
+	var ps powershell.PowerShellCmd
+	err := ps.Run(kvpItemScript, vmName, "AddKvpItems", name, data)
+	return err
+}
+
+// RemoveHostKvpItem removes a KVP item the host sends to the guest.
+func RemoveHostKvpItem(vmName string, name string) error {
+	var ps powershell.PowerShellCmd
+	err := ps.Run(kvpItemScript, vmName, "RemoveKvpItems", name, "")
+	return err
+}
+
 func SetVirtualMachineCpuCount(vmName string, cpu uint) error {
 
 	var script = `
@@ -686,6 +1277,61 @@ Hyper-V\Set-VMProcessor -VMName $vmName -Count $cpu
 	return err
 }
 
+// SetVirtualMachineMemory changes the startup memory and the dynamic memory
+// settings of the VM with a single Set-VMMemory call, so Hyper-V validates
+// the new startup memory against the new limits rather than the current
+// ones.
+func SetVirtualMachineMemory(vmName string, ramBytes int64, enableDynamicMemory *bool, dynamicMemory DynamicMemory) error {
+	var args []string
+	if enableDynamicMemory != nil {
+		args = append(args, fmt.Sprintf("-DynamicMemoryEnabled $%t", *enableDynamicMemory))
+	}
+	if ramBytes > 0 {
+		args = append(args, fmt.Sprintf("-StartupBytes %d", ramBytes))
+	}
+	// The limits can only be changed while dynamic memory is enabled
+	if limits := dynamicMemory.setArgs(); enableDynamicMemory != nil && *enableDynamicMemory && limits != "" {
+		args = append(args, limits)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	var script = `
+param([string]$vmName)
+Hyper-V\Set-VMMemory -VMName $vmName ` + strings.Join(args, " ") + `
+`
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName)
+	return err
+}
+
+func SetVirtualMachineNotes(vmName string, notes string) error {
+
+	var script = `
+param([string]$vmName, [string]$notes)
+Hyper-V\Set-VM -Name $vmName -Notes $notes
+`
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName, notes)
+	return err
+}
+
+func SetVirtualMachineProcessor(vmName string, settings ProcessorSettings) error {
+	args := settings.setArgs()
+	if args == "" {
+		return nil
+	}
+
+	var script = `
+param([string]$vmName)
+Hyper-V\Set-VMProcessor -VMName $vmName ` + args + `
+`
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName)
+	return err
+}
+
 func SetVirtualMachineVirtualizationExtensions(vmName string, enableVirtualizationExtensions bool) error {
 
 	var script = `
@@ -702,12 +1348,17 @@ Hyper-V\Set-VMProcessor -VMName $vmName -ExposeVirtualizationExtensions $exposeV
 	return err
 }
 
-func SetVirtualMachineDynamicMemory(vmName string, enableDynamicMemory bool) error {
+func SetVirtualMachineDynamicMemory(vmName string, enableDynamicMemory bool, dynamicMemory DynamicMemory) error {
 
 	var script = `
 param([string]$vmName, [string]$enableDynamicMemoryString)
 $enableDynamicMemory = [System.Boolean]::Parse($enableDynamicMemoryString)
-Hyper-V\Set-VMMemory -VMName $vmName -DynamicMemoryEnabled $enableDynamicMemory
+Hyper-V\Set-VMMemory -VMName $vmName -DynamicMemoryEnabled $enableDynamicMemory`
+	// The limits can only be changed while dynamic memory is enabled
+	if args := dynamicMemory.setArgs(); enableDynamicMemory && args != "" {
+		script += " " + args
+	}
+	script += `
 `
 	enableDynamicMemoryString := "False"
 	if enableDynamicMemory {
@@ -718,6 +1369,50 @@ Hyper-V\Set-VMMemory -VMName $vmName -DynamicMemoryEnabled $enableDynamicMemory
 	return err
 }
 
+// GetVirtualMachineFramebuffer captures the console of the VM as raw
+// pixels at its current resolution.
+func GetVirtualMachineFramebuffer(vmName string) (Framebuffer, error) {
+	var script = `
+param([string]$vmName)
+$vm = Get-CimInstance -ClassName Msvm_ComputerSystem -Namespace root\virtualization\v2 -Filter "ElementName='$vmName'"
+if (!$vm) {
+    throw "Virtual machine $vmName not found"
+}
+$videoHead = Get-CimAssociatedInstance -InputObject $vm -ResultClassName Msvm_VideoHead | Select-Object -First 1
+$width = [uint16]@($videoHead.CurrentHorizontalResolution)[0]
+$height = [uint16]@($videoHead.CurrentVerticalResolution)[0]
+if (!$width -or !$height) {
+    throw "Virtual machine $vmName has no active display"
+}
+$service = Get-CimInstance -ClassName Msvm_VirtualSystemManagementService -Namespace root\virtualization\v2
+$result = Invoke-CimMethod -InputObject $service -MethodName GetVirtualSystemThumbnailImage -Arguments @{TargetSystem = $vm; WidthPixels = $width; HeightPixels = $height}
+if ($result.ReturnValue -ne 0) {
+    throw "GetVirtualSystemThumbnailImage failed with return value $($result.ReturnValue)"
+}
+"$width $height $([Convert]::ToBase64String($result.ImageData))"
+`
+	var ps powershell.PowerShellCmd
+	cmdOut, err := ps.Output(script, vmName)
+	if err != nil {
+		return Framebuffer{}, err
+	}
+
+	return parseFramebuffer(cmdOut)
+}
+
+// SetVirtualMachineComPort connects COM port number of the VM to the named
+// pipe path. An empty path disconnects the port.
+func SetVirtualMachineComPort(vmName string, number uint, path string) error {
+
+	var script = `
+param([string]$vmName, [int]$number, [string]$path)
+Hyper-V\Set-VMComPort -VMName $vmName -Number $number -Path $path
+`
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName, strconv.FormatInt(int64(number), 10), path)
+	return err
+}
+
 func SetVirtualMachineMacSpoofing(vmName string, enableMacSpoofing bool) error {
 	var script = `
 param([string]$vmName, $enableMacSpoofing)
@@ -809,6 +1504,7 @@ if (Test-Path -Path ([IO.Path]::Combine($path, $vmName, 'Virtual Machines', '*.V
 {
   $vm = Hyper-V\Get-VM -Name $vmName
   $vm_adapter = Hyper-V\Get-VMNetworkAdapter -VM $vm | Select -First 1
+  $vm_memory = Hyper-V\Get-VMMemory -VM $vm
 
   $config = [xml]@"
 <?xml version="1.0" ?>
@@ -827,6 +1523,8 @@ if (Test-Path -Path ([IO.Path]::Combine($path, $vmName, 'Virtual Machines', '*.V
         <limit type="integer">$($vm.MemoryMaximum / 1MB)</limit>
         <reservation type="integer">$($vm.MemoryMinimum / 1MB)</reservation>
         <size type="integer">$($vm.MemoryStartup / 1MB)</size>
+        <target_memory_buffer type="integer">$($vm_memory.Buffer)</target_memory_buffer>
+        <weight type="integer">$($vm_memory.Priority * 100)</weight>
       </bank>
     </memory>
   </settings>
@@ -1125,29 +1823,40 @@ if ($vm.State -eq [Microsoft.HyperV.PowerShell.VMState]::Running) {
 	return err
 }
 
+// IntegrationServiceIds maps the names of the integration services to
+// their IDs. The IDs are used to look the services up, as the names are
+// localized on non-English hosts.
+var IntegrationServiceIds = map[string]string{
+	"Time Synchronization":    "2497F4DE-E9FA-4204-80E4-4B75C46419C0",
+	"Heartbeat":               "84EAAE65-2F2E-45F5-9BB5-0E857DC8EB47",
+	"Key-Value Pair Exchange": "2A34B1C2-FD73-4043-8A5B-DD2159BC743F",
+	"Shutdown":                "9F8233AC-BE49-4C79-8EE3-E7E1985B2077",
+	"VSS":                     "5CED1297-4598-4915-A5FC-AD21BB4D02A4",
+	"Guest Service Interface": "6C09BB55-D683-4DA0-8931-C9BF705F6480",
+}
+
 func EnableVirtualMachineIntegrationService(vmName string, integrationServiceName string) error {
+	return setVirtualMachineIntegrationService(vmName, integrationServiceName, true)
+}
 
-	integrationServiceId := ""
-	switch integrationServiceName {
-	case "Time Synchronization":
-		integrationServiceId = "2497F4DE-E9FA-4204-80E4-4B75C46419C0"
-	case "Heartbeat":
-		integrationServiceId = "84EAAE65-2F2E-45F5-9BB5-0E857DC8EB47"
-	case "Key-Value Pair Exchange":
-		integrationServiceId = "2A34B1C2-FD73-4043-8A5B-DD2159BC743F"
-	case "Shutdown":
-		integrationServiceId = "9F8233AC-BE49-4C79-8EE3-E7E1985B2077"
-	case "VSS":
-		integrationServiceId = "5CED1297-4598-4915-A5FC-AD21BB4D02A4"
-	case "Guest Service Interface":
-		integrationServiceId = "6C09BB55-D683-4DA0-8931-C9BF705F6480"
-	default:
-		panic("unrecognized Integration Service Name")
+func DisableVirtualMachineIntegrationService(vmName string, integrationServiceName string) error {
+	return setVirtualMachineIntegrationService(vmName, integrationServiceName, false)
+}
+
+func setVirtualMachineIntegrationService(vmName string, integrationServiceName string, enable bool) error {
+	integrationServiceId, ok := IntegrationServiceIds[integrationServiceName]
+	if !ok {
+		return fmt.Errorf("unrecognized Integration Service Name: %s", integrationServiceName)
+	}
+
+	cmdlet := "Enable-VMIntegrationService"
+	if !enable {
+		cmdlet = "Disable-VMIntegrationService"
 	}
 
 	var script = `
 param([string]$vmName,[string]$integrationServiceId)
-Hyper-V\Get-VMIntegrationService -VmName $vmName | ?{$_.Id -match $integrationServiceId} | Hyper-V\Enable-VMIntegrationService
+Hyper-V\Get-VMIntegrationService -VmName $vmName | ?{$_.Id -match $integrationServiceId} | Hyper-V\` + cmdlet + `
 `
 
 	var ps powershell.PowerShellCmd
@@ -1282,14 +1991,19 @@ Hyper-V\Get-VMNetworkAdapter -VMName $vmName | Hyper-V\Connect-VMNetworkAdapter
 }
 
 func AddVirtualMachineHardDiskDrive(vmName string, vhdRoot string, vhdName string, vhdSizeBytes int64,
-	vhdBlockSize int64, controllerType string) error {
+	vhdBlockSize int64, controllerType string, diskPerformance DiskPerformance) error {
 
 	var script = `
 param([string]$vmName,[string]$vhdRoot, [string]$vhdName, [string]$vhdSizeInBytes, [string]$vhdBlockSizeInByte, [string]$controllerType)
 $vhdPath = Join-Path -Path $vhdRoot -ChildPath $vhdName
 Hyper-V\New-VHD -path $vhdPath -SizeBytes $vhdSizeInBytes -BlockSizeBytes $vhdBlockSizeInByte
-Hyper-V\Add-VMHardDiskDrive -VMName $vmName -path $vhdPath -controllerType $controllerType
+$drive = Hyper-V\Add-VMHardDiskDrive -VMName $vmName -path $vhdPath -controllerType $controllerType -Passthru
 `
+	if args := diskPerformance.setArgs(); args != "" {
+		script += `$drive | Hyper-V\Set-VMHardDiskDrive ` + args + `
+`
+	}
+
 	var ps powershell.PowerShellCmd
 	err := ps.Run(script, vmName, vhdRoot, vhdName, strconv.FormatInt(vhdSizeBytes, 10), strconv.FormatInt(vhdBlockSize, 10), controllerType)
 	return err
@@ -1450,190 +2164,6 @@ if ($vm.State -eq [Microsoft.HyperV.PowerShell.VMState]::Running) {
 	return err
 }
 
-func TypeScanCodes(vmName string, scanCodes string) error {
-	if len(scanCodes) == 0 {
-		return nil
-	}
-
-	var script = `
-param([string]$vmName, [string]$scanCodes)
-	#Requires -Version 3
-
-	function Hyper-V\Get-VMConsole
-	{
-	    [CmdletBinding()]
-	    param (
-	        [Parameter(Mandatory)]
-	        [string] $VMName
-	    )
-
-	    $ErrorActionPreference = "Stop"
-
-	    $vm = Get-CimInstance -Namespace "root\virtualization\v2" -ClassName Msvm_ComputerSystem -ErrorAction Ignore -Verbose:$false | where ElementName -eq $VMName | select -first 1
-	    if ($vm -eq $null){
-	        Write-Error ("VirtualMachine({0}) is not found!" -f $VMName)
-	    }
-
-	    $vmKeyboard = $vm | Get-CimAssociatedInstance -ResultClassName "Msvm_Keyboard" -ErrorAction Ignore -Verbose:$false
-
-		if ($vmKeyboard -eq $null) {
-			$vmKeyboard = Get-CimInstance -Namespace "root\virtualization\v2" -ClassName Msvm_Keyboard -ErrorAction Ignore -Verbose:$false | where SystemName -eq $vm.Name | select -first 1
-		}
-
-		if ($vmKeyboard -eq $null) {
-			$vmKeyboard = Get-CimInstance -Namespace "root\virtualization" -ClassName Msvm_Keyboard -ErrorAction Ignore -Verbose:$false | where SystemName -eq $vm.Name | select -first 1
-		}
-
-	    if ($vmKeyboard -eq $null){
-	        Write-Error ("VirtualMachine({0}) keyboard class is not found!" -f $VMName)
-	    }
-
-	    #TODO: It may be better using New-Module -AsCustomObject to return console object?
-
-	    #Console object to return
-	    $console = [pscustomobject] @{
-	        Msvm_ComputerSystem = $vm
-	        Msvm_Keyboard = $vmKeyboard
-	    }
-
-	    #Need to import assembly to use System.Windows.Input.Key
-	    Add-Type -AssemblyName WindowsBase
-
-	    #region Add Console Members
-	    $console | Add-Member -MemberType ScriptMethod -Name TypeText -Value {
-	        [OutputType([bool])]
-	        param (
-	            [ValidateNotNullOrEmpty()]
-	            [Parameter(Mandatory)]
-	            [string] $AsciiText
-	        )
-	        $result = $this.Msvm_Keyboard | Invoke-CimMethod -MethodName "TypeText" -Arguments @{ asciiText = $AsciiText }
-	        return (0 -eq $result.ReturnValue)
-	    }
-
-	    #Define method:TypeCtrlAltDel
-	    $console | Add-Member -MemberType ScriptMethod -Name TypeCtrlAltDel -Value {
-	        $result = $this.Msvm_Keyboard | Invoke-CimMethod -MethodName "TypeCtrlAltDel"
-	        return (0 -eq $result.ReturnValue)
-	    }
-
-	    #Define method:TypeKey
-	    $console | Add-Member -MemberType ScriptMethod -Name TypeKey -Value {
-	        [OutputType([bool])]
-	        param (
-	            [Parameter(Mandatory)]
-	            [Windows.Input.Key] $Key,
-	            [Windows.Input.ModifierKeys] $ModifierKey = [Windows.Input.ModifierKeys]::None
-	        )
-
-	        $keyCode = [Windows.Input.KeyInterop]::VirtualKeyFromKey($Key)
-
-	        switch ($ModifierKey)
-	        {
-	            ([Windows.Input.ModifierKeys]::Control){ $modifierKeyCode = [Windows.Input.KeyInterop]::VirtualKeyFromKey([Windows.Input.Key]::LeftCtrl)}
-	            ([Windows.Input.ModifierKeys]::Alt){ $modifierKeyCode = [Windows.Input.KeyInterop]::VirtualKeyFromKey([Windows.Input.Key]::LeftAlt)}
-	            ([Windows.Input.ModifierKeys]::Shift){ $modifierKeyCode = [Windows.Input.KeyInterop]::VirtualKeyFromKey([Windows.Input.Key]::LeftShift)}
-	            ([Windows.Input.ModifierKeys]::Windows){ $modifierKeyCode = [Windows.Input.KeyInterop]::VirtualKeyFromKey([Windows.Input.Key]::LWin)}
-	        }
-
-	        if ($ModifierKey -eq [Windows.Input.ModifierKeys]::None)
-	        {
-	            $result = $this.Msvm_Keyboard | Invoke-CimMethod -MethodName "TypeKey" -Arguments @{ keyCode = $keyCode }
-	        }
-	        else
-	        {
-	            $this.Msvm_Keyboard | Invoke-CimMethod -MethodName "PressKey" -Arguments @{ keyCode = $modifierKeyCode }
-	            $result = $this.Msvm_Keyboard | Invoke-CimMethod -MethodName "TypeKey" -Arguments @{ keyCode = $keyCode }
-	            $this.Msvm_Keyboard | Invoke-CimMethod -MethodName "ReleaseKey" -Arguments @{ keyCode = $modifierKeyCode }
-	        }
-	        $result = return (0 -eq $result.ReturnValue)
-	    }
-
-	    #Define method:Scancodes
-	    $console | Add-Member -MemberType ScriptMethod -Name TypeScancodes -Value {
-	        [OutputType([bool])]
-	        param (
-	            [Parameter(Mandatory)]
-	            [byte[]] $ScanCodes
-	        )
-	        $result = $this.Msvm_Keyboard | Invoke-CimMethod -MethodName "TypeScancodes" -Arguments @{ ScanCodes = $ScanCodes }
-	        return (0 -eq $result.ReturnValue)
-	    }
-
-	    #Define method:ExecCommand
-	    $console | Add-Member -MemberType ScriptMethod -Name ExecCommand -Value {
-	        param (
-	            [Parameter(Mandatory)]
-	            [string] $Command
-	        )
-	        if ([String]::IsNullOrEmpty($Command)){
-	            return
-	        }
-
-	        $console.TypeText($Command) > $null
-	        $console.TypeKey([Windows.Input.Key]::Enter) > $null
-	        #sleep -Milliseconds 100
-	    }
-
-	    #Define method:Dispose
-	    $console | Add-Member -MemberType ScriptMethod -Name Dispose -Value {
-	        $this.Msvm_ComputerSystem.Dispose()
-	        $this.Msvm_Keyboard.Dispose()
-	    }
-
-
-	    #endregion
-
-	    return $console
-	}
-
-	$vmConsole = Hyper-V\Get-VMConsole -VMName $vmName
-	$scanCodesToSend = ''
-	$scanCodes.Split(' ') | %{
-		$scanCode = $_
-
-		if ($scanCode.StartsWith('wait')){
-			$timeToWait = $scanCode.Substring(4)
-			if (!$timeToWait){
-				$timeToWait = "1"
-			}
-
-			if ($scanCodesToSend){
-				$scanCodesToSendByteArray = [byte[]]@($scanCodesToSend.Split(' ') | %{"0x$_"})
-
-                $scanCodesToSendByteArray | %{
-				    $vmConsole.TypeScancodes($_)
-                }
-			}
-
-			write-host "Special code <wait> found, will sleep $timeToWait second(s) at this point."
-			Start-Sleep -s $timeToWait
-
-			$scanCodesToSend = ''
-		} else {
-			if ($scanCodesToSend){
-				write-host "Sending special code '$scanCodesToSend' '$scanCode'"
-				$scanCodesToSend = "$scanCodesToSend $scanCode"
-			} else {
-				write-host "Sending char '$scanCode'"
-				$scanCodesToSend = "$scanCode"
-			}
-		}
-	}
-	if ($scanCodesToSend){
-		$scanCodesToSendByteArray = [byte[]]@($scanCodesToSend.Split(' ') | %{"0x$_"})
-
-        $scanCodesToSendByteArray | %{
-			$vmConsole.TypeScancodes($_)
-        }
-	}
-`
-
-	var ps powershell.PowerShellCmd
-	err := ps.Run(script, vmName, scanCodes)
-	return err
-}
-
 func ConnectVirtualMachine(vmName string) (context.CancelFunc, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cmd := exec.CommandContext(ctx, "vmconnect.exe", "localhost", vmName)
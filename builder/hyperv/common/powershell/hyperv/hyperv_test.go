@@ -132,4 +132,72 @@ Hyper-V\New-VM -Name "myvm" -Path "C://mypath" -MemoryStartupBytes 1024 -VHDPath
 	if ok := strings.Compare(scriptString, expected); ok != 0 {
 		t.Fatalf("EXPECTED: \n%s\n\n RECEIVED: \n%s\n\n", expected, scriptString)
 	}
+
+	opts.FixedVHD = false
+	opts.DiskPerformance = DiskPerformance{
+		MinimumIOPS:     100,
+		MaximumIOPS:     500,
+		CacheAttributes: "WriteCacheAndFUAEnabled",
+	}
+	scriptString, err = getCreateVMScript(&opts)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	expected = `$vhdPath = Join-Path -Path "C://mypath" -ChildPath "myvm.vhdx"
+Hyper-V\New-VHD -Path $vhdPath -SizeBytes 8192 -BlockSizeBytes 10
+Hyper-V\New-VM -Name "myvm" -Path "C://mypath" -MemoryStartupBytes 1024 -VHDPath $vhdPath -SwitchName "hyperv-vmx-switch"
+Hyper-V\Get-VMHardDiskDrive -VMName "myvm" | Hyper-V\Set-VMHardDiskDrive -MinimumIOPS 100 -MaximumIOPS 500 -OverrideCacheAttributes WriteCacheAndFUAEnabled`
+	if ok := strings.Compare(scriptString, expected); ok != 0 {
+		t.Fatalf("EXPECTED: \n%s\n\n RECEIVED: \n%s\n\n", expected, scriptString)
+	}
+
+	opts.DiskPerformance = DiskPerformance{QoSPolicyID: "2f3d2a0c-8e5c-4a1b-9d6f-6f0e4b7c1a2d"}
+	scriptString, err = getCreateVMScript(&opts)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	expected = `$vhdPath = Join-Path -Path "C://mypath" -ChildPath "myvm.vhdx"
+Hyper-V\New-VHD -Path $vhdPath -SizeBytes 8192 -BlockSizeBytes 10
+Hyper-V\New-VM -Name "myvm" -Path "C://mypath" -MemoryStartupBytes 1024 -VHDPath $vhdPath -SwitchName "hyperv-vmx-switch"
+Hyper-V\Get-VMHardDiskDrive -VMName "myvm" | Hyper-V\Set-VMHardDiskDrive -QoSPolicyID "2f3d2a0c-8e5c-4a1b-9d6f-6f0e4b7c1a2d"`
+	if ok := strings.Compare(scriptString, expected); ok != 0 {
+		t.Fatalf("EXPECTED: \n%s\n\n RECEIVED: \n%s\n\n", expected, scriptString)
+	}
+
+	opts.DiskPerformance = DiskPerformance{}
+	opts.StoragePaths = StoragePaths{
+		VhdPath:         "D://disks",
+		CheckpointPath:  "E://checkpoints",
+		SmartPagingPath: "F://paging",
+	}
+	scriptString, err = getCreateVMScript(&opts)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+	expected = `$vhdPath = Join-Path -Path "D://disks" -ChildPath "myvm.vhdx"
+Hyper-V\New-VHD -Path $vhdPath -SizeBytes 8192 -BlockSizeBytes 10
+Hyper-V\New-VM -Name "myvm" -Path "C://mypath" -MemoryStartupBytes 1024 -VHDPath $vhdPath -SwitchName "hyperv-vmx-switch"
+Hyper-V\Set-VM -Name "myvm" -SnapshotFileLocation "E://checkpoints" -SmartPagingFilePath "F://paging"`
+	if ok := strings.Compare(scriptString, expected); ok != 0 {
+		t.Fatalf("EXPECTED: \n%s\n\n RECEIVED: \n%s\n\n", expected, scriptString)
+	}
+}
+
+func Test_parseKvpItems(t *testing.T) {
+	items, err := parseKvpItems(`{"OSName":"Windows Server 2025 Datacenter","Empty":""}` + "\r\n")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(items) != 2 || items["OSName"] != "Windows Server 2025 Datacenter" {
+		t.Fatalf("Bad items: %v", items)
+	}
+
+	items, err = parseKvpItems("")
+	if err != nil || len(items) != 0 {
+		t.Fatalf("Should have no items, got: %v %v", items, err)
+	}
+
+	if _, err := parseKvpItems("OSName=Windows"); err == nil {
+		t.Fatal("Should have error")
+	}
 }
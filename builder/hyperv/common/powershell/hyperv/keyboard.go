@@ -0,0 +1,160 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hyperv
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/powershell"
+)
+
+// keyboardSessionScript finds the keyboard of the VM once and then serves
+// requests from its standard input until it is closed:
+//
+//	ping              - checks that the keyboard was found
+//	text <base64>     - Msvm_Keyboard.TypeText with the decoded ASCII text
+//	scancodes <hex,…> - Msvm_Keyboard.TypeScancodes with the given bytes
+//
+// Each request is answered with "ok" or "error <message>".
+const keyboardSessionScript = `
+param([string]$vmName)
+$ErrorActionPreference = "Stop"
+
+$vm = Get-CimInstance -Namespace "root\virtualization\v2" -ClassName Msvm_ComputerSystem -Verbose:$false | where ElementName -eq $vmName | select -first 1
+if ($vm -eq $null) {
+    throw ("VirtualMachine({0}) is not found!" -f $vmName)
+}
+
+$keyboard = $vm | Get-CimAssociatedInstance -ResultClassName "Msvm_Keyboard" -ErrorAction Ignore -Verbose:$false
+if ($keyboard -eq $null) {
+    $keyboard = Get-CimInstance -Namespace "root\virtualization\v2" -ClassName Msvm_Keyboard -ErrorAction Ignore -Verbose:$false | where SystemName -eq $vm.Name | select -first 1
+}
+if ($keyboard -eq $null) {
+    $keyboard = Get-CimInstance -Namespace "root\virtualization" -ClassName Msvm_Keyboard -ErrorAction Ignore -Verbose:$false | where SystemName -eq $vm.Name | select -first 1
+}
+if ($keyboard -eq $null) {
+    throw ("VirtualMachine({0}) keyboard class is not found!" -f $vmName)
+}
+
+while (($line = [Console]::In.ReadLine()) -ne $null) {
+    $request, $argument = $line.Split(' ', 2)
+    try {
+        $result = $null
+        switch ($request) {
+            'ping' {}
+            'text' {
+                $text = [Text.Encoding]::ASCII.GetString([Convert]::FromBase64String($argument))
+                $result = $keyboard | Invoke-CimMethod -MethodName "TypeText" -Arguments @{ asciiText = $text }
+            }
+            'scancodes' {
+                $scanCodes = [byte[]]@($argument.Split(',') | %{ [Convert]::ToByte($_, 16) })
+                $result = $keyboard | Invoke-CimMethod -MethodName "TypeScancodes" -Arguments @{ scanCodes = $scanCodes }
+            }
+            default {
+                throw "unknown request $request"
+            }
+        }
+        if ($result -ne $null -and $result.ReturnValue -ne 0) {
+            throw "$request failed with return value $($result.ReturnValue)"
+        }
+        [Console]::Out.WriteLine("ok")
+    } catch {
+        [Console]::Out.WriteLine("error " + ($_.Exception.Message -replace '\r?\n', ' '))
+    }
+    [Console]::Out.Flush()
+}
+`
+
+// The longest a keyboard request may take before the session is considered
+// stuck, such as in a hung WMI call
+const keyboardCallTimeout = 2 * time.Minute
+
+// keyboardCaller is the part of a PowerShell session the keyboard uses.
+type keyboardCaller interface {
+	Call(ctx context.Context, request string) (string, error)
+	Close() error
+}
+
+// KeyboardSession types into the console of a VM through a keyboard that
+// stays open for the whole session, so a request only costs the CIM call.
+type KeyboardSession struct {
+	session keyboardCaller
+	// Cancels the pending request, as typing has no context of its own
+	ctx context.Context
+}
+
+// OpenKeyboardSession starts a keyboard session for the VM. Close must be
+// called when done. Requests are abandoned once ctx is done.
+func OpenKeyboardSession(ctx context.Context, vmName string) (*KeyboardSession, error) {
+	var ps powershell.PowerShellCmd
+	session, err := ps.Start(keyboardSessionScript, vmName)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &KeyboardSession{session: session, ctx: ctx}
+	if err := k.call("ping"); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// TypeText types printable ASCII text, letting Hyper-V choose the keys.
+func (k *KeyboardSession) TypeText(text string) error {
+	if text == "" {
+		return nil
+	}
+	for _, c := range text {
+		if c < ' ' || c > '~' {
+			return fmt.Errorf("TypeText only supports printable ASCII, got %q", c)
+		}
+	}
+
+	return k.call("text " + base64.StdEncoding.EncodeToString([]byte(text)))
+}
+
+// TypeScanCodes sends PC XT scancodes given as hex bytes, such as "1c".
+func (k *KeyboardSession) TypeScanCodes(scanCodes []string) error {
+	if len(scanCodes) == 0 {
+		return nil
+	}
+	for _, code := range scanCodes {
+		if _, err := strconv.ParseUint(code, 16, 8); err != nil {
+			return fmt.Errorf("invalid scancode %q", code)
+		}
+	}
+
+	return k.call("scancodes " + strings.Join(scanCodes, ","))
+}
+
+// Close ends the session.
+func (k *KeyboardSession) Close() error {
+	return k.session.Close()
+}
+
+func (k *KeyboardSession) call(request string) error {
+	ctx, cancel := context.WithTimeout(k.ctx, keyboardCallTimeout)
+	defer cancel()
+
+	response, err := k.session.Call(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case response == "ok":
+		return nil
+	case strings.HasPrefix(response, "error "):
+		return fmt.Errorf("%s", strings.TrimPrefix(response, "error "))
+	default:
+		return fmt.Errorf("unexpected keyboard session response: %q", response)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hyperv
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testKeyboardCaller struct {
+	requests  []string
+	responses []string
+	err       error
+	closed    bool
+}
+
+func (c *testKeyboardCaller) Call(ctx context.Context, request string) (string, error) {
+	c.requests = append(c.requests, request)
+	if c.err != nil {
+		return "", c.err
+	}
+	response := "ok"
+	if len(c.responses) > 0 {
+		response, c.responses = c.responses[0], c.responses[1:]
+	}
+	return response, nil
+}
+
+func (c *testKeyboardCaller) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestKeyboardSession(t *testing.T) {
+	caller := new(testKeyboardCaller)
+	k := &KeyboardSession{session: caller, ctx: context.Background()}
+
+	if err := k.TypeText("root"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := k.TypeScanCodes([]string{"1c", "9c"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// Nothing to send
+	if err := k.TypeText(""); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := k.TypeScanCodes(nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := k.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{"text cm9vdA==", "scancodes 1c,9c"}
+	if !reflect.DeepEqual(caller.requests, expected) {
+		t.Fatalf("Expected %v, got %v", expected, caller.requests)
+	}
+	if !caller.closed {
+		t.Fatal("Should have closed the session")
+	}
+}
+
+func TestKeyboardSession_invalidInput(t *testing.T) {
+	caller := new(testKeyboardCaller)
+	k := &KeyboardSession{session: caller, ctx: context.Background()}
+
+	if err := k.TypeText("grüß"); err == nil {
+		t.Fatal("Non-ASCII text should have error")
+	}
+	if err := k.TypeText("a\n"); err == nil {
+		t.Fatal("Control characters should have error")
+	}
+	if err := k.TypeScanCodes([]string{"1c", "1ff"}); err == nil {
+		t.Fatal("Invalid scancodes should have error")
+	}
+	if len(caller.requests) > 0 {
+		t.Fatalf("Should NOT have sent invalid input: %v", caller.requests)
+	}
+}
+
+func TestKeyboardSession_errors(t *testing.T) {
+	caller := &testKeyboardCaller{responses: []string{"error TypeText failed with return value 32768", "what"}}
+	k := &KeyboardSession{session: caller, ctx: context.Background()}
+
+	err := k.TypeText("a")
+	if err == nil || err.Error() != "TypeText failed with return value 32768" {
+		t.Fatalf("Should return the session error, got: %v", err)
+	}
+	if err := k.TypeText("a"); err == nil {
+		t.Fatal("Unexpected responses should have error")
+	}
+
+	caller = &testKeyboardCaller{err: errors.New("PowerShell session ended")}
+	k = &KeyboardSession{session: caller, ctx: context.Background()}
+	if err := k.TypeScanCodes([]string{"1c"}); err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -205,6 +205,24 @@ func GetHostAvailableMemory() float64 {
 	return freeMB
 }
 
+func GetHostLogicalProcessorCount() (uint, error) {
+
+	var script = "(Hyper-V\\Get-VMHost).LogicalProcessorCount"
+
+	var ps PowerShellCmd
+	output, err := ps.Output(script)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := strconv.ParseUint(strings.TrimSpace(output), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint(count), nil
+}
+
 func GetHostName(ip string) (string, error) {
 
 	var script = `
@@ -5,7 +5,9 @@ package powershell
 
 import (
 	"bytes"
+	"context"
 	"testing"
+	"time"
 )
 
 func TestOutput(t *testing.T) {
@@ -70,3 +72,71 @@ func TestRunFile(t *testing.T) {
 		t.Fatalf("output '%v' is not 'a b 15'", cmdOut)
 	}
 }
+
+func TestSession(t *testing.T) {
+
+	var ps PowerShellCmd
+
+	powershellAvailable, _, _ := IsPowershellAvailable()
+
+	if !powershellAvailable {
+		t.Skipf("powershell not installed")
+		return
+	}
+
+	session, err := ps.Start(`
+while (($line = [Console]::In.ReadLine()) -ne $null) {
+    [Console]::Out.WriteLine("echo $line")
+    [Console]::Out.Flush()
+}
+`)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	for _, request := range []string{"one", "two"} {
+		response, err := session.Call(context.Background(), request)
+		if err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+		if response != "echo "+request {
+			t.Fatalf("response '%v' is not 'echo %v'", response, request)
+		}
+	}
+
+	if err := session.Close(); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestSession_timeout(t *testing.T) {
+
+	var ps PowerShellCmd
+
+	powershellAvailable, _, _ := IsPowershellAvailable()
+
+	if !powershellAvailable {
+		t.Skipf("powershell not installed")
+		return
+	}
+
+	session, err := ps.Start(`
+while (($line = [Console]::In.ReadLine()) -ne $null) {
+    Start-Sleep -Seconds 3600
+}
+`)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := session.Call(ctx, "hang"); err == nil {
+		t.Fatalf("should have error")
+	}
+
+	// The process has been killed
+	if err := session.Close(); err == nil {
+		t.Fatalf("should have error")
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package powershell
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/wsl"
+)
+
+// Session is a PowerShell process running a script that reads one request
+// per line from its standard input and answers each with one line on its
+// standard output. It avoids starting PowerShell and rediscovering the
+// same objects for every call in a tight loop.
+type Session struct {
+	command  *exec.Cmd
+	stdin    io.WriteCloser
+	stdout   *bufio.Reader
+	stderr   bytes.Buffer
+	filename string
+	debug    bool
+}
+
+// Start runs the script in a new session. Close must be called when done.
+func (ps *PowerShellCmd) Start(fileContents string, params ...string) (*Session, error) {
+	path, err := ps.getPowerShellPath()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot find PowerShell in the path")
+	}
+
+	filename, err := saveScript(fileContents)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		filename: filename,
+		debug:    os.Getenv("PACKER_POWERSHELL_DEBUG") != "",
+	}
+
+	scriptPath := filename
+	if wsl.IsWSL() {
+		scriptPath, err = wsl.ConvertWSlPathToWindowsPath(filename)
+		if err != nil {
+			s.removeScript()
+			return nil, err
+		}
+	}
+
+	args := createArgs(scriptPath, params...)
+	log.Printf("Start: %s %s", path, args)
+
+	s.command = exec.Command(path, args...)
+	s.command.Stderr = &s.stderr
+
+	s.stdin, err = s.command.StdinPipe()
+	if err != nil {
+		s.removeScript()
+		return nil, err
+	}
+	stdout, err := s.command.StdoutPipe()
+	if err != nil {
+		s.removeScript()
+		return nil, err
+	}
+	s.stdout = bufio.NewReader(stdout)
+
+	if err := s.command.Start(); err != nil {
+		s.removeScript()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Call sends a request line to the session and returns its answer. If ctx
+// is done first, the PowerShell process is killed, as it may be stuck, and
+// the session can't be called anymore.
+func (s *Session) Call(ctx context.Context, request string) (string, error) {
+	type result struct {
+		response string
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		if _, err := io.WriteString(s.stdin, request+"\n"); err != nil {
+			done <- result{err: s.exitError(err)}
+			return
+		}
+
+		response, err := s.stdout.ReadString('\n')
+		if err != nil {
+			done <- result{err: s.exitError(err)}
+			return
+		}
+		done <- result{response: strings.TrimRight(response, "\r\n")}
+	}()
+
+	select {
+	case r := <-done:
+		return r.response, r.err
+	case <-ctx.Done():
+		// Killing the process also ends the pending read
+		if err := s.command.Process.Kill(); err != nil {
+			log.Printf("Error killing PowerShell session: %s", err)
+		}
+		return "", fmt.Errorf("PowerShell session request %q: %s", strings.Fields(request)[0], ctx.Err())
+	}
+}
+
+// exitError explains a failed call by the errors of the PowerShell process,
+// which has usually exited.
+func (s *Session) exitError(err error) error {
+	if stderr := strings.TrimSpace(s.stderr.String()); stderr != "" {
+		return fmt.Errorf("PowerShell error: %s", stderr)
+	}
+	return fmt.Errorf("PowerShell session ended: %s", err)
+}
+
+// Close ends the session by closing its standard input and waits for the
+// PowerShell process to exit.
+func (s *Session) Close() error {
+	defer s.removeScript()
+
+	s.stdin.Close()
+	err := s.command.Wait()
+
+	if stderr := strings.TrimSpace(s.stderr.String()); stderr != "" {
+		return fmt.Errorf("PowerShell error: %s", stderr)
+	}
+	return err
+}
+
+func (s *Session) removeScript() {
+	if !s.debug {
+		os.Remove(s.filename)
+	}
+}
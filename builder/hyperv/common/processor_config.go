@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type ProcessorConfig
+
+package common
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/powershell/hyperv"
+)
+
+const (
+	MaxProcessorPercent        = 100
+	MaxProcessorRelativeWeight = 10000
+)
+
+// ProcessorConfig holds the `Set-VMProcessor` settings applied to the VM
+// on top of `cpus`. Unset values leave the Hyper-V defaults in place.
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	processor {
+//	  reserve                             = 10
+//	  maximum                             = 75
+//	  compatibility_for_migration_enabled = true
+//	}
+//
+// ```
+type ProcessorConfig struct {
+	// The percentage of the VM's processor capacity reserved on the host,
+	// between 0 and 100.
+	Reserve uint `mapstructure:"reserve" required:"false"`
+	// The maximum percentage of processor capacity the VM may use, between
+	// 1 and 100. By default Hyper-V uses 100.
+	Maximum uint `mapstructure:"maximum" required:"false"`
+	// The weight of the VM when competing for processor time with other
+	// VMs, between 1 and 10000. By default Hyper-V uses 100.
+	RelativeWeight uint `mapstructure:"relative_weight" required:"false"`
+	// The number of virtual SMT threads exposed per core. 0 inherits the
+	// host setting and 1 disables SMT for the VM.
+	HwThreadCountPerCore *uint `mapstructure:"hw_thread_count_per_core" required:"false"`
+	// The maximum number of processors per virtual NUMA node.
+	MaximumCountPerNumaNode uint `mapstructure:"maximum_count_per_numa_node" required:"false"`
+	// Limit the processor features exposed to the VM, so the image can be
+	// live migrated between hosts with different processor generations.
+	// This defaults to false.
+	CompatibilityForMigrationEnabled bool `mapstructure:"compatibility_for_migration_enabled" required:"false"`
+	// Expose the hardware virtualization extensions to the VM for nested
+	// virtualization. This is the same as `enable_virtualization_extensions`.
+	ExposeVirtualizationExtensions bool `mapstructure:"expose_virtualization_extensions" required:"false"`
+}
+
+// IsSet reports whether any setting other than the virtualization
+// extensions was requested.
+func (c *ProcessorConfig) IsSet() bool {
+	return c.Reserve > 0 || c.Maximum > 0 || c.RelativeWeight > 0 || c.HwThreadCountPerCore != nil ||
+		c.MaximumCountPerNumaNode > 0 || c.CompatibilityForMigrationEnabled
+}
+
+func (c *ProcessorConfig) Prepare() []error {
+	var errs []error
+
+	if c.Reserve > MaxProcessorPercent {
+		errs = append(errs, fmt.Errorf("processor: reserve must be between 0 and %d, but defined: %d",
+			MaxProcessorPercent, c.Reserve))
+	}
+	if c.Maximum > MaxProcessorPercent {
+		errs = append(errs, fmt.Errorf("processor: maximum must be between 1 and %d, but defined: %d",
+			MaxProcessorPercent, c.Maximum))
+	}
+	if c.Maximum > 0 && c.Reserve > c.Maximum {
+		errs = append(errs, fmt.Errorf("processor: reserve %d must not be greater than maximum %d",
+			c.Reserve, c.Maximum))
+	}
+	if c.RelativeWeight > MaxProcessorRelativeWeight {
+		errs = append(errs, fmt.Errorf("processor: relative_weight must be between 1 and %d, but defined: %d",
+			MaxProcessorRelativeWeight, c.RelativeWeight))
+	}
+
+	return errs
+}
+
+func (c *ProcessorConfig) settings() hyperv.ProcessorSettings {
+	return hyperv.ProcessorSettings{
+		Reserve:                          c.Reserve,
+		Maximum:                          c.Maximum,
+		RelativeWeight:                   c.RelativeWeight,
+		HwThreadCountPerCore:             c.HwThreadCountPerCore,
+		MaximumCountPerNumaNode:          c.MaximumCountPerNumaNode,
+		CompatibilityForMigrationEnabled: c.CompatibilityForMigrationEnabled,
+	}
+}
@@ -0,0 +1,43 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatProcessorConfig is an auto-generated flat version of ProcessorConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatProcessorConfig struct {
+	Reserve                          *uint `mapstructure:"reserve" required:"false" cty:"reserve" hcl:"reserve"`
+	Maximum                          *uint `mapstructure:"maximum" required:"false" cty:"maximum" hcl:"maximum"`
+	RelativeWeight                   *uint `mapstructure:"relative_weight" required:"false" cty:"relative_weight" hcl:"relative_weight"`
+	HwThreadCountPerCore             *uint `mapstructure:"hw_thread_count_per_core" required:"false" cty:"hw_thread_count_per_core" hcl:"hw_thread_count_per_core"`
+	MaximumCountPerNumaNode          *uint `mapstructure:"maximum_count_per_numa_node" required:"false" cty:"maximum_count_per_numa_node" hcl:"maximum_count_per_numa_node"`
+	CompatibilityForMigrationEnabled *bool `mapstructure:"compatibility_for_migration_enabled" required:"false" cty:"compatibility_for_migration_enabled" hcl:"compatibility_for_migration_enabled"`
+	ExposeVirtualizationExtensions   *bool `mapstructure:"expose_virtualization_extensions" required:"false" cty:"expose_virtualization_extensions" hcl:"expose_virtualization_extensions"`
+}
+
+// FlatMapstructure returns a new FlatProcessorConfig.
+// FlatProcessorConfig is an auto-generated flat version of ProcessorConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*ProcessorConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatProcessorConfig)
+}
+
+// HCL2Spec returns the hcl spec of a ProcessorConfig.
+// This spec is used by HCL to read the fields of ProcessorConfig.
+// The decoded values from this spec will then be applied to a FlatProcessorConfig.
+func (*FlatProcessorConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"reserve":                             &hcldec.AttrSpec{Name: "reserve", Type: cty.Number, Required: false},
+		"maximum":                             &hcldec.AttrSpec{Name: "maximum", Type: cty.Number, Required: false},
+		"relative_weight":                     &hcldec.AttrSpec{Name: "relative_weight", Type: cty.Number, Required: false},
+		"hw_thread_count_per_core":            &hcldec.AttrSpec{Name: "hw_thread_count_per_core", Type: cty.Number, Required: false},
+		"maximum_count_per_numa_node":         &hcldec.AttrSpec{Name: "maximum_count_per_numa_node", Type: cty.Number, Required: false},
+		"compatibility_for_migration_enabled": &hcldec.AttrSpec{Name: "compatibility_for_migration_enabled", Type: cty.Bool, Required: false},
+		"expose_virtualization_extensions":    &hcldec.AttrSpec{Name: "expose_virtualization_extensions", Type: cty.Bool, Required: false},
+	}
+	return s
+}
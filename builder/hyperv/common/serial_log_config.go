@@ -0,0 +1,70 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type SerialLogConfig
+
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+const DefaultSerialLogFile = "serial.log"
+
+// SerialLogConfig captures the output of a serial port of the VM for the
+// whole build. The port is connected to a named pipe on the host, which
+// Packer reads into a file under `output_directory`. This works for both
+// generation 1 and generation 2 VMs, as long as the guest writes its
+// console to the port, e.g. with `console=ttyS0` on Linux.
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	serial_log {
+//	  port         = 1
+//	  mirror_to_ui = true
+//	}
+//
+// ```
+type SerialLogConfig struct {
+	// The COM port to capture, 1 or 2. Setting it enables the capture.
+	Port uint `mapstructure:"port" required:"true"`
+	// The name of the log file, relative to `output_directory`. Defaults to
+	// `serial.log`.
+	File string `mapstructure:"file" required:"false"`
+	// Also print each line read from the port to the Packer UI. This
+	// defaults to false. As Packer removes `output_directory` when a build
+	// fails, this is the way to see the output of a failed build unless
+	// `-on-error=abort` is used.
+	MirrorToUi bool `mapstructure:"mirror_to_ui" required:"false"`
+}
+
+// IsSet reports whether serial console capture was requested.
+func (c *SerialLogConfig) IsSet() bool {
+	return c.Port > 0
+}
+
+func (c *SerialLogConfig) Prepare() []error {
+	if !c.IsSet() {
+		return nil
+	}
+
+	var errs []error
+
+	if c.Port > 2 {
+		errs = append(errs, fmt.Errorf("serial_log: port must be 1 or 2, but defined: %d", c.Port))
+	}
+
+	if c.File == "" {
+		c.File = DefaultSerialLogFile
+	}
+	file := strings.ReplaceAll(c.File, `\`, "/")
+	if strings.HasPrefix(file, "/") || strings.Contains(file, ":") || containsDotDot(file) {
+		errs = append(errs, fmt.Errorf("serial_log: file %q must be relative to output_directory", c.File))
+	}
+
+	return errs
+}
@@ -0,0 +1,35 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatSerialLogConfig is an auto-generated flat version of SerialLogConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatSerialLogConfig struct {
+	Port       *uint   `mapstructure:"port" required:"true" cty:"port" hcl:"port"`
+	File       *string `mapstructure:"file" required:"false" cty:"file" hcl:"file"`
+	MirrorToUi *bool   `mapstructure:"mirror_to_ui" required:"false" cty:"mirror_to_ui" hcl:"mirror_to_ui"`
+}
+
+// FlatMapstructure returns a new FlatSerialLogConfig.
+// FlatSerialLogConfig is an auto-generated flat version of SerialLogConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*SerialLogConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatSerialLogConfig)
+}
+
+// HCL2Spec returns the hcl spec of a SerialLogConfig.
+// This spec is used by HCL to read the fields of SerialLogConfig.
+// The decoded values from this spec will then be applied to a FlatSerialLogConfig.
+func (*FlatSerialLogConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"port":         &hcldec.AttrSpec{Name: "port", Type: cty.Number, Required: false},
+		"file":         &hcldec.AttrSpec{Name: "file", Type: cty.String, Required: false},
+		"mirror_to_ui": &hcldec.AttrSpec{Name: "mirror_to_ui", Type: cty.Bool, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,16 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"io"
+
+	"github.com/Microsoft/go-winio"
+)
+
+// openSerialPipe connects to the named pipe of a COM port with overlapped
+// I/O, so closing it cancels a pending read.
+func openSerialPipe(path string) (io.ReadCloser, error) {
+	return winio.DialPipe(path, nil)
+}
@@ -0,0 +1,80 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type ShutdownStage
+
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The ways a shutdown stage powers off the VM, from the most to the least
+// graceful.
+const (
+	// Run shutdown_command in the guest through the communicator
+	ShutdownMethodCommand = "command"
+	// Ask the guest OS to shut down through the Shutdown integration service
+	ShutdownMethodIntegrationService = "integration_service"
+	// Power off the VM without shutting down the guest OS
+	ShutdownMethodTurnOff = "turn_off"
+)
+
+// ShutdownMethods lists the shutdown methods in the order they escalate.
+var ShutdownMethods = []string{
+	ShutdownMethodCommand,
+	ShutdownMethodIntegrationService,
+	ShutdownMethodTurnOff,
+}
+
+// ShutdownStage is a stage of the shutdown_mode escalation chain. When the
+// VM hasn't powered off within the timeout of a stage, the next stage is
+// tried.
+type ShutdownStage struct {
+	// How the stage powers off the VM. One of `command`,
+	// `integration_service` and `turn_off`.
+	Method string `mapstructure:"method" required:"true"`
+	// How long to wait for the VM to power off before escalating to the
+	// next stage. Defaults to `shutdown_timeout`.
+	Timeout time.Duration `mapstructure:"timeout" required:"false"`
+}
+
+func (s *ShutdownStage) Prepare() []error {
+	var errs []error
+
+	s.Method = strings.ToLower(s.Method)
+	if shutdownMethodRank(s.Method) < 0 {
+		errs = append(errs, fmt.Errorf("method must be one of %s, but defined: %q",
+			strings.Join(ShutdownMethods, ", "), s.Method))
+	}
+
+	if s.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout must not be negative"))
+	}
+
+	return errs
+}
+
+// HasShutdownMethod reports whether one of the stages uses method.
+func HasShutdownMethod(stages []ShutdownStage, method string) bool {
+	for _, stage := range stages {
+		if stage.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
+// shutdownMethodRank returns the position of method in ShutdownMethods, or
+// -1 for unknown methods.
+func shutdownMethodRank(method string) int {
+	for i, m := range ShutdownMethods {
+		if m == method {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,33 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatShutdownStage is an auto-generated flat version of ShutdownStage.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatShutdownStage struct {
+	Method  *string `mapstructure:"method" required:"true" cty:"method" hcl:"method"`
+	Timeout *string `mapstructure:"timeout" required:"false" cty:"timeout" hcl:"timeout"`
+}
+
+// FlatMapstructure returns a new FlatShutdownStage.
+// FlatShutdownStage is an auto-generated flat version of ShutdownStage.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*ShutdownStage) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatShutdownStage)
+}
+
+// HCL2Spec returns the hcl spec of a ShutdownStage.
+// This spec is used by HCL to read the fields of ShutdownStage.
+// The decoded values from this spec will then be applied to a FlatShutdownStage.
+func (*FlatShutdownStage) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"method":  &hcldec.AttrSpec{Name: "method", Type: cty.String, Required: false},
+		"timeout": &hcldec.AttrSpec{Name: "timeout", Type: cty.String, Required: false},
+	}
+	return s
+}
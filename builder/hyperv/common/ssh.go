@@ -35,6 +35,22 @@ func CommHost(host string) func(multistep.StateBag) (string, error) {
 	}
 }
 
+// SSHHost returns the connection information for the SSH and WinRM
+// communicators. When SSH connects over Hyper-V sockets, it returns the VM
+// GUID instead of waiting for the VM to get an IP address.
+func SSHHost(config *CommConfig) func(multistep.StateBag) (string, error) {
+	return func(state multistep.StateBag) (string, error) {
+		if config.Comm.Type != "ssh" || config.SSHTransport != SSHTransportHvsock {
+			return CommHost(config.Comm.Host())(state)
+		}
+
+		vmName := state.Get("vmName").(string)
+		driver := state.Get("driver").(Driver)
+
+		return driver.GetVMId(vmName)
+	}
+}
+
 // PSRPHost returns the connection information for PSRP communicator.
 // For HvSocket transport, it returns the VM GUID; for WSMan, it returns the IP address.
 func PSRPHost(config interface{}) func(multistep.StateBag) (string, error) {
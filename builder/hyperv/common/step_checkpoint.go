@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step takes the checkpoints configured for a phase of the build.
+//
+// Produces:
+//
+//	checkpoints []Checkpoint - The checkpoints taken so far
+type StepCheckpoint struct {
+	Phase       string
+	Checkpoints []Checkpoint
+}
+
+func (s *StepCheckpoint) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmName := state.Get("vmName").(string)
+
+	var taken []Checkpoint
+	if v, ok := state.GetOk("checkpoints"); ok {
+		taken = v.([]Checkpoint)
+	}
+
+	for _, checkpoint := range s.Checkpoints {
+		if checkpoint.Phase != s.Phase {
+			continue
+		}
+
+		ui.Say(fmt.Sprintf("Taking checkpoint %s...", checkpoint.Name))
+		err := driver.CreateVirtualMachineCheckpoint(vmName, checkpoint.Name)
+		if err != nil {
+			err := fmt.Errorf("Error taking checkpoint %s: %s", checkpoint.Name, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		taken = append(taken, checkpoint)
+		state.Put("checkpoints", taken)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepCheckpoint) Cleanup(state multistep.StateBag) {
+	// do nothing
+}
+
+// This step removes the checkpoints taken during the build that aren't
+// kept, before the disks are compacted and the VM is exported.
+//
+// Produces:
+//
+//	kept_checkpoints []string - The names of the checkpoints left on the VM
+type StepRemoveCheckpoints struct{}
+
+func (s *StepRemoveCheckpoints) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmName := state.Get("vmName").(string)
+
+	var taken []Checkpoint
+	if v, ok := state.GetOk("checkpoints"); ok {
+		taken = v.([]Checkpoint)
+	}
+
+	var kept []string
+	for _, checkpoint := range taken {
+		if checkpoint.Keep {
+			kept = append(kept, checkpoint.Name)
+			continue
+		}
+
+		ui.Say(fmt.Sprintf("Removing checkpoint %s...", checkpoint.Name))
+		err := driver.RemoveVirtualMachineCheckpoint(vmName, checkpoint.Name)
+		if err != nil {
+			err := fmt.Errorf("Error removing checkpoint %s: %s", checkpoint.Name, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if len(kept) > 0 {
+		state.Put("kept_checkpoints", kept)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepRemoveCheckpoints) Cleanup(state multistep.StateBag) {
+	// do nothing
+}
@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepCheckpoint_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCheckpoint)
+	var _ multistep.Step = new(StepRemoveCheckpoints)
+}
+
+func TestStepCheckpoint(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	checkpoints := []Checkpoint{
+		{Phase: CheckpointPhaseOsInstalled, Name: "clean-os", Keep: true},
+		{Phase: CheckpointPhaseBeforeProvisioning, Name: "packer-before_provisioning"},
+		{Phase: CheckpointPhaseAfterProvisioning, Name: "provisioned"},
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+
+	for _, phase := range []string{CheckpointPhaseOsInstalled, CheckpointPhaseAfterProvisioning} {
+		step := &StepCheckpoint{Phase: phase, Checkpoints: checkpoints}
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("Bad action: %v", action)
+		}
+	}
+
+	expected := []string{"clean-os", "provisioned"}
+	if !reflect.DeepEqual(driver.CreateVirtualMachineCheckpoint_Names, expected) {
+		t.Fatalf("Expected checkpoints %v, got %v", expected, driver.CreateVirtualMachineCheckpoint_Names)
+	}
+	if driver.CreateVirtualMachineCheckpoint_VmName != "foo" {
+		t.Fatalf("Bad vm name: %s", driver.CreateVirtualMachineCheckpoint_VmName)
+	}
+
+	step := new(StepRemoveCheckpoints)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+
+	// The checkpoint of the phase that didn't run is never removed
+	expected = []string{"provisioned"}
+	if !reflect.DeepEqual(driver.RemoveVirtualMachineCheckpoint_Names, expected) {
+		t.Fatalf("Expected removed checkpoints %v, got %v", expected, driver.RemoveVirtualMachineCheckpoint_Names)
+	}
+	kept, ok := state.GetOk("kept_checkpoints")
+	if !ok || !reflect.DeepEqual(kept, []string{"clean-os"}) {
+		t.Fatalf("Should store the kept checkpoints, got: %v", kept)
+	}
+}
+
+func TestStepCheckpoint_none(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	step := &StepCheckpoint{Phase: CheckpointPhaseOsInstalled}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	remove := new(StepRemoveCheckpoints)
+	if action := remove.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+
+	if driver.CreateVirtualMachineCheckpoint_Called || driver.RemoveVirtualMachineCheckpoint_Called {
+		t.Fatal("Should NOT touch checkpoints when none are configured")
+	}
+	if _, ok := state.GetOk("kept_checkpoints"); ok {
+		t.Fatal("Should NOT store 'kept_checkpoints'")
+	}
+}
+
+func TestStepCheckpoint_error(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.CreateVirtualMachineCheckpoint_Err = errors.New("production checkpoint failed")
+
+	step := &StepCheckpoint{
+		Phase:       CheckpointPhaseOsInstalled,
+		Checkpoints: []Checkpoint{{Phase: CheckpointPhaseOsInstalled, Name: "clean-os"}},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("Should have error")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/powershell/hyperv"
 	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/wsl"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -27,11 +28,15 @@ type StepCloneVM struct {
 	CloneAllSnapshots              bool
 	VMName                         string
 	SwitchName                     string
-	CompareCopy                    bool
 	RamSize                        uint
 	Cpu                            uint
+	Processor                      ProcessorConfig
 	EnableMacSpoofing              bool
 	EnableDynamicMemory            bool
+	MemoryMinimum                  uint
+	MemoryMaximum                  uint
+	MemoryBuffer                   uint
+	MemoryWeight                   *uint
 	EnableSecureBoot               bool
 	SecureBootTemplate             string
 	EnableVirtualizationExtensions bool
@@ -40,6 +45,11 @@ type StepCloneVM struct {
 	KeepRegistered                 bool
 	AdditionalDiskSize             []uint
 	DiskBlockSize                  uint
+	MinimumIOPS                    uint64
+	MaximumIOPS                    uint64
+	QoSPolicyID                    string
+	DiskCacheAttributes            string
+	CheckpointType                 string
 }
 
 func (s *StepCloneVM) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -59,6 +69,19 @@ func (s *StepCloneVM) Run(ctx context.Context, state multistep.StateBag) multist
 		}
 	}
 
+	storagePaths, err := storagePaths(state)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Additional disks are kept with the VM's other virtual hard disks
+	vhdDir := path
+	if storagePaths.VhdPath != "" {
+		vhdDir = storagePaths.VhdPath
+	}
+
 	// Determine if we even have an existing virtual harddrive to attach
 	harddrivePath := ""
 	if harddrivePathRaw, ok := state.GetOk("iso_path"); ok {
@@ -86,9 +109,9 @@ func (s *StepCloneVM) Run(ctx context.Context, state multistep.StateBag) multist
 		}
 	}
 
-	err := driver.CloneVirtualMachine(cloneFromVMCXPath, s.CloneFromVMName,
+	err = driver.CloneVirtualMachine(cloneFromVMCXPath, s.CloneFromVMName,
 		s.CloneFromSnapshotName, s.CloneAllSnapshots, s.VMName, path,
-		harddrivePath, ramSize, s.SwitchName, s.CompareCopy)
+		harddrivePath, ramSize, s.SwitchName, storagePaths)
 	if err != nil {
 		err := fmt.Errorf("Error cloning virtual machine: %s", err)
 		state.Put("error", err)
@@ -104,8 +127,18 @@ func (s *StepCloneVM) Run(ctx context.Context, state multistep.StateBag) multist
 		return multistep.ActionHalt
 	}
 
+	if s.Processor.IsSet() {
+		err = driver.SetVirtualMachineProcessor(s.VMName, s.Processor.settings())
+		if err != nil {
+			err := fmt.Errorf("Error setting virtual machine processor: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	if s.EnableDynamicMemory {
-		err = driver.SetVirtualMachineDynamicMemory(s.VMName, s.EnableDynamicMemory)
+		err = driver.SetVirtualMachineDynamicMemory(s.VMName, s.EnableDynamicMemory, s.dynamicMemory())
 		if err != nil {
 			err := fmt.Errorf("Error creating setting virtual machine dynamic memory: %s", err)
 			state.Put("error", err)
@@ -124,6 +157,16 @@ func (s *StepCloneVM) Run(ctx context.Context, state multistep.StateBag) multist
 		}
 	}
 
+	if s.CheckpointType != "" {
+		err = driver.SetVirtualMachineCheckpointType(s.VMName, s.CheckpointType)
+		if err != nil {
+			err := fmt.Errorf("Error setting checkpoint type: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	generation, err := driver.GetVirtualMachineGeneration(s.VMName)
 	if err != nil {
 		err := fmt.Errorf("Error detecting vm generation: %s", err)
@@ -169,7 +212,8 @@ func (s *StepCloneVM) Run(ctx context.Context, state multistep.StateBag) multist
 			diskSize := int64(size * 1024 * 1024)
 			diskFile := fmt.Sprintf("%s-%d.vhdx", s.VMName, index)
 			diskBlockSize := int64(s.DiskBlockSize) * 1024 * 1024
-			err = driver.AddVirtualMachineHardDrive(s.VMName, path, diskFile, diskSize, diskBlockSize, "SCSI")
+			err = driver.AddVirtualMachineHardDrive(s.VMName, vhdDir, diskFile, diskSize, diskBlockSize, "SCSI",
+				s.diskPerformance())
 			if err != nil {
 				err := fmt.Errorf("Error creating and attaching additional disk drive: %s", err)
 				state.Put("error", err)
@@ -198,6 +242,25 @@ func (s *StepCloneVM) Run(ctx context.Context, state multistep.StateBag) multist
 	return multistep.ActionContinue
 }
 
+func (s *StepCloneVM) diskPerformance() hyperv.DiskPerformance {
+	return hyperv.DiskPerformance{
+		MinimumIOPS:     s.MinimumIOPS,
+		MaximumIOPS:     s.MaximumIOPS,
+		QoSPolicyID:     s.QoSPolicyID,
+		CacheAttributes: s.DiskCacheAttributes,
+	}
+}
+
+func (s *StepCloneVM) dynamicMemory() hyperv.DynamicMemory {
+	// convert the MB to bytes
+	return hyperv.DynamicMemory{
+		MinimumBytes: int64(s.MemoryMinimum) * 1024 * 1024,
+		MaximumBytes: int64(s.MemoryMaximum) * 1024 * 1024,
+		Buffer:       s.MemoryBuffer,
+		Priority:     s.MemoryWeight,
+	}
+}
+
 func (s *StepCloneVM) Cleanup(state multistep.StateBag) {
 	if s.VMName == "" {
 		return
@@ -15,6 +15,10 @@ import (
 type StepCollateArtifacts struct {
 	OutputDir  string
 	SkipExport bool
+	// ChecksumType selects the algorithm used for the checksum manifest
+	// written once the artifacts are collated. No manifest is written when
+	// empty or "none".
+	ChecksumType string
 }
 
 // Runs the step required to collate all build artifacts under the
@@ -42,6 +46,10 @@ func (s *StepCollateArtifacts) Run(ctx context.Context, state multistep.StateBag
 		if v, ok := state.GetOk("build_dir"); ok {
 			buildDir = v.(string)
 		}
+		// The disks are in a separate directory if vhd_path was set
+		if v, ok := state.GetOk("vhd_dir"); ok {
+			buildDir = v.(string)
+		}
 		// If the user has chosen to skip a full export of the VM the only
 		// artifacts that they are interested in will be the VHDs. The
 		// called function searches for all disks under the given source
@@ -87,6 +95,23 @@ func (s *StepCollateArtifacts) Run(ctx context.Context, state multistep.StateBag
 		}
 	}
 
+	// The files are hashed in a pass of their own: Export-VM copies them
+	// inside Hyper-V and Move-Item only renames them on the same volume
+	if s.ChecksumType != "" && s.ChecksumType != ChecksumTypeNone {
+		ui.Say(fmt.Sprintf("Writing %s checksum manifest...", s.ChecksumType))
+		manifest, err := NewManifest(s.OutputDir, s.ChecksumType)
+		if err == nil {
+			err = manifest.Write(s.OutputDir)
+		}
+		if err != nil {
+			err = fmt.Errorf("Error writing checksum manifest: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		state.Put("manifest", manifest)
+	}
+
 	return multistep.ActionContinue
 }
 
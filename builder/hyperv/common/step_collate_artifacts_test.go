@@ -5,6 +5,7 @@ package common
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -93,3 +94,62 @@ func TestStepCollateArtifacts_skipExportArtifacts(t *testing.T) {
 		t.Fatal("Should NOT have called PreserveLegacyExportBehaviour")
 	}
 }
+
+func TestStepCollateArtifacts_manifest(t *testing.T) {
+	state := testState(t)
+	step := new(StepCollateArtifacts)
+
+	step.OutputDir = t.TempDir()
+	step.ChecksumType = ChecksumTypeSHA256
+	state.Put("export_path", filepath.Join(step.OutputDir, "foo"))
+
+	vhdPath := filepath.Join(step.OutputDir, "Virtual Hard Disks", "disk.vhdx")
+	if err := os.MkdirAll(filepath.Dir(vhdPath), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.WriteFile(vhdPath, []byte("disk"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("Should NOT have error")
+	}
+
+	raw, ok := state.GetOk("manifest")
+	if !ok {
+		t.Fatal("Should have put the manifest in the state bag")
+	}
+	manifest := raw.(*Manifest)
+	if len(manifest.Files) != 1 || manifest.Files[0].Role != ManifestRoleVhdx {
+		t.Fatalf("Bad manifest: %#v", manifest)
+	}
+
+	for _, name := range []string{"SHA256SUMS", "SHA256SUMS.json"} {
+		if _, err := os.Stat(filepath.Join(step.OutputDir, name)); err != nil {
+			t.Fatalf("Should have written %s: %s", name, err)
+		}
+	}
+}
+
+func TestStepCollateArtifacts_noManifest(t *testing.T) {
+	state := testState(t)
+	step := new(StepCollateArtifacts)
+
+	step.OutputDir = t.TempDir()
+	step.ChecksumType = ChecksumTypeNone
+	state.Put("export_path", filepath.Join(step.OutputDir, "foo"))
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("manifest"); ok {
+		t.Fatal("Should NOT have put a manifest in the state bag")
+	}
+	if _, err := os.Stat(filepath.Join(step.OutputDir, "NONESUMS")); err == nil {
+		t.Fatal("Should NOT have written a manifest")
+	}
+}
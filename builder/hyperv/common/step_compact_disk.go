@@ -26,11 +26,22 @@ func (s *StepCompactDisk) Run(ctx context.Context, state multistep.StateBag) mul
 		return multistep.ActionContinue
 	}
 
-	// Get the dir used to store the VMs files during the build process
+	// Compacting the parent of a checkpoint's differencing disk would
+	// corrupt the checkpoint
+	if _, ok := state.GetOk("kept_checkpoints"); ok {
+		ui.Say("Skipping disk compaction as the VM keeps checkpoints...")
+		return multistep.ActionContinue
+	}
+
+	// Get the dir used to store the VMs files during the build process.
+	// The disks are kept in a separate directory if vhd_path was set.
 	var buildDir string
 	if v, ok := state.GetOk("build_dir"); ok {
 		buildDir = v.(string)
 	}
+	if v, ok := state.GetOk("vhd_dir"); ok {
+		buildDir = v.(string)
+	}
 
 	if wsl.IsWSL() {
 		var err error
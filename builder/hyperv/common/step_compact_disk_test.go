@@ -41,6 +41,24 @@ func TestStepCompactDisk(t *testing.T) {
 	}
 }
 
+func TestStepCompactDisk_vhdDir(t *testing.T) {
+	state := testState(t)
+	step := new(StepCompactDisk)
+
+	// Disks are kept outside the build directory when vhd_path is set
+	state.Put("build_dir", "foopath")
+	state.Put("vhd_dir", "diskpath")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.CompactDisks_Path != "diskpath" {
+		t.Fatalf("Should call with the vhd dir. Got: %s", driver.CompactDisks_Path)
+	}
+}
+
 func TestStepCompactDisk_skip(t *testing.T) {
 	state := testState(t)
 	step := new(StepCompactDisk)
@@ -64,3 +82,20 @@ func TestStepCompactDisk_skip(t *testing.T) {
 		t.Fatal("Should NOT have called CompactDisks")
 	}
 }
+
+func TestStepCompactDisk_keptCheckpoints(t *testing.T) {
+	state := testState(t)
+	step := new(StepCompactDisk)
+
+	state.Put("build_dir", "foopath")
+	state.Put("kept_checkpoints", []string{"clean-os"})
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.CompactDisks_Called {
+		t.Fatal("Should NOT compact the disks of checkpoints")
+	}
+}
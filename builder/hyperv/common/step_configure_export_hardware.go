@@ -0,0 +1,123 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/powershell/hyperv"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step applies the export_hardware settings to the VM after it has
+// been shut down, before it is exported.
+type StepConfigureExportHardware struct {
+	Config ExportHardwareConfig
+}
+
+func (s *StepConfigureExportHardware) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Config.IsSet() {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmName := state.Get("vmName").(string)
+
+	ui.Say("Configuring the hardware of the exported VM...")
+
+	type change struct {
+		description string
+		apply       func() error
+	}
+	var changes []change
+
+	if s.Config.Cpu > 0 {
+		changes = append(changes, change{
+			fmt.Sprintf("%d processors", s.Config.Cpu),
+			func() error { return driver.SetVirtualMachineCpuCount(vmName, s.Config.Cpu) },
+		})
+	}
+	if s.Config.RamSize > 0 || s.Config.EnableDynamicMemory != nil {
+		var settings []string
+		if s.Config.RamSize > 0 {
+			settings = append(settings, fmt.Sprintf("%d MB", s.Config.RamSize))
+		}
+		if s.Config.EnableDynamicMemory != nil {
+			if *s.Config.EnableDynamicMemory {
+				settings = append(settings, "dynamic")
+			} else {
+				settings = append(settings, "static")
+			}
+		}
+		// Apply all memory settings at once, as Hyper-V checks the startup
+		// memory against the dynamic memory limits in place
+		changes = append(changes, change{
+			fmt.Sprintf("%s memory", strings.Join(settings, " ")),
+			func() error {
+				// convert the MB to bytes
+				return driver.SetVirtualMachineMemory(vmName, int64(s.Config.RamSize)*1024*1024,
+					s.Config.EnableDynamicMemory, hyperv.DynamicMemory{
+						MinimumBytes: int64(s.Config.MemoryMinimum) * 1024 * 1024,
+						MaximumBytes: int64(s.Config.MemoryMaximum) * 1024 * 1024,
+					})
+			},
+		})
+	}
+	if s.Config.SwitchName != "" {
+		changes = append(changes, change{
+			fmt.Sprintf("switch %s", s.Config.SwitchName),
+			func() error { return driver.ConnectVirtualMachineNetworkAdapterToSwitch(vmName, s.Config.SwitchName) },
+		})
+	}
+	if s.Config.DisconnectNetwork {
+		changes = append(changes, change{
+			"disconnected network",
+			func() error { return driver.DisconnectVirtualMachineNetworkAdapter(vmName) },
+		})
+	}
+	if s.Config.VlanId != "" {
+		changes = append(changes, change{
+			fmt.Sprintf("VLAN %s", s.Config.VlanId),
+			func() error { return driver.SetVirtualMachineVlanId(vmName, s.Config.VlanId) },
+		})
+	}
+	if s.Config.DisableVlan {
+		changes = append(changes, change{
+			"no VLAN",
+			func() error { return driver.UntagVirtualMachineVlan(vmName) },
+		})
+	}
+	if s.Config.DynamicMacAddress {
+		changes = append(changes, change{
+			"dynamic MAC address",
+			func() error { return driver.SetVmNetworkAdapterDynamicMacAddress(vmName) },
+		})
+	}
+	if s.Config.Notes != "" {
+		changes = append(changes, change{
+			"notes",
+			func() error { return driver.SetVirtualMachineNotes(vmName, s.Config.Notes) },
+		})
+	}
+
+	for _, c := range changes {
+		ui.Message(fmt.Sprintf("Setting %s", c.description))
+		if err := c.apply(); err != nil {
+			err := fmt.Errorf("Error setting %s of the exported VM: %s", c.description, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepConfigureExportHardware) Cleanup(state multistep.StateBag) {
+	// do nothing
+}
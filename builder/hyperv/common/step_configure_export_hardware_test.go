@@ -0,0 +1,152 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepConfigureExportHardware_impl(t *testing.T) {
+	var _ multistep.Step = new(StepConfigureExportHardware)
+}
+
+func TestStepConfigureExportHardware(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	enable := true
+	step := &StepConfigureExportHardware{
+		Config: ExportHardwareConfig{
+			Cpu:                 2,
+			RamSize:             4096,
+			EnableDynamicMemory: &enable,
+			MemoryMaximum:       8192,
+			SwitchName:          "Default Switch",
+			DisableVlan:         true,
+			DynamicMacAddress:   true,
+			Notes:               "Built by Packer",
+		},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+
+	if driver.SetVirtualMachineCpuCount_Cpu != 2 {
+		t.Fatalf("Bad cpus: %d", driver.SetVirtualMachineCpuCount_Cpu)
+	}
+	if driver.SetVirtualMachineMemory_RamBytes != 4096*1024*1024 {
+		t.Fatalf("Bad memory: %d", driver.SetVirtualMachineMemory_RamBytes)
+	}
+	if e := driver.SetVirtualMachineMemory_EnableDynamicMemory; e == nil || !*e ||
+		driver.SetVirtualMachineMemory_DynamicMemory.MaximumBytes != 8192*1024*1024 {
+		t.Fatalf("Bad dynamic memory: %#v", driver.SetVirtualMachineMemory_DynamicMemory)
+	}
+	if driver.SetVirtualMachineDynamicMemory_Called {
+		t.Fatal("Should set the memory in a single change")
+	}
+	if driver.ConnectVirtualMachineNetworkAdapterToSwitch_SwitchName != "Default Switch" {
+		t.Fatalf("Bad switch: %s", driver.ConnectVirtualMachineNetworkAdapterToSwitch_SwitchName)
+	}
+	if driver.DisconnectVirtualMachineNetworkAdapter_Called {
+		t.Fatal("Should NOT disconnect the network")
+	}
+	if !driver.UntagVirtualMachineVlan_Called || driver.SetVirtualMachineVlanId_Called {
+		t.Fatal("Should remove the VLAN")
+	}
+	if !driver.SetVmNetworkAdapterDynamicMacAddress_Called {
+		t.Fatal("Should reset the MAC address")
+	}
+	if driver.SetVirtualMachineNotes_Notes != "Built by Packer" {
+		t.Fatalf("Bad notes: %s", driver.SetVirtualMachineNotes_Notes)
+	}
+}
+
+func TestStepConfigureExportHardware_dynamicToStatic(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// The build used dynamic memory with a minimum above the new startup
+	// memory, which is only valid once dynamic memory is disabled as well
+	disable := false
+	step := &StepConfigureExportHardware{
+		Config: ExportHardwareConfig{RamSize: 4096, EnableDynamicMemory: &disable},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+
+	if driver.SetVirtualMachineMemory_RamBytes != 4096*1024*1024 {
+		t.Fatalf("Bad memory: %d", driver.SetVirtualMachineMemory_RamBytes)
+	}
+	if e := driver.SetVirtualMachineMemory_EnableDynamicMemory; e == nil || *e {
+		t.Fatal("Should disable dynamic memory with the same change")
+	}
+	if driver.SetVirtualMachineDynamicMemory_Called {
+		t.Fatal("Should set the memory in a single change")
+	}
+}
+
+func TestStepConfigureExportHardware_notSet(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	step := &StepConfigureExportHardware{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.SetVirtualMachineCpuCount_Called || driver.SetVirtualMachineMemory_Called {
+		t.Fatal("Should NOT change the hardware")
+	}
+}
+
+func TestStepConfigureExportHardware_error(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.SetVirtualMachineMemory_Err = errors.New("memory is less than the minimum")
+
+	step := &StepConfigureExportHardware{
+		Config: ExportHardwareConfig{RamSize: 1024, DisconnectNetwork: true},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.DisconnectVirtualMachineNetworkAdapter_Called {
+		t.Fatal("Should stop at the first error")
+	}
+}
+
+func TestExportHardwareConfig_Prepare(t *testing.T) {
+	disable := false
+	for _, config := range []ExportHardwareConfig{
+		{RamSize: 1},
+		{MemoryMaximum: 8192},
+		{EnableDynamicMemory: &disable, MemoryMinimum: 512},
+		{SwitchName: "Default Switch", DisconnectNetwork: true},
+		{VlanId: "10", DisableVlan: true},
+	} {
+		if errs := config.Prepare(); len(errs) == 0 {
+			t.Fatalf("Should have error: %#v", config)
+		}
+	}
+
+	config := ExportHardwareConfig{Notes: "Built by Packer"}
+	if errs := config.Prepare(); len(errs) > 0 {
+		t.Fatalf("err: %v", errs)
+	}
+	if !config.IsSet() {
+		t.Fatal("Should be set")
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step enables or disables the integration services of the VM. It is
+// used before the VM first boots, and again before export to set the state
+// the services should have in the final image.
+type StepConfigureIntegrationServices struct {
+	// Integration service names mapped to whether the service is enabled.
+	// Services that aren't listed are left unchanged.
+	Services map[string]bool
+}
+
+func (s *StepConfigureIntegrationServices) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if len(s.Services) == 0 {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	ui.Say("Configuring Integration Services...")
+
+	vmName := state.Get("vmName").(string)
+
+	names := make([]string, 0, len(s.Services))
+	for name := range s.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var err error
+		if s.Services[name] {
+			err = driver.EnableVirtualMachineIntegrationService(vmName, name)
+		} else {
+			err = driver.DisableVirtualMachineIntegrationService(vmName, name)
+		}
+
+		if err != nil {
+			err := fmt.Errorf("Error configuring Integration Service %q: %s", name, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepConfigureIntegrationServices) Cleanup(state multistep.StateBag) {
+	// do nothing
+}
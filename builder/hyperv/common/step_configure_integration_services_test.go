@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepConfigureIntegrationServices_impl(t *testing.T) {
+	var _ multistep.Step = new(StepConfigureIntegrationServices)
+}
+
+func TestStepConfigureIntegrationServices(t *testing.T) {
+	state := testState(t)
+	step := &StepConfigureIntegrationServices{
+		Services: map[string]bool{
+			"Guest Service Interface": true,
+			"Time Synchronization":    false,
+		},
+	}
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("Should NOT have error")
+	}
+
+	if !driver.EnableVirtualMachineIntegrationService_Called {
+		t.Fatal("Should have called EnableVirtualMachineIntegrationService")
+	}
+	if driver.EnableVirtualMachineIntegrationService_IntegrationServiceName != "Guest Service Interface" {
+		t.Fatalf("Should enable Guest Service Interface. Got: %s",
+			driver.EnableVirtualMachineIntegrationService_IntegrationServiceName)
+	}
+	if !driver.DisableVirtualMachineIntegrationService_Called {
+		t.Fatal("Should have called DisableVirtualMachineIntegrationService")
+	}
+	if driver.DisableVirtualMachineIntegrationService_IntegrationServiceName != "Time Synchronization" {
+		t.Fatalf("Should disable Time Synchronization. Got: %s",
+			driver.DisableVirtualMachineIntegrationService_IntegrationServiceName)
+	}
+	if driver.DisableVirtualMachineIntegrationService_VmName != "foo" {
+		t.Fatalf("Should call with the VM name. Got: %s", driver.DisableVirtualMachineIntegrationService_VmName)
+	}
+}
+
+func TestStepConfigureIntegrationServices_noServices(t *testing.T) {
+	state := testState(t)
+	step := new(StepConfigureIntegrationServices)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.EnableVirtualMachineIntegrationService_Called || driver.DisableVirtualMachineIntegrationService_Called {
+		t.Fatal("Should NOT have configured any Integration Service")
+	}
+}
+
+func TestStepConfigureIntegrationServices_error(t *testing.T) {
+	state := testState(t)
+	step := &StepConfigureIntegrationServices{
+		Services: map[string]bool{"Heartbeat": false},
+	}
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.DisableVirtualMachineIntegrationService_Err = fmt.Errorf("disable failed")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("Should have error")
+	}
+}
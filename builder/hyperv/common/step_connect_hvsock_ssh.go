@@ -0,0 +1,171 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	helperssh "github.com/hashicorp/packer-plugin-sdk/communicator/ssh"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const hvsockSSHServiceName = "Packer SSH"
+
+// This step connects the SSH communicator to the guest over Hyper-V
+// sockets. It replaces the SDK's SSH connect step when ssh_transport is
+// hvsock, and otherwise behaves the same.
+//
+// Produces:
+//
+//	communicator packersdk.Communicator
+type StepConnectHvsockSSH struct {
+	Config    *communicator.Config
+	SSHConfig func(multistep.StateBag) (*gossh.ClientConfig, error)
+	// Dial connects to the SSH service of the VM. Defaults to DialHvsock.
+	Dial HvsockDialer
+	// Delay between connection attempts. Defaults to 5s.
+	RetryDelay time.Duration
+}
+
+func (s *StepConnectHvsockSSH) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmName := state.Get("vmName").(string)
+
+	vmId, err := driver.GetVMId(vmName)
+	if err != nil {
+		err := fmt.Errorf("Error getting the VM GUID: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	serviceId := HvsockServiceId(s.Config.SSHPort)
+	if err := driver.RegisterHvsockService(serviceId, hvsockSSHServiceName); err != nil {
+		// Connecting may still work if the service was registered before
+		ui.Error(fmt.Sprintf("WARNING: Error registering Hyper-V socket service %s: %s", serviceId, err))
+	}
+
+	waitCtx := ctx
+	if s.Config.SSHTimeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, s.Config.SSHTimeout)
+		defer cancel()
+	}
+
+	ui.Say("Waiting for SSH over Hyper-V sockets to become available...")
+	comm, err := s.waitForSSH(ctx, waitCtx, state, vmId, serviceId)
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Println("[WARN] Interrupt detected, quitting waiting for SSH.")
+			return multistep.ActionHalt
+		}
+		if waitCtx.Err() != nil {
+			err = fmt.Errorf("Timeout waiting for SSH over Hyper-V sockets.")
+		}
+		state.Put("error", err)
+		ui.Error(fmt.Sprintf("Error waiting for SSH: %s", err))
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Connected to SSH!")
+	s.Config.SSHHost = vmId
+	state.Put("communicator", comm)
+	return multistep.ActionContinue
+}
+
+// waitForSSH retries connecting until it succeeds, waitCtx is done or too
+// many handshakes failed to authenticate. Connections are made with ctx,
+// as the communicator reconnects later in the build.
+func (s *StepConnectHvsockSSH) waitForSSH(ctx context.Context, waitCtx context.Context,
+	state multistep.StateBag, vmId string, serviceId string) (packersdk.Communicator, error) {
+	dial := s.Dial
+	if dial == nil {
+		dial = DialHvsock
+	}
+	retryDelay := s.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = 5 * time.Second
+	}
+
+	var tunnels []ssh.TunnelSpec
+	for _, v := range s.Config.SSHLocalTunnels {
+		t, err := helperssh.ParseTunnelArgument(v, ssh.LocalTunnel)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing port forwarding: %s", err)
+		}
+		tunnels = append(tunnels, t)
+	}
+	for _, v := range s.Config.SSHRemoteTunnels {
+		t, err := helperssh.ParseTunnelArgument(v, ssh.RemoteTunnel)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing port forwarding: %s", err)
+		}
+		tunnels = append(tunnels, t)
+	}
+
+	address := fmt.Sprintf("%s:%d", vmId, s.Config.SSHPort)
+	handshakeAttempts := 0
+	for first := true; ; first = false {
+		if !first {
+			select {
+			case <-waitCtx.Done():
+				return nil, waitCtx.Err()
+			case <-time.After(retryDelay):
+			}
+		}
+
+		sshConfig, err := s.SSHConfig(state)
+		if err != nil {
+			log.Printf("[DEBUG] Error getting SSH config: %s", err)
+			continue
+		}
+
+		connFunc := func() (net.Conn, error) {
+			return dial(ctx, vmId, serviceId)
+		}
+
+		config := &ssh.Config{
+			Connection:             connFunc,
+			SSHConfig:              sshConfig,
+			Pty:                    s.Config.SSHPty,
+			DisableAgentForwarding: s.Config.SSHDisableAgentForwarding,
+			UseSftp:                s.Config.SSHFileTransferMethod == "sftp",
+			KeepAliveInterval:      s.Config.SSHKeepAliveInterval,
+			Timeout:                s.Config.SSHReadWriteTimeout,
+			Tunnels:                tunnels,
+		}
+
+		log.Printf("[INFO] Attempting SSH connection over Hyper-V sockets to %s...", address)
+		comm, err := ssh.New(address, config)
+		if err == nil {
+			return comm, nil
+		}
+		log.Printf("[DEBUG] SSH connection err: %s", err)
+
+		// As in the SDK, only failed authentications count as attempts
+		if strings.Contains(err.Error(), "authenticate") {
+			handshakeAttempts++
+			if s.Config.SSHHandshakeAttempts > 0 && handshakeAttempts >= s.Config.SSHHandshakeAttempts {
+				return nil, errors.New("Packer experienced an authentication error when trying to " +
+					"connect via SSH. This can happen if your username/password are wrong. " +
+					"original error: " + err.Error())
+			}
+		}
+	}
+}
+
+func (s *StepConnectHvsockSSH) Cleanup(state multistep.StateBag) {
+	// do nothing
+}
@@ -0,0 +1,236 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const testVMId = "7d4a3a6e-9a7b-4c2f-8f1e-2b5c3d4e5f60"
+
+// testHvsockSSHServer returns a dialer connecting to an in-process SSH
+// server over loopback, which accepts password and answers every command
+// with "ran: <command>". The dialed services are recorded, and the first
+// failures dials fail as if the guest wasn't listening yet.
+func testHvsockSSHServer(t *testing.T, password string, failures int, dialed *[]string) HvsockDialer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	signer, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	config := &gossh.ServerConfig{
+		PasswordCallback: func(_ gossh.ConnMetadata, p []byte) (*gossh.Permissions, error) {
+			if string(p) != password {
+				return nil, fmt.Errorf("password rejected")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	return func(ctx context.Context, vmId string, serviceId string) (net.Conn, error) {
+		*dialed = append(*dialed, vmId+"/"+serviceId)
+		if failures > 0 {
+			failures--
+			return nil, errors.New("connection refused")
+		}
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		defer listener.Close()
+		go func() {
+			if server, err := listener.Accept(); err == nil {
+				serveTestSSH(server, config)
+			}
+		}()
+		return net.Dial("tcp", listener.Addr().String())
+	}
+}
+
+func serveTestSSH(conn net.Conn, config *gossh.ServerConfig) {
+	sconn, chans, reqs, err := gossh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer sconn.Close()
+	go gossh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(gossh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func() {
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				var exec struct{ Command string }
+				gossh.Unmarshal(req.Payload, &exec)
+				req.Reply(true, nil)
+
+				io.WriteString(channel, "ran: "+exec.Command)
+				channel.SendRequest("exit-status", false, gossh.Marshal(struct{ Status uint32 }{0}))
+				channel.Close()
+				return
+			}
+		}()
+	}
+}
+
+func testHvsockSSHStep(password string, dial HvsockDialer) *StepConnectHvsockSSH {
+	config := &communicator.Config{
+		Type: "ssh",
+		SSH: communicator.SSH{
+			SSHUsername: "packer",
+			SSHPassword: password,
+			SSHPort:     22,
+			SSHTimeout:  time.Minute,
+		},
+	}
+
+	return &StepConnectHvsockSSH{
+		Config:     config,
+		SSHConfig:  config.SSHConfigFunc(),
+		Dial:       dial,
+		RetryDelay: time.Millisecond,
+	}
+}
+
+func TestStepConnectHvsockSSH_impl(t *testing.T) {
+	var _ multistep.Step = new(StepConnectHvsockSSH)
+}
+
+func TestHvsockServiceId(t *testing.T) {
+	if id := HvsockServiceId(22); id != "00000016-facb-11e6-bd58-64006a7986d3" {
+		t.Fatalf("Bad service GUID for port 22: %s", id)
+	}
+}
+
+func TestStepConnectHvsockSSH(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetVMId_Return = testVMId
+
+	var dialed []string
+	step := testHvsockSSHStep("secret", testHvsockSSHServer(t, "secret", 2, &dialed))
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+
+	if !driver.RegisterHvsockService_Called ||
+		driver.RegisterHvsockService_ServiceId != "00000016-facb-11e6-bd58-64006a7986d3" {
+		t.Fatalf("Should register the SSH service. Got: %s", driver.RegisterHvsockService_ServiceId)
+	}
+	if len(dialed) != 3 {
+		t.Fatalf("Should retry until the guest listens, dialed: %v", dialed)
+	}
+	if dialed[2] != testVMId+"/00000016-facb-11e6-bd58-64006a7986d3" {
+		t.Fatalf("Should dial the SSH service of the VM, dialed: %s", dialed[2])
+	}
+
+	comm := state.Get("communicator").(packersdk.Communicator)
+	ui := state.Get("ui").(*packersdk.BasicUi)
+	cmd := &packersdk.RemoteCmd{Command: "uname -a"}
+	if err := cmd.RunWithUi(context.Background(), comm, ui); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if cmd.ExitStatus() != 0 {
+		t.Fatalf("Bad exit status: %d", cmd.ExitStatus())
+	}
+	if output := ui.Writer.(*bytes.Buffer).String(); !strings.Contains(output, "ran: uname -a") {
+		t.Fatalf("Should run the command over SSH, got: %s", output)
+	}
+}
+
+func TestStepConnectHvsockSSH_authenticationError(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetVMId_Return = testVMId
+
+	var dialed []string
+	step := testHvsockSSHStep("wrong", testHvsockSSHServer(t, "secret", 0, &dialed))
+	step.Config.SSHHandshakeAttempts = 2
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("Should have error")
+	}
+	if len(dialed) != 2 {
+		t.Fatalf("Should give up after 2 handshake attempts, dialed: %v", dialed)
+	}
+}
+
+func TestStepConnectHvsockSSH_vmIdError(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetVMId_Err = errors.New("VM not found")
+
+	step := testHvsockSSHStep("secret", nil)
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.RegisterHvsockService_Called {
+		t.Fatal("Should NOT register the service without a VM")
+	}
+}
+
+func TestSSHHost_hvsock(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetVMId_Return = testVMId
+
+	config := &CommConfig{SSHTransport: SSHTransportHvsock}
+	config.Comm.Type = "ssh"
+
+	host, err := SSHHost(config)(state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if host != testVMId {
+		t.Fatalf("Should use the VM GUID as host, got: %s", host)
+	}
+	if driver.Mac_Called {
+		t.Fatal("Should NOT look up the IP address of the VM")
+	}
+}
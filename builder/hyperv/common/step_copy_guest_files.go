@@ -0,0 +1,47 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step copies files from the host into the running guest through the
+// Guest Service Interface, before the communicator connects.
+type StepCopyGuestFiles struct {
+	Files   []GuestFile
+	Timeout time.Duration
+}
+
+func (s *StepCopyGuestFiles) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if len(s.Files) == 0 {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmName := state.Get("vmName").(string)
+
+	for _, f := range s.Files {
+		ui.Say(fmt.Sprintf("Copying %s to %s in the guest...", f.Source, f.Destination))
+		err := CopyFileToGuest(ctx, driver, vmName, f, s.Timeout)
+		if err != nil {
+			err := fmt.Errorf("Error copying %s to the guest: %s", f.Source, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepCopyGuestFiles) Cleanup(state multistep.StateBag) {
+	// do nothing
+}
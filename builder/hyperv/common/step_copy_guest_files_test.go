@@ -0,0 +1,85 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepCopyGuestFiles_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCopyGuestFiles)
+}
+
+func TestStepCopyGuestFiles(t *testing.T) {
+	state := testState(t)
+	step := &StepCopyGuestFiles{
+		Files: []GuestFile{
+			{Source: "payload", Destination: `C:\payload`},
+		},
+		Timeout: time.Minute,
+	}
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("Should NOT have error")
+	}
+
+	if !driver.CopyFileToVirtualMachine_Called {
+		t.Fatal("Should have called CopyFileToVirtualMachine")
+	}
+	if driver.CopyFileToVirtualMachine_VmName != "foo" {
+		t.Fatalf("Should call with the VM name. Got: %s", driver.CopyFileToVirtualMachine_VmName)
+	}
+	if driver.CopyFileToVirtualMachine_Source != "payload" {
+		t.Fatalf("Should call with the source. Got: %s", driver.CopyFileToVirtualMachine_Source)
+	}
+	if driver.CopyFileToVirtualMachine_Destination != `C:\payload` {
+		t.Fatalf("Should call with the destination. Got: %s", driver.CopyFileToVirtualMachine_Destination)
+	}
+}
+
+func TestStepCopyGuestFiles_noFiles(t *testing.T) {
+	state := testState(t)
+	step := new(StepCopyGuestFiles)
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.CopyFileToVirtualMachine_Called {
+		t.Fatal("Should NOT have called CopyFileToVirtualMachine")
+	}
+}
+
+func TestStepCopyGuestFiles_timeout(t *testing.T) {
+	state := testState(t)
+	step := &StepCopyGuestFiles{
+		Files: []GuestFile{
+			{Source: "payload", Destination: `C:\payload`},
+		},
+		Timeout: time.Millisecond,
+	}
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.CopyFileToVirtualMachine_Err = fmt.Errorf("guest service interface not running")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("Should have error")
+	}
+}
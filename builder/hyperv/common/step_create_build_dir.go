@@ -10,6 +10,8 @@ import (
 	"log"
 	"os"
 
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/powershell/hyperv"
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/wsl"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/tmp"
@@ -21,9 +23,19 @@ type StepCreateBuildDir struct {
 	// folders during the build. If unspecified the default temp directory
 	// for the OS is used
 	TempPath string
+	// User supplied directories under which directories uniquely named for
+	// the build are created to hold the virtual hard disks, checkpoints and
+	// smart paging file of the VM. If unspecified these files are kept in
+	// the build directory
+	VhdPath         string
+	CheckpointPath  string
+	SmartPagingPath string
 	// The full path to the build directory. This is the concatenation of
 	// TempPath plus a directory uniquely named for the build
 	buildDir string
+	// The full paths to the directories created under VhdPath,
+	// CheckpointPath and SmartPagingPath
+	storageDirs []string
 	// If true, the build directory will not be deleted when the step
 	// Cleanup() method is called
 	KeepRegistered bool
@@ -55,10 +67,37 @@ func (s *StepCreateBuildDir) Run(ctx context.Context, state multistep.StateBag)
 	// Record the build directory location for later steps
 	state.Put("build_dir", s.buildDir)
 
+	storage := []struct {
+		path     string
+		stateKey string
+		name     string
+	}{
+		{s.VhdPath, "vhd_dir", "virtual hard disk"},
+		{s.CheckpointPath, "checkpoint_dir", "checkpoint"},
+		{s.SmartPagingPath, "smart_paging_dir", "smart paging"},
+	}
+	for _, st := range storage {
+		if st.path == "" {
+			continue
+		}
+
+		dir, err := ioutil.TempDir(st.path, "hyperv")
+		if err != nil {
+			err = fmt.Errorf("Error creating %s directory: %s", st.name, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		log.Printf("Created %s directory: %s", st.name, dir)
+		s.storageDirs = append(s.storageDirs, dir)
+		state.Put(st.stateKey, dir)
+	}
+
 	return multistep.ActionContinue
 }
 
-// Cleanup removes the build directory
+// Cleanup removes the build directory and any separate storage directories
 func (s *StepCreateBuildDir) Cleanup(state multistep.StateBag) {
 	if s.buildDir == "" {
 		return
@@ -80,4 +119,39 @@ func (s *StepCreateBuildDir) Cleanup(state multistep.StateBag) {
 	if err != nil {
 		ui.Error(fmt.Sprintf("Error deleting build directory: %s", err))
 	}
+
+	for _, dir := range s.storageDirs {
+		err := os.RemoveAll(dir)
+		if err != nil {
+			ui.Error(fmt.Sprintf("Error deleting directory %s: %s", dir, err))
+		}
+	}
+}
+
+// storagePaths returns the storage directories created for the build,
+// converted to paths usable on the Hyper-V host.
+func storagePaths(state multistep.StateBag) (hyperv.StoragePaths, error) {
+	var paths hyperv.StoragePaths
+	for key, path := range map[string]*string{
+		"vhd_dir":          &paths.VhdPath,
+		"checkpoint_dir":   &paths.CheckpointPath,
+		"smart_paging_dir": &paths.SmartPagingPath,
+	} {
+		v, ok := state.GetOk(key)
+		if !ok {
+			continue
+		}
+
+		dir := v.(string)
+		if wsl.IsWSL() {
+			var err error
+			dir, err = wsl.ConvertWSlPathToWindowsPath(dir)
+			if err != nil {
+				return paths, err
+			}
+		}
+		*path = dir
+	}
+
+	return paths, nil
 }
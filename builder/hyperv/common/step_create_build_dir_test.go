@@ -125,3 +125,68 @@ func TestStepCreateBuildDir_BadTempPath(t *testing.T) {
 		t.Fatal("Should have error due to bad path")
 	}
 }
+
+func TestStepCreateBuildDir_StoragePaths(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateBuildDir)
+
+	// Create a directory we'll use as the user supplied vhd_path
+	step.VhdPath = genTestDirPath("userVhdDir")
+	err := os.Mkdir(step.VhdPath, 0755) // The directory must exist
+	if err != nil {
+		t.Fatal("Error creating test directory")
+	}
+	defer os.RemoveAll(step.VhdPath)
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("Should NOT have error")
+	}
+
+	v, ok := state.GetOk("vhd_dir")
+	if !ok {
+		t.Fatal("Should store path to vhd directory in statebag as 'vhd_dir'")
+	}
+
+	vhdDir := v.(string)
+	if !strings.HasPrefix(vhdDir, step.VhdPath+string(os.PathSeparator)) {
+		t.Fatalf("vhdDir should be stored in step.VhdPath")
+	}
+	if _, ok := state.GetOk("checkpoint_dir"); ok {
+		t.Fatal("Should NOT store 'checkpoint_dir' when CheckpointPath is empty")
+	}
+
+	// Test Cleanup
+	step.Cleanup(state)
+	if _, err := os.Stat(vhdDir); err == nil {
+		t.Fatalf("Vhd directory should NOT exist after Cleanup: %s", vhdDir)
+	}
+	if _, err := os.Stat(step.VhdPath); err != nil {
+		t.Fatal("User supplied root for vhd directory should NOT be deleted by Cleanup")
+	}
+}
+
+func TestStepCreateBuildDir_BadStoragePath(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateBuildDir)
+
+	// Bad
+	step.CheckpointPath = genTestDirPath("iDontExist")
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("Should have error due to bad path")
+	}
+
+	// The build directory is still removed
+	step.Cleanup(state)
+	if _, err := os.Stat(step.buildDir); err == nil {
+		t.Fatalf("Build directory should NOT exist after Cleanup: %s", step.buildDir)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/iso9660"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/hashicorp/packer-plugin-sdk/tmp"
+)
+
+// This step renders the cloud-init files and writes them to a NoCloud seed
+// image, which StepMountSecondaryDvdImages mounts.
+//
+// Uses:
+//
+//	http_ip string - Set by StepResolveHostIP
+//
+// Produces:
+//
+//	cloud_init_path string - The path to the seed image
+type StepCreateCloudInitSeed struct {
+	Config CloudInitConfig
+	Comm   *communicator.Config
+	Ctx    interpolate.Context
+
+	seedDir string
+}
+
+func (s *StepCreateCloudInitSeed) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Config.IsSet() {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+
+	data := newGuestTemplateData(state)
+	if s.Comm != nil {
+		data.SSHPublicKey = strings.TrimSpace(string(s.Comm.SSHPublicKey))
+	}
+	s.Ctx.Data = data
+
+	var files []iso9660.File
+	for _, f := range []struct {
+		name     string
+		template string
+	}{
+		{"user-data", s.Config.UserData},
+		{"meta-data", s.Config.MetaData},
+		{"network-config", s.Config.NetworkConfig},
+	} {
+		// cloud-init requires user-data and meta-data, even when empty
+		if f.template == "" && f.name == "network-config" {
+			continue
+		}
+		content, err := interpolate.Render(f.template, &s.Ctx)
+		if err != nil {
+			err := fmt.Errorf("Error rendering cloud-init %s: %s", f.name, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		files = append(files, iso9660.File{Name: f.name, Data: []byte(content)})
+	}
+
+	ui.Say("Creating cloud-init seed...")
+	seedDir, err := tmp.Dir("hyperv-cidata")
+	if err != nil {
+		err := fmt.Errorf("Error creating cloud-init seed: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.seedDir = seedDir
+
+	seedPath := filepath.Join(seedDir, "cidata.iso")
+	if err := writeCloudInitSeed(seedPath, files); err != nil {
+		err := fmt.Errorf("Error creating cloud-init seed: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	log.Printf("cloud-init seed written to %s", seedPath)
+	state.Put("cloud_init_path", seedPath)
+	return multistep.ActionContinue
+}
+
+func writeCloudInitSeed(path string, files []iso9660.File) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := iso9660.Write(f, CloudInitLabel, files, time.Now()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *StepCreateCloudInitSeed) Cleanup(state multistep.StateBag) {
+	if s.seedDir == "" {
+		return
+	}
+
+	if err := os.RemoveAll(s.seedDir); err != nil {
+		log.Printf("Error removing cloud-init seed: %s", err)
+	}
+}
+
+// This step deletes the cloud-init seed once the VM is off and the seed is
+// unmounted, so the user-data isn't left behind while the VM is exported.
+type StepDeleteCloudInitSeed struct{}
+
+func (s *StepDeleteCloudInitSeed) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	seedPath, ok := state.Get("cloud_init_path").(string)
+	if !ok || seedPath == "" {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	ui.Say("Deleting cloud-init seed...")
+
+	if err := os.Remove(seedPath); err != nil && !os.IsNotExist(err) {
+		err := fmt.Errorf("Error deleting cloud-init seed: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("cloud_init_path", "")
+	return multistep.ActionContinue
+}
+
+func (s *StepDeleteCloudInitSeed) Cleanup(state multistep.StateBag) {
+	// do nothing
+}
@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepCreateCloudInitSeed_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCreateCloudInitSeed)
+}
+
+func TestStepDeleteCloudInitSeed_impl(t *testing.T) {
+	var _ multistep.Step = new(StepDeleteCloudInitSeed)
+}
+
+func TestStepCreateCloudInitSeed(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+	state.Put("http_port", 8080)
+	state.Put("http_ip", "10.0.0.1")
+
+	config := CloudInitConfig{
+		UserData: "#cloud-config\nssh_authorized_keys: [{{ .SSHPublicKey }}]\n" +
+			"runcmd: [curl http://{{ .HTTPIP }}:{{ .HTTPPort }}/setup.sh]\n",
+		NetworkConfig: "version: 2\n",
+	}
+	if errs := config.Prepare(); len(errs) > 0 {
+		t.Fatalf("err: %v", errs)
+	}
+	comm := &communicator.Config{
+		SSH: communicator.SSH{SSHPublicKey: []byte("ssh-ed25519 AAAA packer\n")},
+	}
+	step := &StepCreateCloudInitSeed{
+		Config: config,
+		Comm:   comm,
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+
+	seedPath := state.Get("cloud_init_path").(string)
+	seed, err := os.ReadFile(seedPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if label := seed[16*2048+40 : 16*2048+46]; string(label) != CloudInitLabel {
+		t.Fatalf("Bad volume label: %q", label)
+	}
+	for _, content := range []string{
+		"ssh_authorized_keys: [ssh-ed25519 AAAA packer]",
+		"runcmd: [curl http://10.0.0.1:8080/setup.sh]",
+		"instance-id: foo\nlocal-hostname: foo\n",
+		"version: 2\n",
+	} {
+		if !bytes.Contains(seed, []byte(content)) {
+			t.Fatalf("Seed should contain %q", content)
+		}
+	}
+
+	step.Cleanup(state)
+	if _, err := os.Stat(filepath.Dir(seedPath)); !os.IsNotExist(err) {
+		t.Fatalf("Should remove the seed directory: %v", err)
+	}
+}
+
+func TestStepCreateCloudInitSeed_notSet(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	step := &StepCreateCloudInitSeed{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("cloud_init_path"); ok {
+		t.Fatal("Should NOT create a seed")
+	}
+}
+
+func TestStepCreateCloudInitSeed_noHostIP(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	// StepResolveHostIP skipped the host IP for an hvsock transport
+	step := &StepCreateCloudInitSeed{
+		Config: CloudInitConfig{UserData: "#cloud-config\nruncmd: [echo '{{ .HTTPIP }}']\n"},
+	}
+	defer step.Cleanup(state)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	seed, err := os.ReadFile(state.Get("cloud_init_path").(string))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Contains(seed, []byte("runcmd: [echo '']")) {
+		t.Fatal("Seed should render an empty HTTPIP")
+	}
+}
+
+func TestStepDeleteCloudInitSeed(t *testing.T) {
+	state := testState(t)
+	seedPath := filepath.Join(t.TempDir(), "cidata.iso")
+	if err := os.WriteFile(seedPath, []byte("seed"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	state.Put("cloud_init_path", seedPath)
+
+	step := &StepDeleteCloudInitSeed{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	if _, err := os.Stat(seedPath); !os.IsNotExist(err) {
+		t.Fatalf("Should delete the seed: %v", err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/powershell/hyperv"
 	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/wsl"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -30,8 +31,13 @@ type StepCreateVM struct {
 	UseLegacyNetworkAdapter        bool
 	Generation                     uint
 	Cpu                            uint
+	Processor                      ProcessorConfig
 	EnableMacSpoofing              bool
 	EnableDynamicMemory            bool
+	MemoryMinimum                  uint
+	MemoryMaximum                  uint
+	MemoryBuffer                   uint
+	MemoryWeight                   *uint
 	EnableSecureBoot               bool
 	SecureBootTemplate             string
 	EnableVirtualizationExtensions bool
@@ -42,6 +48,11 @@ type StepCreateVM struct {
 	FixedVHD                       bool
 	Version                        string
 	KeepRegistered                 bool
+	MinimumIOPS                    uint64
+	MaximumIOPS                    uint64
+	QoSPolicyID                    string
+	DiskCacheAttributes            string
+	CheckpointType                 string
 }
 
 func (s *StepCreateVM) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -64,7 +75,20 @@ func (s *StepCreateVM) Run(ctx context.Context, state multistep.StateBag) multis
 		}
 	}
 
-	err := driver.CheckVMName(s.VMName)
+	storagePaths, err := storagePaths(state)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Additional disks are kept with the VM's other virtual hard disks
+	vhdDir := path
+	if storagePaths.VhdPath != "" {
+		vhdDir = storagePaths.VhdPath
+	}
+
+	err = driver.CheckVMName(s.VMName)
 	if err != nil {
 		s.KeepRegistered = true
 		state.Put("error", err)
@@ -91,7 +115,7 @@ func (s *StepCreateVM) Run(ctx context.Context, state multistep.StateBag) multis
 	diskBlockSize := int64(s.DiskBlockSize) * 1024 * 1024
 
 	err = driver.CreateVirtualMachine(s.VMName, path, harddrivePath, ramSize, diskSize, diskBlockSize,
-		s.SwitchName, s.Generation, s.DifferencingDisk, s.FixedVHD, s.Version)
+		s.SwitchName, s.Generation, s.DifferencingDisk, s.FixedVHD, s.Version, s.diskPerformance(), storagePaths)
 	if err != nil {
 		err := fmt.Errorf("Error creating virtual machine: %s", err)
 		state.Put("error", err)
@@ -117,7 +141,17 @@ func (s *StepCreateVM) Run(ctx context.Context, state multistep.StateBag) multis
 		return multistep.ActionHalt
 	}
 
-	err = driver.SetVirtualMachineDynamicMemory(s.VMName, s.EnableDynamicMemory)
+	if s.Processor.IsSet() {
+		err = driver.SetVirtualMachineProcessor(s.VMName, s.Processor.settings())
+		if err != nil {
+			err := fmt.Errorf("Error setting virtual machine processor: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	err = driver.SetVirtualMachineDynamicMemory(s.VMName, s.EnableDynamicMemory, s.dynamicMemory())
 	if err != nil {
 		err := fmt.Errorf("Error setting virtual machine dynamic memory: %s", err)
 		state.Put("error", err)
@@ -135,6 +169,16 @@ func (s *StepCreateVM) Run(ctx context.Context, state multistep.StateBag) multis
 		}
 	}
 
+	if s.CheckpointType != "" {
+		err = driver.SetVirtualMachineCheckpointType(s.VMName, s.CheckpointType)
+		if err != nil {
+			err := fmt.Errorf("Error setting checkpoint type: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	if s.Generation == 2 {
 		err = driver.SetVirtualMachineSecureBoot(s.VMName, s.EnableSecureBoot, s.SecureBootTemplate)
 		if err != nil {
@@ -169,7 +213,8 @@ func (s *StepCreateVM) Run(ctx context.Context, state multistep.StateBag) multis
 		for index, size := range s.AdditionalDiskSize {
 			diskSize := int64(size * 1024 * 1024)
 			diskFile := fmt.Sprintf("%s-%d.vhdx", s.VMName, index)
-			err = driver.AddVirtualMachineHardDrive(s.VMName, path, diskFile, diskSize, diskBlockSize, "SCSI")
+			err = driver.AddVirtualMachineHardDrive(s.VMName, vhdDir, diskFile, diskSize, diskBlockSize, "SCSI",
+				s.diskPerformance())
 			if err != nil {
 				err := fmt.Errorf("Error creating and attaching additional disk drive: %s", err)
 				state.Put("error", err)
@@ -198,6 +243,25 @@ func (s *StepCreateVM) Run(ctx context.Context, state multistep.StateBag) multis
 	return multistep.ActionContinue
 }
 
+func (s *StepCreateVM) diskPerformance() hyperv.DiskPerformance {
+	return hyperv.DiskPerformance{
+		MinimumIOPS:     s.MinimumIOPS,
+		MaximumIOPS:     s.MaximumIOPS,
+		QoSPolicyID:     s.QoSPolicyID,
+		CacheAttributes: s.DiskCacheAttributes,
+	}
+}
+
+func (s *StepCreateVM) dynamicMemory() hyperv.DynamicMemory {
+	// convert the MB to bytes
+	return hyperv.DynamicMemory{
+		MinimumBytes: int64(s.MemoryMinimum) * 1024 * 1024,
+		MaximumBytes: int64(s.MemoryMaximum) * 1024 * 1024,
+		Buffer:       s.MemoryBuffer,
+		Priority:     s.MemoryWeight,
+	}
+}
+
 func (s *StepCreateVM) Cleanup(state multistep.StateBag) {
 	if s.VMName == "" {
 		return
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/powershell/hyperv"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 )
 
@@ -36,6 +37,135 @@ func TestStepCreateVM(t *testing.T) {
 	}
 }
 
+func TestStepCreateVM_DiskPerformance(t *testing.T) {
+	state := testState(t)
+	step := &StepCreateVM{
+		VMName:              "test-VM-Name",
+		AdditionalDiskSize:  []uint{1024},
+		MinimumIOPS:         100,
+		MaximumIOPS:         500,
+		DiskCacheAttributes: "WriteCacheDisabled",
+	}
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+
+	expected := hyperv.DiskPerformance{
+		MinimumIOPS:     100,
+		MaximumIOPS:     500,
+		CacheAttributes: "WriteCacheDisabled",
+	}
+	if driver.CreateVirtualMachine_DiskPerformance != expected {
+		t.Fatalf("Should call CreateVirtualMachine with disk performance. Got: %#v",
+			driver.CreateVirtualMachine_DiskPerformance)
+	}
+	if driver.AddVirtualMachineHardDrive_DiskPerformance != expected {
+		t.Fatalf("Should call AddVirtualMachineHardDrive with disk performance. Got: %#v",
+			driver.AddVirtualMachineHardDrive_DiskPerformance)
+	}
+}
+
+func TestStepCreateVM_StoragePaths(t *testing.T) {
+	state := testState(t)
+	state.Put("build_dir", "foo/build")
+	state.Put("vhd_dir", "foo/disks")
+	state.Put("checkpoint_dir", "foo/checkpoints")
+	step := &StepCreateVM{
+		VMName:             "test-VM-Name",
+		AdditionalDiskSize: []uint{1024},
+	}
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+
+	expected := hyperv.StoragePaths{
+		VhdPath:        "foo/disks",
+		CheckpointPath: "foo/checkpoints",
+	}
+	if driver.CreateVirtualMachine_StoragePaths != expected {
+		t.Fatalf("Should call CreateVirtualMachine with storage paths. Got: %#v",
+			driver.CreateVirtualMachine_StoragePaths)
+	}
+	if driver.CreateVirtualMachine_Path != "foo/build" {
+		t.Fatalf("Should call CreateVirtualMachine with the build dir. Got: %s",
+			driver.CreateVirtualMachine_Path)
+	}
+	if driver.AddVirtualMachineHardDrive_VhdFile != "foo/disks" {
+		t.Fatalf("Should create additional disks in the vhd dir. Got: %s",
+			driver.AddVirtualMachineHardDrive_VhdFile)
+	}
+}
+
+func TestStepCreateVM_DynamicMemory(t *testing.T) {
+	state := testState(t)
+	weight := uint(80)
+	step := &StepCreateVM{
+		VMName:              "test-VM-Name",
+		EnableDynamicMemory: true,
+		MemoryMinimum:       512,
+		MemoryMaximum:       4096,
+		MemoryBuffer:        10,
+		MemoryWeight:        &weight,
+	}
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+
+	if !driver.SetVirtualMachineDynamicMemory_Called {
+		t.Fatal("Should have called SetVirtualMachineDynamicMemory")
+	}
+	expected := hyperv.DynamicMemory{
+		MinimumBytes: 512 * 1024 * 1024,
+		MaximumBytes: 4096 * 1024 * 1024,
+		Buffer:       10,
+		Priority:     &weight,
+	}
+	if driver.SetVirtualMachineDynamicMemory_DynamicMemory != expected {
+		t.Fatalf("Should call SetVirtualMachineDynamicMemory with the memory settings. Got: %#v",
+			driver.SetVirtualMachineDynamicMemory_DynamicMemory)
+	}
+}
+
+func TestStepCreateVM_Processor(t *testing.T) {
+	state := testState(t)
+	threads := uint(1)
+	step := &StepCreateVM{
+		VMName: "test-VM-Name",
+		Cpu:    2,
+		Processor: ProcessorConfig{
+			Reserve:                          10,
+			Maximum:                          75,
+			HwThreadCountPerCore:             &threads,
+			CompatibilityForMigrationEnabled: true,
+		},
+	}
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+
+	if !driver.SetVirtualMachineProcessor_Called {
+		t.Fatal("Should have called SetVirtualMachineProcessor")
+	}
+	expected := hyperv.ProcessorSettings{
+		Reserve:                          10,
+		Maximum:                          75,
+		HwThreadCountPerCore:             &threads,
+		CompatibilityForMigrationEnabled: true,
+	}
+	if driver.SetVirtualMachineProcessor_Settings != expected {
+		t.Fatalf("Should call SetVirtualMachineProcessor with the processor settings. Got: %#v",
+			driver.SetVirtualMachineProcessor_Settings)
+	}
+}
+
 func TestStepCreateVM_CheckVMNameErr(t *testing.T) {
 	state := testState(t)
 	step := new(StepCreateVM)
@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step removes the devices and bindings only the build needed from the
+// VM before it is exported, so it imports on hosts without the build
+// switch: empty DVD drives, the floppy media, the switch connection, the
+// static MAC address and the VLAN of the network adapters.
+type StepExportCleanup struct {
+	Enabled bool
+}
+
+func (s *StepExportCleanup) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Enabled {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmName := state.Get("vmName").(string)
+
+	ui.Say("Removing build-only devices and bindings from the VM...")
+
+	count, err := driver.DeleteEmptyDvdDrives(vmName)
+	if err != nil {
+		err := fmt.Errorf("Error removing empty dvd drives: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	ui.Message(fmt.Sprintf("Removed %d empty dvd drives", count))
+
+	// A cloned VM keeps the generation of its source, which the config
+	// doesn't necessarily know
+	generation, err := driver.GetVirtualMachineGeneration(vmName)
+	if err != nil {
+		err := fmt.Errorf("Error detecting vm generation: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	type cleanup struct {
+		description string
+		apply       func(string) error
+	}
+	cleanups := []cleanup{
+		{"disconnecting the network adapters", driver.DisconnectVirtualMachineNetworkAdapter},
+		{"resetting the MAC addresses to dynamic", driver.SetVmNetworkAdapterDynamicMacAddress},
+		{"removing the VLAN", driver.UntagVirtualMachineVlan},
+	}
+	// Generation 2 VMs have no floppy drive
+	if generation < 2 {
+		cleanups = append([]cleanup{{"ejecting the floppy", driver.UnmountFloppyDrive}}, cleanups...)
+	}
+
+	for _, c := range cleanups {
+		ui.Message(fmt.Sprintf("Cleanup: %s", c.description))
+		if err := c.apply(vmName); err != nil {
+			err := fmt.Errorf("Error %s: %s", c.description, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepExportCleanup) Cleanup(state multistep.StateBag) {
+	// do nothing
+}
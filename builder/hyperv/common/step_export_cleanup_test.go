@@ -0,0 +1,100 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepExportCleanup_impl(t *testing.T) {
+	var _ multistep.Step = new(StepExportCleanup)
+}
+
+func TestStepExportCleanup(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.DeleteEmptyDvdDrives_Return = 2
+	driver.GetVirtualMachineGeneration_Return = 1
+
+	step := &StepExportCleanup{Enabled: true}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+
+	if !driver.DeleteEmptyDvdDrives_Called || driver.DeleteEmptyDvdDrives_VmName != "foo" {
+		t.Fatal("Should remove the empty dvd drives")
+	}
+	if !driver.UnmountFloppyDrive_Called {
+		t.Fatal("Should eject the floppy")
+	}
+	if !driver.DisconnectVirtualMachineNetworkAdapter_Called {
+		t.Fatal("Should disconnect the network")
+	}
+	if !driver.SetVmNetworkAdapterDynamicMacAddress_Called {
+		t.Fatal("Should reset the MAC address")
+	}
+	if !driver.UntagVirtualMachineVlan_Called {
+		t.Fatal("Should remove the VLAN")
+	}
+}
+
+func TestStepExportCleanup_generation2(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetVirtualMachineGeneration_Return = 2
+
+	step := &StepExportCleanup{Enabled: true}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	if driver.UnmountFloppyDrive_Called {
+		t.Fatal("Should NOT eject the floppy of a generation 2 VM")
+	}
+	if !driver.DisconnectVirtualMachineNetworkAdapter_Called {
+		t.Fatal("Should disconnect the network")
+	}
+}
+
+func TestStepExportCleanup_notSet(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	step := &StepExportCleanup{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.DeleteEmptyDvdDrives_Called || driver.DisconnectVirtualMachineNetworkAdapter_Called {
+		t.Fatal("Should NOT clean up the VM")
+	}
+}
+
+func TestStepExportCleanup_error(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.DisconnectVirtualMachineNetworkAdapter_Err = errors.New("adapter not found")
+	driver.GetVirtualMachineGeneration_Return = 2
+
+	step := &StepExportCleanup{Enabled: true}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("Should have an error")
+	}
+	if driver.SetVmNetworkAdapterDynamicMacAddress_Called {
+		t.Fatal("Should stop at the first error")
+	}
+}
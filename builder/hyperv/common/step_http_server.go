@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	"github.com/hashicorp/packer-plugin-sdk/net"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step runs the HTTP server of the build. Without a CallbackPath it
+// is the SDK's StepHTTPServer. With one, the server is started even when
+// there are no files to serve, and also answers requests to CallbackPath,
+// which the http install_complete strategy waits for.
+//
+// Produces:
+//
+//	http_port int - The port the HTTP server started on.
+//	install_complete_callback <-chan struct{} - Closed on the first request to CallbackPath
+type StepHTTPServer struct {
+	Config       *commonsteps.HTTPConfig
+	CallbackPath string
+
+	step *commonsteps.StepHTTPServer
+	l    *net.Listener
+}
+
+func (s *StepHTTPServer) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	s.step = commonsteps.HTTPServerFromHTTPConfig(s.Config)
+	if s.CallbackPath == "" {
+		return s.step.Run(ctx, state)
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+
+	var files http.Handler = http.NotFoundHandler()
+	if s.step.HTTPDir != "" {
+		if _, err := os.Stat(s.step.HTTPDir); err != nil {
+			err := fmt.Errorf("Error finding %q: %s", s.step.HTTPDir, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		files = s.step.Handler()
+	} else if len(s.step.HTTPContent) > 0 {
+		files = s.step.Handler()
+	}
+
+	callback := make(chan struct{})
+	var once sync.Once
+	mux := http.NewServeMux()
+	mux.Handle("/", files)
+	mux.HandleFunc(s.CallbackPath, func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Install complete callback from %s", r.RemoteAddr)
+		once.Do(func() { close(callback) })
+		fmt.Fprintln(w, "ok")
+	})
+
+	var err error
+	s.l, err = net.ListenRangeConfig{
+		Min:     s.step.HTTPPortMin,
+		Max:     s.step.HTTPPortMax,
+		Addr:    s.step.HTTPAddress,
+		Network: s.step.HTTPNetworkProcotol,
+	}.Listen(ctx)
+	if err != nil {
+		err := fmt.Errorf("Error finding port: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Starting HTTP server on port %d", s.l.Port))
+	server := &http.Server{Handler: mux}
+	go server.Serve(s.l)
+
+	state.Put("http_port", s.l.Port)
+	state.Put("install_complete_callback", (<-chan struct{})(callback))
+	return multistep.ActionContinue
+}
+
+func (s *StepHTTPServer) Cleanup(state multistep.StateBag) {
+	if s.l == nil {
+		if s.step != nil {
+			s.step.Cleanup(state)
+		}
+		return
+	}
+
+	if err := s.l.Close(); err != nil {
+		ui := state.Get("ui").(packersdk.Ui)
+		ui.Error(fmt.Sprintf("Failed closing http server on port %d: %s", s.l.Port, err))
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+)
+
+func TestStepHTTPServer_impl(t *testing.T) {
+	var _ multistep.Step = new(StepHTTPServer)
+}
+
+func testHTTPGet(t *testing.T, port int, path string) (int, string) {
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, path))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestStepHTTPServer_callback(t *testing.T) {
+	state := testState(t)
+	step := &StepHTTPServer{
+		Config: &commonsteps.HTTPConfig{
+			HTTPContent: map[string]string{"/ks.cfg": "text"},
+			HTTPPortMin: 8000,
+			HTTPPortMax: 9000,
+			HTTPAddress: "127.0.0.1",
+		},
+		CallbackPath: DefaultInstallCompletePath,
+	}
+	defer step.Cleanup(state)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	port := state.Get("http_port").(int)
+	callback := state.Get("install_complete_callback").(<-chan struct{})
+
+	if status, body := testHTTPGet(t, port, "/ks.cfg"); status != http.StatusOK || body != "text" {
+		t.Fatalf("Should still serve http_content, got: %d %s", status, body)
+	}
+	select {
+	case <-callback:
+		t.Fatal("Should NOT signal before the callback")
+	default:
+	}
+
+	// The guest may call back more than once
+	for i := 0; i < 2; i++ {
+		if status, _ := testHTTPGet(t, port, DefaultInstallCompletePath); status != http.StatusOK {
+			t.Fatalf("Bad callback status: %d", status)
+		}
+	}
+	select {
+	case <-callback:
+	default:
+		t.Fatal("Should signal the callback")
+	}
+}
+
+func TestStepHTTPServer_noCallback(t *testing.T) {
+	state := testState(t)
+	step := &StepHTTPServer{Config: &commonsteps.HTTPConfig{}}
+	defer step.Cleanup(state)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	if port := state.Get("http_port").(int); port != 0 {
+		t.Fatalf("Should NOT start a server without files, got port: %d", port)
+	}
+	if _, ok := state.GetOk("install_complete_callback"); ok {
+		t.Fatal("Should NOT have a callback")
+	}
+}
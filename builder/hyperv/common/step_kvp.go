@@ -0,0 +1,160 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// GuestFacts are the KVP items the integration services of a guest publish
+// that are added to the generated data of the build.
+var GuestFacts = []string{
+	"OSName",
+	"OSVersion",
+	"FullyQualifiedDomainName",
+	"IntegrationServicesVersion",
+}
+
+// The sizes Hyper-V allows for the name and data of a KVP item
+const (
+	maxKvpNameSize = 512
+	maxKvpDataSize = 2048
+)
+
+// This step sends the kvp_items to the guest before the VM starts.
+//
+// Uses:
+//
+//	http_ip string - Set by StepResolveHostIP
+//
+// Produces:
+//
+//	kvp_host_items []string - The names of the items sent
+type StepSetHostKvpItems struct {
+	Items map[string]string
+	Ctx   interpolate.Context
+}
+
+func (s *StepSetHostKvpItems) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if len(s.Items) == 0 {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmName := state.Get("vmName").(string)
+
+	s.Ctx.Data = newGuestTemplateData(state)
+
+	names := make([]string, 0, len(s.Items))
+	for name := range s.Items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ui.Say("Sending KVP items to the guest...")
+	var sent []string
+	for _, name := range names {
+		value, err := interpolate.Render(s.Items[name], &s.Ctx)
+		if err != nil {
+			err := fmt.Errorf("Error rendering KVP item %s: %s", name, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if len(value) > maxKvpDataSize {
+			err := fmt.Errorf("Error sending KVP item %s: the value is longer than %d bytes", name, maxKvpDataSize)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		log.Printf("Setting KVP item %s=%s", name, value)
+		if err := driver.SetHostKvpItem(vmName, name, value); err != nil {
+			err := fmt.Errorf("Error sending KVP item %s: %s", name, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		sent = append(sent, name)
+		state.Put("kvp_host_items", sent)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepSetHostKvpItems) Cleanup(state multistep.StateBag) {
+	// do nothing
+}
+
+// This step removes the KVP items sent to the guest once the VM is off, so
+// the exported VM doesn't carry values of the build.
+type StepRemoveHostKvpItems struct{}
+
+func (s *StepRemoveHostKvpItems) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	sent, ok := state.Get("kvp_host_items").([]string)
+	if !ok || len(sent) == 0 {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmName := state.Get("vmName").(string)
+
+	ui.Say("Removing KVP items sent to the guest...")
+	for _, name := range sent {
+		if err := driver.RemoveHostKvpItem(vmName, name); err != nil {
+			err := fmt.Errorf("Error removing KVP item %s: %s", name, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	state.Put("kvp_host_items", []string(nil))
+	return multistep.ActionContinue
+}
+
+func (s *StepRemoveHostKvpItems) Cleanup(state multistep.StateBag) {
+	// do nothing
+}
+
+// This step adds the GuestFacts the guest publishes to the generated data.
+// Guests without the Data Exchange integration service don't publish any,
+// which isn't an error; the facts are then empty.
+type StepPublishGuestFacts struct{}
+
+func (s *StepPublishGuestFacts) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmName := state.Get("vmName").(string)
+
+	items, err := driver.GetGuestIntrinsicKvpItems(vmName)
+	if err != nil {
+		ui.Error(fmt.Sprintf("WARNING: Error reading the KVP items of the guest: %s", err))
+	}
+
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	for _, fact := range GuestFacts {
+		value := items[fact]
+		if value != "" {
+			log.Printf("Guest %s: %s", fact, value)
+		}
+		generatedData.Put(fact, value)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepPublishGuestFacts) Cleanup(state multistep.StateBag) {
+	// do nothing
+}
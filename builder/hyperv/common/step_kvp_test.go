@@ -0,0 +1,151 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestStepSetHostKvpItems_impl(t *testing.T) {
+	var _ multistep.Step = new(StepSetHostKvpItems)
+	var _ multistep.Step = new(StepRemoveHostKvpItems)
+	var _ multistep.Step = new(StepPublishGuestFacts)
+}
+
+func TestStepSetHostKvpItems(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+	state.Put("http_port", 8080)
+	state.Put("http_ip", "10.0.0.1")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	step := &StepSetHostKvpItems{
+		Items: map[string]string{
+			"PackerVMName":  "{{ .Name }}",
+			"PackerHTTPURL": "http://{{ .HTTPIP }}:{{ .HTTPPort }}",
+		},
+		Ctx: interpolate.Context{},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+
+	expected := map[string]string{
+		"PackerVMName":  "foo",
+		"PackerHTTPURL": "http://10.0.0.1:8080",
+	}
+	if !reflect.DeepEqual(driver.SetHostKvpItem_Items, expected) {
+		t.Fatalf("Bad items: %#v", driver.SetHostKvpItem_Items)
+	}
+	sent := state.Get("kvp_host_items").([]string)
+	if !reflect.DeepEqual(sent, []string{"PackerHTTPURL", "PackerVMName"}) {
+		t.Fatalf("Bad sent items: %#v", sent)
+	}
+
+	// Removing the items after the build
+	remove := &StepRemoveHostKvpItems{}
+	if action := remove.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	if !reflect.DeepEqual(driver.RemoveHostKvpItem_Names, sent) {
+		t.Fatalf("Bad removed items: %#v", driver.RemoveHostKvpItem_Names)
+	}
+}
+
+func TestStepSetHostKvpItems_noItems(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	step := &StepSetHostKvpItems{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.SetHostKvpItem_Called {
+		t.Fatal("Should NOT send any items")
+	}
+
+	remove := &StepRemoveHostKvpItems{}
+	if action := remove.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.RemoveHostKvpItem_Called {
+		t.Fatal("Should NOT remove any items")
+	}
+}
+
+func TestStepSetHostKvpItems_tooLong(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	step := &StepSetHostKvpItems{
+		Items: map[string]string{"PackerData": strings.Repeat("x", maxKvpDataSize+1)},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.SetHostKvpItem_Called {
+		t.Fatal("Should NOT send the item")
+	}
+}
+
+func TestStepPublishGuestFacts(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetGuestIntrinsicKvpItems_Return = map[string]string{
+		"OSName":                   "Ubuntu",
+		"OSVersion":                "22.04",
+		"FullyQualifiedDomainName": "packer.example.com",
+	}
+
+	step := &StepPublishGuestFacts{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+
+	generatedData := state.Get("generated_data").(map[string]interface{})
+	expected := map[string]interface{}{
+		"OSName":                     "Ubuntu",
+		"OSVersion":                  "22.04",
+		"FullyQualifiedDomainName":   "packer.example.com",
+		"IntegrationServicesVersion": "",
+	}
+	if !reflect.DeepEqual(generatedData, expected) {
+		t.Fatalf("Bad generated data: %#v", generatedData)
+	}
+}
+
+func TestStepPublishGuestFacts_error(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetGuestIntrinsicKvpItems_Err = errors.New("Data Exchange is disabled")
+
+	step := &StepPublishGuestFacts{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("Should NOT fail the build")
+	}
+
+	generatedData := state.Get("generated_data").(map[string]interface{})
+	if generatedData["OSName"] != "" {
+		t.Fatalf("Bad generated data: %#v", generatedData)
+	}
+}
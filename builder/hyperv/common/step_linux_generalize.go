@@ -0,0 +1,274 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// Where the generalization script is uploaded to. It removes itself.
+const linuxGeneralizeScriptPath = "/tmp/packer-linux-generalize.sh"
+
+// The prefix of the lines the script reports on
+const linuxGeneralizeReportPrefix = "packer-generalize: "
+
+// The functions of the generalization script. An action that doesn't apply
+// to the guest, such as cloud_init without cloud-init installed, isn't
+// reported as done.
+const linuxGeneralizeScript = `#!/bin/sh
+# Generalizes the guest before Packer shuts it down and exports it.
+set -u
+
+ID=unknown
+ID_LIKE=
+[ -f /etc/os-release ] && . /etc/os-release
+distro="$ID"
+family="$ID $ID_LIKE"
+echo "packer-generalize: distro $distro"
+
+report() {
+  echo "packer-generalize: done $1"
+}
+
+generalize_cloud_init() {
+  command -v cloud-init >/dev/null 2>&1 || return 0
+  cloud-init clean --logs && report cloud_init
+}
+
+generalize_machine_id() {
+  [ -f /etc/machine-id ] || return 0
+  : > /etc/machine-id
+  # Debian and Ubuntu keep a copy for D-Bus
+  if [ -f /var/lib/dbus/machine-id ] && [ ! -L /var/lib/dbus/machine-id ]; then
+    rm -f /var/lib/dbus/machine-id
+    ln -s /etc/machine-id /var/lib/dbus/machine-id
+  fi
+  report machine_id
+}
+
+generalize_ssh_host_keys() {
+  ls /etc/ssh/ssh_host_*_key >/dev/null 2>&1 || return 0
+  first_boot=
+  # Without cloud-init, Debian and Ubuntu only create the keys when
+  # openssh-server is installed, so sshd would fail to start. Create them
+  # at first boot with a oneshot unit, or keep them without systemd.
+  case "$family" in
+    *debian*|*ubuntu*)
+      if ! command -v cloud-init >/dev/null 2>&1; then
+        [ -d /run/systemd/system ] || return 0
+        cat > /etc/systemd/system/packer-ssh-host-keys.service <<'UNIT'
+[Unit]
+Description=Create the SSH host keys removed by Packer
+Before=ssh.service
+ConditionPathExistsGlob=!/etc/ssh/ssh_host_*_key
+
+[Service]
+Type=oneshot
+ExecStart=/usr/bin/ssh-keygen -A
+
+[Install]
+WantedBy=multi-user.target
+UNIT
+        systemctl enable packer-ssh-host-keys.service >/dev/null 2>&1 || return 0
+        first_boot=yes
+      fi
+      ;;
+  esac
+  rm -f /etc/ssh/ssh_host_*_key /etc/ssh/ssh_host_*_key.pub
+  report ssh_host_keys
+  [ -n "$first_boot" ] && report ssh_host_keys_first_boot
+  return 0
+}
+
+generalize_packer_key() {
+  [ -n "$packer_key" ] || return 0
+  for keys in /root/.ssh/authorized_keys /home/*/.ssh/authorized_keys; do
+    [ -f "$keys" ] || continue
+    grep -vF "$packer_key" "$keys" > "$keys.packer"
+    # Keep the owner and mode of the file
+    cat "$keys.packer" > "$keys"
+    rm -f "$keys.packer"
+  done
+  report packer_key
+}
+
+generalize_dhcp_leases() {
+  case "$family" in
+    *debian*|*ubuntu*) dirs="/var/lib/dhcp" ;;
+    *rhel*|*fedora*|*centos*) dirs="/var/lib/dhclient" ;;
+    *suse*) dirs="/var/lib/wicked /var/lib/dhcp" ;;
+    *) dirs="/var/lib/dhcp /var/lib/dhclient" ;;
+  esac
+  for dir in $dirs /var/lib/NetworkManager; do
+    [ -d "$dir" ] && find "$dir" -type f -name '*lease*' -delete
+  done
+  report dhcp_leases
+}
+
+generalize_shell_history() {
+  rm -f /root/.*_history /home/*/.*_history
+  report shell_history
+}
+
+generalize_logs() {
+  if command -v journalctl >/dev/null 2>&1; then
+    journalctl --rotate >/dev/null 2>&1
+    journalctl --vacuum-time=1s >/dev/null 2>&1
+  fi
+  find /var/log -type f \( -name '*.gz' -o -name '*.[0-9]' -o -name '*.old' \) -delete
+  find /var/log -type f ! -path '/var/log/journal/*' -exec truncate -s 0 {} +
+  report logs
+}
+`
+
+// This step generalizes a Linux guest through the communicator before it
+// is shut down.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	ui           packersdk.Ui
+//
+// Produces:
+//
+//	generated_data LinuxGeneralizeDistro, LinuxGeneralizeActions
+type StepLinuxGeneralize struct {
+	Config LinuxGeneralizeConfig
+	Comm   *communicator.Config
+	Ctx    interpolate.Context
+}
+
+type linuxGeneralizeTemplateData struct {
+	Path     string
+	Password string
+}
+
+func (s *StepLinuxGeneralize) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Config.IsSet() {
+		return multistep.ActionContinue
+	}
+
+	comm := state.Get("communicator").(packersdk.Communicator)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	ui.Say("Generalizing the guest...")
+
+	script := s.script()
+	if err := comm.Upload(linuxGeneralizeScriptPath, strings.NewReader(script), nil); err != nil {
+		err := fmt.Errorf("Error uploading the generalization script: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	data := &linuxGeneralizeTemplateData{Path: linuxGeneralizeScriptPath}
+	if s.Comm != nil {
+		data.Password = s.Comm.Password()
+	}
+	s.Ctx.Data = data
+	command, err := interpolate.Render(s.Config.ExecuteCommand, &s.Ctx)
+	if err != nil {
+		err := fmt.Errorf("Error rendering the generalization execute_command: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	var stdout bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: command,
+		Stdout:  &stdout,
+	}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		err := fmt.Errorf("Error running the generalization script: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	if status := cmd.ExitStatus(); status != 0 {
+		err := fmt.Errorf("The generalization script exited with status %d", status)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	distro, done := parseLinuxGeneralizeReport(stdout.String())
+	if len(done) == 0 {
+		ui.Say("Generalized the guest, no actions applied")
+	} else {
+		ui.Say(fmt.Sprintf("Generalized the %s guest: %s", distro, strings.Join(done, ", ")))
+	}
+
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("LinuxGeneralizeDistro", distro)
+	generatedData.Put("LinuxGeneralizeActions", strings.Join(done, ","))
+
+	return multistep.ActionContinue
+}
+
+func (s *StepLinuxGeneralize) Cleanup(state multistep.StateBag) {
+	// do nothing
+}
+
+// script returns the generalization script running the actions of the
+// config.
+func (s *StepLinuxGeneralize) script() string {
+	packerKey := ""
+	if s.Comm != nil {
+		// The type and key, without the comment
+		fields := strings.Fields(string(s.Comm.SSHPublicKey))
+		if len(fields) >= 2 {
+			packerKey = fields[0] + " " + fields[1]
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(linuxGeneralizeScript)
+	fmt.Fprintf(&b, "\npacker_key='%s'\n\n", packerKey)
+	for _, action := range s.Config.Actions() {
+		fmt.Fprintf(&b, "generalize_%s\n", action)
+	}
+	b.WriteString("\nrm -f \"$0\"\n")
+	return b.String()
+}
+
+// parseLinuxGeneralizeReport returns the distribution and the actions done
+// reported by the generalization script.
+func parseLinuxGeneralizeReport(output string) (string, []string) {
+	distro := ""
+	var done []string
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, linuxGeneralizeReportPrefix) {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(line, linuxGeneralizeReportPrefix))
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "distro":
+			distro = fields[1]
+		case "done":
+			done = append(done, fields[1])
+		}
+	}
+
+	log.Printf("Generalization of %s: %v", distro, done)
+	return distro, done
+}
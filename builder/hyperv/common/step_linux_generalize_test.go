@@ -0,0 +1,130 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestStepLinuxGeneralize_impl(t *testing.T) {
+	var _ multistep.Step = new(StepLinuxGeneralize)
+}
+
+func TestStepLinuxGeneralize(t *testing.T) {
+	state := testShutdownState(t)
+
+	comm := state.Get("communicator").(*packersdk.MockCommunicator)
+	comm.StartStdout = "packer-generalize: distro ubuntu\n" +
+		"packer-generalize: done machine_id\n" +
+		"packer-generalize: done ssh_host_keys\n" +
+		"packer-generalize: done ssh_host_keys_first_boot\n"
+
+	config := LinuxGeneralizeConfig{
+		Enabled:        true,
+		SkipActions:    []string{LinuxGeneralizeLogs},
+		ExecuteCommand: "echo '{{ .Password }}' | sudo -S sh '{{ .Path }}'",
+	}
+	if errs := config.Prepare(); len(errs) > 0 {
+		t.Fatalf("err: %v", errs)
+	}
+	step := &StepLinuxGeneralize{
+		Config: config,
+		Comm: &communicator.Config{
+			Type: "ssh",
+			SSH: communicator.SSH{
+				SSHPassword:  "packer",
+				SSHPublicKey: []byte("ssh-rsa AAAAB3Nza packer_1234\n"),
+			},
+		},
+		Ctx: interpolate.Context{},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+
+	if comm.UploadPath != linuxGeneralizeScriptPath {
+		t.Fatalf("Bad upload path: %s", comm.UploadPath)
+	}
+	if !strings.Contains(comm.UploadData, "\ngeneralize_machine_id\n") {
+		t.Fatalf("Script should run machine_id:\n%s", comm.UploadData)
+	}
+	if strings.Contains(comm.UploadData, "\ngeneralize_logs\n") {
+		t.Fatalf("Script should NOT run logs:\n%s", comm.UploadData)
+	}
+	if !strings.Contains(comm.UploadData, "packer_key='ssh-rsa AAAAB3Nza'") {
+		t.Fatalf("Script should remove the Packer key:\n%s", comm.UploadData)
+	}
+	if !strings.Contains(comm.UploadData, "ExecStart=/usr/bin/ssh-keygen -A") {
+		t.Fatalf("Script should create the SSH host keys at first boot:\n%s", comm.UploadData)
+	}
+	if comm.StartCmd.Command != "echo 'packer' | sudo -S sh '/tmp/packer-linux-generalize.sh'" {
+		t.Fatalf("Bad command: %s", comm.StartCmd.Command)
+	}
+
+	generatedData := state.Get("generated_data").(map[string]interface{})
+	if generatedData["LinuxGeneralizeDistro"] != "ubuntu" {
+		t.Fatalf("Bad distro: %#v", generatedData)
+	}
+	if generatedData["LinuxGeneralizeActions"] != "machine_id,ssh_host_keys,ssh_host_keys_first_boot" {
+		t.Fatalf("Bad actions: %#v", generatedData)
+	}
+}
+
+func TestStepLinuxGeneralize_failure(t *testing.T) {
+	state := testShutdownState(t)
+
+	comm := state.Get("communicator").(*packersdk.MockCommunicator)
+	comm.StartExitStatus = 1
+
+	config := LinuxGeneralizeConfig{Enabled: true}
+	if errs := config.Prepare(); len(errs) > 0 {
+		t.Fatalf("err: %v", errs)
+	}
+	step := &StepLinuxGeneralize{Config: config}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("Should have an error")
+	}
+}
+
+func TestStepLinuxGeneralize_notSet(t *testing.T) {
+	state := testShutdownState(t)
+
+	step := &StepLinuxGeneralize{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+
+	comm := state.Get("communicator").(*packersdk.MockCommunicator)
+	if comm.UploadCalled || comm.StartCalled {
+		t.Fatal("Should NOT run the script")
+	}
+}
+
+func TestLinuxGeneralizeConfig_Prepare(t *testing.T) {
+	config := LinuxGeneralizeConfig{Enabled: true, SkipActions: []string{"machine-id"}}
+	if errs := config.Prepare(); len(errs) == 0 {
+		t.Fatal("Should have error")
+	}
+
+	config = LinuxGeneralizeConfig{Enabled: true, SkipActions: []string{LinuxGeneralizeCloudInit}}
+	if errs := config.Prepare(); len(errs) > 0 {
+		t.Fatalf("err: %v", errs)
+	}
+	if config.ExecuteCommand != DefaultLinuxGeneralizeExecuteCommand {
+		t.Fatalf("Bad execute_command: %s", config.ExecuteCommand)
+	}
+	if actions := config.Actions(); len(actions) != len(LinuxGeneralizeActions)-1 || actions[0] != LinuxGeneralizeMachineId {
+		t.Fatalf("Bad actions: %v", actions)
+	}
+}
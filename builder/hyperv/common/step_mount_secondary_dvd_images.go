@@ -48,6 +48,11 @@ func (s *StepMountSecondaryDvdImages) Run(ctx context.Context, state multistep.S
 		}
 	}
 
+	// And the cloud-init seed
+	if seedPath, ok := state.Get("cloud_init_path").(string); ok && seedPath != "" {
+		isoPaths = append(isoPaths, seedPath)
+	}
+
 	for _, isoPath := range isoPaths {
 		if wsl.IsWSL() {
 			var err error
@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/wsl"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step mounts the boot disk of the VM on the host, copies files and
+// adds drivers to it, and dismounts it again before the VM is started.
+type StepOfflineCustomization struct {
+	Config OfflineCustomizationConfig
+
+	// The path of the vhd while it is mounted on the host
+	mountedVhdPath string
+}
+
+func (s *StepOfflineCustomization) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Config.IsSet() {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	ui.Say("Customizing virtual machine disk offline...")
+
+	vmName := state.Get("vmName").(string)
+
+	vhdPath, root, err := driver.MountVirtualMachineVhd(vmName, s.Config.PartitionNumber)
+	// Dismount in Cleanup even if the partition couldn't be found
+	s.mountedVhdPath = vhdPath
+	if err != nil {
+		err := fmt.Errorf("Error mounting virtual machine disk: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	log.Printf("Mounted %s at %s", vhdPath, root)
+
+	for _, f := range s.Config.Files {
+		source, err := hostPath(f.Source)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		destination := strings.TrimSuffix(root, `\`) + `\` +
+			strings.TrimLeft(strings.ReplaceAll(f.Destination, "/", `\`), `\`)
+
+		ui.Say(fmt.Sprintf("Copying %s to %s...", f.Source, f.Destination))
+		err = driver.CopyFileToMountedVhd(source, destination)
+		if err != nil {
+			err := fmt.Errorf("Error copying %s to virtual machine disk: %s", f.Source, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	for _, d := range s.Config.Drivers {
+		driverPath, err := hostPath(d)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		ui.Say(fmt.Sprintf("Adding drivers from %s...", d))
+		err = driver.AddDriverToMountedVhd(root, driverPath, s.Config.ForceUnsignedDrivers)
+		if err != nil {
+			err := fmt.Errorf("Error adding drivers from %s to virtual machine disk: %s", d, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	err = driver.DismountVhd(vhdPath)
+	if err != nil {
+		err := fmt.Errorf("Error dismounting virtual machine disk: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.mountedVhdPath = ""
+
+	return multistep.ActionContinue
+}
+
+// Cleanup dismounts the vhd if the step failed while it was mounted, so the
+// VM can be deleted.
+func (s *StepOfflineCustomization) Cleanup(state multistep.StateBag) {
+	if s.mountedVhdPath == "" {
+		return
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	err := driver.DismountVhd(s.mountedVhdPath)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error dismounting virtual machine disk %s: %s", s.mountedVhdPath, err))
+		return
+	}
+	s.mountedVhdPath = ""
+}
+
+// hostPath converts a path on the machine running packer to one PowerShell
+// can use on the Hyper-V host.
+func hostPath(path string) (string, error) {
+	if wsl.IsWSL() {
+		return wsl.ConvertWSlPathToWindowsPath(path)
+	}
+	return path, nil
+}
@@ -0,0 +1,140 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepOfflineCustomization_impl(t *testing.T) {
+	var _ multistep.Step = new(StepOfflineCustomization)
+}
+
+func TestStepOfflineCustomization_notSet(t *testing.T) {
+	state := testState(t)
+	step := new(StepOfflineCustomization)
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.MountVirtualMachineVhd_Called {
+		t.Fatal("Should NOT have called MountVirtualMachineVhd")
+	}
+}
+
+func TestStepOfflineCustomization(t *testing.T) {
+	state := testState(t)
+	step := &StepOfflineCustomization{
+		Config: OfflineCustomizationConfig{
+			PartitionNumber: 3,
+			Files: []OfflineFile{
+				{Source: "unattend.xml", Destination: "Windows/Panther/unattend.xml"},
+				{Source: "scripts", Destination: `\setup\scripts`},
+			},
+			Drivers:              []string{"drivers"},
+			ForceUnsignedDrivers: true,
+		},
+	}
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.MountVirtualMachineVhd_VhdPath = `C:\build\foo.vhdx`
+	driver.MountVirtualMachineVhd_Root = `E:\`
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("Should NOT have error")
+	}
+
+	if driver.MountVirtualMachineVhd_VmName != "foo" || driver.MountVirtualMachineVhd_PartitionNumber != 3 {
+		t.Fatalf("Should mount the right partition. Got: %s %d",
+			driver.MountVirtualMachineVhd_VmName, driver.MountVirtualMachineVhd_PartitionNumber)
+	}
+
+	expected := []string{`E:\Windows\Panther\unattend.xml`, `E:\setup\scripts`}
+	if !reflect.DeepEqual(driver.CopyFileToMountedVhd_Destinations, expected) {
+		t.Fatalf("Bad destinations. Got: %#v Wanted: %#v", driver.CopyFileToMountedVhd_Destinations, expected)
+	}
+
+	if driver.AddDriverToMountedVhd_Root != `E:\` || !driver.AddDriverToMountedVhd_ForceUnsigned {
+		t.Fatalf("Should add drivers to the mounted partition. Got: %s", driver.AddDriverToMountedVhd_Root)
+	}
+
+	if driver.DismountVhd_VhdPath != `C:\build\foo.vhdx` {
+		t.Fatalf("Should have dismounted the vhd. Got: %s", driver.DismountVhd_VhdPath)
+	}
+
+	// Nothing left to clean up
+	driver.DismountVhd_Called = false
+	step.Cleanup(state)
+	if driver.DismountVhd_Called {
+		t.Fatal("Should NOT have dismounted the vhd twice")
+	}
+}
+
+func TestStepOfflineCustomization_dismountOnFailure(t *testing.T) {
+	state := testState(t)
+	step := &StepOfflineCustomization{
+		Config: OfflineCustomizationConfig{
+			Files: []OfflineFile{{Source: "unattend.xml", Destination: "unattend.xml"}},
+		},
+	}
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.MountVirtualMachineVhd_VhdPath = `C:\build\foo.vhdx`
+	driver.MountVirtualMachineVhd_Root = `E:\`
+	driver.CopyFileToMountedVhd_Err = errors.New("copy failed")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("Should have error")
+	}
+	if driver.DismountVhd_Called {
+		t.Fatal("Should NOT have dismounted the vhd yet")
+	}
+
+	step.Cleanup(state)
+	if driver.DismountVhd_VhdPath != `C:\build\foo.vhdx` {
+		t.Fatalf("Cleanup should have dismounted the vhd. Got: %s", driver.DismountVhd_VhdPath)
+	}
+}
+
+func TestStepOfflineCustomization_dismountOnMountError(t *testing.T) {
+	state := testState(t)
+	step := &StepOfflineCustomization{
+		Config: OfflineCustomizationConfig{
+			Files: []OfflineFile{{Source: "unattend.xml", Destination: "unattend.xml"}},
+		},
+	}
+	state.Put("vmName", "foo")
+
+	// The vhd was mounted, but its partition couldn't be read
+	driver := state.Get("driver").(*DriverMock)
+	driver.MountVirtualMachineVhd_VhdPath = `C:\build\foo.vhdx`
+	driver.MountVirtualMachineVhd_Err = errors.New("Unexpected output mounting vhd")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.CopyFileToMountedVhd_Called {
+		t.Fatal("Should NOT have copied files")
+	}
+
+	step.Cleanup(state)
+	if driver.DismountVhd_VhdPath != `C:\build\foo.vhdx` {
+		t.Fatalf("Cleanup should have dismounted the vhd. Got: %s", driver.DismountVhd_VhdPath)
+	}
+}
@@ -14,6 +14,10 @@ import (
 // This resizes the first vhd on the virtual machine to the specified DiskSize (in MB)
 type StepResizeVhd struct {
 	DiskSize *uint
+	// Refuse to make the vhd smaller than it already is
+	GrowOnly bool
+	// Grow the last partition of the vhd to fill the resized disk
+	ExpandPartition bool
 }
 
 func (s *StepResizeVhd) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -30,12 +34,59 @@ func (s *StepResizeVhd) Run(ctx context.Context, state multistep.StateBag) multi
 	// convert the MB to bytes
 	newDiskSizeInBytes := uint64(*s.DiskSize) * 1024 * 1024
 
-	err := driver.ResizeVirtualMachineVhd(vmName, newDiskSizeInBytes)
-	if err != nil {
-		err := fmt.Errorf("Error resizing VHD: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	resize := true
+	if s.GrowOnly {
+		currentDiskSizeInBytes, err := driver.GetVirtualMachineVhdSize(vmName)
+		if err != nil {
+			err := fmt.Errorf("Error getting VHD size: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		if newDiskSizeInBytes < currentDiskSizeInBytes {
+			err := fmt.Errorf("Error resizing VHD: disk_size (%d MB) is smaller than the "+
+				"source disk (%d MB) and disks cannot be shrunk", *s.DiskSize, currentDiskSizeInBytes/1024/1024)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		if newDiskSizeInBytes == currentDiskSizeInBytes {
+			ui.Say("VHD is already the requested size, skipping resize...")
+			resize = false
+		}
+	}
+
+	if resize {
+		err := driver.ResizeVirtualMachineVhd(vmName, newDiskSizeInBytes)
+		if err != nil {
+			err := fmt.Errorf("Error resizing VHD: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if s.ExpandPartition {
+		ui.Say("Expanding last partition of vhd...")
+		fileSystem, expanded, err := driver.ExpandVirtualMachineVhdPartition(vmName)
+		if err != nil {
+			err := fmt.Errorf("Error expanding partition: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		if expanded {
+			ui.Say(fmt.Sprintf("Expanded %s partition", fileSystem))
+		} else {
+			if fileSystem == "" {
+				fileSystem = "unknown"
+			}
+			ui.Say(fmt.Sprintf("Partition file system %s cannot be expanded offline, "+
+				"leaving it to the guest", fileSystem))
+		}
 	}
 
 	return multistep.ActionContinue
@@ -0,0 +1,115 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepResizeVhd_impl(t *testing.T) {
+	var _ multistep.Step = new(StepResizeVhd)
+}
+
+func TestStepResizeVhd_noDiskSize(t *testing.T) {
+	state := testState(t)
+	step := new(StepResizeVhd)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.ResizeVirtualMachineVhd_Called {
+		t.Fatal("Should NOT have called ResizeVirtualMachineVhd")
+	}
+}
+
+func TestStepResizeVhd_growOnly(t *testing.T) {
+	state := testState(t)
+	diskSize := uint(2048)
+	step := &StepResizeVhd{
+		DiskSize:        &diskSize,
+		GrowOnly:        true,
+		ExpandPartition: true,
+	}
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetVirtualMachineVhdSize_Return = 1024 * 1024 * 1024
+	driver.ExpandVirtualMachineVhdPartition_FileSystem = "NTFS"
+	driver.ExpandVirtualMachineVhdPartition_Expanded = true
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("Should NOT have error")
+	}
+
+	if !driver.GetVirtualMachineVhdSize_Called {
+		t.Fatal("Should have called GetVirtualMachineVhdSize")
+	}
+	if !driver.ResizeVirtualMachineVhd_Called {
+		t.Fatal("Should have called ResizeVirtualMachineVhd")
+	}
+	if driver.ResizeVirtualMachineVhd_newSizeInBytes != 2048*1024*1024 {
+		t.Fatalf("Should call with correct size. Got: %d", driver.ResizeVirtualMachineVhd_newSizeInBytes)
+	}
+	if !driver.ExpandVirtualMachineVhdPartition_Called {
+		t.Fatal("Should have called ExpandVirtualMachineVhdPartition")
+	}
+	if driver.ExpandVirtualMachineVhdPartition_VmName != "foo" {
+		t.Fatalf("Should call with correct vmName. Got: %s", driver.ExpandVirtualMachineVhdPartition_VmName)
+	}
+}
+
+func TestStepResizeVhd_growOnlySameSize(t *testing.T) {
+	state := testState(t)
+	diskSize := uint(1024)
+	step := &StepResizeVhd{
+		DiskSize: &diskSize,
+		GrowOnly: true,
+	}
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetVirtualMachineVhdSize_Return = 1024 * 1024 * 1024
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.ResizeVirtualMachineVhd_Called {
+		t.Fatal("Should NOT have called ResizeVirtualMachineVhd")
+	}
+	if driver.ExpandVirtualMachineVhdPartition_Called {
+		t.Fatal("Should NOT have called ExpandVirtualMachineVhdPartition")
+	}
+}
+
+func TestStepResizeVhd_growOnlyRefusesShrink(t *testing.T) {
+	state := testState(t)
+	diskSize := uint(512)
+	step := &StepResizeVhd{
+		DiskSize: &diskSize,
+		GrowOnly: true,
+	}
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetVirtualMachineVhdSize_Return = 1024 * 1024 * 1024
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("Should have error")
+	}
+	if driver.ResizeVirtualMachineVhd_Called {
+		t.Fatal("Should NOT have called ResizeVirtualMachineVhd")
+	}
+}
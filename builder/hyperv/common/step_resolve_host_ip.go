@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/wsl"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// guestTemplateData is the data of the templates rendered for the guest
+// before it starts, such as the cloud-init files and the KVP items.
+type guestTemplateData struct {
+	HTTPIP   string
+	HTTPPort int
+	Name     string
+	// Only set for cloud-init
+	SSHPublicKey string
+}
+
+// newGuestTemplateData returns the template data of the build in state.
+// HTTPIP is empty if StepResolveHostIP skipped the host IP.
+func newGuestTemplateData(state multistep.StateBag) *guestTemplateData {
+	data := &guestTemplateData{Name: state.Get("vmName").(string)}
+	if v, ok := state.GetOk("http_port"); ok {
+		data.HTTPPort = v.(int)
+	}
+	if v, ok := state.GetOk("http_ip"); ok {
+		data.HTTPIP = v.(string)
+	}
+	return data
+}
+
+// This step looks up the IP address the VM reaches the host at, once for
+// the templates, the boot command and the HTTP server of the build.
+//
+// Produces:
+//
+//	http_ip string - The IP address of the host on the switch
+type StepResolveHostIP struct {
+	SwitchName string
+	// The transport doesn't need the host IP, e.g. hvsock
+	SkipHostIP bool
+}
+
+func (s *StepResolveHostIP) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if s.SkipHostIP {
+		ui.Say("Skipping Host IP determination (not required for this transport).")
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Determine Host IP for HyperV machine...")
+	hostIp, err := hostIPForSwitch(driver, s.SwitchName)
+	if err != nil {
+		err := fmt.Errorf("Error getting host adapter ip address: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	ui.Say(fmt.Sprintf("Host IP for the HyperV machine: %s", hostIp))
+	state.Put("http_ip", hostIp)
+
+	return multistep.ActionContinue
+}
+
+func (s *StepResolveHostIP) Cleanup(state multistep.StateBag) {
+	// do nothing
+}
+
+// hostIPForSwitch returns the IP address the VM reaches the host, and its
+// HTTP server, at through the switch.
+func hostIPForSwitch(driver Driver, switchName string) (string, error) {
+	hostIp, err := driver.GetHostAdapterIpAddressForSwitch(switchName)
+	if err != nil {
+		return "", err
+	}
+
+	// If running in WSL and the user has specified the WSL switch, then they
+	// almost certainly want the WSL distribution IP as the host IP as this is
+	// what our http server will be listening on.
+	if wsl.IsWSL() {
+		switchNet := net.IPNet{IP: net.ParseIP(hostIp), Mask: net.IPv4Mask(255, 255, 240, 0)}
+		addrs, err := net.InterfaceAddrs()
+		if err == nil {
+			for _, address := range addrs {
+				if ipnet, ok := address.(*net.IPNet); ok && switchNet.Contains(ipnet.IP) {
+					hostIp = ipnet.IP.String()
+					break
+				}
+			}
+		}
+	}
+
+	return hostIp, nil
+}
@@ -0,0 +1,52 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepResolveHostIP_impl(t *testing.T) {
+	var _ multistep.Step = new(StepResolveHostIP)
+}
+
+func TestStepResolveHostIP(t *testing.T) {
+	state := testState(t)
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetHostAdapterIpAddressForSwitch_Return = "10.0.0.1"
+
+	step := &StepResolveHostIP{SwitchName: "packer-switch"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	if driver.GetHostAdapterIpAddressForSwitch_SwitchName != "packer-switch" {
+		t.Fatalf("Should look up the host IP on the switch, got: %s", driver.GetHostAdapterIpAddressForSwitch_SwitchName)
+	}
+	if ip := state.Get("http_ip"); ip != "10.0.0.1" {
+		t.Fatalf("Bad http_ip: %v", ip)
+	}
+}
+
+func TestStepResolveHostIP_skipHostIP(t *testing.T) {
+	state := testState(t)
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetHostAdapterIpAddressForSwitch_Err = errors.New("no switch")
+
+	step := &StepResolveHostIP{SkipHostIP: true}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	if driver.GetHostAdapterIpAddressForSwitch_Called {
+		t.Fatal("Should NOT look up the host IP")
+	}
+	if _, ok := state.GetOk("http_ip"); ok {
+		t.Fatal("Should NOT set http_ip")
+	}
+}
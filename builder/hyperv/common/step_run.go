@@ -7,9 +7,7 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"net"
 
-	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common/wsl"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -17,9 +15,7 @@ import (
 type StepRun struct {
 	GuiCancelFunc context.CancelFunc
 	Headless      bool
-	SwitchName    string
 	vmName        string
-	SkipHostIP    bool
 }
 
 func (s *StepRun) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -27,40 +23,8 @@ func (s *StepRun) Run(ctx context.Context, state multistep.StateBag) multistep.S
 	ui := state.Get("ui").(packersdk.Ui)
 	vmName := state.Get("vmName").(string)
 
-	ui.Say("Determine Host IP for HyperV machine...")
-	var hostIp string
 	var err error
 
-	if !s.SkipHostIP {
-		hostIp, err = driver.GetHostAdapterIpAddressForSwitch(s.SwitchName)
-		if err != nil {
-			err := fmt.Errorf("Error getting host adapter ip address: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
-
-		// If running in WSL and the user has specified the WSL switch, then they
-		// almost certainly want the WSL distribution IP as the host IP as this is
-		// what our http server will be listening on.
-		if wsl.IsWSL() {
-			switchNet := net.IPNet{IP: net.ParseIP(hostIp), Mask: net.IPv4Mask(255, 255, 240, 0)}
-			addrs, err := net.InterfaceAddrs()
-			if err == nil {
-				for _, address := range addrs {
-					if ipnet, ok := address.(*net.IPNet); ok && switchNet.Contains(ipnet.IP) {
-						hostIp = ipnet.IP.String()
-						break
-					}
-				}
-			}
-		}
-		ui.Say(fmt.Sprintf("Host IP for the HyperV machine: %s", hostIp))
-		state.Put("http_ip", hostIp)
-	} else {
-		ui.Say("Skipping Host IP determination (not required for this transport).")
-	}
-
 	if !s.Headless {
 		ui.Say("Attempting to connect with vmconnect...")
 		s.GuiCancelFunc, err = driver.Connect(vmName)
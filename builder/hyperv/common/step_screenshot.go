@@ -0,0 +1,177 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// screenshotDir returns the directory console screenshots are written to.
+// It sits next to the output directory rather than inside it so that the
+// screenshots of a failed build aren't removed along with its output.
+func screenshotDir(outputDir string) string {
+	return filepath.Join(filepath.Dir(filepath.Clean(outputDir)), "debug")
+}
+
+// screenshotter saves numbered PNG captures of the VM console.
+type screenshotter struct {
+	driver Driver
+	vmName string
+	dir    string
+
+	mu    sync.Mutex
+	count int
+}
+
+// Capture saves a screenshot of the console, labelled to tell where in the
+// build it was taken, and returns its path.
+func (s *screenshotter) Capture(label string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.driver.GetVirtualMachineScreenshot(s.vmName)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", err
+	}
+
+	s.count++
+	path := filepath.Join(s.dir, fmt.Sprintf("%s-%03d-%s.png", s.vmName, s.count, label))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+
+	log.Printf("Saved console screenshot to %s", path)
+	return path, nil
+}
+
+// This step makes console screenshots available to the steps that follow
+// it, takes them periodically if an interval is set, and takes one when
+// the build halts. It has to run after the VM is started so its cleanup
+// runs while the VM is still on.
+//
+// Produces:
+//
+//	screenshotter *screenshotter - Used to capture the console
+type StepScreenshot struct {
+	OutputDir string
+	Interval  time.Duration
+
+	shots *screenshotter
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func (s *StepScreenshot) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(Driver)
+	vmName := state.Get("vmName").(string)
+
+	s.shots = &screenshotter{
+		driver: driver,
+		vmName: vmName,
+		dir:    screenshotDir(s.OutputDir),
+	}
+	state.Put("screenshotter", s.shots)
+
+	if s.Interval > 0 {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.captureEvery(s.Interval)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepScreenshot) captureEvery(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// The VM may be rebooting or off, so failures are only logged
+			if _, err := s.shots.Capture("interval"); err != nil {
+				log.Printf("Error capturing console screenshot: %s", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *StepScreenshot) Cleanup(state multistep.StateBag) {
+	if s.shots == nil {
+		return
+	}
+
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+
+	if _, ok := state.GetOk(multistep.StateHalted); !ok {
+		return
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	path, err := s.shots.Capture("failure")
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error capturing console screenshot: %s", err))
+		return
+	}
+	ui.Say(fmt.Sprintf("Console screenshot of the failed build saved to %s", path))
+}
+
+// screenshotBCDriver wraps a boot command driver to capture the console
+// each time a group of keys has been sent, which happens before every
+// <wait> and at the end of the boot command.
+type screenshotBCDriver struct {
+	bootcommand.BCDriver
+
+	shots  *screenshotter
+	group  int
+	typing bool
+}
+
+func (d *screenshotBCDriver) SendKey(key rune, action bootcommand.KeyAction) error {
+	d.typing = true
+	return d.BCDriver.SendKey(key, action)
+}
+
+func (d *screenshotBCDriver) SendSpecial(special string, action bootcommand.KeyAction) error {
+	d.typing = true
+	return d.BCDriver.SendSpecial(special, action)
+}
+
+func (d *screenshotBCDriver) Flush() error {
+	if err := d.BCDriver.Flush(); err != nil {
+		return err
+	}
+	if !d.typing {
+		return nil
+	}
+
+	d.typing = false
+	d.group++
+	if _, err := d.shots.Capture(fmt.Sprintf("boot-command-%d", d.group)); err != nil {
+		log.Printf("Error capturing console screenshot: %s", err)
+	}
+	return nil
+}
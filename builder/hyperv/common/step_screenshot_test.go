@@ -0,0 +1,158 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepScreenshot_impl(t *testing.T) {
+	var _ multistep.Step = new(StepScreenshot)
+}
+
+func TestScreenshotDir(t *testing.T) {
+	base := t.TempDir()
+	dir := screenshotDir(filepath.Join(base, "output-foo") + string(filepath.Separator))
+	if dir != filepath.Join(base, "debug") {
+		t.Fatalf("Should be next to the output directory. Got: %s", dir)
+	}
+}
+
+func testScreenshotState(t *testing.T) (multistep.StateBag, *DriverMock, string) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetVirtualMachineScreenshot_Return = []byte("png")
+	return state, driver, filepath.Join(t.TempDir(), "output-foo")
+}
+
+func TestStepScreenshot_failure(t *testing.T) {
+	state, driver, outputDir := testScreenshotState(t)
+	step := &StepScreenshot{OutputDir: outputDir}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("screenshotter"); !ok {
+		t.Fatal("Should have put the screenshotter in the state")
+	}
+
+	state.Put(multistep.StateHalted, true)
+	step.Cleanup(state)
+
+	if driver.GetVirtualMachineScreenshot_VmName != "foo" {
+		t.Fatalf("Should capture the build VM. Got: %s", driver.GetVirtualMachineScreenshot_VmName)
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(outputDir), "debug", "foo-001-failure.png"))
+	if err != nil {
+		t.Fatalf("Should have saved the screenshot: %s", err)
+	}
+	if string(data) != "png" {
+		t.Fatalf("Bad screenshot: %q", data)
+	}
+}
+
+func TestStepScreenshot_success(t *testing.T) {
+	state, driver, outputDir := testScreenshotState(t)
+	step := &StepScreenshot{OutputDir: outputDir}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	step.Cleanup(state)
+
+	if driver.GetVirtualMachineScreenshot_Called {
+		t.Fatal("Should NOT capture a screenshot of a successful build")
+	}
+}
+
+func TestStepScreenshot_failureError(t *testing.T) {
+	state, driver, outputDir := testScreenshotState(t)
+	driver.GetVirtualMachineScreenshot_Err = errors.New("no display")
+	step := &StepScreenshot{OutputDir: outputDir}
+
+	step.Run(context.Background(), state)
+	state.Put(multistep.StateHalted, true)
+	step.Cleanup(state)
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(outputDir), "debug")); !os.IsNotExist(err) {
+		t.Fatal("Should NOT have created the debug directory")
+	}
+}
+
+func TestStepScreenshot_interval(t *testing.T) {
+	state, _, outputDir := testScreenshotState(t)
+	step := &StepScreenshot{OutputDir: outputDir, Interval: 10 * time.Millisecond}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+
+	first := filepath.Join(filepath.Dir(outputDir), "debug", "foo-001-interval.png")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(first); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Should have captured a screenshot at the interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	step.Cleanup(state)
+}
+
+type testBCDriver struct {
+	flushed int
+}
+
+func (d *testBCDriver) SendKey(rune, bootcommand.KeyAction) error       { return nil }
+func (d *testBCDriver) SendSpecial(string, bootcommand.KeyAction) error { return nil }
+func (d *testBCDriver) Flush() error {
+	d.flushed++
+	return nil
+}
+
+func TestScreenshotBCDriver(t *testing.T) {
+	_, driver, outputDir := testScreenshotState(t)
+	shots := &screenshotter{driver: driver, vmName: "foo", dir: screenshotDir(outputDir)}
+	inner := new(testBCDriver)
+	d := &screenshotBCDriver{BCDriver: inner, shots: shots}
+
+	seq, err := bootcommand.GenerateExpressionSequence("a<enter><wait10ms><wait10ms>b")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := seq.Do(context.Background(), d); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if inner.flushed == 0 {
+		t.Fatal("Should flush the wrapped driver")
+	}
+
+	// One screenshot before the waits and one at the end. The second wait
+	// has nothing new to show.
+	entries, err := os.ReadDir(shots.dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	expected := []string{"foo-001-boot-command-1.png", "foo-002-boot-command-2.png"}
+	if len(names) != len(expected) || names[0] != expected[0] || names[1] != expected[1] {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// How long to wait for the pipe reader to finish once the VM is off, and
+// again once the pipe has been closed
+const serialLogStopTimeout = 10 * time.Second
+
+// StepStartSerialLog connects a COM port of the VM to a named pipe and
+// copies everything written to it into a log file until the build ends.
+// It has to run before the VM is started so the output of the early boot
+// is captured.
+type StepStartSerialLog struct {
+	Config    SerialLogConfig
+	OutputDir string
+
+	// Injectable for testing. Nil opens the named pipe with overlapped I/O.
+	OpenPipeFunc func(path string) (io.ReadCloser, error)
+
+	capture *serialLogCapture
+}
+
+func (s *StepStartSerialLog) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Config.IsSet() {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmName := state.Get("vmName").(string)
+
+	logPath := filepath.Join(s.OutputDir, s.Config.File)
+	ui.Say(fmt.Sprintf("Capturing COM%d to %s...", s.Config.Port, logPath))
+
+	err := os.MkdirAll(filepath.Dir(logPath), 0755)
+	if err != nil {
+		err := fmt.Errorf("Error creating serial log directory: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	file, err := os.Create(logPath)
+	if err != nil {
+		err := fmt.Errorf("Error creating serial log: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	pipePath := `\\.\pipe\packer-` + vmName
+	err = driver.SetVirtualMachineComPort(vmName, s.Config.Port, pipePath)
+	if err != nil {
+		file.Close()
+		err := fmt.Errorf("Error connecting COM%d to %s: %s", s.Config.Port, pipePath, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	openPipe := openSerialPipe
+	if s.OpenPipeFunc != nil {
+		openPipe = s.OpenPipeFunc
+	}
+
+	var w io.Writer = file
+	if s.Config.MirrorToUi {
+		w = io.MultiWriter(file, &serialLogUiWriter{ui: ui})
+	}
+
+	s.capture = &serialLogCapture{
+		port:        s.Config.Port,
+		file:        file,
+		stopTimeout: serialLogStopTimeout,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go s.capture.run(func() (io.ReadCloser, error) { return openPipe(pipePath) }, w)
+	state.Put("serial_log", s.capture)
+
+	return multistep.ActionContinue
+}
+
+// Cleanup stops the capture if the build failed before StepStopSerialLog
+// ran. The port isn't disconnected, as the VM is removed anyway unless it
+// is kept registered for debugging.
+func (s *StepStartSerialLog) Cleanup(state multistep.StateBag) {
+	if s.capture == nil {
+		return
+	}
+
+	s.capture.Stop()
+}
+
+// StepStopSerialLog ends the serial console capture once the VM is off and
+// disconnects the COM port, so the exported VM doesn't reference the pipe
+// of the build.
+type StepStopSerialLog struct{}
+
+func (s *StepStopSerialLog) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	v, ok := state.GetOk("serial_log")
+	if !ok {
+		return multistep.ActionContinue
+	}
+	capture := v.(*serialLogCapture)
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmName := state.Get("vmName").(string)
+
+	ui.Say("Stopping serial console capture...")
+	capture.Stop()
+
+	err := driver.SetVirtualMachineComPort(vmName, capture.port, "")
+	if err != nil {
+		err := fmt.Errorf("Error disconnecting COM%d: %s", capture.port, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepStopSerialLog) Cleanup(state multistep.StateBag) {}
+
+// serialLogCapture copies the named pipe of a COM port into a file. The
+// pipe is reopened whenever it closes, e.g. while the VM restarts, until
+// the capture is stopped. The reader closes the file when it finishes, so
+// nothing is written to it once closed.
+type serialLogCapture struct {
+	port        uint
+	file        *os.File
+	stopTimeout time.Duration
+
+	// The pipe being read, closed by Stop to unblock the reader
+	mu   sync.Mutex
+	pipe io.Closer
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func (c *serialLogCapture) run(open func() (io.ReadCloser, error), w io.Writer) {
+	defer close(c.done)
+	defer c.file.Close()
+
+	for {
+		pipe, err := open()
+		if err == nil {
+			c.track(pipe)
+			_, err = io.Copy(w, pipe)
+			c.track(nil)
+			pipe.Close()
+		}
+		if err != nil {
+			log.Printf("Serial log: %s", err)
+		}
+
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// track records the pipe being read, or nil once it has been read.
+func (c *serialLogCapture) track(pipe io.Closer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pipe = pipe
+}
+
+// Stop waits for the pipe reader to finish, which happens once the VM is
+// off. If the pipe is still open after the timeout, it is closed to unblock
+// the reader. It is safe to call more than once.
+func (c *serialLogCapture) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+
+		select {
+		case <-c.done:
+			return
+		case <-time.After(c.stopTimeout):
+		}
+
+		log.Printf("Serial log: timed out waiting for the pipe reader to finish, closing the pipe")
+		c.mu.Lock()
+		if c.pipe != nil {
+			c.pipe.Close()
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-c.done:
+		case <-time.After(c.stopTimeout):
+			// The reader still closes the log once its read returns
+			log.Printf("Serial log: the pipe reader is still blocked")
+		}
+	})
+}
+
+// serialLogUiWriter prints complete lines written to it to the UI.
+type serialLogUiWriter struct {
+	ui  packersdk.Ui
+	buf bytes.Buffer
+}
+
+func (w *serialLogUiWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// Keep the incomplete line for the next write
+			w.buf.WriteString(line)
+			return len(p), nil
+		}
+		w.ui.Message(fmt.Sprintf("serial: %s", strings.TrimRight(line, "\r\n")))
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepStartSerialLog_impl(t *testing.T) {
+	var _ multistep.Step = new(StepStartSerialLog)
+}
+
+func TestStepStopSerialLog_impl(t *testing.T) {
+	var _ multistep.Step = new(StepStopSerialLog)
+}
+
+// testSerialPipe returns an OpenPipeFunc that serves output once and then
+// fails, like a pipe whose VM has been turned off.
+func testSerialPipe(output string) (func(string) (io.ReadCloser, error), *string) {
+	var mu sync.Mutex
+	var opened string
+	served := false
+	return func(path string) (io.ReadCloser, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		opened = path
+		if served {
+			return nil, errors.New("pipe closed")
+		}
+		served = true
+		return io.NopCloser(strings.NewReader(output)), nil
+	}, &opened
+}
+
+func TestStepSerialLog(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+	outputDir := t.TempDir()
+
+	openPipe, opened := testSerialPipe("Booting...\r\nlogin: ")
+	start := &StepStartSerialLog{
+		Config:       SerialLogConfig{Port: 2, File: "logs/serial.log"},
+		OutputDir:    outputDir,
+		OpenPipeFunc: openPipe,
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := start.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.SetVirtualMachineComPort_Number != 2 {
+		t.Fatalf("Should connect COM2. Got: COM%d", driver.SetVirtualMachineComPort_Number)
+	}
+	if driver.SetVirtualMachineComPort_Path != `\\.\pipe\packer-foo` {
+		t.Fatalf("Should connect the port to the build pipe. Got: %s", driver.SetVirtualMachineComPort_Path)
+	}
+
+	stop := new(StepStopSerialLog)
+	if action := stop.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("Should NOT have error")
+	}
+	if driver.SetVirtualMachineComPort_Path != "" {
+		t.Fatalf("Should disconnect the port. Got: %s", driver.SetVirtualMachineComPort_Path)
+	}
+	if *opened != `\\.\pipe\packer-foo` {
+		t.Fatalf("Should read the build pipe. Got: %s", *opened)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "logs", "serial.log"))
+	if err != nil {
+		t.Fatalf("Should have written the serial log: %s", err)
+	}
+	if string(data) != "Booting...\r\nlogin: " {
+		t.Fatalf("Bad serial log: %q", data)
+	}
+
+	// Cleanup after a successful stop is a no-op
+	start.Cleanup(state)
+}
+
+// blockingSerialPipe serves output and then blocks reading until it is
+// closed, like a pipe the VM still holds open.
+type blockingSerialPipe struct {
+	output    *strings.Reader
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (p *blockingSerialPipe) Read(b []byte) (int, error) {
+	if p.output.Len() > 0 {
+		return p.output.Read(b)
+	}
+	<-p.closed
+	return 0, os.ErrClosed
+}
+
+func (p *blockingSerialPipe) Close() error {
+	p.closeOnce.Do(func() { close(p.closed) })
+	return nil
+}
+
+func TestSerialLogCapture_stopBlockedReader(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "serial.log")
+	file, err := os.Create(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pipe := &blockingSerialPipe{output: strings.NewReader("Shutting down...\r\n"), closed: make(chan struct{})}
+	capture := &serialLogCapture{
+		file:        file,
+		stopTimeout: 10 * time.Millisecond,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go capture.run(func() (io.ReadCloser, error) { return pipe, nil }, file)
+
+	// Let the reader copy the output and block
+	for i := 0; i < 100; i++ {
+		if data, _ := os.ReadFile(logPath); len(data) > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	capture.Stop()
+	select {
+	case <-capture.done:
+	default:
+		t.Fatal("Stop should close the pipe and wait for the reader")
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Should have written the serial log: %s", err)
+	}
+	if string(data) != "Shutting down...\r\n" {
+		t.Fatalf("Bad serial log: %q", data)
+	}
+	if _, err := file.Write([]byte("x")); err == nil {
+		t.Fatal("The reader should have closed the log")
+	}
+}
+
+func TestStepSerialLog_mirrorToUi(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+	ui := state.Get("ui").(*packersdk.BasicUi)
+
+	openPipe, _ := testSerialPipe("Booting...\r\nlogin: ")
+	start := &StepStartSerialLog{
+		Config:       SerialLogConfig{Port: 1, File: "serial.log", MirrorToUi: true},
+		OutputDir:    t.TempDir(),
+		OpenPipeFunc: openPipe,
+	}
+
+	if action := start.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	start.Cleanup(state)
+
+	output := ui.Writer.(*bytes.Buffer).String()
+	if !strings.Contains(output, "serial: Booting...\n") {
+		t.Fatalf("Should mirror complete lines to the UI. Got: %q", output)
+	}
+	if strings.Contains(output, "login:") {
+		t.Fatalf("Should NOT mirror incomplete lines to the UI. Got: %q", output)
+	}
+}
+
+func TestStepSerialLog_notSet(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+	start := new(StepStartSerialLog)
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := start.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if action := new(StepStopSerialLog).Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.SetVirtualMachineComPort_Called {
+		t.Fatal("Should NOT have called SetVirtualMachineComPort")
+	}
+}
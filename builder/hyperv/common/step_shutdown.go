@@ -15,8 +15,10 @@ import (
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
-// This step shuts down the machine. It first attempts to do so gracefully,
-// but ultimately forcefully shuts it down if that fails.
+// This step shuts down the machine. It tries the stages of Mode in turn,
+// escalating to the next one when the VM hasn't powered off within the
+// timeout of a stage. Without Mode, the VM is shut down with Command, or
+// through the Shutdown integration service without a command.
 //
 // Uses:
 //
@@ -27,88 +29,191 @@ import (
 //
 // Produces:
 //
-//	<nothing>
+//	shutdown_method string - The method of the stage that powered the VM off
 type StepShutdown struct {
-	Command         string
+	Command string
+	// The timeout of the stages that don't set one
 	Timeout         time.Duration
 	DisableShutdown bool
+	Mode            []ShutdownStage
+	// How often to check whether the VM is off. Defaults to 500ms.
+	PollInterval time.Duration
 }
 
 func (s *StepShutdown) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-
-	comm := state.Get("communicator").(packersdk.Communicator)
 	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packersdk.Ui)
 	vmName := state.Get("vmName").(string)
 
-	if !s.DisableShutdown {
-		if s.Command != "" {
-			ui.Say("Gracefully halting virtual machine...")
-			log.Printf("Executing shutdown command: %s", s.Command)
+	stages := s.Mode
+	if len(stages) == 0 {
+		method := ShutdownMethodIntegrationService
+		if s.Command != "" || s.DisableShutdown {
+			method = ShutdownMethodCommand
+		}
+		stages = []ShutdownStage{{Method: method}}
+	}
 
-			var stdout, stderr bytes.Buffer
-			cmd := &packersdk.RemoteCmd{
-				Command: s.Command,
-				Stdout:  &stdout,
-				Stderr:  &stderr,
-			}
-			if err := comm.Start(ctx, cmd); err != nil {
-				err := fmt.Errorf("Failed to send shutdown command: %s", err)
+	for i, stage := range stages {
+		last := i == len(stages)-1
+
+		stopped, err := s.begin(ctx, state, stage.Method)
+		if err != nil {
+			if last {
 				state.Put("error", err)
 				ui.Error(err.Error())
 				return multistep.ActionHalt
 			}
+			ui.Error(fmt.Sprintf("%s, escalating...", err))
+			continue
+		}
 
-		} else {
-			ui.Say("Forcibly halting virtual machine...")
-			if err := driver.Stop(vmName); err != nil {
-				err := fmt.Errorf("Error stopping VM: %s", err)
+		timeout := stage.Timeout
+		if timeout == 0 {
+			timeout = s.Timeout
+		}
+
+		// Wait for the machine to actually shut down
+		log.Printf("Waiting max %s for shutdown to complete", timeout)
+		err = s.waitForOff(ctx, driver, vmName, timeout, stopped)
+		switch {
+		case err == nil:
+			log.Println("VM shut down.")
+			ui.Say(fmt.Sprintf("Virtual machine powered off by %s.", shutdownMethodDescription(stage.Method)))
+			state.Put("shutdown_method", stage.Method)
+			return multistep.ActionContinue
+		case ctx.Err() != nil:
+			// The step sequence was cancelled, so cancel the halt wait and just exit.
+			log.Println("[WARN] Interrupt detected, quitting waiting for shutdown.")
+			return multistep.ActionHalt
+		case !errors.Is(err, context.DeadlineExceeded):
+			// The shutdown request failed while waiting
+			if last {
 				state.Put("error", err)
 				ui.Error(err.Error())
 				return multistep.ActionHalt
 			}
+			ui.Error(fmt.Sprintf("%s, escalating...", err))
+		case last:
+			err := errors.New("Timeout while waiting for machine to shut down.")
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		default:
+			ui.Say(fmt.Sprintf("Virtual machine not powered off by %s within %s, escalating...",
+				shutdownMethodDescription(stage.Method), timeout))
 		}
-	} else {
-		ui.Say("Automatic shutdown disabled.")
 	}
 
-	// Wait for the machine to actually shut down
-	log.Printf("Waiting max %s for shutdown to complete", s.Timeout)
-	shutdownTimer := time.After(s.Timeout)
-
-	waitRunning := make(chan bool, 1)
-	go func() {
-		// loop until the VM has shut down.
-		for {
-			running, _ := driver.IsRunning(vmName)
-			if !running {
-				waitRunning <- true
+	// Not reached, there is always a stage
+	return multistep.ActionHalt
+}
+
+// begin starts powering off the VM with method. The returned channel, if not
+// nil, receives the result of a shutdown request that is still running.
+func (s *StepShutdown) begin(ctx context.Context, state multistep.StateBag, method string) (<-chan error, error) {
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmName := state.Get("vmName").(string)
+
+	switch method {
+	case ShutdownMethodCommand:
+		if s.DisableShutdown {
+			ui.Say("Automatic shutdown disabled.")
+			return nil, nil
+		}
+
+		comm := state.Get("communicator").(packersdk.Communicator)
+		ui.Say("Gracefully halting virtual machine...")
+		log.Printf("Executing shutdown command: %s", s.Command)
+
+		var stdout, stderr bytes.Buffer
+		cmd := &packersdk.RemoteCmd{
+			Command: s.Command,
+			Stdout:  &stdout,
+			Stderr:  &stderr,
+		}
+		if err := comm.Start(ctx, cmd); err != nil {
+			return nil, fmt.Errorf("Failed to send shutdown command: %s", err)
+		}
+	case ShutdownMethodIntegrationService:
+		ui.Say("Halting virtual machine through the Shutdown integration service...")
+		// Stop-VM blocks until the guest has shut down, so run it in the
+		// background where the stage timeout and cancellation can abandon it
+		stopped := make(chan error, 1)
+		go func() {
+			if err := driver.Stop(vmName); err != nil {
+				stopped <- fmt.Errorf("Error stopping VM: %s", err)
 				return
 			}
-
-			time.Sleep(500 * time.Millisecond)
+			stopped <- nil
+		}()
+		return stopped, nil
+	case ShutdownMethodTurnOff:
+		ui.Say("Forcibly halting virtual machine...")
+		if err := driver.TurnOff(vmName); err != nil {
+			return nil, fmt.Errorf("Error turning off VM: %s", err)
 		}
-	}()
+	default:
+		return nil, fmt.Errorf("Unknown shutdown method %q", method)
+	}
+
+	return nil, nil
+}
+
+// waitForOff polls the VM until it is off, timeout has elapsed, ctx is done
+// or the shutdown request sent to stopped fails.
+func (s *StepShutdown) waitForOff(ctx context.Context, driver Driver, vmName string, timeout time.Duration,
+	stopped <-chan error) error {
+	pollInterval := s.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = 500 * time.Millisecond
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
 
 	for {
-		// Wait for the shutdown timeout to elapse, an interrupt, or the VM to be shut down.
+		off, err := driver.IsOff(vmName)
+		if err != nil {
+			log.Printf("Error checking whether the VM is off: %s", err)
+		} else if off {
+			if stopped != nil {
+				// Stop-VM returns as soon as the VM is off
+				select {
+				case <-stopped:
+				case <-ctx.Done():
+				}
+			}
+			return nil
+		}
+
 		select {
-		case <-shutdownTimer:
-			err := errors.New("Timeout while waiting for machine to shut down.")
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
 		case <-ctx.Done():
-			// The step sequence was cancelled, so cancel the halt wait and just exit.
-			log.Println("[WARN] Interrupt detected, quitting waiting for shutdown.")
-			return multistep.ActionHalt
-		case <-waitRunning:
-			log.Println("VM shut down.")
-			return multistep.ActionContinue
-		default:
-			time.Sleep(500 * time.Millisecond)
+			return ctx.Err()
+		case err := <-stopped:
+			if err != nil {
+				return err
+			}
+			stopped = nil
+		case <-ticker.C:
 		}
 	}
 }
 
 func (s *StepShutdown) Cleanup(state multistep.StateBag) {}
+
+func shutdownMethodDescription(method string) string {
+	switch method {
+	case ShutdownMethodCommand:
+		return "the shutdown command"
+	case ShutdownMethodIntegrationService:
+		return "the Shutdown integration service"
+	case ShutdownMethodTurnOff:
+		return "turning it off"
+	}
+	return method
+}
@@ -0,0 +1,214 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepShutdown_impl(t *testing.T) {
+	var _ multistep.Step = new(StepShutdown)
+}
+
+// turnOffDriver reports the VM as off once it has been turned off.
+type turnOffDriver struct {
+	*DriverMock
+}
+
+func (d *turnOffDriver) IsOff(vmName string) (bool, error) {
+	d.IsOff_Called = true
+	return d.TurnOff_Called, nil
+}
+
+// blockingStopDriver doesn't return from Stop until released, like Stop-VM
+// for a guest that ignores the shutdown request.
+type blockingStopDriver struct {
+	turnOffDriver
+	release chan struct{}
+}
+
+func (d *blockingStopDriver) Stop(vmName string) error {
+	<-d.release
+	return nil
+}
+
+func testShutdownState(t *testing.T) multistep.StateBag {
+	state := testState(t)
+	state.Put("vmName", "foo")
+	state.Put("communicator", new(packersdk.MockCommunicator))
+	return state
+}
+
+func TestStepShutdown_integrationService(t *testing.T) {
+	state := testShutdownState(t)
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsOff_Return = true
+
+	step := &StepShutdown{Timeout: time.Minute}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	if !driver.Stop_Called || driver.TurnOff_Called {
+		t.Fatal("Should stop the VM through the integration service")
+	}
+	if method := state.Get("shutdown_method"); method != ShutdownMethodIntegrationService {
+		t.Fatalf("Bad shutdown method: %v", method)
+	}
+}
+
+func TestStepShutdown_command(t *testing.T) {
+	state := testShutdownState(t)
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsOff_Return = true
+
+	step := &StepShutdown{Command: "shutdown -P now", Timeout: time.Minute}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+
+	comm := state.Get("communicator").(*packersdk.MockCommunicator)
+	if comm.StartCmd == nil || comm.StartCmd.Command != "shutdown -P now" {
+		t.Fatalf("Bad command: %#v", comm.StartCmd)
+	}
+	if driver.Stop_Called {
+		t.Fatal("Should NOT stop the VM")
+	}
+	if method := state.Get("shutdown_method"); method != ShutdownMethodCommand {
+		t.Fatalf("Bad shutdown method: %v", method)
+	}
+}
+
+func TestStepShutdown_escalation(t *testing.T) {
+	state := testShutdownState(t)
+	driver := &turnOffDriver{DriverMock: new(DriverMock)}
+	state.Put("driver", driver)
+
+	step := &StepShutdown{
+		Command: "shutdown -P now",
+		Timeout: time.Minute,
+		Mode: []ShutdownStage{
+			{Method: ShutdownMethodCommand, Timeout: 10 * time.Millisecond},
+			{Method: ShutdownMethodIntegrationService, Timeout: 10 * time.Millisecond},
+			{Method: ShutdownMethodTurnOff},
+		},
+		PollInterval: time.Millisecond,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	if !driver.Stop_Called || !driver.TurnOff_Called {
+		t.Fatal("Should escalate to turning off the VM")
+	}
+	if method := state.Get("shutdown_method"); method != ShutdownMethodTurnOff {
+		t.Fatalf("Bad shutdown method: %v", method)
+	}
+}
+
+func TestStepShutdown_escalationOnError(t *testing.T) {
+	state := testShutdownState(t)
+	driver := &turnOffDriver{DriverMock: new(DriverMock)}
+	driver.Stop_Err = errors.New("The Shutdown integration service is not available")
+	state.Put("driver", driver)
+
+	step := &StepShutdown{
+		Timeout: time.Minute,
+		Mode: []ShutdownStage{
+			{Method: ShutdownMethodIntegrationService},
+			{Method: ShutdownMethodTurnOff},
+		},
+		PollInterval: time.Millisecond,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	if !driver.TurnOff_Called {
+		t.Fatal("Should turn off the VM")
+	}
+}
+
+func TestStepShutdown_blockingStop(t *testing.T) {
+	state := testShutdownState(t)
+	driver := &blockingStopDriver{
+		turnOffDriver: turnOffDriver{DriverMock: new(DriverMock)},
+		release:       make(chan struct{}),
+	}
+	defer close(driver.release)
+	state.Put("driver", driver)
+
+	step := &StepShutdown{
+		Timeout: time.Minute,
+		Mode: []ShutdownStage{
+			{Method: ShutdownMethodIntegrationService, Timeout: 10 * time.Millisecond},
+			{Method: ShutdownMethodTurnOff},
+		},
+		PollInterval: time.Millisecond,
+	}
+
+	done := make(chan multistep.StepAction, 1)
+	go func() { done <- step.Run(context.Background(), state) }()
+
+	select {
+	case action := <-done:
+		if action != multistep.ActionContinue {
+			t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Should NOT wait for Stop to return")
+	}
+	if !driver.TurnOff_Called {
+		t.Fatal("Should escalate to turning off the VM")
+	}
+	if method := state.Get("shutdown_method"); method != ShutdownMethodTurnOff {
+		t.Fatalf("Bad shutdown method: %v", method)
+	}
+}
+
+func TestStepShutdown_timeout(t *testing.T) {
+	state := testShutdownState(t)
+
+	driver := state.Get("driver").(*DriverMock)
+
+	step := &StepShutdown{Timeout: 10 * time.Millisecond, PollInterval: time.Millisecond}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("Should have a timeout error")
+	}
+	if driver.TurnOff_Called {
+		t.Fatal("Should NOT turn off the VM")
+	}
+}
+
+func TestStepShutdown_cancel(t *testing.T) {
+	state := testShutdownState(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	step := &StepShutdown{
+		Timeout: time.Hour,
+		Mode: []ShutdownStage{
+			{Method: ShutdownMethodIntegrationService},
+			{Method: ShutdownMethodTurnOff},
+		},
+		PollInterval: time.Millisecond,
+	}
+	if action := step.Run(ctx, state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	if driver.TurnOff_Called {
+		t.Fatal("Should NOT escalate after an interrupt")
+	}
+}
@@ -6,7 +6,8 @@ package common
 import (
 	"context"
 	"fmt"
-	"strings"
+	"image"
+	"log"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
@@ -23,11 +24,13 @@ type bootCommandTemplateData struct {
 
 // This step "types" the boot command into the VM via the Hyper-V virtual keyboard
 type StepTypeBootCommand struct {
-	BootCommand   string
-	BootWait      time.Duration
-	SwitchName    string
-	Ctx           interpolate.Context
-	GroupInterval time.Duration
+	BootCommand    string
+	BootWait       time.Duration
+	SwitchName     string
+	Ctx            interpolate.Context
+	GroupInterval  time.Duration
+	KeyboardLayout string
+	KeyInterval    time.Duration
 }
 
 func (s *StepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -58,11 +61,30 @@ func (s *StepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag)
 		vmName,
 	}
 
-	sendCodes := func(codes []string) error {
-		scanCodesToSendString := strings.Join(codes, " ")
-		return driver.TypeScanCodes(vmName, scanCodesToSendString)
+	layout := s.KeyboardLayout
+	if layout == "" {
+		layout = DefaultKeyboardLayout
+	}
+	openKeyboard := func() (Keyboard, error) {
+		return driver.OpenKeyboard(ctx, vmName)
+	}
+	keyboard, err := newKeyboardDriver(ctx, openKeyboard, layout, s.GroupInterval, s.KeyInterval)
+	if err != nil {
+		err := fmt.Errorf("Error preparing boot command: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	defer func() {
+		if err := keyboard.Close(); err != nil {
+			log.Printf("Error closing keyboard: %s", err)
+		}
+	}()
+
+	var d bootcommand.BCDriver = keyboard
+	if shots, ok := state.GetOk("screenshotter"); ok {
+		d = &screenshotBCDriver{BCDriver: d, shots: shots.(*screenshotter)}
 	}
-	d := bootcommand.NewPCXTDriver(sendCodes, 32, s.GroupInterval)
 
 	ui.Say("Typing the boot command...")
 	command, err := interpolate.Render(s.BootCommand, &s.Ctx)
@@ -74,7 +96,7 @@ func (s *StepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag)
 		return multistep.ActionHalt
 	}
 
-	seq, err := bootcommand.GenerateExpressionSequence(command)
+	segments, err := splitBootCommand(command)
 	if err != nil {
 		err := fmt.Errorf("Error generating boot command: %s", err)
 		state.Put("error", err)
@@ -82,11 +104,37 @@ func (s *StepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag)
 		return multistep.ActionHalt
 	}
 
-	if err := seq.Do(ctx, d); err != nil {
-		err := fmt.Errorf("Error running boot command: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	captureFunc := func() (image.Image, error) {
+		return captureScreen(driver, vmName)
+	}
+
+	for _, segment := range segments {
+		seq, err := bootcommand.GenerateExpressionSequence(segment.Keys)
+		if err != nil {
+			err := fmt.Errorf("Error generating boot command: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		if err := seq.Do(ctx, d); err != nil {
+			err := fmt.Errorf("Error running boot command: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		if segment.Wait == nil {
+			continue
+		}
+
+		ui.Say(fmt.Sprintf("Waiting for the screen: %s", segment.Wait))
+		if err := waitForScreen(ctx, captureFunc, *segment.Wait, screenPollInterval); err != nil {
+			err := fmt.Errorf("Error running boot command: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 	}
 
 	return multistep.ActionContinue
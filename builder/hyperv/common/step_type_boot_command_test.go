@@ -0,0 +1,72 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestStepTypeBootCommand_impl(t *testing.T) {
+	var _ multistep.Step = new(StepTypeBootCommand)
+}
+
+func TestStepTypeBootCommand(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+	state.Put("http_port", 8080)
+	state.Put("http_ip", "10.0.0.1")
+
+	step := &StepTypeBootCommand{
+		BootCommand:   "linux ks=http://{{ .HTTPIP }}:{{ .HTTPPort }}/ks.cfg<enter>",
+		Ctx:           interpolate.Context{},
+		GroupInterval: time.Nanosecond,
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.OpenKeyboard_VmName != "foo" {
+		t.Fatalf("Should open the keyboard of the VM. Got: %s", driver.OpenKeyboard_VmName)
+	}
+
+	keyboard := driver.OpenKeyboard_Return
+	expected := []string{"text:linux ks=http://10.0.0.1:8080/ks.cfg", "scancodes:1c 9c"}
+	if !reflect.DeepEqual(keyboard.Typed, expected) {
+		t.Fatalf("Expected %v, got %v", expected, keyboard.Typed)
+	}
+	if !keyboard.Close_Called {
+		t.Fatal("Should close the keyboard")
+	}
+}
+
+func TestStepTypeBootCommand_keyboardError(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+	state.Put("http_port", 0)
+
+	step := &StepTypeBootCommand{
+		BootCommand:   "<enter>",
+		Ctx:           interpolate.Context{},
+		GroupInterval: time.Nanosecond,
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.OpenKeyboard_Err = errors.New("keyboard class is not found")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("Should have error")
+	}
+}
@@ -19,10 +19,12 @@ import (
 type StepValidateHost struct {
 	EnableVirtualizationExtensions bool
 	RamSize                        uint
+	Cpu                            uint
 
 	// Injectable for testing. Nil means use real PowerShell functions.
-	HasVirtExtFunc    func() (bool, error)
-	GetHostMemoryFunc func() float64
+	HasVirtExtFunc               func() (bool, error)
+	GetHostMemoryFunc            func() float64
+	GetHostLogicalProcessorsFunc func() (uint, error)
 }
 
 func (s *StepValidateHost) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -51,6 +53,29 @@ func (s *StepValidateHost) Run(ctx context.Context, state multistep.StateBag) mu
 		}
 	}
 
+	// The VM can't be started with more virtual processors than the host
+	// has logical processors.
+	if s.Cpu > 1 {
+		getProcessors := powershell.GetHostLogicalProcessorCount
+		if s.GetHostLogicalProcessorsFunc != nil {
+			getProcessors = s.GetHostLogicalProcessorsFunc
+		}
+
+		count, err := getProcessors()
+		if err != nil {
+			err := fmt.Errorf("failed detecting host logical processor count: %w", err)
+			ui.Error(err.Error())
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+		if s.Cpu > count {
+			err := fmt.Errorf("cpus: %d exceeds the %d logical processors of the host", s.Cpu, count)
+			ui.Error(err.Error())
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+	}
+
 	// Check host memory (warning only).
 	if warning := s.checkHostAvailableMemory(); warning != "" {
 		ui.Say(fmt.Sprintf("Warning: %s", warning))
@@ -138,3 +138,57 @@ func TestStepValidateHost_SufficientMemory(t *testing.T) {
 		t.Fatalf("should NOT have memory warning, got: %q", writer.String())
 	}
 }
+
+func TestStepValidateHost_CpuWithinHostProcessors(t *testing.T) {
+	state, _, _ := testValidateHostState(t)
+	step := &StepValidateHost{
+		RamSize:                      1024,
+		Cpu:                          4,
+		GetHostMemoryFunc:            func() float64 { return 8192 },
+		GetHostLogicalProcessorsFunc: func() (uint, error) { return 8, nil },
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("expected ActionContinue, got %v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+}
+
+func TestStepValidateHost_CpuExceedsHostProcessors(t *testing.T) {
+	state, _, errWriter := testValidateHostState(t)
+	step := &StepValidateHost{
+		RamSize:                      1024,
+		Cpu:                          16,
+		GetHostMemoryFunc:            func() float64 { return 8192 },
+		GetHostLogicalProcessorsFunc: func() (uint, error) { return 8, nil },
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("expected ActionHalt, got %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(errWriter.String(), "logical processors") {
+		t.Fatalf("expected processor count error, got: %q", errWriter.String())
+	}
+}
+
+func TestStepValidateHost_CpuDetectionError(t *testing.T) {
+	state, _, _ := testValidateHostState(t)
+	step := &StepValidateHost{
+		RamSize:                      1024,
+		Cpu:                          2,
+		GetHostMemoryFunc:            func() float64 { return 8192 },
+		GetHostLogicalProcessorsFunc: func() (uint, error) { return 0, fmt.Errorf("boom") },
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("expected ActionHalt, got %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
@@ -6,6 +6,9 @@ package common
 import (
 	"context"
 	"fmt"
+	"log"
+	"net"
+	"strconv"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -48,48 +51,166 @@ func (s *StepWaitForPowerOff) Run(ctx context.Context, state multistep.StateBag)
 func (s *StepWaitForPowerOff) Cleanup(state multistep.StateBag) {
 }
 
+// This step waits for the guest OS installation to complete with the
+// strategy of install_complete, before the communicator connects.
+//
+// Uses:
+//
+//	install_complete_callback <-chan struct{} - With the http strategy
 type StepWaitForInstallToComplete struct {
-	ExpectedRebootCount uint
-	ActionName          string
+	Config InstallCompleteConfig
+	// Host returns the IP address of the guest for the tcp strategy
+	Host func(multistep.StateBag) (string, error)
+	// Delay between checks. Defaults to 10s.
+	PollInterval time.Duration
 }
 
 func (s *StepWaitForInstallToComplete) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Config.IsSet() {
+		return multistep.ActionContinue
+	}
+
 	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packersdk.Ui)
 	vmName := state.Get("vmName").(string)
 
-	if len(s.ActionName) > 0 {
-		ui.Say(fmt.Sprintf("%v ! Waiting for VM to reboot %v times...", s.ActionName, s.ExpectedRebootCount))
+	var callback <-chan struct{}
+	if s.Config.Strategy == InstallCompleteHTTP {
+		v, ok := state.GetOk("install_complete_callback")
+		if !ok {
+			err := fmt.Errorf("Error waiting for install to complete: the HTTP server doesn't answer %s", s.Config.Path)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		callback = v.(<-chan struct{})
 	}
 
-	var rebootCount uint
-	var lastUptime uint64
+	pollInterval := s.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = time.Second * SleepSeconds
+	}
 
-	for rebootCount < s.ExpectedRebootCount {
-		uptime, err := driver.Uptime(vmName)
+	waitCtx, cancel := context.WithTimeout(ctx, s.Config.Timeout)
+	defer cancel()
 
+	ui.Say(fmt.Sprintf("Waiting for install to complete (%s)...", s.description()))
+	var rebootCount uint
+	var lastUptime uint64
+	for {
+		var done bool
+		var err error
+		switch s.Config.Strategy {
+		case InstallCompleteTCP:
+			done = s.portOpen(waitCtx, state)
+		case InstallCompleteHTTP:
+			select {
+			case <-callback:
+				done = true
+			default:
+			}
+		case InstallCompleteKVP:
+			var items map[string]string
+			items, err = driver.GetGuestKvpItems(vmName)
+			value := items[s.Config.KVPKey]
+			if err != nil {
+				err = fmt.Errorf("Error reading KVP key %s: %s", s.Config.KVPKey, err)
+			} else if s.Config.KVPValue != "" {
+				done = value == s.Config.KVPValue
+			} else {
+				done = value != ""
+			}
+		case InstallCompletePowerOff:
+			done, err = driver.IsOff(vmName)
+			if err != nil {
+				err = fmt.Errorf("Error checking if vm is off: %s", err)
+			}
+		case InstallCompleteReboots:
+			var uptime uint64
+			uptime, err = driver.Uptime(vmName)
+			if err != nil {
+				err = fmt.Errorf("Error checking uptime: %s", err)
+				break
+			}
+			if uptime < lastUptime {
+				rebootCount++
+				ui.Say(fmt.Sprintf("Detected reboot %d after %d seconds...", rebootCount, lastUptime))
+			}
+			lastUptime = uptime
+			done = rebootCount >= s.Config.RebootCount
+		}
 		if err != nil {
-			err := fmt.Errorf("Error checking uptime: %s", err)
 			state.Put("error", err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
+		if done {
+			break
+		}
 
-		if uptime < lastUptime {
-			rebootCount++
-			ui.Say(fmt.Sprintf("%v  -> Detected reboot %v after %v seconds...", s.ActionName, rebootCount, lastUptime))
+		select {
+		case <-callback:
+		case <-time.After(pollInterval):
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return multistep.ActionHalt
+			}
+			err := fmt.Errorf("Timeout waiting for install to complete (%s) after %s", s.description(), s.Config.Timeout)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
 		}
+	}
 
-		lastUptime = uptime
+	ui.Say("Install complete")
 
-		if rebootCount < s.ExpectedRebootCount {
-			time.Sleep(time.Second * SleepSeconds)
+	// The communicator needs the VM running again
+	if s.Config.Strategy == InstallCompletePowerOff {
+		ui.Say("Starting the virtual machine...")
+		if err := driver.Start(vmName); err != nil {
+			err := fmt.Errorf("Error starting vm: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
 		}
 	}
 
 	return multistep.ActionContinue
 }
 
+func (s *StepWaitForInstallToComplete) description() string {
+	switch s.Config.Strategy {
+	case InstallCompleteTCP:
+		return fmt.Sprintf("guest port %d", s.Config.Port)
+	case InstallCompleteHTTP:
+		return fmt.Sprintf("callback to %s", s.Config.Path)
+	case InstallCompleteKVP:
+		return fmt.Sprintf("KVP key %s", s.Config.KVPKey)
+	case InstallCompletePowerOff:
+		return "power off"
+	}
+	return fmt.Sprintf("%d reboots", s.Config.RebootCount)
+}
+
+// portOpen reports whether the guest accepts connections on the port. The
+// guest not having an IP address yet isn't an error.
+func (s *StepWaitForInstallToComplete) portOpen(ctx context.Context, state multistep.StateBag) bool {
+	host, err := s.Host(state)
+	if err != nil || host == "" {
+		log.Printf("[DEBUG] Waiting for the guest IP address: %v", err)
+		return false
+	}
+
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(s.Config.Port)))
+	if err != nil {
+		log.Printf("[DEBUG] Guest port %d not open yet: %s", s.Config.Port, err)
+		return false
+	}
+	conn.Close()
+	return true
+}
+
 func (s *StepWaitForInstallToComplete) Cleanup(state multistep.StateBag) {
 
 }
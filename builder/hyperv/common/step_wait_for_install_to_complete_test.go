@@ -0,0 +1,206 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepWaitForInstallToComplete_impl(t *testing.T) {
+	var _ multistep.Step = new(StepWaitForInstallToComplete)
+}
+
+// uptimeDriver reports the uptimes in turn, like a VM rebooting.
+type uptimeDriver struct {
+	*DriverMock
+	uptimes []uint64
+}
+
+func (d *uptimeDriver) Uptime(vmName string) (uint64, error) {
+	uptime := d.uptimes[0]
+	if len(d.uptimes) > 1 {
+		d.uptimes = d.uptimes[1:]
+	}
+	return uptime, nil
+}
+
+func testInstallCompleteStep(t *testing.T, config InstallCompleteConfig) *StepWaitForInstallToComplete {
+	if errs := config.Prepare(); len(errs) > 0 {
+		t.Fatalf("err: %v", errs)
+	}
+	return &StepWaitForInstallToComplete{
+		Config:       config,
+		PollInterval: time.Millisecond,
+	}
+}
+
+func TestStepWaitForInstallToComplete_notSet(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	step := &StepWaitForInstallToComplete{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v", action)
+	}
+}
+
+func TestStepWaitForInstallToComplete_tcp(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer listener.Close()
+
+	step := testInstallCompleteStep(t, InstallCompleteConfig{
+		Strategy: InstallCompleteTCP,
+		Port:     listener.Addr().(*net.TCPAddr).Port,
+	})
+	lookups := 0
+	step.Host = func(multistep.StateBag) (string, error) {
+		// The guest gets its IP address after a while
+		lookups++
+		if lookups < 3 {
+			return "", errors.New("no IP address")
+		}
+		return "127.0.0.1", nil
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	if lookups != 3 {
+		t.Fatalf("Should wait for the IP address, looked up %d times", lookups)
+	}
+}
+
+func TestStepWaitForInstallToComplete_http(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	callback := make(chan struct{})
+	state.Put("install_complete_callback", (<-chan struct{})(callback))
+
+	step := testInstallCompleteStep(t, InstallCompleteConfig{Strategy: InstallCompleteHTTP})
+	step.PollInterval = time.Hour
+	time.AfterFunc(10*time.Millisecond, func() { close(callback) })
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+}
+
+func TestStepWaitForInstallToComplete_kvp(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetGuestKvpItems_Return = map[string]string{"PackerInstall": "done"}
+
+	step := testInstallCompleteStep(t, InstallCompleteConfig{
+		Strategy: InstallCompleteKVP,
+		KVPKey:   "PackerInstall",
+		KVPValue: "done",
+	})
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	if driver.GetGuestKvpItems_VmName != "foo" {
+		t.Fatalf("Bad KVP lookup: %s", driver.GetGuestKvpItems_VmName)
+	}
+
+	// Another value doesn't complete the installation
+	driver.GetGuestKvpItems_Return = map[string]string{"PackerInstall": "installing"}
+	step.Config.Timeout = 20 * time.Millisecond
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("Should have a timeout error")
+	}
+}
+
+func TestStepWaitForInstallToComplete_powerOff(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsOff_Return = true
+
+	step := testInstallCompleteStep(t, InstallCompleteConfig{Strategy: InstallCompletePowerOff})
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	if !driver.Start_Called {
+		t.Fatal("Should start the VM again for the communicator")
+	}
+}
+
+func TestStepWaitForInstallToComplete_reboots(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+	driver := &uptimeDriver{
+		DriverMock: new(DriverMock),
+		uptimes:    []uint64{10, 200, 5, 60, 300, 1, 2},
+	}
+	state.Put("driver", driver)
+
+	step := testInstallCompleteStep(t, InstallCompleteConfig{
+		Strategy:    InstallCompleteReboots,
+		RebootCount: 2,
+	})
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+	if len(driver.uptimes) != 1 {
+		t.Fatalf("Should stop after the second reboot, left: %v", driver.uptimes)
+	}
+}
+
+func TestStepWaitForInstallToComplete_error(t *testing.T) {
+	state := testState(t)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsOff_Err = errors.New("VM not found")
+
+	step := testInstallCompleteStep(t, InstallCompleteConfig{Strategy: InstallCompletePowerOff})
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if driver.Start_Called {
+		t.Fatal("Should NOT start the VM")
+	}
+}
+
+func TestInstallCompleteConfig_Prepare(t *testing.T) {
+	for _, config := range []InstallCompleteConfig{
+		{Strategy: "ping"},
+		{Strategy: InstallCompleteTCP},
+		{Strategy: InstallCompleteHTTP, Path: "install-complete"},
+		{Strategy: InstallCompleteKVP},
+		{Strategy: InstallCompleteReboots},
+		{Strategy: InstallCompletePowerOff, Timeout: -time.Second},
+	} {
+		if errs := config.Prepare(); len(errs) == 0 {
+			t.Fatalf("Should have error: %#v", config)
+		}
+	}
+
+	config := InstallCompleteConfig{Strategy: "HTTP"}
+	if errs := config.Prepare(); len(errs) > 0 {
+		t.Fatalf("err: %v", errs)
+	}
+	if config.CallbackPath() != DefaultInstallCompletePath || config.Timeout != DefaultInstallCompleteTimeout {
+		t.Fatalf("Bad defaults: %#v", config)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+)
+
+// How many lines of the end of the sysprep log are shown when sysprep fails
+const sysprepLogTailLines = 40
+
+// This step generalizes a Windows guest with sysprep in place of
+// StepShutdown. Sysprep is launched detached, and the guest powering itself
+// off within Timeout is success. A guest that reboots instead, which is
+// how sysprep fails, fails the build with the end of the sysprep log.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	driver       Driver
+//	ui           packersdk.Ui
+//	vmName       string
+//
+// Produces:
+//
+//	shutdown_method string - "windows_generalize"
+type StepWindowsGeneralize struct {
+	Config  WindowsGeneralizeConfig
+	Timeout time.Duration
+	// How often to check whether the VM is off. Defaults to 500ms.
+	PollInterval time.Duration
+}
+
+func (s *StepWindowsGeneralize) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	comm := state.Get("communicator").(packersdk.Communicator)
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	vmName := state.Get("vmName").(string)
+
+	if s.Config.UnattendFile != "" {
+		ui.Say("Uploading the sysprep unattend file...")
+		if err := s.uploadUnattend(ctx, comm); err != nil {
+			err := fmt.Errorf("Error uploading the sysprep unattend file: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	uptime, err := driver.Uptime(vmName)
+	if err != nil {
+		err := fmt.Errorf("Error getting the uptime of the VM: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Generalizing the guest with sysprep...")
+	command := s.Config.SysprepCommand()
+	log.Printf("Executing sysprep command: %s", command)
+	var stdout, stderr bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: command,
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		// Sysprep may end the session before the command returns
+		log.Printf("[WARN] Lost the communicator while launching sysprep: %s", err)
+	} else if status := cmd.ExitStatus(); status != 0 {
+		err := fmt.Errorf("Error launching sysprep, exit status %d: %s", status, stderr.String())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	log.Printf("Waiting max %s for sysprep to power off the VM", s.Timeout)
+	rebooted, err := s.waitForOff(ctx, driver, vmName, uptime)
+	switch {
+	case err == nil && !rebooted:
+		ui.Say("Virtual machine powered off by sysprep.")
+		state.Put("shutdown_method", "windows_generalize")
+		return multistep.ActionContinue
+	case ctx.Err() != nil:
+		log.Println("[WARN] Interrupt detected, quitting waiting for sysprep.")
+		return multistep.ActionHalt
+	case rebooted:
+		err = errors.New("Sysprep failed: the guest rebooted instead of powering off")
+	default:
+		err = errors.New("Timeout while waiting for sysprep to power off the VM.")
+	}
+
+	state.Put("error", err)
+	ui.Error(err.Error())
+	s.showLog(ctx, comm, ui)
+	return multistep.ActionHalt
+}
+
+func (s *StepWindowsGeneralize) Cleanup(state multistep.StateBag) {}
+
+func (s *StepWindowsGeneralize) uploadUnattend(ctx context.Context, comm packersdk.Communicator) error {
+	f, err := os.Open(s.Config.UnattendFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cmd := &packersdk.RemoteCmd{
+		Command: `powershell -NoProfile -Command "New-Item -ItemType Directory -Force -Path C:\Windows\Panther\Unattend | Out-Null"`,
+	}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return err
+	}
+	if status := cmd.Wait(); status != 0 {
+		return fmt.Errorf("creating the directory failed with exit status %d", status)
+	}
+
+	return comm.Upload(WindowsGeneralizeUnattendPath, f, nil)
+}
+
+// waitForOff polls the VM until it is off, it has rebooted, Timeout has
+// elapsed or ctx is done. A reboot shows as the uptime going down.
+func (s *StepWindowsGeneralize) waitForOff(ctx context.Context, driver Driver, vmName string, uptime uint64) (bool, error) {
+	pollInterval := s.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = 500 * time.Millisecond
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		off, err := driver.IsOff(vmName)
+		if err != nil {
+			log.Printf("Error checking whether the VM is off: %s", err)
+		} else if off {
+			return false, nil
+		}
+
+		// The uptime is also 0 while the VM is stopping
+		if current, err := driver.Uptime(vmName); err == nil && current > 0 {
+			if current < uptime {
+				return true, nil
+			}
+			uptime = current
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// showLog shows the end of the sysprep log, retrying while the guest comes
+// back up after a reboot.
+func (s *StepWindowsGeneralize) showLog(ctx context.Context, comm packersdk.Communicator, ui packersdk.Ui) {
+	var buf bytes.Buffer
+	err := retry.Config{
+		Tries:      10,
+		RetryDelay: func() time.Duration { return 10 * time.Second },
+	}.Run(ctx, func(ctx context.Context) error {
+		buf.Reset()
+		return comm.Download(WindowsGeneralizeLogPath, &buf)
+	})
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error downloading the sysprep log %s: %s", WindowsGeneralizeLogPath, err))
+		return
+	}
+
+	log.Printf("Sysprep log:\n%s", buf.String())
+	ui.Error(fmt.Sprintf("The end of the sysprep log %s:\n%s", WindowsGeneralizeLogPath,
+		logTail(buf.String(), sysprepLogTailLines)))
+}
+
+// logTail returns the last n lines of s.
+func logTail(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\r\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
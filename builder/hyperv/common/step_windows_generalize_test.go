@@ -0,0 +1,109 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepWindowsGeneralize_impl(t *testing.T) {
+	var _ multistep.Step = new(StepWindowsGeneralize)
+}
+
+func TestStepWindowsGeneralize(t *testing.T) {
+	state := testShutdownState(t)
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsOff_Return = true
+
+	unattend := filepath.Join(t.TempDir(), "unattend.xml")
+	if err := os.WriteFile(unattend, []byte("<unattend/>"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	step := &StepWindowsGeneralize{
+		Config:  WindowsGeneralizeConfig{Mode: WindowsGeneralizeOOBE, UnattendFile: unattend},
+		Timeout: time.Minute,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Bad action: %v, error: %v", action, state.Get("error"))
+	}
+
+	comm := state.Get("communicator").(*packersdk.MockCommunicator)
+	if comm.UploadPath != WindowsGeneralizeUnattendPath || comm.UploadData != "<unattend/>" {
+		t.Fatalf("Bad upload: %s: %s", comm.UploadPath, comm.UploadData)
+	}
+	if !strings.Contains(comm.StartCmd.Command, "sysprep.exe") {
+		t.Fatalf("Bad command: %s", comm.StartCmd.Command)
+	}
+	if method := state.Get("shutdown_method"); method != "windows_generalize" {
+		t.Fatalf("Bad shutdown method: %v", method)
+	}
+}
+
+func TestStepWindowsGeneralize_reboot(t *testing.T) {
+	state := testShutdownState(t)
+	driver := &uptimeDriver{
+		DriverMock: new(DriverMock),
+		uptimes:    []uint64{100, 110, 5},
+	}
+	state.Put("driver", driver)
+
+	comm := state.Get("communicator").(*packersdk.MockCommunicator)
+	comm.DownloadData = "Sysprep starting\nError SYSPRP Package Microsoft.Foo was installed for a user\n"
+
+	step := &StepWindowsGeneralize{
+		Config:       WindowsGeneralizeConfig{Mode: WindowsGeneralizeOOBE},
+		Timeout:      time.Minute,
+		PollInterval: time.Millisecond,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Bad action: %v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("Should have an error")
+	}
+	if comm.DownloadPath != WindowsGeneralizeLogPath {
+		t.Fatalf("Should download the sysprep log, downloaded: %s", comm.DownloadPath)
+	}
+
+	ui := state.Get("ui").(*packersdk.BasicUi)
+	if output := ui.Writer.(*bytes.Buffer).String(); !strings.Contains(output, "Error SYSPRP") {
+		t.Fatalf("Should show the sysprep log: %s", output)
+	}
+}
+
+func TestWindowsGeneralizeConfig_Prepare(t *testing.T) {
+	for _, config := range []WindowsGeneralizeConfig{
+		{Mode: "generalize"},
+		{Mode: WindowsGeneralizeOOBE, UnattendFile: filepath.Join(t.TempDir(), "missing.xml")},
+	} {
+		if errs := config.Prepare(); len(errs) == 0 {
+			t.Fatalf("Should have error: %#v", config)
+		}
+	}
+
+	config := WindowsGeneralizeConfig{Mode: "Audit", VMMode: true}
+	if errs := config.Prepare(); len(errs) > 0 {
+		t.Fatalf("err: %v", errs)
+	}
+	command := config.SysprepCommand()
+	for _, arg := range []string{"'/generalize'", "'/audit'", "'/shutdown'", "'/mode:vm'"} {
+		if !strings.Contains(command, arg) {
+			t.Fatalf("Command should pass %s: %s", arg, command)
+		}
+	}
+	if strings.Contains(command, "/unattend") {
+		t.Fatalf("Command should NOT pass an unattend file: %s", command)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type WindowsGeneralizeConfig
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The modes sysprep leaves the generalized image in
+const (
+	WindowsGeneralizeOOBE  = "oobe"
+	WindowsGeneralizeAudit = "audit"
+)
+
+const (
+	// Where the unattend file is uploaded to. Sysprep caches it for the
+	// specialize and OOBE passes, so it doesn't need to survive.
+	WindowsGeneralizeUnattendPath = `C:\Windows\Panther\Unattend\packer-unattend.xml`
+	// The log sysprep writes the actions it took to
+	WindowsGeneralizeLogPath = `C:\Windows\System32\Sysprep\Panther\setupact.log`
+)
+
+// WindowsGeneralizeConfig generalizes a Windows guest with sysprep instead
+// of running `shutdown_command` at the end of the build. Sysprep is
+// launched detached through the communicator, so the connection dropping
+// while it runs isn't an error, and the guest powering itself off within
+// `shutdown_timeout` completes the build.
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	windows_generalize {
+//	  mode          = "oobe"
+//	  unattend_file = "oobe-unattend.xml"
+//	}
+//
+// ```
+type WindowsGeneralizeConfig struct {
+	// The mode the generalized image boots into, `oobe` or `audit`.
+	// Setting it enables the generalization.
+	Mode string `mapstructure:"mode" required:"true"`
+	// An unattend file on the host for the specialize and OOBE passes of
+	// the next boot, for instance to skip the OOBE screens. It is uploaded
+	// to `C:\Windows\Panther\Unattend\packer-unattend.xml` and passed to
+	// sysprep with `/unattend`.
+	UnattendFile string `mapstructure:"unattend_file" required:"false"`
+	// Pass `/mode:vm` to sysprep, which skips the hardware detection of the
+	// next boot. The image must then only be deployed to Hyper-V VMs with
+	// the same hardware profile. This defaults to false.
+	VMMode bool `mapstructure:"vm_mode" required:"false"`
+}
+
+// IsSet reports whether the guest should be generalized.
+func (c *WindowsGeneralizeConfig) IsSet() bool {
+	return c.Mode != ""
+}
+
+func (c *WindowsGeneralizeConfig) Prepare() []error {
+	if !c.IsSet() {
+		return nil
+	}
+
+	var errs []error
+
+	c.Mode = strings.ToLower(c.Mode)
+	if c.Mode != WindowsGeneralizeOOBE && c.Mode != WindowsGeneralizeAudit {
+		errs = append(errs, fmt.Errorf("windows_generalize: mode must be %s or %s, but defined: %s",
+			WindowsGeneralizeOOBE, WindowsGeneralizeAudit, c.Mode))
+	}
+
+	if c.UnattendFile != "" {
+		if _, err := os.Stat(c.UnattendFile); err != nil {
+			errs = append(errs, fmt.Errorf("windows_generalize: unattend_file is invalid: %s", err))
+		}
+	}
+
+	return errs
+}
+
+// SysprepCommand returns the PowerShell command launching sysprep without
+// waiting for it, so it returns before sysprep ends the communicator's
+// session.
+func (c *WindowsGeneralizeConfig) SysprepCommand() string {
+	args := []string{"/generalize", "/" + c.Mode, "/shutdown", "/quiet"}
+	if c.VMMode {
+		args = append(args, "/mode:vm")
+	}
+	if c.UnattendFile != "" {
+		args = append(args, "/unattend:"+WindowsGeneralizeUnattendPath)
+	}
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + arg + "'"
+	}
+
+	return `powershell -NoProfile -ExecutionPolicy Bypass -Command "` +
+		`Start-Process -FilePath $env:SystemRoot\System32\Sysprep\sysprep.exe -ArgumentList ` +
+		strings.Join(quoted, ",") + `"`
+}
@@ -0,0 +1,35 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatWindowsGeneralizeConfig is an auto-generated flat version of WindowsGeneralizeConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatWindowsGeneralizeConfig struct {
+	Mode         *string `mapstructure:"mode" required:"true" cty:"mode" hcl:"mode"`
+	UnattendFile *string `mapstructure:"unattend_file" required:"false" cty:"unattend_file" hcl:"unattend_file"`
+	VMMode       *bool   `mapstructure:"vm_mode" required:"false" cty:"vm_mode" hcl:"vm_mode"`
+}
+
+// FlatMapstructure returns a new FlatWindowsGeneralizeConfig.
+// FlatWindowsGeneralizeConfig is an auto-generated flat version of WindowsGeneralizeConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*WindowsGeneralizeConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatWindowsGeneralizeConfig)
+}
+
+// HCL2Spec returns the hcl spec of a WindowsGeneralizeConfig.
+// This spec is used by HCL to read the fields of WindowsGeneralizeConfig.
+// The decoded values from this spec will then be applied to a FlatWindowsGeneralizeConfig.
+func (*FlatWindowsGeneralizeConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"mode":          &hcldec.AttrSpec{Name: "mode", Type: cty.String, Required: false},
+		"unattend_file": &hcldec.AttrSpec{Name: "unattend_file", Type: cty.String, Required: false},
+		"vm_mode":       &hcldec.AttrSpec{Name: "vm_mode", Type: cty.Bool, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,339 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type WindowsUnattendConfig
+
+package common
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// The name Windows Setup looks for in the root of removable media
+const UnattendFileName = "Autounattend.xml"
+
+const (
+	DefaultUnattendLocale   = "en-US"
+	DefaultUnattendTimeZone = "UTC"
+)
+
+// WindowsUnattendConfig generates the `Autounattend.xml` answer file of an
+// unattended Windows installation. The file is attached through
+// `cd_content` on generation 2 VMs and `floppy_content` on generation 1
+// VMs. The disk is wiped and partitioned for the generation: MBR with a
+// system partition for generation 1, GPT with EFI and MSR partitions for
+// generation 2.
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	windows_unattend {
+//	  image_name     = "Windows Server 2025 SERVERDATACENTERCORE"
+//	  admin_password = var.admin_password
+//	  time_zone      = "W. Europe Standard Time"
+//	  enable_winrm   = true
+//	}
+//
+// ```
+type WindowsUnattendConfig struct {
+	// The password of the built-in Administrator account. Setting it
+	// enables the generation of the answer file.
+	AdminPassword string `mapstructure:"admin_password" required:"true"`
+	// The index of the image to install from `install.wim`, as listed by
+	// `Get-WindowsImage`. Defaults to 1 unless `image_name` is set.
+	ImageIndex uint `mapstructure:"image_index" required:"false"`
+	// The name of the image to install from `install.wim`, for instance
+	// `Windows Server 2025 SERVERSTANDARDCORE`. Selects the edition instead
+	// of `image_index`.
+	ImageName string `mapstructure:"image_name" required:"false"`
+	// The product key to install with. Evaluation media and images that
+	// don't ask for a key during setup don't need it.
+	ProductKey string `mapstructure:"product_key" required:"false"`
+	// The language and locale of setup and the installed system, for
+	// instance `de-DE`. Defaults to `en-US`.
+	Locale string `mapstructure:"locale" required:"false"`
+	// The keyboard layout of setup and the installed system, as an input
+	// locale such as `de-DE` or `0407:00000407`. Defaults to `locale`.
+	InputLocale string `mapstructure:"input_locale" required:"false"`
+	// The Windows time zone, for instance `Pacific Standard Time`. Defaults
+	// to `UTC`.
+	TimeZone string `mapstructure:"time_zone" required:"false"`
+	// How many times Administrator is logged on automatically after setup.
+	// First logon commands only run at a logon, so this defaults to 1 when
+	// there are any, and 0 otherwise.
+	AutoLogonCount uint `mapstructure:"auto_logon_count" required:"false"`
+	// Enable WinRM over HTTP with basic authentication at first logon, and
+	// allow port 5985 through the firewall, for the `winrm` communicator.
+	EnableWinRM bool `mapstructure:"enable_winrm" required:"false"`
+	// Install and start the OpenSSH server at first logon, for the `ssh`
+	// communicator. Installing the capability may need Windows Update.
+	EnableOpenSSH bool `mapstructure:"enable_openssh" required:"false"`
+	// Run `Enable-PSRemoting` at first logon, for the `psrp` communicator
+	// with the `wsman` transport. The `hvsock` transport doesn't need it.
+	EnablePSRP bool `mapstructure:"enable_psrp" required:"false"`
+	// More commands to run at first logon, after the ones enabling the
+	// communicators.
+	FirstLogonCommands []string `mapstructure:"first_logon_commands" required:"false"`
+}
+
+// IsSet reports whether an answer file should be generated.
+func (c *WindowsUnattendConfig) IsSet() bool {
+	return c.AdminPassword != ""
+}
+
+func (c *WindowsUnattendConfig) Prepare() []error {
+	if !c.IsSet() {
+		return nil
+	}
+
+	var errs []error
+
+	if c.ImageName != "" && c.ImageIndex != 0 {
+		errs = append(errs, fmt.Errorf("windows_unattend: only one of image_index and image_name can be set"))
+	}
+	if c.ImageName == "" && c.ImageIndex == 0 {
+		c.ImageIndex = 1
+	}
+
+	if c.Locale == "" {
+		c.Locale = DefaultUnattendLocale
+	}
+	if c.InputLocale == "" {
+		c.InputLocale = c.Locale
+	}
+	if c.TimeZone == "" {
+		c.TimeZone = DefaultUnattendTimeZone
+	}
+
+	if c.AutoLogonCount == 0 && len(c.firstLogonCommands()) > 0 {
+		c.AutoLogonCount = 1
+	}
+
+	return errs
+}
+
+type unattendCommand struct {
+	Description string
+	CommandLine string
+}
+
+// firstLogonCommands returns the commands enabling the communicators
+// followed by the configured ones.
+func (c *WindowsUnattendConfig) firstLogonCommands() []unattendCommand {
+	var commands []unattendCommand
+
+	if c.EnableWinRM {
+		commands = append(commands,
+			unattendCommand{"Enable WinRM", `cmd.exe /c winrm quickconfig -q`},
+			unattendCommand{"Allow unencrypted WinRM", `cmd.exe /c winrm set winrm/config/service @{AllowUnencrypted="true"}`},
+			unattendCommand{"Allow WinRM basic authentication", `cmd.exe /c winrm set winrm/config/service/auth @{Basic="true"}`},
+			unattendCommand{"Allow WinRM through the firewall", `cmd.exe /c netsh advfirewall firewall add rule name="WinRM-HTTP" dir=in localport=5985 protocol=TCP action=allow`},
+		)
+	}
+	if c.EnableOpenSSH {
+		commands = append(commands,
+			unattendCommand{"Install OpenSSH server", `powershell.exe -NoProfile -Command "Add-WindowsCapability -Online -Name OpenSSH.Server~~~~0.0.1.0"`},
+			unattendCommand{"Start OpenSSH server", `powershell.exe -NoProfile -Command "Set-Service -Name sshd -StartupType Automatic; Start-Service -Name sshd"`},
+		)
+	}
+	if c.EnablePSRP {
+		commands = append(commands,
+			unattendCommand{"Enable PowerShell remoting", `powershell.exe -NoProfile -Command "Enable-PSRemoting -Force -SkipNetworkProfileCheck"`},
+		)
+	}
+	for i, command := range c.FirstLogonCommands {
+		commands = append(commands, unattendCommand{fmt.Sprintf("First logon command %d", i+1), command})
+	}
+
+	return commands
+}
+
+// Render returns the answer file for a VM of the given generation.
+func (c *WindowsUnattendConfig) Render(generation uint) ([]byte, error) {
+	data := struct {
+		*WindowsUnattendConfig
+		Generation uint
+		Commands   []unattendCommand
+	}{c, generation, c.firstLogonCommands()}
+
+	var buf bytes.Buffer
+	if err := unattendTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+var unattendTemplate = template.Must(template.New("unattend").Funcs(template.FuncMap{
+	"xml": xmlEscape,
+	"add": func(a, b int) int { return a + b },
+}).Parse(`<?xml version="1.0" encoding="utf-8"?>
+<unattend xmlns="urn:schemas-microsoft-com:unattend" xmlns:wcm="http://schemas.microsoft.com/WMIConfig/2002/State">
+    <settings pass="windowsPE">
+        <component name="Microsoft-Windows-International-Core-WinPE" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+            <SetupUILanguage>
+                <UILanguage>{{ xml .Locale }}</UILanguage>
+            </SetupUILanguage>
+            <InputLocale>{{ xml .InputLocale }}</InputLocale>
+            <SystemLocale>{{ xml .Locale }}</SystemLocale>
+            <UILanguage>{{ xml .Locale }}</UILanguage>
+            <UserLocale>{{ xml .Locale }}</UserLocale>
+        </component>
+        <component name="Microsoft-Windows-Setup" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+            <DiskConfiguration>
+                <Disk wcm:action="add">
+                    <DiskID>0</DiskID>
+                    <WillWipeDisk>true</WillWipeDisk>
+                    <CreatePartitions>
+{{- if eq .Generation 2 }}
+                        <CreatePartition wcm:action="add">
+                            <Order>1</Order>
+                            <Size>260</Size>
+                            <Type>EFI</Type>
+                        </CreatePartition>
+                        <CreatePartition wcm:action="add">
+                            <Order>2</Order>
+                            <Size>16</Size>
+                            <Type>MSR</Type>
+                        </CreatePartition>
+                        <CreatePartition wcm:action="add">
+                            <Order>3</Order>
+                            <Extend>true</Extend>
+                            <Type>Primary</Type>
+                        </CreatePartition>
+                    </CreatePartitions>
+                    <ModifyPartitions>
+                        <ModifyPartition wcm:action="add">
+                            <Order>1</Order>
+                            <PartitionID>1</PartitionID>
+                            <Format>FAT32</Format>
+                            <Label>System</Label>
+                        </ModifyPartition>
+                        <ModifyPartition wcm:action="add">
+                            <Order>2</Order>
+                            <PartitionID>3</PartitionID>
+                            <Format>NTFS</Format>
+                            <Label>Windows</Label>
+                            <Letter>C</Letter>
+                        </ModifyPartition>
+                    </ModifyPartitions>
+{{- else }}
+                        <CreatePartition wcm:action="add">
+                            <Order>1</Order>
+                            <Size>500</Size>
+                            <Type>Primary</Type>
+                        </CreatePartition>
+                        <CreatePartition wcm:action="add">
+                            <Order>2</Order>
+                            <Extend>true</Extend>
+                            <Type>Primary</Type>
+                        </CreatePartition>
+                    </CreatePartitions>
+                    <ModifyPartitions>
+                        <ModifyPartition wcm:action="add">
+                            <Order>1</Order>
+                            <PartitionID>1</PartitionID>
+                            <Active>true</Active>
+                            <Format>NTFS</Format>
+                            <Label>System Reserved</Label>
+                        </ModifyPartition>
+                        <ModifyPartition wcm:action="add">
+                            <Order>2</Order>
+                            <PartitionID>2</PartitionID>
+                            <Format>NTFS</Format>
+                            <Label>Windows</Label>
+                            <Letter>C</Letter>
+                        </ModifyPartition>
+                    </ModifyPartitions>
+{{- end }}
+                </Disk>
+            </DiskConfiguration>
+            <ImageInstall>
+                <OSImage>
+                    <InstallTo>
+                        <DiskID>0</DiskID>
+                        <PartitionID>{{ if eq .Generation 2 }}3{{ else }}2{{ end }}</PartitionID>
+                    </InstallTo>
+                    <InstallFrom>
+                        <MetaData wcm:action="add">
+{{- if .ImageName }}
+                            <Key>/IMAGE/NAME</Key>
+                            <Value>{{ xml .ImageName }}</Value>
+{{- else }}
+                            <Key>/IMAGE/INDEX</Key>
+                            <Value>{{ .ImageIndex }}</Value>
+{{- end }}
+                        </MetaData>
+                    </InstallFrom>
+                </OSImage>
+            </ImageInstall>
+            <UserData>
+                <AcceptEula>true</AcceptEula>
+{{- if .ProductKey }}
+                <ProductKey>
+                    <Key>{{ xml .ProductKey }}</Key>
+                </ProductKey>
+{{- end }}
+            </UserData>
+        </component>
+    </settings>
+    <settings pass="oobeSystem">
+        <component name="Microsoft-Windows-International-Core" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+            <InputLocale>{{ xml .InputLocale }}</InputLocale>
+            <SystemLocale>{{ xml .Locale }}</SystemLocale>
+            <UILanguage>{{ xml .Locale }}</UILanguage>
+            <UserLocale>{{ xml .Locale }}</UserLocale>
+        </component>
+        <component name="Microsoft-Windows-Shell-Setup" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+            <TimeZone>{{ xml .TimeZone }}</TimeZone>
+            <OOBE>
+                <HideEULAPage>true</HideEULAPage>
+                <HideLocalAccountScreen>true</HideLocalAccountScreen>
+                <HideOEMRegistrationScreen>true</HideOEMRegistrationScreen>
+                <HideOnlineAccountScreens>true</HideOnlineAccountScreens>
+                <HideWirelessSetupInOOBE>true</HideWirelessSetupInOOBE>
+                <ProtectYourPC>3</ProtectYourPC>
+            </OOBE>
+            <UserAccounts>
+                <AdministratorPassword>
+                    <Value>{{ xml .AdminPassword }}</Value>
+                    <PlainText>true</PlainText>
+                </AdministratorPassword>
+            </UserAccounts>
+{{- if gt .AutoLogonCount 0 }}
+            <AutoLogon>
+                <Enabled>true</Enabled>
+                <LogonCount>{{ .AutoLogonCount }}</LogonCount>
+                <Username>Administrator</Username>
+                <Password>
+                    <Value>{{ xml .AdminPassword }}</Value>
+                    <PlainText>true</PlainText>
+                </Password>
+            </AutoLogon>
+{{- end }}
+{{- if .Commands }}
+            <FirstLogonCommands>
+{{- range $i, $command := .Commands }}
+                <SynchronousCommand wcm:action="add">
+                    <Order>{{ add $i 1 }}</Order>
+                    <Description>{{ xml $command.Description }}</Description>
+                    <CommandLine>{{ xml $command.CommandLine }}</CommandLine>
+                </SynchronousCommand>
+{{- end }}
+            </FirstLogonCommands>
+{{- end }}
+        </component>
+    </settings>
+</unattend>
+`))
@@ -0,0 +1,53 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package common
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatWindowsUnattendConfig is an auto-generated flat version of WindowsUnattendConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatWindowsUnattendConfig struct {
+	AdminPassword      *string  `mapstructure:"admin_password" required:"true" cty:"admin_password" hcl:"admin_password"`
+	ImageIndex         *uint    `mapstructure:"image_index" required:"false" cty:"image_index" hcl:"image_index"`
+	ImageName          *string  `mapstructure:"image_name" required:"false" cty:"image_name" hcl:"image_name"`
+	ProductKey         *string  `mapstructure:"product_key" required:"false" cty:"product_key" hcl:"product_key"`
+	Locale             *string  `mapstructure:"locale" required:"false" cty:"locale" hcl:"locale"`
+	InputLocale        *string  `mapstructure:"input_locale" required:"false" cty:"input_locale" hcl:"input_locale"`
+	TimeZone           *string  `mapstructure:"time_zone" required:"false" cty:"time_zone" hcl:"time_zone"`
+	AutoLogonCount     *uint    `mapstructure:"auto_logon_count" required:"false" cty:"auto_logon_count" hcl:"auto_logon_count"`
+	EnableWinRM        *bool    `mapstructure:"enable_winrm" required:"false" cty:"enable_winrm" hcl:"enable_winrm"`
+	EnableOpenSSH      *bool    `mapstructure:"enable_openssh" required:"false" cty:"enable_openssh" hcl:"enable_openssh"`
+	EnablePSRP         *bool    `mapstructure:"enable_psrp" required:"false" cty:"enable_psrp" hcl:"enable_psrp"`
+	FirstLogonCommands []string `mapstructure:"first_logon_commands" required:"false" cty:"first_logon_commands" hcl:"first_logon_commands"`
+}
+
+// FlatMapstructure returns a new FlatWindowsUnattendConfig.
+// FlatWindowsUnattendConfig is an auto-generated flat version of WindowsUnattendConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*WindowsUnattendConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatWindowsUnattendConfig)
+}
+
+// HCL2Spec returns the hcl spec of a WindowsUnattendConfig.
+// This spec is used by HCL to read the fields of WindowsUnattendConfig.
+// The decoded values from this spec will then be applied to a FlatWindowsUnattendConfig.
+func (*FlatWindowsUnattendConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"admin_password":       &hcldec.AttrSpec{Name: "admin_password", Type: cty.String, Required: false},
+		"image_index":          &hcldec.AttrSpec{Name: "image_index", Type: cty.Number, Required: false},
+		"image_name":           &hcldec.AttrSpec{Name: "image_name", Type: cty.String, Required: false},
+		"product_key":          &hcldec.AttrSpec{Name: "product_key", Type: cty.String, Required: false},
+		"locale":               &hcldec.AttrSpec{Name: "locale", Type: cty.String, Required: false},
+		"input_locale":         &hcldec.AttrSpec{Name: "input_locale", Type: cty.String, Required: false},
+		"time_zone":            &hcldec.AttrSpec{Name: "time_zone", Type: cty.String, Required: false},
+		"auto_logon_count":     &hcldec.AttrSpec{Name: "auto_logon_count", Type: cty.Number, Required: false},
+		"enable_winrm":         &hcldec.AttrSpec{Name: "enable_winrm", Type: cty.Bool, Required: false},
+		"enable_openssh":       &hcldec.AttrSpec{Name: "enable_openssh", Type: cty.Bool, Required: false},
+		"enable_psrp":          &hcldec.AttrSpec{Name: "enable_psrp", Type: cty.Bool, Required: false},
+		"first_logon_commands": &hcldec.AttrSpec{Name: "first_logon_commands", Type: cty.List(cty.String), Required: false},
+	}
+	return s
+}
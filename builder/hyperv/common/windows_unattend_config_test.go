@@ -0,0 +1,119 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+func testWindowsUnattendConfig() WindowsUnattendConfig {
+	return WindowsUnattendConfig{
+		AdminPassword:      "P@ss<word>&1",
+		ImageName:          "Windows Server 2025 SERVERDATACENTERCORE",
+		ProductKey:         "D764K-2NDRG-47T6Q-P8T8W-YP6DF",
+		Locale:             "de-DE",
+		TimeZone:           "W. Europe Standard Time",
+		EnableWinRM:        true,
+		EnableOpenSSH:      true,
+		EnablePSRP:         true,
+		FirstLogonCommands: []string{`cmd.exe /c echo done > C:\packer.txt`},
+	}
+}
+
+func TestWindowsUnattendConfig_Prepare(t *testing.T) {
+	var c WindowsUnattendConfig
+	if errs := c.Prepare(); len(errs) > 0 {
+		t.Fatalf("Should NOT have errors when unset: %v", errs)
+	}
+	if c.IsSet() {
+		t.Fatal("Should NOT be set without admin_password")
+	}
+
+	c = WindowsUnattendConfig{AdminPassword: "secret", EnablePSRP: true}
+	if errs := c.Prepare(); len(errs) > 0 {
+		t.Fatalf("err: %v", errs)
+	}
+	if c.ImageIndex != 1 || c.Locale != DefaultUnattendLocale || c.InputLocale != DefaultUnattendLocale ||
+		c.TimeZone != DefaultUnattendTimeZone {
+		t.Fatalf("Bad defaults: %#v", c)
+	}
+	if c.AutoLogonCount != 1 {
+		t.Fatalf("Should log on once to run the first logon commands, got: %d", c.AutoLogonCount)
+	}
+
+	c = WindowsUnattendConfig{AdminPassword: "secret", ImageIndex: 2, ImageName: "Windows 11 Pro"}
+	if errs := c.Prepare(); len(errs) != 1 {
+		t.Fatalf("Should have an error for both image_index and image_name, got: %v", errs)
+	}
+}
+
+func TestWindowsUnattendConfig_Render(t *testing.T) {
+	for _, generation := range []uint{1, 2} {
+		c := testWindowsUnattendConfig()
+		if errs := c.Prepare(); len(errs) > 0 {
+			t.Fatalf("err: %v", errs)
+		}
+
+		unattend, err := c.Render(generation)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		// The answer file must be well-formed XML
+		decoder := xml.NewDecoder(bytes.NewReader(unattend))
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Generation %d answer file is not valid XML: %s", generation, err)
+			}
+		}
+
+		golden := filepath.Join("testdata", fmt.Sprintf("windows_unattend_gen%d.xml", generation))
+		if *updateGolden {
+			if err := os.WriteFile(golden, unattend, 0644); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+		expected, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !bytes.Equal(unattend, expected) {
+			t.Fatalf("Generation %d answer file doesn't match %s, got:\n%s", generation, golden, unattend)
+		}
+	}
+}
+
+func TestWindowsUnattendConfig_RenderMinimal(t *testing.T) {
+	c := WindowsUnattendConfig{AdminPassword: "secret"}
+	if errs := c.Prepare(); len(errs) > 0 {
+		t.Fatalf("err: %v", errs)
+	}
+
+	unattend, err := c.Render(2)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, element := range []string{"<ProductKey>", "<AutoLogon>", "<FirstLogonCommands>"} {
+		if strings.Contains(string(unattend), element) {
+			t.Fatalf("Should NOT contain %s when not configured", element)
+		}
+	}
+	if !strings.Contains(string(unattend), "<Key>/IMAGE/INDEX</Key>") {
+		t.Fatal("Should select the image by index")
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 	"github.com/hashicorp/packer-plugin-sdk/shutdowncommand"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
@@ -53,8 +54,17 @@ type Config struct {
 	// The timeout can be changed using the `shutdown_timeout` option.
 	DisableShutdown bool `mapstructure:"disable_shutdown" required:"false"`
 	// The size, in megabytes, of the hard disk to create
-	// for the VM. By default, this is 40 GB.
+	// for the VM. By default, this is 40 GB. When `iso_url` points to a
+	// VHD/VHDX, the copied or differencing disk is grown to this size
+	// instead, and left at the size of the source disk if unset. Disks are
+	// never shrunk.
 	DiskSize uint `mapstructure:"disk_size" required:"false"`
+	// If true, the last partition of a VHD/VHDX source is grown offline to
+	// fill the disk after it has been resized to `disk_size`. Only NTFS and
+	// ReFS partitions can be expanded from the host; other file systems are
+	// left for the guest to grow, e.g. with cloud-init's growpart module.
+	// This defaults to false.
+	ExpandPartition bool `mapstructure:"expand_partition" required:"false"`
 	// If true use a legacy network adapter as the NIC.
 	// This defaults to false. A legacy network adapter is fully emulated NIC, and is thus
 	// supported by various exotic operating systems, but this emulation requires
@@ -73,6 +83,10 @@ type Config struct {
 	// option is outputing a disk that is in the format required for upload to
 	// Azure.
 	FixedVHD bool `mapstructure:"use_fixed_vhd_format" required:"false"`
+	// Generate the `Autounattend.xml` answer file of an unattended Windows
+	// installation. See the [Windows Unattend](#windows-unattend) section
+	// for details.
+	WindowsUnattend hypervcommon.WindowsUnattendConfig `mapstructure:"windows_unattend" required:"false"`
 
 	ctx interpolate.Context
 }
@@ -87,6 +101,9 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"boot_command",
+				"cloud_init",
+				"kvp_items",
+				"linux_generalize",
 			},
 		},
 	}, raws...)
@@ -103,6 +120,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 	errs = packersdk.MultiErrorAppend(errs, isoErrs...)
 
 	errs = packersdk.MultiErrorAppend(errs, b.config.BootConfig.Prepare(&b.config.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, hypervcommon.CheckBootCommandScreenWaits(b.config.FlatBootCommand())...)
 	errs = packersdk.MultiErrorAppend(errs, b.config.HTTPConfig.Prepare(&b.config.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, b.config.OutputConfig.Prepare(&b.config.ctx, &b.config.PackerConfig)...)
 	errs = packersdk.MultiErrorAppend(errs, b.config.CommConfig.Prepare(&b.config.ctx)...)
@@ -112,14 +130,35 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 	errs = packersdk.MultiErrorAppend(errs, commonErrs...)
 	warnings = append(warnings, commonWarns...)
 
-	if len(b.config.ISOConfig.ISOUrls) < 1 ||
-		(strings.ToLower(filepath.Ext(b.config.ISOConfig.ISOUrls[0])) != ".vhd" &&
-			strings.ToLower(filepath.Ext(b.config.ISOConfig.ISOUrls[0])) != ".vhdx") {
+	if !b.isVhdSource() {
 		//We only create a new hard drive if an existing one to copy from does not exist
 		err = b.checkDiskSize()
 		if err != nil {
 			errs = packersdk.MultiErrorAppend(errs, err)
 		}
+	} else if b.config.DiskSize > hypervcommon.MaxDiskSize {
+		// The size of the source disk is only known once it has been
+		// copied, so growing instead of shrinking is checked when resizing
+		err = fmt.Errorf("disk_size: virtual machine requires disk space <= %v GB, but defined: %v GB",
+			hypervcommon.MaxDiskSize/1024, b.config.DiskSize/1024)
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+
+	if b.config.OfflineCustomization.IsSet() && !b.isVhdSource() {
+		err = errors.New("offline_customization requires a VHD/VHDX iso_url.")
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+
+	errs = packersdk.MultiErrorAppend(errs, b.config.WindowsUnattend.Prepare()...)
+	if b.config.WindowsUnattend.IsSet() {
+		if err := b.attachWindowsUnattend(); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, err)
+		}
+	}
+
+	if b.config.ExpandPartition && (!b.isVhdSource() || b.config.DiskSize == 0) {
+		err = errors.New("expand_partition requires a VHD/VHDX iso_url and disk_size to be set.")
+		errs = packersdk.MultiErrorAppend(errs, err)
 	}
 
 	if b.config.Cpu < 1 {
@@ -150,9 +189,35 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		errs = packersdk.MultiErrorAppend(errs, err)
 	}
 
+	if b.config.ShutdownCommand == "" && !b.config.DisableShutdown &&
+		hypervcommon.HasShutdownMethod(b.config.ShutdownMode, hypervcommon.ShutdownMethodCommand) {
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("shutdown_mode: the command method requires shutdown_command or disable_shutdown"))
+	}
+
+	if b.config.WindowsGeneralize.IsSet() {
+		if b.config.DisableShutdown {
+			errs = packersdk.MultiErrorAppend(errs,
+				fmt.Errorf("windows_generalize and disable_shutdown can't be used together"))
+		}
+		if b.config.Comm.Type == "none" {
+			errs = packersdk.MultiErrorAppend(errs,
+				fmt.Errorf("windows_generalize needs a communicator to launch sysprep"))
+		}
+	}
+	if b.config.LinuxGeneralize.IsSet() && b.config.Comm.Type == "none" {
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("linux_generalize needs a communicator to run the generalization script"))
+	}
+	if b.config.InstallComplete.Strategy == hypervcommon.InstallCompleteTCP && b.config.CommConfig.UsesHvsock() {
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("install_complete strategy %q needs the IP address of the guest, which the hvsock "+
+				"transport doesn't wait for; use another strategy", hypervcommon.InstallCompleteTCP))
+	}
+
 	// Warnings
 
-	if b.config.ShutdownCommand == "" {
+	if b.config.ShutdownCommand == "" && !b.config.WindowsGeneralize.IsSet() {
 		warnings = append(warnings,
 			"A shutdown_command was not specified. Without a shutdown command, Packer\n"+
 				"will forcibly halt the virtual machine, which may result in data loss.")
@@ -162,7 +227,12 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		return nil, warnings, errs
 	}
 
-	return nil, warnings, nil
+	// VMName lets provisioners such as hyperv-copy find the VM of the build
+	generatedData := append([]string{"VMName"}, hypervcommon.GuestFacts...)
+	if b.config.LinuxGeneralize.IsSet() {
+		generatedData = append(generatedData, hypervcommon.LinuxGeneralizeData...)
+	}
+	return generatedData, warnings, nil
 }
 
 // Run executes a Packer build and returns a packersdk.Artifact representing
@@ -181,10 +251,29 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	state.Put("hook", hook)
 	state.Put("ui", ui)
 
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("VMName", b.config.VMName)
+
+	customConnect := map[string]multistep.Step{
+		"psrp": &psrp.StepConnect{
+			Config: &b.config.CommConfig.PSRP,
+			Host:   hypervcommon.PSRPHost(&b.config.CommConfig),
+		},
+	}
+	if b.config.Comm.Type == "ssh" && b.config.SSHTransport == hypervcommon.SSHTransportHvsock {
+		customConnect["ssh"] = &hypervcommon.StepConnectHvsockSSH{
+			Config:    &b.config.CommConfig.Comm,
+			SSHConfig: b.config.CommConfig.Comm.SSHConfigFunc(),
+		}
+	}
+
 	steps := []multistep.Step{
 		&hypervcommon.StepCreateBuildDir{
-			TempPath:       b.config.TempPath,
-			KeepRegistered: b.config.KeepRegistered,
+			TempPath:        b.config.TempPath,
+			VhdPath:         b.config.VhdPath,
+			CheckpointPath:  b.config.CheckpointPath,
+			SmartPagingPath: b.config.SmartPagingPath,
+			KeepRegistered:  b.config.KeepRegistered,
 		},
 		&commonsteps.StepOutputDir{
 			Force: b.config.PackerForce,
@@ -193,6 +282,7 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		&hypervcommon.StepValidateHost{
 			EnableVirtualizationExtensions: b.config.EnableVirtualizationExtensions,
 			RamSize:                        b.config.RamSize,
+			Cpu:                            b.config.Cpu,
 		},
 		&commonsteps.StepDownload{
 			Checksum:    b.config.ISOChecksum,
@@ -208,7 +298,10 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Content:     b.config.FloppyConfig.FloppyContent,
 			Label:       b.config.FloppyConfig.FloppyLabel,
 		},
-		commonsteps.HTTPServerFromHTTPConfig(&b.config.HTTPConfig),
+		&hypervcommon.StepHTTPServer{
+			Config:       &b.config.HTTPConfig,
+			CallbackPath: b.config.InstallComplete.CallbackPath(),
+		},
 		&hypervcommon.StepCreateSwitch{
 			SwitchName: b.config.SwitchName,
 		},
@@ -220,8 +313,13 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			DiskBlockSize:                  b.config.DiskBlockSize,
 			Generation:                     b.config.Generation,
 			Cpu:                            b.config.Cpu,
+			Processor:                      b.config.Processor,
 			EnableMacSpoofing:              b.config.EnableMacSpoofing,
 			EnableDynamicMemory:            b.config.EnableDynamicMemory,
+			MemoryMinimum:                  b.config.MemoryMinimum,
+			MemoryMaximum:                  b.config.MemoryMaximum,
+			MemoryBuffer:                   b.config.MemoryBuffer,
+			MemoryWeight:                   b.config.MemoryWeight,
 			EnableSecureBoot:               b.config.EnableSecureBoot,
 			SecureBootTemplate:             b.config.SecureBootTemplate,
 			EnableVirtualizationExtensions: b.config.EnableVirtualizationExtensions,
@@ -233,8 +331,23 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			FixedVHD:                       b.config.FixedVHD,
 			Version:                        b.config.Version,
 			KeepRegistered:                 b.config.KeepRegistered,
+			MinimumIOPS:                    b.config.MinimumIOPS,
+			MaximumIOPS:                    b.config.MaximumIOPS,
+			QoSPolicyID:                    b.config.QoSPolicyID,
+			DiskCacheAttributes:            b.config.DiskCacheAttributes,
+			CheckpointType:                 b.config.CheckpointType,
+		},
+		&hypervcommon.StepResizeVhd{
+			DiskSize:        b.vhdSourceDiskSize(),
+			GrowOnly:        true,
+			ExpandPartition: b.config.ExpandPartition,
+		},
+		&hypervcommon.StepOfflineCustomization{
+			Config: b.config.OfflineCustomization,
+		},
+		&hypervcommon.StepConfigureIntegrationServices{
+			Services: b.config.IntegrationServices,
 		},
-		&hypervcommon.StepEnableIntegrationService{},
 
 		&hypervcommon.StepMountDvdDrive{
 			Generation:      b.config.Generation,
@@ -254,6 +367,20 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Content: b.config.CDConfig.CDContent,
 			Label:   b.config.CDConfig.CDLabel,
 		},
+		&hypervcommon.StepResolveHostIP{
+			SwitchName: b.config.SwitchName,
+			SkipHostIP: b.config.CommConfig.UsesHvsock(),
+		},
+		multistep.If(b.config.Comm.Type == "ssh" && b.config.CloudInit.UsesSSHPublicKey(),
+			&communicator.StepSSHKeyGen{
+				CommConf:            &b.config.CommConfig.Comm,
+				SSHTemporaryKeyPair: b.config.CommConfig.Comm.SSH.SSHTemporaryKeyPair,
+			}),
+		&hypervcommon.StepCreateCloudInitSeed{
+			Config: b.config.CloudInit,
+			Comm:   &b.config.CommConfig.Comm,
+			Ctx:    b.config.ctx,
+		},
 		&hypervcommon.StepMountSecondaryDvdImages{
 			IsoPaths:   b.config.SecondaryDvdImages,
 			Generation: b.config.Generation,
@@ -271,19 +398,47 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Generation:      b.config.Generation,
 			FirstBootDevice: b.config.FirstBootDevice,
 		},
+		&hypervcommon.StepStartSerialLog{
+			Config:    b.config.SerialLog,
+			OutputDir: b.config.OutputDir,
+		},
 
+		&hypervcommon.StepSetHostKvpItems{
+			Items: b.config.KVPItems,
+			Ctx:   b.config.ctx,
+		},
 		&hypervcommon.StepRun{
-			Headless:   b.config.Headless,
-			SwitchName: b.config.SwitchName,
-			SkipHostIP: b.config.Comm.Type == "psrp" && b.config.PSRPTransport == "hvsock",
+			Headless: b.config.Headless,
+		},
+
+		&hypervcommon.StepScreenshot{
+			OutputDir: b.config.OutputDir,
+			Interval:  b.config.ScreenshotInterval,
 		},
 
 		&hypervcommon.StepTypeBootCommand{
-			BootCommand:   b.config.FlatBootCommand(),
-			BootWait:      b.config.BootWait,
-			SwitchName:    b.config.SwitchName,
-			Ctx:           b.config.ctx,
-			GroupInterval: b.config.BootConfig.BootGroupInterval,
+			BootCommand:    b.config.FlatBootCommand(),
+			BootWait:       b.config.BootWait,
+			SwitchName:     b.config.SwitchName,
+			Ctx:            b.config.ctx,
+			GroupInterval:  b.config.BootConfig.BootGroupInterval,
+			KeyboardLayout: b.config.BootKeyboardLayout,
+			KeyInterval:    b.config.BootKeyInterval,
+		},
+
+		&hypervcommon.StepWaitForInstallToComplete{
+			Config: b.config.InstallComplete,
+			Host:   hypervcommon.CommHost(b.config.Comm.Host()),
+		},
+		// Taken before the guest files and the communicator change the guest
+		&hypervcommon.StepCheckpoint{
+			Phase:       hypervcommon.CheckpointPhaseOsInstalled,
+			Checkpoints: b.config.Checkpoints,
+		},
+
+		&hypervcommon.StepCopyGuestFiles{
+			Files:   b.config.GuestFiles,
+			Timeout: b.config.GuestFilesTimeout,
 		},
 
 		&hypervcommon.StepConfigurePSRP{
@@ -292,15 +447,16 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 
 		// configure the communicator ssh, winrm, or psrp
 		&communicator.StepConnect{
-			Config:    &b.config.CommConfig.Comm,
-			Host:      hypervcommon.CommHost(b.config.CommConfig.Comm.Host()),
-			SSHConfig: b.config.CommConfig.Comm.SSHConfigFunc(),
-			CustomConnect: map[string]multistep.Step{
-				"psrp": &psrp.StepConnect{
-					Config: &b.config.CommConfig.PSRP,
-					Host:   hypervcommon.PSRPHost(&b.config.CommConfig),
-				},
-			},
+			Config:        &b.config.CommConfig.Comm,
+			Host:          hypervcommon.SSHHost(&b.config.CommConfig),
+			SSHConfig:     b.config.CommConfig.Comm.SSHConfigFunc(),
+			CustomConnect: customConnect,
+		},
+		&hypervcommon.StepPublishGuestFacts{},
+
+		&hypervcommon.StepCheckpoint{
+			Phase:       hypervcommon.CheckpointPhaseBeforeProvisioning,
+			Checkpoints: b.config.Checkpoints,
 		},
 
 		// provision requires communicator to be setup
@@ -311,23 +467,51 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Comm: &b.config.CommConfig.Comm,
 		},
 
-		&hypervcommon.StepShutdown{
+		&hypervcommon.StepLinuxGeneralize{
+			Config: b.config.LinuxGeneralize,
+			Comm:   &b.config.CommConfig.Comm,
+			Ctx:    b.config.ctx,
+		},
+
+		multistep.If(!b.config.WindowsGeneralize.IsSet(), &hypervcommon.StepShutdown{
 			Command:         b.config.ShutdownCommand,
 			Timeout:         b.config.ShutdownTimeout,
 			DisableShutdown: b.config.DisableShutdown,
-		},
+			Mode:            b.config.ShutdownMode,
+		}),
+		multistep.If(b.config.WindowsGeneralize.IsSet(), &hypervcommon.StepWindowsGeneralize{
+			Config:  b.config.WindowsGeneralize,
+			Timeout: b.config.ShutdownTimeout,
+		}),
 
 		// wait for the vm to be powered off
 		&hypervcommon.StepWaitForPowerOff{},
+		&hypervcommon.StepStopSerialLog{},
 
 		// remove the secondary dvd images
 		// after we power down
 		&hypervcommon.StepUnmountSecondaryDvdImages{},
+		&hypervcommon.StepDeleteCloudInitSeed{},
+		&hypervcommon.StepRemoveHostKvpItems{},
 		&hypervcommon.StepUnmountGuestAdditions{},
 		&hypervcommon.StepUnmountDvdDrive{},
 		&hypervcommon.StepUnmountFloppyDrive{
 			Generation: b.config.Generation,
 		},
+		&hypervcommon.StepConfigureIntegrationServices{
+			Services: b.config.ExportIntegrationServices,
+		},
+		&hypervcommon.StepExportCleanup{
+			Enabled: b.config.ExportCleanup,
+		},
+		&hypervcommon.StepConfigureExportHardware{
+			Config: b.config.ExportHardware,
+		},
+		&hypervcommon.StepCheckpoint{
+			Phase:       hypervcommon.CheckpointPhaseAfterProvisioning,
+			Checkpoints: b.config.Checkpoints,
+		},
+		&hypervcommon.StepRemoveCheckpoints{},
 		&hypervcommon.StepCompactDisk{
 			SkipCompaction: b.config.SkipCompaction,
 		},
@@ -336,8 +520,9 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			SkipExport: b.config.SkipExport,
 		},
 		&hypervcommon.StepCollateArtifacts{
-			OutputDir:  b.config.OutputDir,
-			SkipExport: b.config.SkipExport,
+			OutputDir:    b.config.OutputDir,
+			SkipExport:   b.config.SkipExport,
+			ChecksumType: b.config.ManifestChecksumType,
 		},
 
 		// the clean up actions for each step will be executed reverse order
@@ -360,12 +545,71 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	if _, ok := state.GetOk(multistep.StateHalted); ok {
 		return nil, errors.New("build was halted.")
 	}
-	generatedData := map[string]interface{}{"generated_data": state.Get("generated_data")}
-	return hypervcommon.NewArtifact(b.config.OutputDir, generatedData)
+	artifactData := map[string]interface{}{
+		"generated_data": state.Get("generated_data"),
+		"manifest":       state.Get("manifest"),
+	}
+	return hypervcommon.NewArtifact(b.config.OutputDir, artifactData)
 }
 
 // Cancel.
 
+// isVhdSource reports whether the VM boots from a copy of an existing
+// VHD/VHDX rather than from an ISO.
+func (b *Builder) isVhdSource() bool {
+	if len(b.config.ISOConfig.ISOUrls) < 1 {
+		return false
+	}
+
+	extension := strings.ToLower(filepath.Ext(b.config.ISOConfig.ISOUrls[0]))
+	return extension == ".vhd" || extension == ".vhdx"
+}
+
+// vhdSourceDiskSize returns the size to grow a VHD/VHDX source to, or nil
+// if the disk is to be left alone.
+func (b *Builder) vhdSourceDiskSize() *uint {
+	if !b.isVhdSource() || b.config.DiskSize == 0 {
+		return nil
+	}
+
+	diskSize := b.config.DiskSize
+	return &diskSize
+}
+
+// attachWindowsUnattend adds the generated answer file to the CD on
+// generation 2 VMs, which have no floppy drive, and to the floppy disk on
+// generation 1 VMs.
+func (b *Builder) attachWindowsUnattend() error {
+	unattend, err := b.config.WindowsUnattend.Render(b.config.Generation)
+	if err != nil {
+		return fmt.Errorf("windows_unattend: %s", err)
+	}
+
+	content, files, option := &b.config.CDContent, b.config.CDFiles, "cd"
+	if b.config.Generation < 2 {
+		content, files, option = &b.config.FloppyContent, b.config.FloppyFiles, "floppy"
+	}
+
+	for name := range *content {
+		if strings.EqualFold(filepath.Base(name), hypervcommon.UnattendFileName) {
+			return fmt.Errorf("windows_unattend can't be used with an %s in %s_content",
+				hypervcommon.UnattendFileName, option)
+		}
+	}
+	for _, file := range files {
+		if strings.EqualFold(filepath.Base(file), hypervcommon.UnattendFileName) {
+			return fmt.Errorf("windows_unattend can't be used with an %s in %s_files",
+				hypervcommon.UnattendFileName, option)
+		}
+	}
+
+	if *content == nil {
+		*content = make(map[string]string)
+	}
+	(*content)[hypervcommon.UnattendFileName] = string(unattend)
+	return nil
+}
+
 func (b *Builder) checkDiskSize() error {
 	if b.config.DiskSize == 0 {
 		b.config.DiskSize = hypervcommon.DefaultDiskSize
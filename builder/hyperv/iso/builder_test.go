@@ -9,9 +9,12 @@ package iso
 import (
 	"context"
 	"fmt"
+	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	hypervcommon "github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common"
 	"github.com/hashicorp/packer-plugin-sdk/common"
@@ -589,6 +592,40 @@ func TestBuilderPrepare_CommConfig(t *testing.T) {
 		}
 	}
 
+	// Test SSH over Hyper-V sockets
+	{
+		config := testConfig()
+		config["communicator"] = "ssh"
+		config["ssh_username"] = "username"
+		config["ssh_password"] = "password"
+		config["ssh_transport"] = "hvsock"
+
+		var b Builder
+		_, _, err := b.Prepare(config)
+		if err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+		if !b.config.CommConfig.UsesHvsock() {
+			t.Error("should use hvsock")
+		}
+
+		// Hyper-V sockets can't go through a bastion host
+		config["ssh_bastion_host"] = "bastion.example.com"
+		b = Builder{}
+		_, _, err = b.Prepare(config)
+		if err == nil {
+			t.Fatal("should have error")
+		}
+
+		delete(config, "ssh_bastion_host")
+		config["ssh_transport"] = "vsock"
+		b = Builder{}
+		_, _, err = b.Prepare(config)
+		if err == nil {
+			t.Fatal("should have error")
+		}
+	}
+
 }
 
 func TestUserVariablesInBootCommand(t *testing.T) {
@@ -660,3 +697,908 @@ func TestBuilderPrepare_UseLegacyNetworkAdapter(t *testing.T) {
 		t.Fatal("should have error")
 	}
 }
+
+func TestBuilderPrepare_DiskSizeWithExistingHarddrive(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	delete(config, "iso_url")
+	config["iso_urls"] = []string{"http://www.packer.io/hdd.vhdx"}
+
+	// Left at the size of the source disk when unset
+	delete(config, "disk_size")
+	_, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.DiskSize != 0 {
+		t.Fatalf("disk_size should not be defaulted. Got: %d", b.config.DiskSize)
+	}
+	if b.vhdSourceDiskSize() != nil {
+		t.Fatal("should not resize the source disk")
+	}
+
+	// Grown when set
+	config["disk_size"] = 131072
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if size := b.vhdSourceDiskSize(); size == nil || *size != 131072 {
+		t.Fatalf("should resize the source disk. Got: %v", size)
+	}
+
+	// Still bounded by the maximum vhdx size
+	config["disk_size"] = hypervcommon.MaxDiskSize + 1
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_ExpandPartition(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	config["expand_partition"] = true
+
+	// Requires a vhd source
+	_, _, err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Requires disk_size
+	delete(config, "iso_url")
+	delete(config, "disk_size")
+	config["iso_urls"] = []string{"http://www.packer.io/hdd.vhdx"}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	config["disk_size"] = 131072
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_DiskPerformance(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["minimum_iops"] = 100
+	config["maximum_iops"] = 500
+	config["disk_cache_attributes"] = "writecachedisabled"
+	_, warns, err := b.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.DiskCacheAttributes != "WriteCacheDisabled" {
+		t.Fatalf("disk_cache_attributes should be normalized. Got: %s", b.config.DiskCacheAttributes)
+	}
+
+	// Minimum above maximum
+	config["minimum_iops"] = 1000
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Unknown cache attributes
+	config["minimum_iops"] = 100
+	config["disk_cache_attributes"] = "WriteBack"
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Policy combined with IOPS
+	delete(config, "disk_cache_attributes")
+	config["qos_policy_id"] = "2f3d2a0c-8e5c-4a1b-9d6f-6f0e4b7c1a2d"
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Policy on its own
+	delete(config, "minimum_iops")
+	delete(config, "maximum_iops")
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Malformed policy ID
+	config["qos_policy_id"] = "\"; Remove-Item C:\\ -Recurse; \""
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_OfflineCustomization(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	source, err := os.CreateTemp("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	source.Close()
+	defer os.Remove(source.Name())
+
+	config["offline_customization"] = map[string]interface{}{
+		"files": []map[string]interface{}{
+			{"source": source.Name(), "destination": "Windows/Panther/unattend.xml"},
+		},
+	}
+
+	// Requires a vhd source
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	delete(config, "iso_url")
+	config["iso_urls"] = []string{"http://www.packer.io/hdd.vhdx"}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(b.config.OfflineCustomization.Files) != 1 {
+		t.Fatalf("bad: %#v", b.config.OfflineCustomization)
+	}
+
+	for _, destination := range []string{"", `C:\Windows`, "/etc/hosts", `..\outside`, "a/../../b"} {
+		config["offline_customization"] = map[string]interface{}{
+			"files": []map[string]interface{}{
+				{"source": source.Name(), "destination": destination},
+			},
+		}
+		b = Builder{}
+		_, _, err = b.Prepare(config)
+		if err == nil {
+			t.Fatalf("should have error for destination %q", destination)
+		}
+	}
+
+	config["offline_customization"] = map[string]interface{}{
+		"files": []map[string]interface{}{
+			{"source": source.Name() + "-missing", "destination": "unattend.xml"},
+		},
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error for a missing source")
+	}
+}
+
+func TestBuilderPrepare_DynamicMemory(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["memory"] = 2048
+	config["enable_dynamic_memory"] = true
+	config["memory_minimum"] = 1024
+	config["memory_maximum"] = 8192
+	config["memory_buffer"] = 10
+	config["memory_weight"] = 0
+	_, warns, err := b.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.MemoryWeight == nil || *b.config.MemoryWeight != 0 {
+		t.Fatalf("memory_weight should be set to 0. Got: %v", b.config.MemoryWeight)
+	}
+
+	// Minimum above startup memory
+	config["memory_minimum"] = 4096
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Maximum below startup memory
+	config["memory_minimum"] = 1024
+	config["memory_maximum"] = 1024
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Buffer out of range
+	config["memory_maximum"] = 8192
+	config["memory_buffer"] = 1
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Weight out of range
+	config["memory_buffer"] = 10
+	config["memory_weight"] = 101
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Dynamic memory disabled
+	config["memory_weight"] = 50
+	config["enable_dynamic_memory"] = false
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Nested virtualization
+	config["enable_dynamic_memory"] = true
+	config["enable_virtualization_extensions"] = true
+	config["enable_mac_spoofing"] = true
+	config["memory"] = 4096
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_Processor(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["processor"] = map[string]interface{}{
+		"reserve":                             10,
+		"maximum":                             75,
+		"relative_weight":                     200,
+		"hw_thread_count_per_core":            0,
+		"compatibility_for_migration_enabled": true,
+	}
+	_, warns, err := b.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.Processor.HwThreadCountPerCore == nil || *b.config.Processor.HwThreadCountPerCore != 0 {
+		t.Fatalf("hw_thread_count_per_core should be set to 0. Got: %v", b.config.Processor.HwThreadCountPerCore)
+	}
+
+	// Reserve above maximum
+	config["processor"] = map[string]interface{}{
+		"reserve": 80,
+		"maximum": 75,
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Weight out of range
+	config["processor"] = map[string]interface{}{
+		"relative_weight": 10001,
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Virtualization extensions set in the block
+	config["processor"] = map[string]interface{}{
+		"expose_virtualization_extensions": true,
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !b.config.EnableVirtualizationExtensions {
+		t.Fatal("expose_virtualization_extensions should enable virtualization extensions")
+	}
+}
+
+func TestBuilderPrepare_IntegrationServices(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// The Guest Service Interface is enabled by default
+	_, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if enabled, ok := b.config.IntegrationServices["Guest Service Interface"]; !ok || !enabled {
+		t.Fatalf("Guest Service Interface should be enabled. Got: %#v", b.config.IntegrationServices)
+	}
+
+	config["integration_services"] = map[string]bool{
+		"time synchronization":    false,
+		"Guest Service Interface": false,
+	}
+	config["export_integration_services"] = map[string]bool{
+		"Time Synchronization": true,
+		"heartbeat":            false,
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	expected := map[string]bool{
+		"Time Synchronization":    false,
+		"Guest Service Interface": false,
+	}
+	if !reflect.DeepEqual(b.config.IntegrationServices, expected) {
+		t.Fatalf("bad integration_services: %#v", b.config.IntegrationServices)
+	}
+	expected = map[string]bool{
+		"Time Synchronization": true,
+		"Heartbeat":            false,
+	}
+	if !reflect.DeepEqual(b.config.ExportIntegrationServices, expected) {
+		t.Fatalf("bad export_integration_services: %#v", b.config.ExportIntegrationServices)
+	}
+
+	// Unknown service
+	config["export_integration_services"] = map[string]bool{"Clipboard": true}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_GuestFiles(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	source, err := os.CreateTemp("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	source.Close()
+	defer os.Remove(source.Name())
+
+	config["guest_files"] = []map[string]interface{}{
+		{"source": source.Name(), "destination": `C:\payload.zip`},
+	}
+	_, _, err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.GuestFilesTimeout != hypervcommon.DefaultGuestFilesTimeout {
+		t.Fatalf("bad guest_files_timeout: %s", b.config.GuestFilesTimeout)
+	}
+
+	// The Guest Service Interface is required
+	config["integration_services"] = map[string]bool{"Guest Service Interface": false}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Missing destination
+	delete(config, "integration_services")
+	config["guest_files"] = []map[string]interface{}{
+		{"source": source.Name()},
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_SerialLog(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["serial_log"] = map[string]interface{}{
+		"port": 1,
+	}
+	_, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.SerialLog.File != hypervcommon.DefaultSerialLogFile {
+		t.Fatalf("bad serial_log file: %s", b.config.SerialLog.File)
+	}
+
+	// Only COM1 and COM2 exist
+	config["serial_log"] = map[string]interface{}{
+		"port": 3,
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// The log must stay in the output directory
+	config["serial_log"] = map[string]interface{}{
+		"port": 1,
+		"file": "../serial.log",
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_Checkpoints(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["checkpoint_type"] = "productiononly"
+	config["checkpoints"] = []map[string]interface{}{
+		{"phase": "os_installed", "name": "clean-os", "keep": true},
+		{"phase": "after_provisioning"},
+	}
+	_, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.CheckpointType != "ProductionOnly" {
+		t.Fatalf("bad checkpoint_type: %s", b.config.CheckpointType)
+	}
+	if b.config.Checkpoints[1].Name != "packer-after_provisioning" {
+		t.Fatalf("bad checkpoint name: %s", b.config.Checkpoints[1].Name)
+	}
+
+	config["checkpoint_type"] = "Automatic"
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	delete(config, "checkpoint_type")
+
+	for _, checkpoints := range [][]map[string]interface{}{
+		{{"phase": "after_install"}},
+		{{"phase": "os_installed", "name": "base"}, {"phase": "after_provisioning", "name": "base"}},
+	} {
+		config["checkpoints"] = checkpoints
+		b = Builder{}
+		_, _, err = b.Prepare(config)
+		if err == nil {
+			t.Fatalf("should have error: %v", checkpoints)
+		}
+	}
+
+	// Kept checkpoints are only available in an exported VM
+	config["checkpoints"] = []map[string]interface{}{
+		{"phase": "os_installed", "keep": true},
+	}
+	config["skip_export"] = true
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	delete(config, "skip_export")
+
+	// Checkpoints of the running VM would restore the build state
+	config["export_cleanup"] = true
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	config["checkpoints"] = []map[string]interface{}{
+		{"phase": "after_provisioning", "keep": true},
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_ScreenshotInterval(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["screenshot_interval"] = "30s"
+	_, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.ScreenshotInterval != 30*time.Second {
+		t.Fatalf("bad screenshot_interval: %s", b.config.ScreenshotInterval)
+	}
+
+	config["screenshot_interval"] = "-1s"
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_BootCommandScreenWaits(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["boot_command"] = []string{"<waitIdle 5s><enter>"}
+	_, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	config["boot_command"] = []string{`<waitScreen "does-not-exist.png" 30s><enter>`}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_BootKeyboardLayout(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	_, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.BootKeyboardLayout != hypervcommon.DefaultKeyboardLayout {
+		t.Fatalf("bad boot_keyboard_layout: %s", b.config.BootKeyboardLayout)
+	}
+
+	config["boot_keyboard_layout"] = "de"
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	config["boot_keyboard_layout"] = "dvorak"
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_BootKeyInterval(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["boot_key_interval"] = "50ms"
+	_, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.BootKeyInterval != 50*time.Millisecond {
+		t.Fatalf("bad boot_key_interval: %s", b.config.BootKeyInterval)
+	}
+
+	config["boot_key_interval"] = "-1ms"
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_WindowsUnattend(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["windows_unattend"] = map[string]interface{}{
+		"admin_password": "packer",
+		"enable_winrm":   true,
+	}
+	config["generation"] = 2
+	_, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !strings.Contains(b.config.CDContent[hypervcommon.UnattendFileName], "<PartitionID>3</PartitionID>") {
+		t.Fatalf("generation 2 should get the GPT answer file on the CD: %v", b.config.CDContent)
+	}
+
+	config["generation"] = 1
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !strings.Contains(b.config.FloppyContent[hypervcommon.UnattendFileName], "<PartitionID>2</PartitionID>") {
+		t.Fatalf("generation 1 should get the MBR answer file on the floppy: %v", b.config.FloppyContent)
+	}
+	if len(b.config.CDContent) > 0 {
+		t.Fatalf("generation 1 should not get a CD: %v", b.config.CDContent)
+	}
+
+	// The generated answer file would replace the one given
+	config["floppy_content"] = map[string]string{
+		"autounattend.xml": "<unattend/>",
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	delete(config, "floppy_content")
+
+	config["windows_unattend"] = map[string]interface{}{
+		"admin_password": "packer",
+		"image_index":    2,
+		"image_name":     "Windows 11 Pro",
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_CloudInit(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["cloud_init"] = map[string]interface{}{
+		"user_data": "#cloud-config\nruncmd: [curl http://{{ .HTTPIP }}:{{ .HTTPPort }}/]\n",
+	}
+	_, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !strings.Contains(b.config.CloudInit.UserData, "{{ .HTTPIP }}") {
+		t.Fatalf("user_data should be rendered when the VM starts: %s", b.config.CloudInit.UserData)
+	}
+	if b.config.CloudInit.MetaData != hypervcommon.DefaultCloudInitMetaData {
+		t.Fatalf("bad meta_data: %s", b.config.CloudInit.MetaData)
+	}
+
+	config["cloud_init"] = map[string]interface{}{
+		"user_data": "#cloud-config\nhostname: {{ .Name",
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_InstallComplete(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["install_complete"] = map[string]interface{}{
+		"strategy": "http",
+		"timeout":  "90m",
+	}
+	_, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.InstallComplete.CallbackPath() != hypervcommon.DefaultInstallCompletePath {
+		t.Fatalf("bad install_complete path: %s", b.config.InstallComplete.Path)
+	}
+	if b.config.InstallComplete.Timeout != 90*time.Minute {
+		t.Fatalf("bad install_complete timeout: %s", b.config.InstallComplete.Timeout)
+	}
+
+	config["install_complete"] = map[string]interface{}{
+		"strategy": "tcp",
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_InstallCompleteTCPHvsock(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["install_complete"] = map[string]interface{}{
+		"strategy": "tcp",
+		"port":     22,
+	}
+	_, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// The guest may never get an IP address over hvsock
+	config["ssh_transport"] = "hvsock"
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil || !strings.Contains(err.Error(), "install_complete") {
+		t.Fatalf("should have install_complete error: %v", err)
+	}
+}
+
+func TestBuilderPrepare_KVPItems(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["kvp_items"] = map[string]interface{}{
+		"PackerHTTPURL": "http://{{ .HTTPIP }}:{{ .HTTPPort }}",
+	}
+	generatedData, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	// The templates are rendered when the items are sent
+	if b.config.KVPItems["PackerHTTPURL"] != "http://{{ .HTTPIP }}:{{ .HTTPPort }}" {
+		t.Fatalf("bad kvp_items: %#v", b.config.KVPItems)
+	}
+	if len(generatedData) != 1+len(hypervcommon.GuestFacts) {
+		t.Fatalf("bad generated data: %#v", generatedData)
+	}
+
+	config["kvp_items"] = map[string]interface{}{
+		"PackerHTTPURL": "{{ .HTTPIP",
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_ShutdownMode(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["shutdown_command"] = "shutdown -P now"
+	config["shutdown_mode"] = []map[string]interface{}{
+		{"method": "command", "timeout": "10m"},
+		{"method": "Turn_Off"},
+	}
+	_, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.ShutdownMode[1].Method != hypervcommon.ShutdownMethodTurnOff {
+		t.Fatalf("bad shutdown_mode: %#v", b.config.ShutdownMode)
+	}
+
+	// Stages escalate
+	config["shutdown_mode"] = []map[string]interface{}{
+		{"method": "turn_off"},
+		{"method": "integration_service"},
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// The command stage needs a command
+	delete(config, "shutdown_command")
+	config["shutdown_mode"] = []map[string]interface{}{
+		{"method": "command"},
+		{"method": "turn_off"},
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_WindowsGeneralize(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["windows_generalize"] = map[string]interface{}{
+		"mode": "oobe",
+	}
+	_, warns, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	for _, warn := range warns {
+		if strings.Contains(warn, "shutdown_command") {
+			t.Fatalf("should not warn about shutdown_command: %s", warn)
+		}
+	}
+
+	config["disable_shutdown"] = true
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	delete(config, "disable_shutdown")
+	config["shutdown_mode"] = []map[string]interface{}{
+		{"method": "turn_off"},
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_LinuxGeneralize(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["linux_generalize"] = map[string]interface{}{
+		"enabled":         true,
+		"execute_command": "echo '{{ .Password }}' | sudo -S sh '{{ .Path }}'",
+	}
+	generatedData, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	// The command is rendered when the script runs
+	if b.config.LinuxGeneralize.ExecuteCommand != "echo '{{ .Password }}' | sudo -S sh '{{ .Path }}'" {
+		t.Fatalf("bad execute_command: %s", b.config.LinuxGeneralize.ExecuteCommand)
+	}
+	if len(generatedData) != 1+len(hypervcommon.GuestFacts)+len(hypervcommon.LinuxGeneralizeData) {
+		t.Fatalf("bad generated data: %#v", generatedData)
+	}
+
+	config["windows_generalize"] = map[string]interface{}{
+		"mode": "oobe",
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_ExportHardware(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["cpus"] = 8
+	config["memory"] = 16384
+	config["export_hardware"] = map[string]interface{}{
+		"cpus":                2,
+		"memory":              4096,
+		"disconnect_network":  true,
+		"dynamic_mac_address": true,
+	}
+	_, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.Cpu != 8 || b.config.ExportHardware.Cpu != 2 {
+		t.Fatalf("bad cpus: %d, exported: %d", b.config.Cpu, b.config.ExportHardware.Cpu)
+	}
+
+	config["export_hardware"] = map[string]interface{}{
+		"memory_minimum": 512,
+	}
+	b = Builder{}
+	_, _, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 	"github.com/hashicorp/packer-plugin-sdk/shutdowncommand"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
@@ -72,9 +73,9 @@ type Config struct {
 	// the changes will be written to the new disk. This is especially useful if
 	// your source is a VHD/VHDX. This defaults to false.
 	DifferencingDisk bool `mapstructure:"differencing_disk" required:"false"`
-	// When cloning a vm to build from, we run a powershell
-	// Compare-VM command, which, depending on your version of Windows, may need
-	// the "Copy" flag to be set to true or false. Defaults to "false". Command:
+	// Deprecated, this has no effect. The Compare-VM command run when cloning
+	// a vm copies the disks of the clone when `vhd_path` is set, and uses
+	// them in place otherwise.
 	CompareCopy bool `mapstructure:"copy_in_compare" required:"false"`
 
 	ctx interpolate.Context
@@ -90,6 +91,9 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"boot_command",
+				"cloud_init",
+				"kvp_items",
+				"linux_generalize",
 			},
 		},
 	}, raws...)
@@ -108,6 +112,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 	}
 
 	errs = packersdk.MultiErrorAppend(errs, b.config.BootConfig.Prepare(&b.config.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, hypervcommon.CheckBootCommandScreenWaits(b.config.FlatBootCommand())...)
 	errs = packersdk.MultiErrorAppend(errs, b.config.HTTPConfig.Prepare(&b.config.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, b.config.OutputConfig.Prepare(&b.config.ctx, &b.config.PackerConfig)...)
 	errs = packersdk.MultiErrorAppend(errs, b.config.CommConfig.Prepare(&b.config.ctx)...)
@@ -157,9 +162,41 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		}
 	}
 
+	if b.config.ShutdownCommand == "" && !b.config.DisableShutdown &&
+		hypervcommon.HasShutdownMethod(b.config.ShutdownMode, hypervcommon.ShutdownMethodCommand) {
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("shutdown_mode: the command method requires shutdown_command or disable_shutdown"))
+	}
+
+	if b.config.WindowsGeneralize.IsSet() {
+		if b.config.DisableShutdown {
+			errs = packersdk.MultiErrorAppend(errs,
+				fmt.Errorf("windows_generalize and disable_shutdown can't be used together"))
+		}
+		if b.config.Comm.Type == "none" {
+			errs = packersdk.MultiErrorAppend(errs,
+				fmt.Errorf("windows_generalize needs a communicator to launch sysprep"))
+		}
+	}
+	if b.config.LinuxGeneralize.IsSet() && b.config.Comm.Type == "none" {
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("linux_generalize needs a communicator to run the generalization script"))
+	}
+	if b.config.InstallComplete.Strategy == hypervcommon.InstallCompleteTCP && b.config.CommConfig.UsesHvsock() {
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("install_complete strategy %q needs the IP address of the guest, which the hvsock "+
+				"transport doesn't wait for; use another strategy", hypervcommon.InstallCompleteTCP))
+	}
+
 	// Warnings
 
-	if b.config.ShutdownCommand == "" {
+	if b.config.CompareCopy {
+		warnings = hypervcommon.Appendwarns(warnings,
+			"copy_in_compare is deprecated and has no effect. Set vhd_path to copy the\n"+
+				"disks of the clone to a separate location.")
+	}
+
+	if b.config.ShutdownCommand == "" && !b.config.WindowsGeneralize.IsSet() {
 		warnings = hypervcommon.Appendwarns(warnings,
 			"A shutdown_command was not specified. Without a shutdown command, Packer\n"+
 				"will forcibly halt the virtual machine, which may result in data loss.")
@@ -169,7 +206,12 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		return nil, warnings, errs
 	}
 
-	return nil, warnings, nil
+	// VMName lets provisioners such as hyperv-copy find the VM of the build
+	generatedData := append([]string{"VMName"}, hypervcommon.GuestFacts...)
+	if b.config.LinuxGeneralize.IsSet() {
+		generatedData = append(generatedData, hypervcommon.LinuxGeneralizeData...)
+	}
+	return generatedData, warnings, nil
 }
 
 // Run executes a Packer build and returns a packersdk.Artifact representing
@@ -188,10 +230,29 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	state.Put("hook", hook)
 	state.Put("ui", ui)
 
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("VMName", b.config.VMName)
+
+	customConnect := map[string]multistep.Step{
+		"psrp": &psrp.StepConnect{
+			Config: &b.config.CommConfig.PSRP,
+			Host:   hypervcommon.PSRPHost(&b.config.CommConfig),
+		},
+	}
+	if b.config.Comm.Type == "ssh" && b.config.SSHTransport == hypervcommon.SSHTransportHvsock {
+		customConnect["ssh"] = &hypervcommon.StepConnectHvsockSSH{
+			Config:    &b.config.CommConfig.Comm,
+			SSHConfig: b.config.CommConfig.Comm.SSHConfigFunc(),
+		}
+	}
+
 	steps := []multistep.Step{
 		&hypervcommon.StepCreateBuildDir{
-			TempPath:       b.config.TempPath,
-			KeepRegistered: b.config.KeepRegistered,
+			TempPath:        b.config.TempPath,
+			VhdPath:         b.config.VhdPath,
+			CheckpointPath:  b.config.CheckpointPath,
+			SmartPagingPath: b.config.SmartPagingPath,
+			KeepRegistered:  b.config.KeepRegistered,
 		},
 		&commonsteps.StepOutputDir{
 			Force: b.config.PackerForce,
@@ -200,6 +261,7 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		&hypervcommon.StepValidateHost{
 			EnableVirtualizationExtensions: b.config.EnableVirtualizationExtensions,
 			RamSize:                        b.config.RamSize,
+			Cpu:                            b.config.Cpu,
 		},
 		&StepValidateClone{},
 		&commonsteps.StepDownload{
@@ -216,7 +278,10 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Content:     b.config.FloppyConfig.FloppyContent,
 			Label:       b.config.FloppyConfig.FloppyLabel,
 		},
-		commonsteps.HTTPServerFromHTTPConfig(&b.config.HTTPConfig),
+		&hypervcommon.StepHTTPServer{
+			Config:       &b.config.HTTPConfig,
+			CallbackPath: b.config.InstallComplete.CallbackPath(),
+		},
 		&hypervcommon.StepCreateSwitch{
 			SwitchName: b.config.SwitchName,
 		},
@@ -227,11 +292,15 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			CloneAllSnapshots:              b.config.CloneAllSnapshots,
 			VMName:                         b.config.VMName,
 			SwitchName:                     b.config.SwitchName,
-			CompareCopy:                    b.config.CompareCopy,
 			RamSize:                        b.config.RamSize,
 			Cpu:                            b.config.Cpu,
+			Processor:                      b.config.Processor,
 			EnableMacSpoofing:              b.config.EnableMacSpoofing,
 			EnableDynamicMemory:            b.config.EnableDynamicMemory,
+			MemoryMinimum:                  b.config.MemoryMinimum,
+			MemoryMaximum:                  b.config.MemoryMaximum,
+			MemoryBuffer:                   b.config.MemoryBuffer,
+			MemoryWeight:                   b.config.MemoryWeight,
 			EnableSecureBoot:               b.config.EnableSecureBoot,
 			SecureBootTemplate:             b.config.SecureBootTemplate,
 			EnableVirtualizationExtensions: b.config.EnableVirtualizationExtensions,
@@ -240,13 +309,24 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			KeepRegistered:                 b.config.KeepRegistered,
 			AdditionalDiskSize:             b.config.AdditionalDiskSize,
 			DiskBlockSize:                  b.config.DiskBlockSize,
+			MinimumIOPS:                    b.config.MinimumIOPS,
+			MaximumIOPS:                    b.config.MaximumIOPS,
+			QoSPolicyID:                    b.config.QoSPolicyID,
+			DiskCacheAttributes:            b.config.DiskCacheAttributes,
+			CheckpointType:                 b.config.CheckpointType,
 		},
 
 		&hypervcommon.StepResizeVhd{
 			DiskSize: b.config.DiskSize,
 		},
 
-		&hypervcommon.StepEnableIntegrationService{},
+		&hypervcommon.StepOfflineCustomization{
+			Config: b.config.OfflineCustomization,
+		},
+
+		&hypervcommon.StepConfigureIntegrationServices{
+			Services: b.config.IntegrationServices,
+		},
 
 		&hypervcommon.StepMountDvdDrive{
 			Generation:      b.config.Generation,
@@ -266,6 +346,20 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Content: b.config.CDConfig.CDContent,
 			Label:   b.config.CDConfig.CDLabel,
 		},
+		&hypervcommon.StepResolveHostIP{
+			SwitchName: b.config.SwitchName,
+			SkipHostIP: b.config.CommConfig.UsesHvsock(),
+		},
+		multistep.If(b.config.Comm.Type == "ssh" && b.config.CloudInit.UsesSSHPublicKey(),
+			&communicator.StepSSHKeyGen{
+				CommConf:            &b.config.CommConfig.Comm,
+				SSHTemporaryKeyPair: b.config.CommConfig.Comm.SSH.SSHTemporaryKeyPair,
+			}),
+		&hypervcommon.StepCreateCloudInitSeed{
+			Config: b.config.CloudInit,
+			Comm:   &b.config.CommConfig.Comm,
+			Ctx:    b.config.ctx,
+		},
 		&hypervcommon.StepMountSecondaryDvdImages{
 			IsoPaths:   b.config.SecondaryDvdImages,
 			Generation: b.config.Generation,
@@ -283,19 +377,47 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Generation:      b.config.Generation,
 			FirstBootDevice: b.config.FirstBootDevice,
 		},
+		&hypervcommon.StepStartSerialLog{
+			Config:    b.config.SerialLog,
+			OutputDir: b.config.OutputDir,
+		},
 
+		&hypervcommon.StepSetHostKvpItems{
+			Items: b.config.KVPItems,
+			Ctx:   b.config.ctx,
+		},
 		&hypervcommon.StepRun{
-			Headless:   b.config.Headless,
-			SwitchName: b.config.SwitchName,
-			SkipHostIP: b.config.Comm.Type == "psrp" && b.config.PSRPTransport == "hvsock",
+			Headless: b.config.Headless,
+		},
+
+		&hypervcommon.StepScreenshot{
+			OutputDir: b.config.OutputDir,
+			Interval:  b.config.ScreenshotInterval,
 		},
 
 		&hypervcommon.StepTypeBootCommand{
-			BootCommand:   b.config.FlatBootCommand(),
-			BootWait:      b.config.BootWait,
-			SwitchName:    b.config.SwitchName,
-			Ctx:           b.config.ctx,
-			GroupInterval: b.config.BootConfig.BootGroupInterval,
+			BootCommand:    b.config.FlatBootCommand(),
+			BootWait:       b.config.BootWait,
+			SwitchName:     b.config.SwitchName,
+			Ctx:            b.config.ctx,
+			GroupInterval:  b.config.BootConfig.BootGroupInterval,
+			KeyboardLayout: b.config.BootKeyboardLayout,
+			KeyInterval:    b.config.BootKeyInterval,
+		},
+
+		&hypervcommon.StepWaitForInstallToComplete{
+			Config: b.config.InstallComplete,
+			Host:   hypervcommon.CommHost(b.config.Comm.Host()),
+		},
+		// Taken before the guest files and the communicator change the guest
+		&hypervcommon.StepCheckpoint{
+			Phase:       hypervcommon.CheckpointPhaseOsInstalled,
+			Checkpoints: b.config.Checkpoints,
+		},
+
+		&hypervcommon.StepCopyGuestFiles{
+			Files:   b.config.GuestFiles,
+			Timeout: b.config.GuestFilesTimeout,
 		},
 
 		&hypervcommon.StepConfigurePSRP{
@@ -304,15 +426,16 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 
 		// configure the communicator ssh, winrm, or psrp
 		&communicator.StepConnect{
-			Config:    &b.config.CommConfig.Comm,
-			Host:      hypervcommon.CommHost(b.config.CommConfig.Comm.Host()),
-			SSHConfig: b.config.CommConfig.Comm.SSHConfigFunc(),
-			CustomConnect: map[string]multistep.Step{
-				"psrp": &psrp.StepConnect{
-					Config: &b.config.CommConfig.PSRP,
-					Host:   hypervcommon.PSRPHost(&b.config.CommConfig),
-				},
-			},
+			Config:        &b.config.CommConfig.Comm,
+			Host:          hypervcommon.SSHHost(&b.config.CommConfig),
+			SSHConfig:     b.config.CommConfig.Comm.SSHConfigFunc(),
+			CustomConnect: customConnect,
+		},
+		&hypervcommon.StepPublishGuestFacts{},
+
+		&hypervcommon.StepCheckpoint{
+			Phase:       hypervcommon.CheckpointPhaseBeforeProvisioning,
+			Checkpoints: b.config.Checkpoints,
 		},
 
 		// provision requires communicator to be setup
@@ -323,23 +446,51 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Comm: &b.config.CommConfig.Comm,
 		},
 
-		&hypervcommon.StepShutdown{
+		&hypervcommon.StepLinuxGeneralize{
+			Config: b.config.LinuxGeneralize,
+			Comm:   &b.config.CommConfig.Comm,
+			Ctx:    b.config.ctx,
+		},
+
+		multistep.If(!b.config.WindowsGeneralize.IsSet(), &hypervcommon.StepShutdown{
 			Command:         b.config.ShutdownCommand,
 			Timeout:         b.config.ShutdownTimeout,
 			DisableShutdown: b.config.DisableShutdown,
-		},
+			Mode:            b.config.ShutdownMode,
+		}),
+		multistep.If(b.config.WindowsGeneralize.IsSet(), &hypervcommon.StepWindowsGeneralize{
+			Config:  b.config.WindowsGeneralize,
+			Timeout: b.config.ShutdownTimeout,
+		}),
 
 		// wait for the vm to be powered off
 		&hypervcommon.StepWaitForPowerOff{},
+		&hypervcommon.StepStopSerialLog{},
 
 		// remove the secondary dvd images
 		// after we power down
 		&hypervcommon.StepUnmountSecondaryDvdImages{},
+		&hypervcommon.StepDeleteCloudInitSeed{},
+		&hypervcommon.StepRemoveHostKvpItems{},
 		&hypervcommon.StepUnmountGuestAdditions{},
 		&hypervcommon.StepUnmountDvdDrive{},
 		&hypervcommon.StepUnmountFloppyDrive{
 			Generation: b.config.Generation,
 		},
+		&hypervcommon.StepConfigureIntegrationServices{
+			Services: b.config.ExportIntegrationServices,
+		},
+		&hypervcommon.StepExportCleanup{
+			Enabled: b.config.ExportCleanup,
+		},
+		&hypervcommon.StepConfigureExportHardware{
+			Config: b.config.ExportHardware,
+		},
+		&hypervcommon.StepCheckpoint{
+			Phase:       hypervcommon.CheckpointPhaseAfterProvisioning,
+			Checkpoints: b.config.Checkpoints,
+		},
+		&hypervcommon.StepRemoveCheckpoints{},
 		&hypervcommon.StepCompactDisk{
 			SkipCompaction: b.config.SkipCompaction,
 		},
@@ -348,8 +499,9 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			SkipExport: b.config.SkipExport,
 		},
 		&hypervcommon.StepCollateArtifacts{
-			OutputDir:  b.config.OutputDir,
-			SkipExport: b.config.SkipExport,
+			OutputDir:    b.config.OutputDir,
+			SkipExport:   b.config.SkipExport,
+			ChecksumType: b.config.ManifestChecksumType,
 		},
 	}
 
@@ -373,8 +525,11 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		return nil, errors.New("build was halted.")
 	}
 
-	generatedData := map[string]interface{}{"generated_data": state.Get("generated_data")}
-	return hypervcommon.NewArtifact(b.config.OutputDir, generatedData)
+	artifactData := map[string]interface{}{
+		"generated_data": state.Get("generated_data"),
+		"manifest":       state.Get("manifest"),
+	}
+	return hypervcommon.NewArtifact(b.config.OutputDir, artifactData)
 }
 
 // Cancel.
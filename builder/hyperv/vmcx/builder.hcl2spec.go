@@ -4,6 +4,7 @@ package vmcx
 
 import (
 	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common"
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -34,6 +35,7 @@ type FlatConfig struct {
 	BootWait                  *string           `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
 	BootCommand               []string          `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
 	OutputDir                 *string           `mapstructure:"output_directory" required:"false" cty:"output_directory" hcl:"output_directory"`
+	ManifestChecksumType      *string           `mapstructure:"manifest_checksum_type" required:"false" cty:"manifest_checksum_type" hcl:"manifest_checksum_type"`
 	Type                      *string           `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
 	PauseBeforeConnect        *string           `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
 	SSHHost                   *string           `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
@@ -83,64 +85,96 @@ type FlatConfig struct {
 	WinRMUseSSL               *bool             `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
 	WinRMInsecure             *bool             `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
 	WinRMUseNTLM              *bool             `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	SSHTransport              *string           `mapstructure:"ssh_transport" required:"false" cty:"ssh_transport" hcl:"ssh_transport"`
 
-	PSRPHost                       *string           `mapstructure:"psrp_host" required:"false" cty:"psrp_host" hcl:"psrp_host"`
-	PSRPPort                       *int              `mapstructure:"psrp_port" required:"false" cty:"psrp_port" hcl:"psrp_port"`
-	PSRPUsername                   *string           `mapstructure:"psrp_username" required:"false" cty:"psrp_username" hcl:"psrp_username"`
-	PSRPPassword                   *string           `mapstructure:"psrp_password" required:"false" cty:"psrp_password" hcl:"psrp_password"`
-	PSRPTimeout                    *string           `mapstructure:"psrp_timeout" required:"false" cty:"psrp_timeout" hcl:"psrp_timeout"`
-	PSRPTransport                  *string           `mapstructure:"psrp_transport" required:"false" cty:"psrp_transport" hcl:"psrp_transport"`
-	PSRPVMID                       *string           `mapstructure:"psrp_vmid" required:"false" cty:"psrp_vmid" hcl:"psrp_vmid"`
-	PSRPConfigurationName          *string           `mapstructure:"psrp_configuration_name" required:"false" cty:"psrp_configuration_name" hcl:"psrp_configuration_name"`
-	PSRPUseTLS                     *bool             `mapstructure:"psrp_use_tls" required:"false" cty:"psrp_use_tls" hcl:"psrp_use_tls"`
-	PSRPInsecure                   *bool             `mapstructure:"psrp_insecure" required:"false" cty:"psrp_insecure" hcl:"psrp_insecure"`
-	PSRPAuthType                   *string           `mapstructure:"psrp_auth_type" required:"false" cty:"psrp_auth_type" hcl:"psrp_auth_type"`
-	PSRPDomain                     *string           `mapstructure:"psrp_domain" required:"false" cty:"psrp_domain" hcl:"psrp_domain"`
-	PSRPRealm                      *string           `mapstructure:"psrp_realm" required:"false" cty:"psrp_realm" hcl:"psrp_realm"`
-	FloppyFiles                    []string          `mapstructure:"floppy_files" cty:"floppy_files" hcl:"floppy_files"`
-	FloppyDirectories              []string          `mapstructure:"floppy_dirs" cty:"floppy_dirs" hcl:"floppy_dirs"`
-	FloppyContent                  map[string]string `mapstructure:"floppy_content" cty:"floppy_content" hcl:"floppy_content"`
-	FloppyLabel                    *string           `mapstructure:"floppy_label" cty:"floppy_label" hcl:"floppy_label"`
-	CDFiles                        []string          `mapstructure:"cd_files" cty:"cd_files" hcl:"cd_files"`
-	CDContent                      map[string]string `mapstructure:"cd_content" cty:"cd_content" hcl:"cd_content"`
-	CDLabel                        *string           `mapstructure:"cd_label" cty:"cd_label" hcl:"cd_label"`
-	DiskBlockSize                  *uint             `mapstructure:"disk_block_size" required:"false" cty:"disk_block_size" hcl:"disk_block_size"`
-	RamSize                        *uint             `mapstructure:"memory" required:"false" cty:"memory" hcl:"memory"`
-	SecondaryDvdImages             []string          `mapstructure:"secondary_iso_images" required:"false" cty:"secondary_iso_images" hcl:"secondary_iso_images"`
-	AdditionalDiskSize             []uint            `mapstructure:"disk_additional_size" required:"false" cty:"disk_additional_size" hcl:"disk_additional_size"`
-	GuestAdditionsMode             *string           `mapstructure:"guest_additions_mode" required:"false" cty:"guest_additions_mode" hcl:"guest_additions_mode"`
-	GuestAdditionsPath             *string           `mapstructure:"guest_additions_path" required:"false" cty:"guest_additions_path" hcl:"guest_additions_path"`
-	VMName                         *string           `mapstructure:"vm_name" required:"false" cty:"vm_name" hcl:"vm_name"`
-	SwitchName                     *string           `mapstructure:"switch_name" required:"false" cty:"switch_name" hcl:"switch_name"`
-	SwitchVlanId                   *string           `mapstructure:"switch_vlan_id" required:"false" cty:"switch_vlan_id" hcl:"switch_vlan_id"`
-	MacAddress                     *string           `mapstructure:"mac_address" required:"false" cty:"mac_address" hcl:"mac_address"`
-	VlanId                         *string           `mapstructure:"vlan_id" required:"false" cty:"vlan_id" hcl:"vlan_id"`
-	Cpu                            *uint             `mapstructure:"cpus" required:"false" cty:"cpus" hcl:"cpus"`
-	Generation                     *uint             `mapstructure:"generation" required:"false" cty:"generation" hcl:"generation"`
-	EnableMacSpoofing              *bool             `mapstructure:"enable_mac_spoofing" required:"false" cty:"enable_mac_spoofing" hcl:"enable_mac_spoofing"`
-	EnableDynamicMemory            *bool             `mapstructure:"enable_dynamic_memory" required:"false" cty:"enable_dynamic_memory" hcl:"enable_dynamic_memory"`
-	EnableSecureBoot               *bool             `mapstructure:"enable_secure_boot" required:"false" cty:"enable_secure_boot" hcl:"enable_secure_boot"`
-	SecureBootTemplate             *string           `mapstructure:"secure_boot_template" required:"false" cty:"secure_boot_template" hcl:"secure_boot_template"`
-	EnableVirtualizationExtensions *bool             `mapstructure:"enable_virtualization_extensions" required:"false" cty:"enable_virtualization_extensions" hcl:"enable_virtualization_extensions"`
-	EnableTPM                      *bool             `mapstructure:"enable_tpm" required:"false" cty:"enable_tpm" hcl:"enable_tpm"`
-	TempPath                       *string           `mapstructure:"temp_path" required:"false" cty:"temp_path" hcl:"temp_path"`
-	Version                        *string           `mapstructure:"configuration_version" required:"false" cty:"configuration_version" hcl:"configuration_version"`
-	KeepRegistered                 *bool             `mapstructure:"keep_registered" required:"false" cty:"keep_registered" hcl:"keep_registered"`
-	SkipCompaction                 *bool             `mapstructure:"skip_compaction" required:"false" cty:"skip_compaction" hcl:"skip_compaction"`
-	SkipExport                     *bool             `mapstructure:"skip_export" required:"false" cty:"skip_export" hcl:"skip_export"`
-	Headless                       *bool             `mapstructure:"headless" required:"false" cty:"headless" hcl:"headless"`
-	FirstBootDevice                *string           `mapstructure:"first_boot_device" required:"false" cty:"first_boot_device" hcl:"first_boot_device"`
-	BootOrder                      []string          `mapstructure:"boot_order" required:"false" cty:"boot_order" hcl:"boot_order"`
-	ShutdownCommand                *string           `mapstructure:"shutdown_command" required:"false" cty:"shutdown_command" hcl:"shutdown_command"`
-	ShutdownTimeout                *string           `mapstructure:"shutdown_timeout" required:"false" cty:"shutdown_timeout" hcl:"shutdown_timeout"`
-	DisableShutdown                *bool             `mapstructure:"disable_shutdown" required:"false" cty:"disable_shutdown" hcl:"disable_shutdown"`
-	DiskSize                       *uint             `mapstructure:"disk_size" required:"false" cty:"disk_size" hcl:"disk_size"`
-	CloneFromVMCXPath              *string           `mapstructure:"clone_from_vmcx_path" cty:"clone_from_vmcx_path" hcl:"clone_from_vmcx_path"`
-	CloneFromVMName                *string           `mapstructure:"clone_from_vm_name" cty:"clone_from_vm_name" hcl:"clone_from_vm_name"`
-	CloneFromSnapshotName          *string           `mapstructure:"clone_from_snapshot_name" required:"false" cty:"clone_from_snapshot_name" hcl:"clone_from_snapshot_name"`
-	CloneAllSnapshots              *bool             `mapstructure:"clone_all_snapshots" required:"false" cty:"clone_all_snapshots" hcl:"clone_all_snapshots"`
-	DifferencingDisk               *bool             `mapstructure:"differencing_disk" required:"false" cty:"differencing_disk" hcl:"differencing_disk"`
-	CompareCopy                    *bool             `mapstructure:"copy_in_compare" required:"false" cty:"copy_in_compare" hcl:"copy_in_compare"`
+	PSRPHost                       *string                                `mapstructure:"psrp_host" required:"false" cty:"psrp_host" hcl:"psrp_host"`
+	PSRPPort                       *int                                   `mapstructure:"psrp_port" required:"false" cty:"psrp_port" hcl:"psrp_port"`
+	PSRPUsername                   *string                                `mapstructure:"psrp_username" required:"false" cty:"psrp_username" hcl:"psrp_username"`
+	PSRPPassword                   *string                                `mapstructure:"psrp_password" required:"false" cty:"psrp_password" hcl:"psrp_password"`
+	PSRPTimeout                    *string                                `mapstructure:"psrp_timeout" required:"false" cty:"psrp_timeout" hcl:"psrp_timeout"`
+	PSRPTransport                  *string                                `mapstructure:"psrp_transport" required:"false" cty:"psrp_transport" hcl:"psrp_transport"`
+	PSRPVMID                       *string                                `mapstructure:"psrp_vmid" required:"false" cty:"psrp_vmid" hcl:"psrp_vmid"`
+	PSRPConfigurationName          *string                                `mapstructure:"psrp_configuration_name" required:"false" cty:"psrp_configuration_name" hcl:"psrp_configuration_name"`
+	PSRPUseTLS                     *bool                                  `mapstructure:"psrp_use_tls" required:"false" cty:"psrp_use_tls" hcl:"psrp_use_tls"`
+	PSRPInsecure                   *bool                                  `mapstructure:"psrp_insecure" required:"false" cty:"psrp_insecure" hcl:"psrp_insecure"`
+	PSRPAuthType                   *string                                `mapstructure:"psrp_auth_type" required:"false" cty:"psrp_auth_type" hcl:"psrp_auth_type"`
+	PSRPDomain                     *string                                `mapstructure:"psrp_domain" required:"false" cty:"psrp_domain" hcl:"psrp_domain"`
+	PSRPRealm                      *string                                `mapstructure:"psrp_realm" required:"false" cty:"psrp_realm" hcl:"psrp_realm"`
+	FloppyFiles                    []string                               `mapstructure:"floppy_files" cty:"floppy_files" hcl:"floppy_files"`
+	FloppyDirectories              []string                               `mapstructure:"floppy_dirs" cty:"floppy_dirs" hcl:"floppy_dirs"`
+	FloppyContent                  map[string]string                      `mapstructure:"floppy_content" cty:"floppy_content" hcl:"floppy_content"`
+	FloppyLabel                    *string                                `mapstructure:"floppy_label" cty:"floppy_label" hcl:"floppy_label"`
+	CDFiles                        []string                               `mapstructure:"cd_files" cty:"cd_files" hcl:"cd_files"`
+	CDContent                      map[string]string                      `mapstructure:"cd_content" cty:"cd_content" hcl:"cd_content"`
+	CDLabel                        *string                                `mapstructure:"cd_label" cty:"cd_label" hcl:"cd_label"`
+	DiskBlockSize                  *uint                                  `mapstructure:"disk_block_size" required:"false" cty:"disk_block_size" hcl:"disk_block_size"`
+	RamSize                        *uint                                  `mapstructure:"memory" required:"false" cty:"memory" hcl:"memory"`
+	SecondaryDvdImages             []string                               `mapstructure:"secondary_iso_images" required:"false" cty:"secondary_iso_images" hcl:"secondary_iso_images"`
+	AdditionalDiskSize             []uint                                 `mapstructure:"disk_additional_size" required:"false" cty:"disk_additional_size" hcl:"disk_additional_size"`
+	MinimumIOPS                    *uint64                                `mapstructure:"minimum_iops" required:"false" cty:"minimum_iops" hcl:"minimum_iops"`
+	MaximumIOPS                    *uint64                                `mapstructure:"maximum_iops" required:"false" cty:"maximum_iops" hcl:"maximum_iops"`
+	QoSPolicyID                    *string                                `mapstructure:"qos_policy_id" required:"false" cty:"qos_policy_id" hcl:"qos_policy_id"`
+	DiskCacheAttributes            *string                                `mapstructure:"disk_cache_attributes" required:"false" cty:"disk_cache_attributes" hcl:"disk_cache_attributes"`
+	GuestAdditionsMode             *string                                `mapstructure:"guest_additions_mode" required:"false" cty:"guest_additions_mode" hcl:"guest_additions_mode"`
+	GuestAdditionsPath             *string                                `mapstructure:"guest_additions_path" required:"false" cty:"guest_additions_path" hcl:"guest_additions_path"`
+	VMName                         *string                                `mapstructure:"vm_name" required:"false" cty:"vm_name" hcl:"vm_name"`
+	SwitchName                     *string                                `mapstructure:"switch_name" required:"false" cty:"switch_name" hcl:"switch_name"`
+	SwitchVlanId                   *string                                `mapstructure:"switch_vlan_id" required:"false" cty:"switch_vlan_id" hcl:"switch_vlan_id"`
+	MacAddress                     *string                                `mapstructure:"mac_address" required:"false" cty:"mac_address" hcl:"mac_address"`
+	VlanId                         *string                                `mapstructure:"vlan_id" required:"false" cty:"vlan_id" hcl:"vlan_id"`
+	Cpu                            *uint                                  `mapstructure:"cpus" required:"false" cty:"cpus" hcl:"cpus"`
+	Generation                     *uint                                  `mapstructure:"generation" required:"false" cty:"generation" hcl:"generation"`
+	EnableMacSpoofing              *bool                                  `mapstructure:"enable_mac_spoofing" required:"false" cty:"enable_mac_spoofing" hcl:"enable_mac_spoofing"`
+	EnableDynamicMemory            *bool                                  `mapstructure:"enable_dynamic_memory" required:"false" cty:"enable_dynamic_memory" hcl:"enable_dynamic_memory"`
+	MemoryMinimum                  *uint                                  `mapstructure:"memory_minimum" required:"false" cty:"memory_minimum" hcl:"memory_minimum"`
+	MemoryMaximum                  *uint                                  `mapstructure:"memory_maximum" required:"false" cty:"memory_maximum" hcl:"memory_maximum"`
+	MemoryBuffer                   *uint                                  `mapstructure:"memory_buffer" required:"false" cty:"memory_buffer" hcl:"memory_buffer"`
+	MemoryWeight                   *uint                                  `mapstructure:"memory_weight" required:"false" cty:"memory_weight" hcl:"memory_weight"`
+	EnableSecureBoot               *bool                                  `mapstructure:"enable_secure_boot" required:"false" cty:"enable_secure_boot" hcl:"enable_secure_boot"`
+	SecureBootTemplate             *string                                `mapstructure:"secure_boot_template" required:"false" cty:"secure_boot_template" hcl:"secure_boot_template"`
+	EnableVirtualizationExtensions *bool                                  `mapstructure:"enable_virtualization_extensions" required:"false" cty:"enable_virtualization_extensions" hcl:"enable_virtualization_extensions"`
+	EnableTPM                      *bool                                  `mapstructure:"enable_tpm" required:"false" cty:"enable_tpm" hcl:"enable_tpm"`
+	IntegrationServices            map[string]bool                        `mapstructure:"integration_services" required:"false" cty:"integration_services" hcl:"integration_services"`
+	ExportIntegrationServices      map[string]bool                        `mapstructure:"export_integration_services" required:"false" cty:"export_integration_services" hcl:"export_integration_services"`
+	TempPath                       *string                                `mapstructure:"temp_path" required:"false" cty:"temp_path" hcl:"temp_path"`
+	VhdPath                        *string                                `mapstructure:"vhd_path" required:"false" cty:"vhd_path" hcl:"vhd_path"`
+	CheckpointPath                 *string                                `mapstructure:"checkpoint_path" required:"false" cty:"checkpoint_path" hcl:"checkpoint_path"`
+	SmartPagingPath                *string                                `mapstructure:"smart_paging_path" required:"false" cty:"smart_paging_path" hcl:"smart_paging_path"`
+	Version                        *string                                `mapstructure:"configuration_version" required:"false" cty:"configuration_version" hcl:"configuration_version"`
+	KeepRegistered                 *bool                                  `mapstructure:"keep_registered" required:"false" cty:"keep_registered" hcl:"keep_registered"`
+	SkipCompaction                 *bool                                  `mapstructure:"skip_compaction" required:"false" cty:"skip_compaction" hcl:"skip_compaction"`
+	SkipExport                     *bool                                  `mapstructure:"skip_export" required:"false" cty:"skip_export" hcl:"skip_export"`
+	Headless                       *bool                                  `mapstructure:"headless" required:"false" cty:"headless" hcl:"headless"`
+	FirstBootDevice                *string                                `mapstructure:"first_boot_device" required:"false" cty:"first_boot_device" hcl:"first_boot_device"`
+	BootOrder                      []string                               `mapstructure:"boot_order" required:"false" cty:"boot_order" hcl:"boot_order"`
+	OfflineCustomization           *common.FlatOfflineCustomizationConfig `mapstructure:"offline_customization" required:"false" cty:"offline_customization" hcl:"offline_customization"`
+	GuestFiles                     []common.FlatGuestFile                 `mapstructure:"guest_files" required:"false" cty:"guest_files" hcl:"guest_files"`
+	GuestFilesTimeout              *string                                `mapstructure:"guest_files_timeout" required:"false" cty:"guest_files_timeout" hcl:"guest_files_timeout"`
+	SerialLog                      *common.FlatSerialLogConfig            `mapstructure:"serial_log" required:"false" cty:"serial_log" hcl:"serial_log"`
+	ScreenshotInterval             *string                                `mapstructure:"screenshot_interval" required:"false" cty:"screenshot_interval" hcl:"screenshot_interval"`
+	BootKeyboardLayout             *string                                `mapstructure:"boot_keyboard_layout" required:"false" cty:"boot_keyboard_layout" hcl:"boot_keyboard_layout"`
+	BootKeyInterval                *string                                `mapstructure:"boot_key_interval" required:"false" cty:"boot_key_interval" hcl:"boot_key_interval"`
+	Processor                      *common.FlatProcessorConfig            `mapstructure:"processor" required:"false" cty:"processor" hcl:"processor"`
+	CheckpointType                 *string                                `mapstructure:"checkpoint_type" required:"false" cty:"checkpoint_type" hcl:"checkpoint_type"`
+	Checkpoints                    []common.FlatCheckpoint                `mapstructure:"checkpoints" required:"false" cty:"checkpoints" hcl:"checkpoints"`
+	CloudInit                      *common.FlatCloudInitConfig            `mapstructure:"cloud_init" required:"false" cty:"cloud_init" hcl:"cloud_init"`
+	InstallComplete                *common.FlatInstallCompleteConfig      `mapstructure:"install_complete" required:"false" cty:"install_complete" hcl:"install_complete"`
+	KVPItems                       map[string]string                      `mapstructure:"kvp_items" required:"false" cty:"kvp_items" hcl:"kvp_items"`
+	ShutdownMode                   []common.FlatShutdownStage             `mapstructure:"shutdown_mode" required:"false" cty:"shutdown_mode" hcl:"shutdown_mode"`
+	WindowsGeneralize              *common.FlatWindowsGeneralizeConfig    `mapstructure:"windows_generalize" required:"false" cty:"windows_generalize" hcl:"windows_generalize"`
+	LinuxGeneralize                *common.FlatLinuxGeneralizeConfig      `mapstructure:"linux_generalize" required:"false" cty:"linux_generalize" hcl:"linux_generalize"`
+	ExportHardware                 *common.FlatExportHardwareConfig       `mapstructure:"export_hardware" required:"false" cty:"export_hardware" hcl:"export_hardware"`
+	ExportCleanup                  *bool                                  `mapstructure:"export_cleanup" required:"false" cty:"export_cleanup" hcl:"export_cleanup"`
+	ShutdownCommand                *string                                `mapstructure:"shutdown_command" required:"false" cty:"shutdown_command" hcl:"shutdown_command"`
+	ShutdownTimeout                *string                                `mapstructure:"shutdown_timeout" required:"false" cty:"shutdown_timeout" hcl:"shutdown_timeout"`
+	DisableShutdown                *bool                                  `mapstructure:"disable_shutdown" required:"false" cty:"disable_shutdown" hcl:"disable_shutdown"`
+	DiskSize                       *uint                                  `mapstructure:"disk_size" required:"false" cty:"disk_size" hcl:"disk_size"`
+	CloneFromVMCXPath              *string                                `mapstructure:"clone_from_vmcx_path" cty:"clone_from_vmcx_path" hcl:"clone_from_vmcx_path"`
+	CloneFromVMName                *string                                `mapstructure:"clone_from_vm_name" cty:"clone_from_vm_name" hcl:"clone_from_vm_name"`
+	CloneFromSnapshotName          *string                                `mapstructure:"clone_from_snapshot_name" required:"false" cty:"clone_from_snapshot_name" hcl:"clone_from_snapshot_name"`
+	CloneAllSnapshots              *bool                                  `mapstructure:"clone_all_snapshots" required:"false" cty:"clone_all_snapshots" hcl:"clone_all_snapshots"`
+	DifferencingDisk               *bool                                  `mapstructure:"differencing_disk" required:"false" cty:"differencing_disk" hcl:"differencing_disk"`
+	CompareCopy                    *bool                                  `mapstructure:"copy_in_compare" required:"false" cty:"copy_in_compare" hcl:"copy_in_compare"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -179,6 +213,7 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"boot_wait":                    &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
 		"boot_command":                 &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
 		"output_directory":             &hcldec.AttrSpec{Name: "output_directory", Type: cty.String, Required: false},
+		"manifest_checksum_type":       &hcldec.AttrSpec{Name: "manifest_checksum_type", Type: cty.String, Required: false},
 		"communicator":                 &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
 		"pause_before_connecting":      &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
 		"ssh_host":                     &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
@@ -228,6 +263,7 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"winrm_use_ssl":                &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
 		"winrm_insecure":               &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
 		"winrm_use_ntlm":               &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
+		"ssh_transport":                &hcldec.AttrSpec{Name: "ssh_transport", Type: cty.String, Required: false},
 
 		"psrp_host":                        &hcldec.AttrSpec{Name: "psrp_host", Type: cty.String, Required: false},
 		"psrp_port":                        &hcldec.AttrSpec{Name: "psrp_port", Type: cty.Number, Required: false},
@@ -253,6 +289,10 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"memory":                           &hcldec.AttrSpec{Name: "memory", Type: cty.Number, Required: false},
 		"secondary_iso_images":             &hcldec.AttrSpec{Name: "secondary_iso_images", Type: cty.List(cty.String), Required: false},
 		"disk_additional_size":             &hcldec.AttrSpec{Name: "disk_additional_size", Type: cty.List(cty.Number), Required: false},
+		"minimum_iops":                     &hcldec.AttrSpec{Name: "minimum_iops", Type: cty.Number, Required: false},
+		"maximum_iops":                     &hcldec.AttrSpec{Name: "maximum_iops", Type: cty.Number, Required: false},
+		"qos_policy_id":                    &hcldec.AttrSpec{Name: "qos_policy_id", Type: cty.String, Required: false},
+		"disk_cache_attributes":            &hcldec.AttrSpec{Name: "disk_cache_attributes", Type: cty.String, Required: false},
 		"guest_additions_mode":             &hcldec.AttrSpec{Name: "guest_additions_mode", Type: cty.String, Required: false},
 		"guest_additions_path":             &hcldec.AttrSpec{Name: "guest_additions_path", Type: cty.String, Required: false},
 		"vm_name":                          &hcldec.AttrSpec{Name: "vm_name", Type: cty.String, Required: false},
@@ -264,11 +304,20 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"generation":                       &hcldec.AttrSpec{Name: "generation", Type: cty.Number, Required: false},
 		"enable_mac_spoofing":              &hcldec.AttrSpec{Name: "enable_mac_spoofing", Type: cty.Bool, Required: false},
 		"enable_dynamic_memory":            &hcldec.AttrSpec{Name: "enable_dynamic_memory", Type: cty.Bool, Required: false},
+		"memory_minimum":                   &hcldec.AttrSpec{Name: "memory_minimum", Type: cty.Number, Required: false},
+		"memory_maximum":                   &hcldec.AttrSpec{Name: "memory_maximum", Type: cty.Number, Required: false},
+		"memory_buffer":                    &hcldec.AttrSpec{Name: "memory_buffer", Type: cty.Number, Required: false},
+		"memory_weight":                    &hcldec.AttrSpec{Name: "memory_weight", Type: cty.Number, Required: false},
 		"enable_secure_boot":               &hcldec.AttrSpec{Name: "enable_secure_boot", Type: cty.Bool, Required: false},
 		"secure_boot_template":             &hcldec.AttrSpec{Name: "secure_boot_template", Type: cty.String, Required: false},
 		"enable_virtualization_extensions": &hcldec.AttrSpec{Name: "enable_virtualization_extensions", Type: cty.Bool, Required: false},
 		"enable_tpm":                       &hcldec.AttrSpec{Name: "enable_tpm", Type: cty.Bool, Required: false},
+		"integration_services":             &hcldec.AttrSpec{Name: "integration_services", Type: cty.Map(cty.String), Required: false},
+		"export_integration_services":      &hcldec.AttrSpec{Name: "export_integration_services", Type: cty.Map(cty.String), Required: false},
 		"temp_path":                        &hcldec.AttrSpec{Name: "temp_path", Type: cty.String, Required: false},
+		"vhd_path":                         &hcldec.AttrSpec{Name: "vhd_path", Type: cty.String, Required: false},
+		"checkpoint_path":                  &hcldec.AttrSpec{Name: "checkpoint_path", Type: cty.String, Required: false},
+		"smart_paging_path":                &hcldec.AttrSpec{Name: "smart_paging_path", Type: cty.String, Required: false},
 		"configuration_version":            &hcldec.AttrSpec{Name: "configuration_version", Type: cty.String, Required: false},
 		"keep_registered":                  &hcldec.AttrSpec{Name: "keep_registered", Type: cty.Bool, Required: false},
 		"skip_compaction":                  &hcldec.AttrSpec{Name: "skip_compaction", Type: cty.Bool, Required: false},
@@ -276,6 +325,24 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"headless":                         &hcldec.AttrSpec{Name: "headless", Type: cty.Bool, Required: false},
 		"first_boot_device":                &hcldec.AttrSpec{Name: "first_boot_device", Type: cty.String, Required: false},
 		"boot_order":                       &hcldec.AttrSpec{Name: "boot_order", Type: cty.List(cty.String), Required: false},
+		"offline_customization":            &hcldec.BlockSpec{TypeName: "offline_customization", Nested: hcldec.ObjectSpec((*common.FlatOfflineCustomizationConfig)(nil).HCL2Spec())},
+		"guest_files":                      &hcldec.BlockListSpec{TypeName: "guest_files", Nested: hcldec.ObjectSpec((*common.FlatGuestFile)(nil).HCL2Spec())},
+		"guest_files_timeout":              &hcldec.AttrSpec{Name: "guest_files_timeout", Type: cty.String, Required: false},
+		"serial_log":                       &hcldec.BlockSpec{TypeName: "serial_log", Nested: hcldec.ObjectSpec((*common.FlatSerialLogConfig)(nil).HCL2Spec())},
+		"screenshot_interval":              &hcldec.AttrSpec{Name: "screenshot_interval", Type: cty.String, Required: false},
+		"boot_keyboard_layout":             &hcldec.AttrSpec{Name: "boot_keyboard_layout", Type: cty.String, Required: false},
+		"boot_key_interval":                &hcldec.AttrSpec{Name: "boot_key_interval", Type: cty.String, Required: false},
+		"processor":                        &hcldec.BlockSpec{TypeName: "processor", Nested: hcldec.ObjectSpec((*common.FlatProcessorConfig)(nil).HCL2Spec())},
+		"checkpoint_type":                  &hcldec.AttrSpec{Name: "checkpoint_type", Type: cty.String, Required: false},
+		"checkpoints":                      &hcldec.BlockListSpec{TypeName: "checkpoints", Nested: hcldec.ObjectSpec((*common.FlatCheckpoint)(nil).HCL2Spec())},
+		"cloud_init":                       &hcldec.BlockSpec{TypeName: "cloud_init", Nested: hcldec.ObjectSpec((*common.FlatCloudInitConfig)(nil).HCL2Spec())},
+		"install_complete":                 &hcldec.BlockSpec{TypeName: "install_complete", Nested: hcldec.ObjectSpec((*common.FlatInstallCompleteConfig)(nil).HCL2Spec())},
+		"kvp_items":                        &hcldec.AttrSpec{Name: "kvp_items", Type: cty.Map(cty.String), Required: false},
+		"shutdown_mode":                    &hcldec.BlockListSpec{TypeName: "shutdown_mode", Nested: hcldec.ObjectSpec((*common.FlatShutdownStage)(nil).HCL2Spec())},
+		"windows_generalize":               &hcldec.BlockSpec{TypeName: "windows_generalize", Nested: hcldec.ObjectSpec((*common.FlatWindowsGeneralizeConfig)(nil).HCL2Spec())},
+		"linux_generalize":                 &hcldec.BlockSpec{TypeName: "linux_generalize", Nested: hcldec.ObjectSpec((*common.FlatLinuxGeneralizeConfig)(nil).HCL2Spec())},
+		"export_hardware":                  &hcldec.BlockSpec{TypeName: "export_hardware", Nested: hcldec.ObjectSpec((*common.FlatExportHardwareConfig)(nil).HCL2Spec())},
+		"export_cleanup":                   &hcldec.AttrSpec{Name: "export_cleanup", Type: cty.Bool, Required: false},
 		"shutdown_command":                 &hcldec.AttrSpec{Name: "shutdown_command", Type: cty.String, Required: false},
 		"shutdown_timeout":                 &hcldec.AttrSpec{Name: "shutdown_timeout", Type: cty.String, Required: false},
 		"disable_shutdown":                 &hcldec.AttrSpec{Name: "disable_shutdown", Type: cty.Bool, Required: false},
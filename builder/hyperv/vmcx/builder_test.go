@@ -522,3 +522,65 @@ func TestUserVariablesInBootCommand(t *testing.T) {
 		t.Fatalf("should not have error: %#v", ret)
 	}
 }
+
+func TestBuilderPrepare_ExportCleanupGen2Clone(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	//Create vmcx folder
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+	config["clone_from_vmcx_path"] = td
+	config["export_cleanup"] = true
+
+	_, _, err = b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// The generation isn't set, so the config keeps the default while the
+	// clone is a generation 2 VM without a floppy drive
+	driver := &hypervcommon.DriverMock{GetVirtualMachineGeneration_Return: 2}
+	state := new(multistep.BasicStateBag)
+	state.Put("driver", driver)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("vmName", "packer-foo")
+
+	step := &hypervcommon.StepExportCleanup{
+		Enabled: b.config.ExportCleanup,
+	}
+	if ret := step.Run(context.Background(), state); ret != multistep.ActionContinue {
+		t.Fatalf("should not have error: %#v", state.Get("error"))
+	}
+	if driver.UnmountFloppyDrive_Called {
+		t.Fatal("should NOT eject the floppy of a generation 2 clone")
+	}
+	if !driver.DisconnectVirtualMachineNetworkAdapter_Called {
+		t.Fatal("should disconnect the network")
+	}
+}
+
+func TestBuilderPrepare_CopyInCompare(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	//Create vmcx folder
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+	config["clone_from_vmcx_path"] = td
+	config["copy_in_compare"] = true
+
+	_, warns, err := b.Prepare(config)
+	if len(warns) != 1 {
+		t.Fatalf("should have a deprecation warning: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
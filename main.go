@@ -11,6 +11,7 @@ import (
 
 	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/iso"
 	"github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/vmcx"
+	hypervcopy "github.com/hashicorp/packer-plugin-hyperv/provisioner/copy"
 	"github.com/hashicorp/packer-plugin-hyperv/version"
 )
 
@@ -18,6 +19,7 @@ func main() {
 	pps := plugin.NewSet()
 	pps.RegisterBuilder("iso", new(iso.Builder))
 	pps.RegisterBuilder("vmcx", new(vmcx.Builder))
+	pps.RegisterProvisioner("copy", new(hypervcopy.Provisioner))
 	pps.SetVersion(version.PluginVersion)
 	err := pps.Run()
 	if err != nil {
@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+// Package copy implements the hyperv-copy provisioner, which copies files
+// into the guest through the Guest Service Interface instead of the
+// communicator.
+package copy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	hypervcommon "github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// DefaultTimeout is how long the copy is retried while the Guest Service
+// Interface isn't available. The provisioner runs once the guest is up, so
+// it is much shorter than the builder's guest_files_timeout.
+const DefaultTimeout = 5 * time.Minute
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// The path of the file or directory on the host to copy.
+	Source string `mapstructure:"source" required:"true"`
+	// The absolute path in the guest. For a file this is the path of the
+	// copy, for a directory the directory its contents are copied into.
+	// Missing parent directories are created.
+	Destination string `mapstructure:"destination" required:"true"`
+	// The name of the Hyper-V VM to copy into. Defaults to the VM of the
+	// `hyperv-iso` or `hyperv-vmcx` build the provisioner runs in.
+	VMName string `mapstructure:"vm_name" required:"false"`
+	// How long to keep retrying while the Guest Service Interface isn't
+	// running in the guest. Defaults to 5m.
+	Timeout time.Duration `mapstructure:"timeout" required:"false"`
+
+	ctx interpolate.Context
+}
+
+type Provisioner struct {
+	config Config
+
+	// Nil means the Hyper-V PowerShell driver is used
+	driver hypervcommon.Driver
+}
+
+func (p *Provisioner) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *Provisioner) Prepare(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "hyperv-copy",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packersdk.MultiError
+	file := hypervcommon.GuestFile{
+		Source:      p.config.Source,
+		Destination: p.config.Destination,
+	}
+	for _, err := range file.Prepare() {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+
+	if p.config.Timeout == 0 {
+		p.config.Timeout = DefaultTimeout
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *Provisioner) Provision(ctx context.Context, ui packersdk.Ui, _ packersdk.Communicator,
+	generatedData map[string]interface{}) error {
+	vmName := p.config.VMName
+	if vmName == "" {
+		// Builders other than the Hyper-V ones don't set VMName, or set it
+		// to a placeholder when the data isn't available
+		name, ok := generatedData["VMName"].(string)
+		if !ok || name == "" || name == "Build_VMName" {
+			return errors.New("vm_name must be set when not running in a Hyper-V build")
+		}
+		vmName = name
+	}
+
+	driver := p.driver
+	if driver == nil {
+		var err error
+		driver, err = hypervcommon.NewHypervPS4Driver()
+		if err != nil {
+			return fmt.Errorf("failed creating Hyper-V driver: %w", err)
+		}
+	}
+
+	ui.Say(fmt.Sprintf("Copying %s to %s in %s...", p.config.Source, p.config.Destination, vmName))
+	file := hypervcommon.GuestFile{
+		Source:      p.config.Source,
+		Destination: p.config.Destination,
+	}
+	err := hypervcommon.CopyFileToGuest(ctx, driver, vmName, file, p.config.Timeout)
+	if err != nil {
+		return fmt.Errorf("Error copying %s to the guest: %s", p.config.Source, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package copy
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	hypervcommon "github.com/hashicorp/packer-plugin-hyperv/builder/hyperv/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func testConfig(t *testing.T) map[string]interface{} {
+	source, err := os.CreateTemp("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	source.Close()
+	t.Cleanup(func() { os.Remove(source.Name()) })
+
+	return map[string]interface{}{
+		"source":      source.Name(),
+		"destination": `C:\payload.zip`,
+	}
+}
+
+func testUi() *packersdk.BasicUi {
+	return &packersdk.BasicUi{
+		Reader:      new(bytes.Buffer),
+		Writer:      new(bytes.Buffer),
+		ErrorWriter: new(bytes.Buffer),
+	}
+}
+
+func TestProvisioner_Impl(t *testing.T) {
+	var raw interface{} = &Provisioner{}
+	if _, ok := raw.(packersdk.Provisioner); !ok {
+		t.Fatal("must be a Provisioner")
+	}
+}
+
+func TestProvisionerPrepare_Defaults(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(testConfig(t)); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if p.config.Timeout != DefaultTimeout {
+		t.Fatalf("bad timeout: %s", p.config.Timeout)
+	}
+}
+
+func TestProvisionerPrepare_InvalidSource(t *testing.T) {
+	var p Provisioner
+	config := testConfig(t)
+	config["source"] = "/i/dont/exist"
+	if err := p.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+
+	delete(config, "source")
+	p = Provisioner{}
+	if err := p.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestProvisionerPrepare_NoDestination(t *testing.T) {
+	var p Provisioner
+	config := testConfig(t)
+	delete(config, "destination")
+	if err := p.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestProvisionerProvision_BuildVM(t *testing.T) {
+	driver := new(hypervcommon.DriverMock)
+	p := Provisioner{driver: driver}
+	config := testConfig(t)
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	err := p.Provision(context.Background(), testUi(), nil, map[string]interface{}{"VMName": "packer-foo"})
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if driver.CopyFileToVirtualMachine_VmName != "packer-foo" {
+		t.Fatalf("should copy into the VM of the build. Got: %s", driver.CopyFileToVirtualMachine_VmName)
+	}
+	if driver.CopyFileToVirtualMachine_Destination != config["destination"] {
+		t.Fatalf("bad destination: %s", driver.CopyFileToVirtualMachine_Destination)
+	}
+}
+
+func TestProvisionerProvision_VMName(t *testing.T) {
+	driver := new(hypervcommon.DriverMock)
+	p := Provisioner{driver: driver}
+	config := testConfig(t)
+	config["vm_name"] = "other"
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	err := p.Provision(context.Background(), testUi(), nil, map[string]interface{}{"VMName": "packer-foo"})
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if driver.CopyFileToVirtualMachine_VmName != "other" {
+		t.Fatalf("should copy into vm_name. Got: %s", driver.CopyFileToVirtualMachine_VmName)
+	}
+}
+
+func TestProvisionerProvision_NoVMName(t *testing.T) {
+	driver := new(hypervcommon.DriverMock)
+	p := Provisioner{driver: driver}
+	if err := p.Prepare(testConfig(t)); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	err := p.Provision(context.Background(), testUi(), nil, map[string]interface{}{})
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	if driver.CopyFileToVirtualMachine_Called {
+		t.Fatal("should NOT have copied anything")
+	}
+}